@@ -4,7 +4,10 @@ import (
 	"time"
 
 	"github.com/Ryan-A-B/beddybytes/golang/cmd/analyze-usage-stats/internal/shared"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/connections"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventschema"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/sessions"
 )
 
 type Stats struct {
@@ -27,7 +30,7 @@ func New() *Stats {
 
 func (stats *Stats) Apply(event *eventlog.Event) {
 	switch event.Type {
-	case shared.EventTypeServerStarted:
+	case eventschema.EventTypeServerStarted:
 		disconnectTime := shared.EventTime(event)
 		for connectionID := range stats.SessionInfoByConnectionID {
 			if _, ok := stats.DisconnectTimeByConnectionID[connectionID]; ok {
@@ -35,9 +38,8 @@ func (stats *Stats) Apply(event *eventlog.Event) {
 			}
 			stats.DisconnectTimeByConnectionID[connectionID] = disconnectTime
 		}
-	case shared.EventTypeSessionStarted:
-		var data shared.StartSessionEventData
-		shared.MustUnmarshal(event.Data, &data)
+	case sessions.EventTypeStarted:
+		data := eventschema.DecodeOrFatal[sessions.EventStarted](event)
 		session := &shared.SessionInfo{
 			ID:               data.ID,
 			AccountID:        event.AccountID,
@@ -49,9 +51,8 @@ func (stats *Stats) Apply(event *eventlog.Event) {
 		}
 		stats.SessionInfoByID[data.ID] = session
 		stats.SessionInfoByConnectionID[data.HostConnectionID] = session
-	case shared.EventTypeSessionEnded:
-		var data shared.EndSessionEventData
-		shared.MustUnmarshal(event.Data, &data)
+	case sessions.EventTypeEnded:
+		data := eventschema.DecodeOrFatal[sessions.EventEnded](event)
 		session, ok := stats.SessionInfoByID[data.ID]
 		if !ok {
 			return
@@ -65,13 +66,11 @@ func (stats *Stats) Apply(event *eventlog.Event) {
 		delete(stats.SessionInfoByID, data.ID)
 		delete(stats.SessionInfoByConnectionID, session.HostConnectionID)
 		delete(stats.DisconnectTimeByConnectionID, session.HostConnectionID)
-	case shared.EventTypeClientConnected:
-		var data shared.ClientConnectedEventData
-		shared.MustUnmarshal(event.Data, &data)
+	case connections.EventTypeConnected:
+		data := eventschema.DecodeOrFatal[connections.EventConnected](event)
 		delete(stats.DisconnectTimeByConnectionID, data.ConnectionID)
-	case shared.EventTypeClientDisconnected:
-		var data shared.ClientDisconnectedEventData
-		shared.MustUnmarshal(event.Data, &data)
+	case connections.EventTypeDisconnected:
+		data := eventschema.DecodeOrFatal[connections.EventDisconnected](event)
 		session, ok := stats.SessionInfoByConnectionID[data.ConnectionID]
 		if !ok {
 			return
@@ -80,7 +79,7 @@ func (stats *Stats) Apply(event *eventlog.Event) {
 			return
 		}
 		disconnectTime := shared.EventTime(event)
-		if shared.DisconnectWasClean(data.WebSocketCloseCode) {
+		if data.Reason == connections.DisconnectReasonClean {
 			stats.DurationByAccountID[event.AccountID] += disconnectTime.Sub(session.StartTime)
 			delete(stats.SessionInfoByID, session.ID)
 			delete(stats.SessionInfoByConnectionID, data.ConnectionID)
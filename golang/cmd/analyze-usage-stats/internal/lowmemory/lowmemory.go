@@ -4,7 +4,10 @@ import (
 	"time"
 
 	"github.com/Ryan-A-B/beddybytes/golang/cmd/analyze-usage-stats/internal/shared"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/connections"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventschema"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/sessions"
 )
 
 type Stats struct {
@@ -40,7 +43,7 @@ func New(cacheSize int) *Stats {
 
 func (stats *Stats) Apply(event *eventlog.Event) {
 	switch event.Type {
-	case shared.EventTypeServerStarted:
+	case eventschema.EventTypeServerStarted:
 		disconnectTime := shared.EventTime(event)
 		sessionInfos := make([]*shared.SessionInfo, 0, len(stats.SessionInfoByConnectionID))
 		for _, session := range stats.SessionInfoByConnectionID {
@@ -49,9 +52,8 @@ func (stats *Stats) Apply(event *eventlog.Event) {
 		for _, session := range sessionInfos {
 			stats.trackDisconnectedSession(session, disconnectTime)
 		}
-	case shared.EventTypeSessionStarted:
-		var data shared.StartSessionEventData
-		shared.MustUnmarshal(event.Data, &data)
+	case sessions.EventTypeStarted:
+		data := eventschema.DecodeOrFatal[sessions.EventStarted](event)
 		session := &shared.SessionInfo{
 			ID:               data.ID,
 			AccountID:        event.AccountID,
@@ -69,9 +71,8 @@ func (stats *Stats) Apply(event *eventlog.Event) {
 		}
 		stats.SessionInfoByID[data.ID] = session
 		stats.SessionInfoByConnectionID[data.HostConnectionID] = session
-	case shared.EventTypeSessionEnded:
-		var data shared.EndSessionEventData
-		shared.MustUnmarshal(event.Data, &data)
+	case sessions.EventTypeEnded:
+		data := eventschema.DecodeOrFatal[sessions.EventEnded](event)
 		session, ok := stats.SessionInfoByID[data.ID]
 		if ok {
 			stats.DurationByAccountID[session.AccountID] += shared.EventTime(event).Sub(session.StartTime)
@@ -96,9 +97,8 @@ func (stats *Stats) Apply(event *eventlog.Event) {
 		stats.EndedWhileDisconnectedGap += shared.EventTime(event).Sub(evictedSession.DisconnectTime)
 		stats.DurationByAccountID[evictedSession.AccountID] += shared.EventTime(event).Sub(evictedSession.StartTime)
 		delete(stats.DisconnectedSessionByID, data.ID)
-	case shared.EventTypeClientConnected:
-		var data shared.ClientConnectedEventData
-		shared.MustUnmarshal(event.Data, &data)
+	case connections.EventTypeConnected:
+		data := eventschema.DecodeOrFatal[connections.EventConnected](event)
 		reconnectTime := shared.EventTime(event)
 		disconnectedSession, ok := stats.removeDisconnectedSessionByConnectionID(data.ConnectionID)
 		if ok {
@@ -113,15 +113,14 @@ func (stats *Stats) Apply(event *eventlog.Event) {
 			stats.MissedReconnectGap += reconnectTime.Sub(evictedSession.DisconnectTime)
 			stats.removeEvictedSessionByConnectionID(data.ConnectionID)
 		}
-	case shared.EventTypeClientDisconnected:
-		var data shared.ClientDisconnectedEventData
-		shared.MustUnmarshal(event.Data, &data)
+	case connections.EventTypeDisconnected:
+		data := eventschema.DecodeOrFatal[connections.EventDisconnected](event)
 		session, ok := stats.SessionInfoByConnectionID[data.ConnectionID]
 		if !ok {
 			return
 		}
 		disconnectTime := shared.EventTime(event)
-		if shared.DisconnectWasClean(data.WebSocketCloseCode) {
+		if data.Reason == connections.DisconnectReasonClean {
 			stats.DurationByAccountID[event.AccountID] += disconnectTime.Sub(session.StartTime)
 			stats.removeActiveSession(session)
 			return
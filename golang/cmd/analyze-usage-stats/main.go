@@ -104,9 +104,12 @@ func parseCacheSizes(cacheSizesFlag string) ([]int, error) {
 }
 
 func loadEvents(ctx context.Context, eventLogPath string) ([]*eventlog.Event, error) {
-	log := eventlog.NewFileEventLog(&eventlog.NewFileEventLogInput{
+	log, err := eventlog.NewReadOnlyFileEventLog(&eventlog.NewReadOnlyFileEventLogInput{
 		FolderPath: eventLogPath,
 	})
+	if err != nil {
+		return nil, err
+	}
 	iterator := log.GetEventIterator(ctx, eventlog.GetEventIteratorInput{})
 	events := make([]*eventlog.Event, 0, 1024)
 	for iterator.Next(ctx) {
@@ -126,7 +129,7 @@ func defaultEventLogPath() string {
 		filepath.Join("..", "..", "..", "eventlog"),
 	}
 	for _, candidate := range candidates {
-		if _, err := os.Stat(filepath.Join(candidate, eventlog.EventsFileName)); err == nil {
+		if _, err := os.Stat(filepath.Join(candidate, eventlog.MetadataFileName)); err == nil {
 			return candidate
 		}
 	}
@@ -7,8 +7,9 @@ import (
 
 	"github.com/Ryan-A-B/beddybytes/golang/cmd/analyze-usage-stats/internal/fullstate"
 	"github.com/Ryan-A-B/beddybytes/golang/cmd/analyze-usage-stats/internal/lowmemory"
-	"github.com/Ryan-A-B/beddybytes/golang/cmd/analyze-usage-stats/internal/shared"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/connections"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/sessions"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
@@ -20,30 +21,30 @@ func TestLowMemoryMatchesFullStateSessionLifetimeSemantics(t *testing.T) {
 		baseTime := time.Unix(1_700_000_000, 0).UTC()
 
 		events := []*eventlog.Event{
-			analyzerEvent(accountID, shared.EventTypeSessionStarted, baseTime, shared.StartSessionEventData{
+			analyzerEvent(accountID, sessions.EventTypeStarted, baseTime, sessions.EventStarted{
 				ID:               sessionID,
 				Name:             "test",
 				HostConnectionID: connectionID,
 				StartedAt:        baseTime,
 			}),
-			analyzerEvent(accountID, shared.EventTypeClientDisconnected, baseTime.Add(time.Hour), shared.ClientDisconnectedEventData{
+			analyzerEvent(accountID, connections.EventTypeDisconnected, baseTime.Add(time.Hour), connections.EventDisconnectedV1{
 				ClientID:           "client-1",
 				ConnectionID:       connectionID,
 				RequestID:          "request-1",
 				WebSocketCloseCode: 1006,
 			}),
-			analyzerEvent(accountID, shared.EventTypeClientConnected, baseTime.Add(3*time.Hour), shared.ClientConnectedEventData{
+			analyzerEvent(accountID, connections.EventTypeConnected, baseTime.Add(3*time.Hour), connections.EventConnected{
 				ClientID:     "client-1",
 				ConnectionID: connectionID,
 				RequestID:    "request-2",
 			}),
-			analyzerEvent(accountID, shared.EventTypeClientDisconnected, baseTime.Add(5*time.Hour), shared.ClientDisconnectedEventData{
+			analyzerEvent(accountID, connections.EventTypeDisconnected, baseTime.Add(5*time.Hour), connections.EventDisconnectedV1{
 				ClientID:           "client-1",
 				ConnectionID:       connectionID,
 				RequestID:          "request-3",
 				WebSocketCloseCode: 1006,
 			}),
-			analyzerEvent(accountID, shared.EventTypeSessionEnded, baseTime.Add(8*time.Hour), shared.EndSessionEventData{
+			analyzerEvent(accountID, sessions.EventTypeEnded, baseTime.Add(8*time.Hour), sessions.EventEnded{
 				ID: sessionID,
 			}),
 		}
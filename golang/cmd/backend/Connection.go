@@ -13,29 +13,15 @@ import (
 	"github.com/gorilla/websocket"
 	uuid "github.com/satori/go.uuid"
 
+	"github.com/Ryan-A-B/beddybytes/golang/internal/accounts"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/backendmqtt"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/contextx"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/httpx"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/logx"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/mqttx"
 )
 
-const EventTypeClientConnected = "client.connected"
-const EventTypeClientDisconnected = "client.disconnected"
-
-type ClientConnectedEventData struct {
-	ClientID     string `json:"client_id"`
-	ConnectionID string `json:"connection_id"`
-	RequestID    string `json:"request_id"`
-}
-
-type ClientDisconnectedEventData struct {
-	ClientID           string `json:"client_id"`
-	ConnectionID       string `json:"connection_id"`
-	RequestID          string `json:"request_id"`
-	WebSocketCloseCode int    `json:"web_socket_close_code"`
-}
-
 type MessageType string
 
 const (
@@ -99,18 +85,25 @@ type ConnectionStoreKey struct {
 }
 
 func (handlers *Handlers) HandleConnection(responseWriter http.ResponseWriter, request *http.Request) {
-	ctx := request.Context()
+	ctx, cancel := context.WithCancel(request.Context())
+	defer cancel()
 	accountID := contextx.GetAccountID(ctx)
 	vars := mux.Vars(request)
 	clientID := vars["client_id"]
 	connectionID := vars["connection_id"]
 	requestID := uuid.NewV4().String()
+	if handlers.LiveConnections.IsDeleted(accountID) {
+		httpx.Error(responseWriter, accounts.ErrAccountNotFound.Here())
+		return
+	}
 	conn, err := handlers.Upgrader.Upgrade(responseWriter, request, nil)
 	if err != nil {
 		logx.Errorln(err)
 		return
 	}
 	defer conn.Close()
+	// Canceling ends the connection, which unsubscribes the inbox.
+	defer handlers.LiveConnections.Add(accountID, cancel)()
 	inboxTopic := backendmqtt.ClientWebRTCInboxTopic(accountID, clientID)
 	token := handlers.MQTTClient.Subscribe(inboxTopic, 1, func(client mqtt.Client, message mqtt.Message) {
 		payload := new(backendmqtt.WebRTCInboxPayload)
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/accounts"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+)
+
+// LiveConnections closes the websockets and event streams of an account when
+// it is deleted. Closing a connection also unsubscribes its MQTT inbox.
+type LiveConnections struct {
+	mutex             sync.Mutex
+	nextID            int
+	closeByAccountID  map[string]map[int]func()
+	deletedAccountIDs map[string]struct{}
+}
+
+func NewLiveConnections() *LiveConnections {
+	return &LiveConnections{
+		closeByAccountID:  make(map[string]map[int]func()),
+		deletedAccountIDs: make(map[string]struct{}),
+	}
+}
+
+// Add registers closeConnection to be called when the account is deleted,
+// until the returned remove is called. It is called straight away if the
+// account has already been deleted.
+func (live *LiveConnections) Add(accountID string, closeConnection func()) (remove func()) {
+	live.mutex.Lock()
+	defer live.mutex.Unlock()
+	if _, ok := live.deletedAccountIDs[accountID]; ok {
+		closeConnection()
+		return func() {}
+	}
+	id := live.nextID
+	live.nextID++
+	closeByID := live.closeByAccountID[accountID]
+	if closeByID == nil {
+		closeByID = make(map[int]func())
+		live.closeByAccountID[accountID] = closeByID
+	}
+	closeByID[id] = closeConnection
+	return func() {
+		live.mutex.Lock()
+		defer live.mutex.Unlock()
+		delete(closeByID, id)
+		if len(closeByID) == 0 {
+			delete(live.closeByAccountID, accountID)
+		}
+	}
+}
+
+// IsDeleted reports whether the account has been deleted, so that a
+// connection can be refused before it announces itself.
+func (live *LiveConnections) IsDeleted(accountID string) bool {
+	live.mutex.Lock()
+	defer live.mutex.Unlock()
+	_, ok := live.deletedAccountIDs[accountID]
+	return ok
+}
+
+func (live *LiveConnections) CloseAccount(accountID string) {
+	live.mutex.Lock()
+	defer live.mutex.Unlock()
+	live.deletedAccountIDs[accountID] = struct{}{}
+	for _, closeConnection := range live.closeByAccountID[accountID] {
+		closeConnection()
+	}
+	delete(live.closeByAccountID, accountID)
+}
+
+// Run follows account.deleted from the start of the log, so that accounts
+// deleted before a restart are refused too, until ctx is done or reading the
+// log fails.
+func (live *LiveConnections) Run(ctx context.Context, eventLog eventlog.EventLog) (err error) {
+	events := eventlog.Follow(ctx, eventlog.FollowInput{
+		EventLog: eventLog,
+		Types:    []string{accounts.EventTypeAccountDeleted},
+	})
+	for events.Next(ctx) {
+		live.CloseAccount(events.Event().AccountID)
+	}
+	return events.Err()
+}
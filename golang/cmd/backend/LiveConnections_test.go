@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLiveConnections(t *testing.T) {
+	Convey("TestLiveConnections", t, func() {
+		live := NewLiveConnections()
+		accountID := uuid.NewV4().String()
+		otherAccountID := uuid.NewV4().String()
+		closed := make(map[string]int)
+		newClose := func(name string) func() {
+			return func() {
+				closed[name]++
+			}
+		}
+		live.Add(accountID, newClose("first"))
+		removeSecond := live.Add(accountID, newClose("second"))
+		live.Add(otherAccountID, newClose("other"))
+		removeSecond()
+		So(live.IsDeleted(accountID), ShouldBeFalse)
+		live.CloseAccount(accountID)
+		So(closed, ShouldResemble, map[string]int{"first": 1})
+		So(live.IsDeleted(accountID), ShouldBeTrue)
+		So(live.IsDeleted(otherAccountID), ShouldBeFalse)
+		Convey("added after the account is deleted", func() {
+			remove := live.Add(accountID, newClose("late"))
+			So(closed["late"], ShouldEqual, 1)
+			remove()
+		})
+	})
+}
@@ -2,12 +2,17 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"maps"
+	"slices"
 	"sync"
 	"time"
 
+	"github.com/Ryan-A-B/beddybytes/golang/internal/accounts"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/connections"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
-	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventschema"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/logx"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/sessions"
 )
 
 type UsageStats struct {
@@ -72,32 +77,46 @@ func (stats *UsageStats) catchUp(ctx context.Context) {
 	defer stats.mutex.Unlock()
 	iterator := stats.log.GetEventIterator(ctx, eventlog.GetEventIteratorInput{
 		FromCursor: stats.cursor,
+		Types:      statsEventTypes,
 	})
+	var err error
 	for iterator.Next(ctx) {
 		event := iterator.Event()
-		stats.applyEvent(ctx, event)
+		err = stats.applyEvent(ctx, event)
+		if err != nil {
+			break
+		}
 		stats.cursor = event.LogicalClock
 	}
+	if err == nil {
+		err = iterator.Err()
+	}
+	if err != nil {
+		logx.Warnln("usage stats are behind the log:", err)
+	}
 }
 
-func (stats *UsageStats) applyEvent(ctx context.Context, event *eventlog.Event) {
+func (stats *UsageStats) applyEvent(ctx context.Context, event *eventlog.Event) error {
 	apply, ok := statsApplyByType[event.Type]
 	if !ok {
-		return
+		return nil
 	}
-	apply(ctx, stats, event)
+	return apply(ctx, stats, event)
 }
 
-type statsApplyFunc func(ctx context.Context, stats *UsageStats, event *eventlog.Event)
+type statsApplyFunc func(ctx context.Context, stats *UsageStats, event *eventlog.Event) error
 
 var statsApplyByType = map[string]statsApplyFunc{
-	EventTypeServerStarted:      applyServerStartedEvent,
-	EventTypeSessionStarted:     applySessionStartedEvent,
-	EventTypeSessionEnded:       applySessionEndedEvent,
-	EventTypeClientConnected:    applyClientConnectedEvent,
-	EventTypeClientDisconnected: applyClientDisconnectedEvent,
+	eventschema.EventTypeServerStarted: applyServerStartedEvent,
+	sessions.EventTypeStarted:          applySessionStartedEvent,
+	sessions.EventTypeEnded:            applySessionEndedEvent,
+	connections.EventTypeConnected:     applyClientConnectedEvent,
+	connections.EventTypeDisconnected:  applyClientDisconnectedEvent,
+	accounts.EventTypeAccountDeleted:   applyAccountDeletedEvent,
 }
 
+var statsEventTypes = slices.Collect(maps.Keys(statsApplyByType))
+
 type SessionInfo struct {
 	ID               string
 	AccountID        string
@@ -117,10 +136,11 @@ type DisconnectedSessionDurationInfo struct {
 	DisconnectTime time.Time
 }
 
-func applySessionStartedEvent(ctx context.Context, stats *UsageStats, event *eventlog.Event) {
-	var sessionStartedData StartSessionEventData
-	err := json.Unmarshal(event.Data, &sessionStartedData)
-	fatal.OnError(err)
+func applySessionStartedEvent(ctx context.Context, stats *UsageStats, event *eventlog.Event) error {
+	sessionStartedData, err := eventschema.DecodeEvent[sessions.EventStarted](event)
+	if err != nil {
+		return err
+	}
 	sessionInfo := SessionInfo{
 		ID:               sessionStartedData.ID,
 		AccountID:        event.AccountID,
@@ -135,63 +155,70 @@ func applySessionStartedEvent(ctx context.Context, stats *UsageStats, event *eve
 	}
 	stats.sessionInfoByID[sessionStartedData.ID] = &sessionInfo
 	stats.sessionInfoByConnectionID[sessionStartedData.HostConnectionID] = &sessionInfo
+	return nil
 }
 
-func applySessionEndedEvent(ctx context.Context, stats *UsageStats, event *eventlog.Event) {
-	var sessionEndedData EndSessionEventData
-	err := json.Unmarshal(event.Data, &sessionEndedData)
-	fatal.OnError(err)
+func applySessionEndedEvent(ctx context.Context, stats *UsageStats, event *eventlog.Event) error {
+	sessionEndedData, err := eventschema.DecodeEvent[sessions.EventEnded](event)
+	if err != nil {
+		return err
+	}
 	sessionInfo, ok := stats.sessionInfoByID[sessionEndedData.ID]
 	if ok {
 		endTime := time.Unix(event.UnixTimestamp, 0)
 		duration := endTime.Sub(sessionInfo.StartTime)
 		stats.durationByAccountID[sessionInfo.AccountID] += duration
 		stats.removeActiveSession(sessionInfo)
-		return
+		return nil
 	}
 	disconnectedSessionDurationInfo, ok := stats.disconnectedSessionByID[sessionEndedData.ID]
 	if !ok {
-		return
+		return nil
 	}
 	endTime := time.Unix(event.UnixTimestamp, 0)
 	duration := endTime.Sub(disconnectedSessionDurationInfo.StartTime)
 	stats.durationByAccountID[disconnectedSessionDurationInfo.AccountID] += duration
 	delete(stats.disconnectedSessionByID, sessionEndedData.ID)
 	stats.removeDisconnectedSessionByID(event.AccountID, sessionEndedData.ID)
+	return nil
 }
 
-func applyClientConnectedEvent(ctx context.Context, stats *UsageStats, event *eventlog.Event) {
-	var clientConnectedData ClientConnectedEventData
-	err := json.Unmarshal(event.Data, &clientConnectedData)
-	fatal.OnError(err)
+func applyClientConnectedEvent(ctx context.Context, stats *UsageStats, event *eventlog.Event) error {
+	clientConnectedData, err := eventschema.DecodeEvent[connections.EventConnected](event)
+	if err != nil {
+		return err
+	}
 	disconnectedSession, ok := stats.removeDisconnectedSessionByConnectionID(clientConnectedData.ConnectionID)
 	if !ok {
-		return
+		return nil
 	}
 	delete(stats.disconnectedSessionByID, disconnectedSession.ID)
 	stats.sessionInfoByID[disconnectedSession.ID] = disconnectedSession.SessionInfo
 	stats.sessionInfoByConnectionID[disconnectedSession.HostConnectionID] = disconnectedSession.SessionInfo
+	return nil
 }
 
-func applyClientDisconnectedEvent(ctx context.Context, stats *UsageStats, event *eventlog.Event) {
-	var clientDisconnectedData ClientDisconnectedEventData
-	err := json.Unmarshal(event.Data, &clientDisconnectedData)
-	fatal.OnError(err)
+func applyClientDisconnectedEvent(ctx context.Context, stats *UsageStats, event *eventlog.Event) error {
+	clientDisconnectedData, err := eventschema.DecodeEvent[connections.EventDisconnected](event)
+	if err != nil {
+		return err
+	}
 	sessionInfo, ok := stats.sessionInfoByConnectionID[clientDisconnectedData.ConnectionID]
 	if !ok {
-		return
+		return nil
 	}
 	disconnectTime := time.Unix(event.UnixTimestamp, 0)
-	if disconnectWasClean(clientDisconnectedData.WebSocketCloseCode) {
+	if clientDisconnectedData.Reason == connections.DisconnectReasonClean {
 		duration := disconnectTime.Sub(sessionInfo.StartTime)
 		stats.durationByAccountID[event.AccountID] += duration
 		stats.removeActiveSession(sessionInfo)
-		return
+		return nil
 	}
 	stats.trackDisconnectedSession(sessionInfo, disconnectTime)
+	return nil
 }
 
-func applyServerStartedEvent(ctx context.Context, stats *UsageStats, event *eventlog.Event) {
+func applyServerStartedEvent(ctx context.Context, stats *UsageStats, event *eventlog.Event) error {
 	disconnectTime := time.Unix(event.UnixTimestamp, 0)
 	sessionInfos := make([]*SessionInfo, 0, len(stats.sessionInfoByConnectionID))
 	for _, sessionInfo := range stats.sessionInfoByConnectionID {
@@ -200,6 +227,26 @@ func applyServerStartedEvent(ctx context.Context, stats *UsageStats, event *even
 	for _, sessionInfo := range sessionInfos {
 		stats.trackDisconnectedSession(sessionInfo, disconnectTime)
 	}
+	return nil
+}
+
+func applyAccountDeletedEvent(ctx context.Context, stats *UsageStats, event *eventlog.Event) error {
+	for _, sessionInfo := range stats.sessionInfoByID {
+		if sessionInfo.AccountID == event.AccountID {
+			stats.removeActiveSession(sessionInfo)
+		}
+	}
+	for id, sessionInfo := range stats.disconnectedSessionByID {
+		if sessionInfo.AccountID == event.AccountID {
+			delete(stats.disconnectedSessionByID, id)
+		}
+	}
+	for _, disconnectedSession := range stats.disconnectedSessionsByAccountID[event.AccountID] {
+		delete(stats.disconnectedSessionByConnectionID, disconnectedSession.HostConnectionID)
+	}
+	delete(stats.disconnectedSessionsByAccountID, event.AccountID)
+	delete(stats.durationByAccountID, event.AccountID)
+	return nil
 }
 
 func (stats *UsageStats) trackDisconnectedSession(sessionInfo *SessionInfo, disconnectTime time.Time) {
@@ -270,12 +317,3 @@ func (stats *UsageStats) removeDisconnectedSessionByID(accountID string, session
 	}
 	return nil, false
 }
-
-func disconnectWasClean(closeCode int) bool {
-	switch closeCode {
-	case 1000, 1001:
-		return true
-	default:
-		return false
-	}
-}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+const UsageStatsSnapshotSchemaVersion = 1
+
+type usageStatsSnapshotState struct {
+	SessionInfoByID                 map[string]*SessionInfo                     `json:"session_info_by_id"`
+	SessionInfoByConnectionID       map[string]*SessionInfo                     `json:"session_info_by_connection_id"`
+	DisconnectedSessionByID         map[string]*DisconnectedSessionDurationInfo `json:"disconnected_session_by_id"`
+	DisconnectedSessionsByAccountID map[string][]*DisconnectedSessionInfo       `json:"disconnected_sessions_by_account_id"`
+	DurationByAccountID             map[string]time.Duration                    `json:"duration_by_account_id"`
+}
+
+func (stats *UsageStats) MarshalSnapshot(ctx context.Context) (cursor int64, data []byte, err error) {
+	stats.catchUp(ctx)
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+	data, err = json.Marshal(usageStatsSnapshotState{
+		SessionInfoByID:                 stats.sessionInfoByID,
+		SessionInfoByConnectionID:       stats.sessionInfoByConnectionID,
+		DisconnectedSessionByID:         stats.disconnectedSessionByID,
+		DisconnectedSessionsByAccountID: stats.disconnectedSessionsByAccountID,
+		DurationByAccountID:             stats.durationByAccountID,
+	})
+	cursor = stats.cursor
+	return
+}
+
+func (stats *UsageStats) UnmarshalSnapshot(ctx context.Context, cursor int64, data []byte) (err error) {
+	state := usageStatsSnapshotState{
+		SessionInfoByID:                 make(map[string]*SessionInfo),
+		SessionInfoByConnectionID:       make(map[string]*SessionInfo),
+		DisconnectedSessionByID:         make(map[string]*DisconnectedSessionDurationInfo),
+		DisconnectedSessionsByAccountID: make(map[string][]*DisconnectedSessionInfo),
+		DurationByAccountID:             make(map[string]time.Duration),
+	}
+	err = json.Unmarshal(data, &state)
+	if err != nil {
+		return
+	}
+	// disconnectedSessionByConnectionID indexes the same entries as
+	// disconnectedSessionsByAccountID so it is rebuilt rather than stored
+	disconnectedSessionByConnectionID := make(map[string]*DisconnectedSessionInfo)
+	for _, disconnectedSessions := range state.DisconnectedSessionsByAccountID {
+		for _, disconnectedSession := range disconnectedSessions {
+			disconnectedSessionByConnectionID[disconnectedSession.HostConnectionID] = disconnectedSession
+		}
+	}
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+	stats.cursor = cursor
+	stats.sessionInfoByID = state.SessionInfoByID
+	stats.sessionInfoByConnectionID = state.SessionInfoByConnectionID
+	stats.disconnectedSessionByID = state.DisconnectedSessionByID
+	stats.disconnectedSessionByConnectionID = disconnectedSessionByConnectionID
+	stats.disconnectedSessionsByAccountID = state.DisconnectedSessionsByAccountID
+	stats.durationByAccountID = state.DurationByAccountID
+	return
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/connections"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/sessions"
+)
+
+func TestUsageStatsSnapshot(t *testing.T) {
+	Convey("TestUsageStatsSnapshot", t, func() {
+		ctx := context.Background()
+		folderPath, err := os.MkdirTemp("testdata", "TestUsageStatsSnapshot-*")
+		So(err, ShouldBeNil)
+		log := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
+			FolderPath: folderPath,
+		})
+		accountID := uuid.NewV4().String()
+		appendEvent := func(eventType string, data interface{}) {
+			_, err := log.Append(ctx, eventlog.AppendInput{
+				Type:      eventType,
+				AccountID: accountID,
+				Data:      fatal.UnlessMarshalJSON(data),
+			})
+			So(err, ShouldBeNil)
+		}
+		activeConnectionID := uuid.NewV4().String()
+		disconnectedConnectionID := uuid.NewV4().String()
+		appendEvent(sessions.EventTypeStarted, sessions.EventStarted{
+			ID:               uuid.NewV4().String(),
+			Name:             "active",
+			HostConnectionID: activeConnectionID,
+			StartedAt:        time.Now().Add(-2 * time.Hour),
+		})
+		appendEvent(sessions.EventTypeStarted, sessions.EventStarted{
+			ID:               uuid.NewV4().String(),
+			Name:             "disconnected",
+			HostConnectionID: disconnectedConnectionID,
+			StartedAt:        time.Now().Add(-time.Hour),
+		})
+		appendEvent(connections.EventTypeDisconnected, connections.EventDisconnectedV1{
+			ConnectionID:       disconnectedConnectionID,
+			WebSocketCloseCode: 1006,
+		})
+		original := NewUsageStats(ctx, NewUsageStatsInput{
+			Log: log,
+		})
+		cursor, data, err := original.MarshalSnapshot(ctx)
+		So(err, ShouldBeNil)
+		So(cursor, ShouldEqual, 3)
+		restored := NewUsageStats(ctx, NewUsageStatsInput{
+			Log: log,
+		})
+		err = restored.UnmarshalSnapshot(ctx, cursor, data)
+		So(err, ShouldBeNil)
+		So(restored.GetCountOfActiveSessions(ctx), ShouldEqual, original.GetCountOfActiveSessions(ctx))
+		So(restored.GetTotalDuration(ctx), ShouldAlmostEqual, original.GetTotalDuration(ctx), time.Second)
+		Convey("disconnected session reconnects after restore", func() {
+			appendEvent(connections.EventTypeConnected, connections.EventConnected{
+				ConnectionID: disconnectedConnectionID,
+			})
+			So(restored.GetCountOfActiveSessions(ctx), ShouldEqual, 2)
+			So(restored.GetCountOfActiveSessions(ctx), ShouldEqual, original.GetCountOfActiveSessions(ctx))
+			So(restored.GetTotalDuration(ctx), ShouldAlmostEqual, original.GetTotalDuration(ctx), time.Second)
+		})
+	})
+}
@@ -9,7 +9,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Ryan-A-B/beddybytes/golang/internal/accounts"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/connections"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventschema"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/sessions"
 	uuid "github.com/satori/go.uuid"
 	. "github.com/smartystreets/goconvey/convey"
 )
@@ -22,7 +26,7 @@ func TestUsageStats(t *testing.T) {
 		ctx := context.Background()
 		folderPath, err := os.MkdirTemp("testdata", "TestUsageStats-*")
 		So(err, ShouldBeNil)
-		log := eventlog.NewFileEventLog(&eventlog.NewFileEventLogInput{
+		log := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
 			FolderPath: folderPath,
 		})
 		stats := NewUsageStats(ctx, NewUsageStatsInput{
@@ -35,7 +39,7 @@ func TestUsageStats(t *testing.T) {
 			hostClientID := uuid.NewV4().String()
 			hostConnectionID := uuid.NewV4().String()
 			expectedDuration := time.Hour
-			sessionStartedData := StartSessionEventData{
+			sessionStartedData := sessions.EventStarted{
 				ID:               uuid.NewV4().String(),
 				Name:             "test",
 				HostConnectionID: hostConnectionID,
@@ -44,7 +48,7 @@ func TestUsageStats(t *testing.T) {
 			data, err := json.Marshal(sessionStartedData)
 			So(err, ShouldBeNil)
 			_, err = log.Append(ctx, eventlog.AppendInput{
-				Type:      EventTypeSessionStarted,
+				Type:      sessions.EventTypeStarted,
 				AccountID: accountID,
 				Data:      data,
 			})
@@ -58,14 +62,24 @@ func TestUsageStats(t *testing.T) {
 				So(stats.GetTotalDuration(ctx), ShouldAlmostEqual, expectedDuration, time.Second)
 				So(stats.GetCountOfActiveSessions(ctx), ShouldEqual, 1)
 			})
+			Convey("delete account", func() {
+				_, err = log.Append(ctx, eventlog.AppendInput{
+					Type:      accounts.EventTypeAccountDeleted,
+					AccountID: accountID,
+					Data:      []byte("{}"),
+				})
+				So(err, ShouldBeNil)
+				So(stats.GetTotalDuration(ctx), ShouldEqual, 0)
+				So(stats.GetCountOfActiveSessions(ctx), ShouldEqual, 0)
+			})
 			Convey("end session", func() {
-				sessionEndedData := EndSessionEventData{
+				sessionEndedData := sessions.EventEnded{
 					ID: sessionStartedData.ID,
 				}
 				data, err := json.Marshal(sessionEndedData)
 				So(err, ShouldBeNil)
 				_, err = log.Append(ctx, eventlog.AppendInput{
-					Type:      EventTypeSessionEnded,
+					Type:      sessions.EventTypeEnded,
 					AccountID: accountID,
 					Data:      data,
 				})
@@ -79,7 +93,7 @@ func TestUsageStats(t *testing.T) {
 				})
 			})
 			Convey("host connects", func() {
-				clientConnectedData := ClientConnectedEventData{
+				clientConnectedData := connections.EventConnected{
 					ClientID:     hostClientID,
 					ConnectionID: hostConnectionID,
 					RequestID:    uuid.NewV4().String(),
@@ -87,7 +101,7 @@ func TestUsageStats(t *testing.T) {
 				data, err := json.Marshal(clientConnectedData)
 				So(err, ShouldBeNil)
 				_, err = log.Append(ctx, eventlog.AppendInput{
-					Type:      EventTypeClientConnected,
+					Type:      connections.EventTypeConnected,
 					AccountID: accountID,
 					Data:      data,
 				})
@@ -96,7 +110,7 @@ func TestUsageStats(t *testing.T) {
 				So(stats.GetCountOfActiveSessions(ctx), ShouldEqual, 1)
 				Convey("host connection ends", func() {
 					Convey("clean", func() {
-						clientDisconnectedData := ClientDisconnectedEventData{
+						clientDisconnectedData := connections.EventDisconnectedV1{
 							ClientID:           hostClientID,
 							ConnectionID:       hostConnectionID,
 							RequestID:          clientConnectedData.RequestID,
@@ -105,7 +119,7 @@ func TestUsageStats(t *testing.T) {
 						data, err := json.Marshal(clientDisconnectedData)
 						So(err, ShouldBeNil)
 						_, err = log.Append(ctx, eventlog.AppendInput{
-							Type:      EventTypeClientDisconnected,
+							Type:      connections.EventTypeDisconnected,
 							AccountID: accountID,
 							Data:      data,
 						})
@@ -119,7 +133,7 @@ func TestUsageStats(t *testing.T) {
 						})
 					})
 					Convey("unclean", func() {
-						clientDisconnectedData := ClientDisconnectedEventData{
+						clientDisconnectedData := connections.EventDisconnectedV1{
 							ClientID:           hostClientID,
 							ConnectionID:       hostConnectionID,
 							RequestID:          clientConnectedData.RequestID,
@@ -128,7 +142,7 @@ func TestUsageStats(t *testing.T) {
 						data, err := json.Marshal(clientDisconnectedData)
 						So(err, ShouldBeNil)
 						_, err = log.Append(ctx, eventlog.AppendInput{
-							Type:      EventTypeClientDisconnected,
+							Type:      connections.EventTypeDisconnected,
 							AccountID: accountID,
 							Data:      data,
 						})
@@ -141,7 +155,7 @@ func TestUsageStats(t *testing.T) {
 							So(stats.GetCountOfActiveSessions(ctx), ShouldEqual, 0)
 						})
 						Convey("host connects again", func() {
-							clientConnectedData := ClientConnectedEventData{
+							clientConnectedData := connections.EventConnected{
 								ClientID:     hostClientID,
 								ConnectionID: hostConnectionID,
 								RequestID:    uuid.NewV4().String(),
@@ -149,7 +163,7 @@ func TestUsageStats(t *testing.T) {
 							data, err := json.Marshal(clientConnectedData)
 							So(err, ShouldBeNil)
 							_, err = log.Append(ctx, eventlog.AppendInput{
-								Type:      EventTypeClientConnected,
+								Type:      connections.EventTypeConnected,
 								AccountID: accountID,
 								Data:      data,
 							})
@@ -168,7 +182,7 @@ func TestUsageStats(t *testing.T) {
 				})
 				Convey("server restarts", func() {
 					_, err = log.Append(ctx, eventlog.AppendInput{
-						Type:      EventTypeServerStarted,
+						Type:      eventschema.EventTypeServerStarted,
 						AccountID: accountID,
 						Data:      nil,
 					})
@@ -181,7 +195,7 @@ func TestUsageStats(t *testing.T) {
 						So(stats.GetCountOfActiveSessions(ctx), ShouldEqual, 0)
 					})
 					Convey("client connects", func() {
-						clientConnectedData := ClientConnectedEventData{
+						clientConnectedData := connections.EventConnected{
 							ClientID:     hostClientID,
 							ConnectionID: hostConnectionID,
 							RequestID:    uuid.NewV4().String(),
@@ -189,7 +203,7 @@ func TestUsageStats(t *testing.T) {
 						data, err := json.Marshal(clientConnectedData)
 						So(err, ShouldBeNil)
 						_, err = log.Append(ctx, eventlog.AppendInput{
-							Type:      EventTypeClientConnected,
+							Type:      connections.EventTypeConnected,
 							AccountID: accountID,
 							Data:      data,
 						})
@@ -215,7 +229,7 @@ func TestUsageStatsDisconnectedSessionCache(t *testing.T) {
 		ctx := context.Background()
 		folderPath, err := os.MkdirTemp("testdata", "TestUsageStatsDisconnectedSessionCache-*")
 		So(err, ShouldBeNil)
-		log := eventlog.NewFileEventLog(&eventlog.NewFileEventLogInput{
+		log := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
 			FolderPath: folderPath,
 		})
 		stats := NewUsageStats(ctx, NewUsageStatsInput{
@@ -224,7 +238,7 @@ func TestUsageStatsDisconnectedSessionCache(t *testing.T) {
 		accountID := uuid.NewV4().String()
 		for i := 0; i < maxDisconnectedSessionsPerAccount+2; i++ {
 			connectionID := uuid.NewV4().String()
-			sessionStartedData := StartSessionEventData{
+			sessionStartedData := sessions.EventStarted{
 				ID:               uuid.NewV4().String(),
 				Name:             "test",
 				HostConnectionID: connectionID,
@@ -233,12 +247,12 @@ func TestUsageStatsDisconnectedSessionCache(t *testing.T) {
 			data, err := json.Marshal(sessionStartedData)
 			So(err, ShouldBeNil)
 			_, err = log.Append(ctx, eventlog.AppendInput{
-				Type:      EventTypeSessionStarted,
+				Type:      sessions.EventTypeStarted,
 				AccountID: accountID,
 				Data:      data,
 			})
 			So(err, ShouldBeNil)
-			clientDisconnectedData := ClientDisconnectedEventData{
+			clientDisconnectedData := connections.EventDisconnectedV1{
 				ClientID:           uuid.NewV4().String(),
 				ConnectionID:       connectionID,
 				RequestID:          uuid.NewV4().String(),
@@ -247,7 +261,7 @@ func TestUsageStatsDisconnectedSessionCache(t *testing.T) {
 			data, err = json.Marshal(clientDisconnectedData)
 			So(err, ShouldBeNil)
 			_, err = log.Append(ctx, eventlog.AppendInput{
-				Type:      EventTypeClientDisconnected,
+				Type:      connections.EventTypeDisconnected,
 				AccountID: accountID,
 				Data:      data,
 			})
@@ -265,7 +279,7 @@ func TestUsageStatsEvictedDisconnectedSessionsStillCountTowardsDuration(t *testi
 		ctx := context.Background()
 		folderPath, err := os.MkdirTemp("testdata", "TestUsageStatsEvictedDisconnectedSessionsStillCountTowardsDuration-*")
 		So(err, ShouldBeNil)
-		log := eventlog.NewFileEventLog(&eventlog.NewFileEventLogInput{
+		log := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
 			FolderPath: folderPath,
 		})
 		stats := NewUsageStats(ctx, NewUsageStatsInput{
@@ -275,7 +289,7 @@ func TestUsageStatsEvictedDisconnectedSessionsStillCountTowardsDuration(t *testi
 		expectedDuration := time.Duration(0)
 		for i := 0; i < maxDisconnectedSessionsPerAccount+2; i++ {
 			connectionID := uuid.NewV4().String()
-			sessionStartedData := StartSessionEventData{
+			sessionStartedData := sessions.EventStarted{
 				ID:               uuid.NewV4().String(),
 				Name:             "test",
 				HostConnectionID: connectionID,
@@ -285,12 +299,12 @@ func TestUsageStatsEvictedDisconnectedSessionsStillCountTowardsDuration(t *testi
 			data, err := json.Marshal(sessionStartedData)
 			So(err, ShouldBeNil)
 			_, err = log.Append(ctx, eventlog.AppendInput{
-				Type:      EventTypeSessionStarted,
+				Type:      sessions.EventTypeStarted,
 				AccountID: accountID,
 				Data:      data,
 			})
 			So(err, ShouldBeNil)
-			clientDisconnectedData := ClientDisconnectedEventData{
+			clientDisconnectedData := connections.EventDisconnectedV1{
 				ClientID:           uuid.NewV4().String(),
 				ConnectionID:       connectionID,
 				RequestID:          uuid.NewV4().String(),
@@ -299,7 +313,7 @@ func TestUsageStatsEvictedDisconnectedSessionsStillCountTowardsDuration(t *testi
 			data, err = json.Marshal(clientDisconnectedData)
 			So(err, ShouldBeNil)
 			_, err = log.Append(ctx, eventlog.AppendInput{
-				Type:      EventTypeClientDisconnected,
+				Type:      connections.EventTypeDisconnected,
 				AccountID: accountID,
 				Data:      data,
 			})
@@ -321,30 +335,30 @@ func TestUsageStatsIncludesOfflineTimeAfterUncleanDisconnect(t *testing.T) {
 		connectionID := "connection-1"
 		baseTime := time.Unix(1_700_000_000, 0).UTC()
 
-		stats.applyEvent(ctx, usageStatsEvent(accountID, EventTypeSessionStarted, baseTime, StartSessionEventData{
+		stats.applyEvent(ctx, usageStatsEvent(accountID, sessions.EventTypeStarted, baseTime, sessions.EventStarted{
 			ID:               sessionID,
 			Name:             "test",
 			HostConnectionID: connectionID,
 			StartedAt:        baseTime,
 		}))
-		stats.applyEvent(ctx, usageStatsEvent(accountID, EventTypeClientDisconnected, baseTime.Add(time.Hour), ClientDisconnectedEventData{
+		stats.applyEvent(ctx, usageStatsEvent(accountID, connections.EventTypeDisconnected, baseTime.Add(time.Hour), connections.EventDisconnectedV1{
 			ClientID:           "client-1",
 			ConnectionID:       connectionID,
 			RequestID:          "request-1",
 			WebSocketCloseCode: 1006,
 		}))
-		stats.applyEvent(ctx, usageStatsEvent(accountID, EventTypeClientConnected, baseTime.Add(3*time.Hour), ClientConnectedEventData{
+		stats.applyEvent(ctx, usageStatsEvent(accountID, connections.EventTypeConnected, baseTime.Add(3*time.Hour), connections.EventConnected{
 			ClientID:     "client-1",
 			ConnectionID: connectionID,
 			RequestID:    "request-2",
 		}))
-		stats.applyEvent(ctx, usageStatsEvent(accountID, EventTypeClientDisconnected, baseTime.Add(5*time.Hour), ClientDisconnectedEventData{
+		stats.applyEvent(ctx, usageStatsEvent(accountID, connections.EventTypeDisconnected, baseTime.Add(5*time.Hour), connections.EventDisconnectedV1{
 			ClientID:           "client-1",
 			ConnectionID:       connectionID,
 			RequestID:          "request-3",
 			WebSocketCloseCode: 1006,
 		}))
-		stats.applyEvent(ctx, usageStatsEvent(accountID, EventTypeSessionEnded, baseTime.Add(8*time.Hour), EndSessionEventData{
+		stats.applyEvent(ctx, usageStatsEvent(accountID, sessions.EventTypeEnded, baseTime.Add(8*time.Hour), sessions.EventEnded{
 			ID: sessionID,
 		}))
 
@@ -361,7 +375,7 @@ func TestUsageStatsIncludesOfflineTimeAfterUncleanDisconnect(t *testing.T) {
 func BenchmarkUsageStatsRealData(b *testing.B) {
 	ctx := context.Background()
 	eventLogPath := findRealEventLogPath(b)
-	log := eventlog.NewFileEventLog(&eventlog.NewFileEventLogInput{
+	log := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
 		FolderPath: eventLogPath,
 	})
 	b.ReportAllocs()
@@ -410,8 +424,8 @@ func findRealEventLogPath(tb testing.TB) string {
 		goModPath := filepath.Join(dir, "go.mod")
 		if _, err := os.Stat(goModPath); err == nil {
 			eventLogPath := filepath.Join(dir, "eventlog")
-			eventsPath := filepath.Join(eventLogPath, eventlog.EventsFileName)
-			if _, err := os.Stat(eventsPath); err == nil {
+			metadataPath := filepath.Join(eventLogPath, eventlog.MetadataFileName)
+			if _, err := os.Stat(metadataPath); err == nil {
 				return eventLogPath
 			}
 			tb.Skipf("real eventlog not found at %s", eventLogPath)
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -10,13 +11,13 @@ import (
 
 	"github.com/Ryan-A-B/beddybytes/golang/internal/contextx"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventschema"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/httpx"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/logx"
 	"github.com/ansel1/merry"
 )
 
-const EventTypeServerStarted = "server.started"
-
 type Event struct {
 	ID            string          `json:"id"`
 	Type          string          `json:"type"`
@@ -32,57 +33,82 @@ func (handlers *Handlers) GetEvents(responseWriter http.ResponseWriter, request
 			httpx.Error(responseWriter, err)
 		}
 	}()
-	ctx := request.Context()
+	ctx, cancel := context.WithCancel(request.Context())
+	defer cancel()
+	request = request.WithContext(ctx)
 	accountID := contextx.GetAccountID(ctx)
+	defer handlers.LiveConnections.Add(accountID, cancel)()
 	fromCursor, err := Int64FormValue(request, "from_cursor", 0)
 	if err != nil {
 		return
 	}
+	streamEvents(responseWriter, request, streamEventsInput{
+		Follow: eventlog.FollowInput{
+			EventLog:      handlers.EventLog,
+			FromCursor:    fromCursor,
+			AccountID:     accountID,
+			UnscopedTypes: []string{eventschema.EventTypeServerStarted},
+		},
+		KeepAliveInterval: 30 * time.Second,
+		Hello: func() {
+			io.WriteString(responseWriter, ": hello\n\n")
+		},
+		KeepAlive: func() {
+			io.WriteString(responseWriter, ": keep-alive\n\n")
+		},
+		Write: func(event *eventlog.Event) {
+			WriteEvent(responseWriter, event)
+		},
+	})
+}
+
+type streamEventsInput struct {
+	Follow            eventlog.FollowInput
+	KeepAliveInterval time.Duration
+	Hello             func()
+	KeepAlive         func()
+	Write             func(event *eventlog.Event)
+}
+
+// streamEvents follows the log as server-sent events until the client goes
+// away. Hello, KeepAlive and Write are all called from the calling goroutine.
+func streamEvents(responseWriter http.ResponseWriter, request *http.Request, input streamEventsInput) {
+	ctx := request.Context()
 	flusher, ok := responseWriter.(http.Flusher)
 	fatal.Unless(ok, "responseWriter does not support flushing")
 	responseWriter.Header().Set("Content-Type", "text/event-stream")
 	responseWriter.Header().Set("Cache-Control", "no-cache")
-	io.WriteString(responseWriter, ": hello\n\n")
+	input.Hello()
 	flusher.Flush()
 	eventC := make(chan *eventlog.Event)
 	go func() {
 		defer close(eventC)
-		events := eventlog.Follow(ctx, eventlog.FollowInput{
-			EventLog:   handlers.EventLog,
-			FromCursor: fromCursor,
-		})
+		events := eventlog.Follow(ctx, input.Follow)
 		for events.Next(ctx) {
-			event := events.Event()
-			if ShouldSkipEvent(accountID, event) {
-				continue
-			}
-			eventC <- event
+			eventC <- events.Event()
+		}
+		err := events.Err()
+		if err != nil && !merry.Is(err, eventlog.ErrCanceled) {
+			logx.Errorln(err)
 		}
-		fatal.OnError(events.Err())
 	}()
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := time.NewTicker(input.KeepAliveInterval)
+	defer ticker.Stop()
 	for {
 		select {
 		case event, ok := <-eventC:
 			if !ok {
 				return
 			}
-			WriteEvent(responseWriter, event)
+			input.Write(event)
 			flusher.Flush()
 		case <-ticker.C:
-			io.WriteString(responseWriter, ": keep-alive\n\n")
+			input.KeepAlive()
 			flusher.Flush()
 		}
 	}
 }
 
-func ShouldSkipEvent(accountID string, event *eventlog.Event) bool {
-	if event.Type == EventTypeServerStarted {
-		return false
-	}
-	return event.AccountID != accountID
-}
-
 func WriteEvent(responseWriter http.ResponseWriter, event *eventlog.Event) {
 	payload := Event{
 		ID:            event.ID,
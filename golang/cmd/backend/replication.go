@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/httpx"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/replication"
+)
+
+const replicationKeepAliveInterval = 5 * time.Second
+
+// GetReplicationEvents streams every event of the log to a standby as it is
+// stored, including the fields GetEvents leaves out, so that the standby can
+// append identical events. The primary's head is sent on connecting and with
+// every keep-alive so that the standby can report its lag. It is the cursor
+// of the log, so sending it does not read any events.
+func (handlers *Handlers) GetReplicationEvents(responseWriter http.ResponseWriter, request *http.Request) {
+	var err error
+	defer func() {
+		if err != nil {
+			httpx.Error(responseWriter, err)
+		}
+	}()
+	fromCursor, err := Int64FormValue(request, "from_cursor", 0)
+	if err != nil {
+		return
+	}
+	writeHead := func() {
+		head := replication.Head{
+			LogicalClock: handlers.ReplicatedEventLog.Cursor(),
+		}
+		fmt.Fprintf(responseWriter, "event: %s\ndata: %s\n\n", replication.HeadEventName, fatal.UnlessMarshalJSON(&head))
+	}
+	streamEvents(responseWriter, request, streamEventsInput{
+		Follow: eventlog.FollowInput{
+			EventLog:   handlers.ReplicatedEventLog,
+			FromCursor: fromCursor,
+		},
+		KeepAliveInterval: replicationKeepAliveInterval,
+		Hello:             writeHead,
+		KeepAlive:         writeHead,
+		Write: func(event *eventlog.Event) {
+			fmt.Fprintf(responseWriter, "data: %s\n\n", fatal.UnlessMarshalJSON(event))
+		},
+	})
+}
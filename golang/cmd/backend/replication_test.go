@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/replication"
+)
+
+func TestReplication(t *testing.T) {
+	Convey("TestReplication", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		key := []byte("0123456789abcdef0123456789abcdef")
+		primaryFolderPath, err := os.MkdirTemp("testdata", "TestReplicationPrimary-*")
+		So(err, ShouldBeNil)
+		primary := eventlog.NewThreadSafeDecorator(&eventlog.NewThreadSafeDecoratorInput{
+			Decorated: eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
+				FolderPath: primaryFolderPath,
+			}),
+		})
+		handlers := Handlers{
+			EventLog:           primary,
+			ReplicatedEventLog: primary,
+			Key:                key,
+		}
+		router := mux.NewRouter()
+		handlers.AddRoutes(router)
+		server := httptest.NewServer(router)
+		defer server.Close()
+		defer cancel()
+		appendEvents := func(n int) {
+			inputs := make([]eventlog.AppendInput, n)
+			for i := range inputs {
+				inputs[i] = eventlog.AppendInput{
+					Type:      "test",
+					AccountID: "account",
+					Data:      fatal.UnlessMarshalJSON(i),
+				}
+			}
+			_, err := primary.AppendBatch(ctx, inputs)
+			So(err, ShouldBeNil)
+		}
+		appendEvents(3)
+		standbyFolderPath, err := os.MkdirTemp("testdata", "TestReplicationStandby-*")
+		So(err, ShouldBeNil)
+		replica := eventlog.NewIndexedEventLogOrFatal(&eventlog.NewIndexedEventLogInput{
+			FolderPath: standbyFolderPath,
+		})
+		standby := replication.NewStandby(replication.NewStandbyInput{
+			Replica:       replica,
+			PrimaryURL:    server.URL,
+			Key:           key,
+			RetryInterval: 10 * time.Millisecond,
+		})
+		go standby.Run(ctx)
+		waitForCursor := func(cursor int64) replication.Status {
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				status := standby.Status()
+				if status.Cursor >= cursor || time.Now().After(deadline) {
+					return status
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+		readAll := func(eventLog eventlog.EventLog) (events []*eventlog.Event) {
+			iterator := eventLog.GetEventIterator(ctx, eventlog.GetEventIteratorInput{})
+			for iterator.Next(ctx) {
+				events = append(events, iterator.Event())
+			}
+			So(iterator.Err(), ShouldBeNil)
+			return
+		}
+		status := waitForCursor(3)
+		So(status.Cursor, ShouldEqual, 3)
+		So(status.PrimaryCursor, ShouldEqual, 3)
+		So(status.LagEvents, ShouldEqual, 0)
+		So(status.Connected, ShouldBeTrue)
+		Convey("follows new events", func() {
+			appendEvents(2)
+			So(waitForCursor(5).Cursor, ShouldEqual, 5)
+			So(readAll(replica), ShouldResemble, readAll(primary))
+		})
+		Convey("unauthorized", func() {
+			response, err := http.Get(server.URL + replication.EventsPath)
+			So(err, ShouldBeNil)
+			response.Body.Close()
+			So(response.StatusCode, ShouldEqual, http.StatusUnauthorized)
+		})
+	})
+}
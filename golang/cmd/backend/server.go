@@ -14,6 +14,7 @@ import (
 
 	"github.com/ansel1/merry"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/dgrijalva/jwt-go"
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/gorilla/mux"
@@ -24,12 +25,15 @@ import (
 	"github.com/Ryan-A-B/beddybytes/golang/internal/babystationlist"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/backendmqtt"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/connectionstore"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/contextx"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventschema"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/httpx"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/logx"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/mailer"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/mqttx"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/replication"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/resetpassword"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/sessionlist"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/sessionstore"
@@ -73,10 +77,12 @@ type Handlers struct {
 	SessionList          *sessionlist.SessionList
 	BabyStationList      *babystationlist.BabyStationList
 	EventLog             eventlog.EventLog
+	ReplicatedEventLog   eventlog.EventLog
 	MQTTClient           mqtt.Client
 	ConnectionRegistry   *backendmqtt.ConnectionRegistry
 	PendingSessionStarts *backendmqtt.PendingSessionStarts
 	UsageStats           *UsageStats
+	LiveConnections      *LiveConnections
 
 	Key interface{}
 }
@@ -103,6 +109,9 @@ func (handlers *Handlers) HandleWebsocket(responseWriter http.ResponseWriter, re
 		return
 	}
 	defer conn.Close()
+	defer handlers.LiveConnections.Add(contextx.GetAccountID(ctx), func() {
+		conn.Close()
+	})()
 	client := handlers.ClientStore.Put(ctx, PutClientInput{
 		ID:    clientID,
 		Type:  clientType,
@@ -177,7 +186,8 @@ func (handlers *Handlers) GetBabyStationListSnapshot(responseWriter http.Respons
 	ctx := request.Context()
 	output, err := handlers.BabyStationList.GetSnapshot(ctx)
 	if err != nil {
-		log.Panicln(err)
+		logx.Warnln(err)
+		httpx.Error(responseWriter, err)
 		return
 	}
 	err = json.NewEncoder(responseWriter).Encode(output)
@@ -214,6 +224,10 @@ func (handlers *Handlers) AddRoutes(router *mux.Router) {
 	babyStationRouter := router.PathPrefix("/baby_station_list_snapshot").Subrouter()
 	babyStationRouter.Use(internal.NewAuthorizationMiddleware(handlers.Key).Middleware)
 	babyStationRouter.HandleFunc("", handlers.GetBabyStationListSnapshot).Methods(http.MethodGet).Name("GetBabyStationListSnapshot")
+
+	replicationRouter := router.PathPrefix("/replication").Subrouter()
+	replicationRouter.Use(internal.NewServiceAuthorizationMiddleware(handlers.Key, replication.Service).Middleware)
+	replicationRouter.HandleFunc("/events", handlers.GetReplicationEvents).Methods(http.MethodGet).Name("GetReplicationEvents")
 }
 
 func main() {
@@ -221,13 +235,20 @@ func main() {
 	ctx := context.Background()
 	key := []byte(internal.EnvStringOrFatal("ENCRYPTION_KEY"))
 	cookieDomain := internal.EnvStringOrFatal("COOKIE_DOMAIN")
-	eventLog := eventlog.NewThreadSafeDecorator(&eventlog.NewThreadSafeDecoratorInput{
+	replica := newReplica()
+	if primaryURL := internal.EnvStringOrDefault("REPLICATION_PRIMARY_URL", ""); primaryURL != "" {
+		runStandby(ctx, replica, primaryURL, key)
+		return
+	}
+	// Standbys replicate the log as it is stored, below encryption.
+	replicatedEventLog := eventlog.NewThreadSafeDecorator(&eventlog.NewThreadSafeDecoratorInput{
 		Decorated: eventlog.NewCachingDecoratorOrFatal(ctx, eventlog.NewCachingDecoratorInput{
-			Decorated: eventlog.NewFileEventLog(&eventlog.NewFileEventLogInput{
-				FolderPath: internal.EnvStringOrFatal("FILE_EVENT_LOG_FOLDER_PATH"),
-			}),
+			Decorated: newHashChainDecorator(ctx, replica),
+			MaxEvents: int(internal.EnvInt64OrDefault("EVENT_LOG_CACHE_MAX_EVENTS", 0)),
+			MaxBytes:  internal.EnvInt64OrDefault("EVENT_LOG_CACHE_MAX_BYTES", 0),
 		}),
 	})
+	eventLog := newEncryptingDecorator(replicatedEventLog)
 	mqttClient := newMQTTClient()
 	connectionRegistry := backendmqtt.NewConnectionRegistry()
 	pendingSessionStarts := backendmqtt.NewPendingSessionStarts()
@@ -235,12 +256,11 @@ func main() {
 		EventLog: eventLog,
 		Retain:   4 * time.Hour,
 	})
+	accountStore := newAccountStore(ctx)
 	accountHandlers := accounts.Handlers{
-		CookieDomain: cookieDomain,
-		EventLog:     eventLog,
-		AccountStore: &accounts.AccountStore{
-			Store: store.NewMemoryStore(),
-		},
+		CookieDomain:                 cookieDomain,
+		EventLog:                     eventLog,
+		AccountStore:                 accountStore,
 		SigningMethod:                jwt.SigningMethodHS256,
 		Key:                          key,
 		AccessTokenDuration:          1 * time.Hour,
@@ -251,14 +271,29 @@ func main() {
 			TTL: 15 * time.Minute,
 		}),
 		Mailer: newMailer(ctx),
+		PasswordHashParams: accounts.PasswordHashParams{
+			Iterations: int(internal.EnvInt64OrDefault("PASSWORD_HASH_ITERATIONS", int64(accounts.DefaultPasswordHashParams.Iterations))),
+			KeyLength:  accounts.DefaultPasswordHashParams.KeyLength,
+		},
 	}
+	snapshotStore := newSnapshotStore()
+	projectErrorPolicy := newProjectErrorPolicy()
+	// The accounts projection isn't snapshotted: it holds emails and password
+	// hashes, which are only kept encrypted under each account's data key so
+	// that erasing the account erases them. cmd/remove-accounts-snapshot
+	// removes a snapshot written by an older backend.
+	// A persistent account store is already up to date to its cursor, so it
+	// only replays newer events.
+	accountsFromCursor, err := accountStore.GetCursor(ctx)
+	fatal.OnError(err)
 	go func() {
-		eventlog.Project(ctx, eventlog.ProjectInput{
+		err := eventlog.Project(ctx, eventlog.ProjectInput{
 			EventLog:   accountHandlers.EventLog,
-			FromCursor: 0,
+			FromCursor: accountsFromCursor,
 			Apply:      accountHandlers.ApplyEvent,
+			OnError:    projectErrorPolicy,
 		})
-		log.Fatal("eventlog.Project exited")
+		log.Fatalln("eventlog.Project exited:", err)
 	}()
 	handlers := Handlers{
 		Upgrader: websocket.Upgrader{
@@ -288,21 +323,34 @@ func main() {
 			EventLog: eventLog,
 		}),
 		EventLog:             eventLog,
+		ReplicatedEventLog:   replicatedEventLog,
 		MQTTClient:           mqttClient,
 		ConnectionRegistry:   connectionRegistry,
 		PendingSessionStarts: pendingSessionStarts,
 		UsageStats: NewUsageStats(ctx, NewUsageStatsInput{
 			Log: eventLog,
 		}),
-		Key: key,
+		LiveConnections: NewLiveConnections(),
+		Key:             key,
 	}
+	runSnapshots(ctx, newSnapshots(snapshotStore, "sessionlist", sessionlist.SnapshotSchemaVersion), handlers.SessionList)
+	runSnapshots(ctx, newSnapshots(snapshotStore, "babystationlist", babystationlist.SnapshotSchemaVersion), handlers.BabyStationList)
+	runSnapshots(ctx, newSnapshots(snapshotStore, "usagestats", UsageStatsSnapshotSchemaVersion), handlers.UsageStats)
 	go func() {
-		eventlog.Project(ctx, eventlog.ProjectInput{
-			EventLog:   handlers.EventLog,
-			FromCursor: 0,
-			Apply:      handlers.SessionProjection.ApplyEvent,
+		err := eventlog.Project(ctx, eventlog.ProjectInput{
+			EventLog:         handlers.EventLog,
+			FromCursor:       0,
+			Apply:            handlers.SessionProjection.ApplyEvent,
+			Snapshots:        newSnapshots(snapshotStore, "sessions", SessionProjectionSnapshotSchemaVersion),
+			Snapshotter:      &handlers.SessionProjection,
+			SnapshotInterval: snapshotInterval,
+			OnError:          projectErrorPolicy,
 		})
-		log.Fatal("eventlog.Project exited")
+		log.Fatalln("eventlog.Project exited:", err)
+	}()
+	go func() {
+		err := handlers.LiveConnections.Run(ctx, eventLog)
+		log.Fatalln("LiveConnections.Run exited:", err)
 	}()
 	go func() {
 		backendmqtt.RunClientStatusSync(ctx, backendmqtt.RunClientStatusSyncInput{
@@ -331,6 +379,7 @@ func main() {
 		})
 		log.Fatal("backendmqtt.RunParentStationAnnouncementSync exited")
 	}()
+	runEventLogBackup(ctx, replicatedEventLog, replica)
 	router := mux.NewRouter()
 	router.Use(internal.LoggingMiddleware)
 	handlers.AddRoutes(router.NewRoute().Subrouter())
@@ -347,12 +396,237 @@ func main() {
 
 func appendServerStartedEvent(ctx context.Context, eventLog eventlog.EventLog) {
 	_, err := eventLog.Append(ctx, eventlog.AppendInput{
-		Type: EventTypeServerStarted,
+		Type: eventschema.EventTypeServerStarted,
 		Data: fatal.UnlessMarshalJSON(nil),
 	})
 	fatal.OnError(err)
 }
 
+const snapshotInterval = 5 * time.Minute
+
+const eventLogBackupInterval = time.Minute
+
+const accountStoreReencryptionRetryInterval = 10 * time.Minute
+
+// newReplica opens the log the events are stored in: an IndexedEventLog if
+// INDEXED_EVENT_LOG_FOLDER_PATH is set, and a FileEventLog otherwise.
+func newReplica() eventlog.Replica {
+	if folderPath := internal.EnvStringOrDefault("INDEXED_EVENT_LOG_FOLDER_PATH", ""); folderPath != "" {
+		return newIndexedEventLog(folderPath)
+	}
+	return newFileEventLog()
+}
+
+func newIndexedEventLog(folderPath string) *eventlog.IndexedEventLog {
+	logx.Infoln("storing events in the IndexedEventLog in", folderPath)
+	return eventlog.NewIndexedEventLogOrFatal(&eventlog.NewIndexedEventLogInput{
+		FolderPath: folderPath,
+	})
+}
+
+func newFileEventLog() *eventlog.FileEventLog {
+	folderPath := internal.EnvStringOrFatal("FILE_EVENT_LOG_FOLDER_PATH")
+	logx.Infoln("storing events in the FileEventLog in", folderPath)
+	return eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
+		FolderPath: folderPath,
+	})
+}
+
+// newHashChainDecorator hash chains the log when EVENT_LOG_HASH_CHAIN is
+// true. Once enabled it has to stay enabled, as an event appended without a
+// previous hash after the chain has started is reported as a broken link.
+func newHashChainDecorator(ctx context.Context, decorated eventlog.EventLog) eventlog.EventLog {
+	if internal.EnvStringOrDefault("EVENT_LOG_HASH_CHAIN", "") != "true" {
+		return decorated
+	}
+	return eventlog.NewHashChainDecoratorOrFatal(ctx, eventlog.NewHashChainDecoratorInput{
+		Decorated: decorated,
+	})
+}
+
+// runStandby replicates the log of the primary at primaryURL into replica and
+// serves only the replication status. The replicated events keep the
+// primary's hash chain and encryption, but data keys are not replicated, so
+// EVENT_LOG_DATA_KEY_FOLDER_PATH has to be shared with the primary. A standby
+// is promoted by restarting it without REPLICATION_PRIMARY_URL once the
+// primary has stopped.
+func runStandby(ctx context.Context, replica eventlog.Replica, primaryURL string, key []byte) {
+	standby := replication.NewStandby(replication.NewStandbyInput{
+		Replica:    replica,
+		PrimaryURL: primaryURL,
+		Key:        key,
+		ID:         internal.EnvStringOrDefault("REPLICATION_STANDBY_ID", "standby"),
+	})
+	go standby.Run(ctx)
+	router := mux.NewRouter()
+	router.Use(internal.LoggingMiddleware)
+	router.HandleFunc("/replication/status", standby.GetStatus).Methods(http.MethodGet).Name("GetReplicationStatus")
+	addr := internal.EnvStringOrFatal("SERVER_ADDR")
+	server := http.Server{
+		Addr:    addr,
+		Handler: router,
+	}
+	fmt.Printf("Replicating %s, listening on %s\n", primaryURL, addr)
+	log.Fatal(server.ListenAndServe())
+}
+
+// isAESKeyLength reports whether a key of length bytes selects AES-128,
+// AES-192 or AES-256.
+func isAESKeyLength(length int) bool {
+	return length == 16 || length == 24 || length == 32
+}
+
+// newEncryptingDecorator encrypts account events when EVENT_LOG_MASTER_KEY is
+// set. It sits above the cache so that erasing an account's data key takes
+// effect without a restart.
+func newEncryptingDecorator(decorated eventlog.EventLog) eventlog.EventLog {
+	masterKey := internal.EnvStringOrDefault("EVENT_LOG_MASTER_KEY", "")
+	if masterKey == "" {
+		return decorated
+	}
+	fatal.Unless(isAESKeyLength(len(masterKey)), "EVENT_LOG_MASTER_KEY must be 16, 24 or 32 bytes")
+	folderPath := internal.EnvStringOrFatal("EVENT_LOG_DATA_KEY_FOLDER_PATH")
+	err := os.MkdirAll(folderPath, 0700)
+	fatal.OnError(err)
+	return eventlog.NewEncryptingDecorator(eventlog.NewEncryptingDecoratorInput{
+		Decorated: decorated,
+		DataKeys: eventlog.NewDataKeys(eventlog.NewDataKeysInput{
+			Store: store.NewFileSystemStore(&store.NewFileSystemStoreInput{
+				Root: folderPath,
+			}),
+			MasterKey: []byte(masterKey),
+		}),
+		Types: []string{
+			accounts.EventTypeAccountCreated,
+			accounts.EventTypeAccountPasswordReset,
+			accounts.EventTypeAccountPasswordHashUpgraded,
+		},
+	})
+}
+
+// newAccountStore keeps accounts in memory, rebuilt from the event log on
+// start, unless ACCOUNT_STORE_FOLDER_PATH is set, in which case they are kept
+// on disk encrypted with ACCOUNT_STORE_ENCRYPTION_KEY, under file names
+// hashed with ACCOUNT_STORE_KEY_HASHING_KEY so that emails can't be read from
+// them. To rotate the encryption key, the old one is moved to
+// ACCOUNT_STORE_PREVIOUS_ENCRYPTION_KEY, with its ID, and the store is
+// re-encrypted in the background.
+func newAccountStore(ctx context.Context) *accounts.AccountStore {
+	folderPath := internal.EnvStringOrDefault("ACCOUNT_STORE_FOLDER_PATH", "")
+	if folderPath == "" {
+		return accounts.NewAccountStore(store.NewMemoryStore())
+	}
+	err := os.MkdirAll(folderPath, 0700)
+	fatal.OnError(err)
+	currentKeyID := internal.EnvStringOrDefault("ACCOUNT_STORE_ENCRYPTION_KEY_ID", store.DefaultKeyID)
+	keys := map[string][]byte{
+		currentKeyID: []byte(internal.EnvStringOrFatal("ACCOUNT_STORE_ENCRYPTION_KEY")),
+	}
+	previousKey := internal.EnvStringOrDefault("ACCOUNT_STORE_PREVIOUS_ENCRYPTION_KEY", "")
+	if previousKey != "" {
+		previousKeyID := internal.EnvStringOrDefault("ACCOUNT_STORE_PREVIOUS_ENCRYPTION_KEY_ID", store.DefaultKeyID)
+		fatal.Unless(previousKeyID != currentKeyID, "ACCOUNT_STORE_PREVIOUS_ENCRYPTION_KEY_ID must differ from ACCOUNT_STORE_ENCRYPTION_KEY_ID")
+		keys[previousKeyID] = []byte(previousKey)
+	}
+	encryptedStore := store.NewEncryptingDecorator(&store.NewEncryptingDecoratorInput{
+		Store: store.NewFileSystemStore(&store.NewFileSystemStoreInput{
+			Root: folderPath,
+		}),
+		Keys:         keys,
+		CurrentKeyID: currentKeyID,
+	})
+	if previousKey != "" {
+		go encryptedStore.RunReencryption(ctx, "", accountStoreReencryptionRetryInterval)
+	}
+	return accounts.NewAccountStore(store.NewKeyHashingDecorator(&store.NewKeyHashingDecoratorInput{
+		Store:   encryptedStore,
+		HashKey: []byte(internal.EnvStringOrFatal("ACCOUNT_STORE_KEY_HASHING_KEY")),
+	}))
+}
+
+// runEventLogBackup ships the event log to EVENT_LOG_BACKUP_S3_BUCKET when it
+// is set, with the start of the hash chain recorded by replica.
+// cmd/restore-eventlog rebuilds a log folder from the backup.
+func runEventLogBackup(ctx context.Context, eventLog eventlog.EventLog, replica eventlog.Replica) {
+	bucket := internal.EnvStringOrDefault("EVENT_LOG_BACKUP_S3_BUCKET", "")
+	if bucket == "" {
+		return
+	}
+	cfg, err := config.LoadDefaultConfig(ctx)
+	fatal.OnError(err)
+	hashChain, _ := replica.(eventlog.HashChainStarter)
+	backup, err := eventlog.NewBackup(ctx, eventlog.NewBackupInput{
+		EventLog:  eventLog,
+		HashChain: hashChain,
+		Store: store.NewS3Store(&store.NewS3StoreInput{
+			Client: s3.NewFromConfig(cfg),
+			Bucket: bucket,
+			Prefix: internal.EnvStringOrDefault("EVENT_LOG_BACKUP_S3_PREFIX", "eventlog/"),
+		}),
+	})
+	fatal.OnError(err)
+	go backup.Run(ctx, eventLogBackupInterval)
+}
+
+// newProjectErrorPolicy reads what projections do with an event they cannot
+// read from EVENT_LOG_PROJECT_ON_ERROR, which is halt, skip or retry.
+func newProjectErrorPolicy() eventlog.ErrorPolicy {
+	policy := internal.EnvStringOrDefault("EVENT_LOG_PROJECT_ON_ERROR", "halt")
+	switch policy {
+	case "halt":
+		return eventlog.ErrorPolicyHalt
+	case "skip":
+		return eventlog.ErrorPolicySkip
+	case "retry":
+		return eventlog.ErrorPolicyRetry
+	default:
+		panic("invalid EVENT_LOG_PROJECT_ON_ERROR")
+	}
+}
+
+func newSnapshotStore() store.Store {
+	folderPath := internal.EnvStringOrDefault("SNAPSHOT_FOLDER_PATH", "")
+	if folderPath == "" {
+		return nil
+	}
+	err := os.MkdirAll(folderPath, 0700)
+	fatal.OnError(err)
+	return store.NewFileSystemStore(&store.NewFileSystemStoreInput{
+		Root: folderPath,
+	})
+}
+
+func newSnapshots(snapshotStore store.Store, key string, schemaVersion int) *eventlog.SnapshotStore {
+	if snapshotStore == nil {
+		return nil
+	}
+	return eventlog.NewSnapshotStore(eventlog.NewSnapshotStoreInput{
+		Store:         snapshotStore,
+		Key:           key,
+		SchemaVersion: schemaVersion,
+	})
+}
+
+// runSnapshots restores a projection that catches up on read from its latest
+// snapshot and then keeps saving new snapshots in the background.
+func runSnapshots(ctx context.Context, snapshots *eventlog.SnapshotStore, snapshotter eventlog.Snapshotter) {
+	if snapshots == nil {
+		return
+	}
+	_, err := eventlog.RestoreSnapshot(ctx, eventlog.RestoreSnapshotInput{
+		Snapshots:   snapshots,
+		Snapshotter: snapshotter,
+	})
+	if err != nil {
+		logx.Warnln("failed to restore snapshot, replaying from start:", err)
+	}
+	go eventlog.RunSnapshots(ctx, eventlog.RunSnapshotsInput{
+		Snapshots:   snapshots,
+		Snapshotter: snapshotter,
+		Interval:    snapshotInterval,
+	})
+}
+
 func newMailer(ctx context.Context) accounts.Mailer {
 	implementation := internal.EnvStringOrFatal("MAILER_IMPLEMENTATION")
 	switch implementation {
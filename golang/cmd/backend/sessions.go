@@ -5,31 +5,25 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ansel1/merry"
 	"github.com/gorilla/mux"
 
+	"github.com/Ryan-A-B/beddybytes/golang/internal/accounts"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/backendmqtt"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/contextx"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventschema"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/httpx"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/sessions"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/sessionstore"
 )
 
-const EventTypeSessionStarted = "session.started"
-const EventTypeSessionEnded = "session.ended"
-
-type StartSessionEventData struct {
-	ID               string    `json:"id"`
-	Name             string    `json:"name"`
-	HostConnectionID string    `json:"host_connection_id"`
-	StartedAt        time.Time `json:"started_at"`
-}
-
-func (session *StartSessionEventData) validate() error {
+func validateStartSession(session *sessions.EventStarted) error {
 	if session.ID == "" {
 		return merry.New("session id is empty").WithHTTPCode(http.StatusBadRequest)
 	}
@@ -50,6 +44,11 @@ type Session struct {
 	StartedAt        time.Time `json:"started_at"`
 }
 
+// StartSession announces a session, whose start event is appended when the
+// announcement comes back over MQTT. The ETag is the logical clock of the
+// session's stream before the start. If-Match is checked against it here and
+// again when the start event is appended, so a start that loses a race after
+// the response is not appended.
 func (handlers *Handlers) StartSession(responseWriter http.ResponseWriter, request *http.Request) {
 	var err error
 	defer func() {
@@ -58,16 +57,20 @@ func (handlers *Handlers) StartSession(responseWriter http.ResponseWriter, reque
 			httpx.Error(responseWriter, err)
 		}
 	}()
+	expectedLogicalClock, err := getIfMatchLogicalClock(request)
+	if err != nil {
+		return
+	}
 	ctx := request.Context()
 	vars := mux.Vars(request)
 	sessionID := vars["session_id"]
-	var session StartSessionEventData
+	var session sessions.EventStarted
 	err = json.NewDecoder(request.Body).Decode(&session)
 	if err != nil {
 		err = merry.WithHTTPCode(err, http.StatusBadRequest)
 		return
 	}
-	err = session.validate()
+	err = validateStartSession(&session)
 	if err != nil {
 		return
 	}
@@ -77,39 +80,52 @@ func (handlers *Handlers) StartSession(responseWriter http.ResponseWriter, reque
 	}
 	accountID := contextx.GetAccountID(ctx)
 	pending := backendmqtt.PendingSessionStart{
-		SessionID:    session.ID,
-		Name:         session.Name,
-		ConnectionID: session.HostConnectionID,
-		StartedAt:    session.StartedAt,
+		SessionID:            session.ID,
+		Name:                 session.Name,
+		ConnectionID:         session.HostConnectionID,
+		StartedAt:            session.StartedAt,
+		ExpectedLogicalClock: expectedLogicalClock,
 	}
 	if err = pending.Validate(); err != nil {
 		err = merry.WithHTTPCode(err, http.StatusBadRequest)
 		return
 	}
+	head, err := eventlog.GetStreamHead(ctx, handlers.EventLog, accountID, sessions.StreamKey(accountID, sessionID))
+	if err != nil {
+		return
+	}
+	if expectedLogicalClock != nil && *expectedLogicalClock != head {
+		err = merry.Wrap(&eventlog.ConflictError{
+			StreamKey:            sessions.StreamKey(accountID, sessionID),
+			ExpectedLogicalClock: *expectedLogicalClock,
+			ActualLogicalClock:   head,
+		}).WithHTTPCode(http.StatusConflict)
+		return
+	}
 	connection, ok := handlers.ConnectionRegistry.GetByConnectionID(accountID, session.HostConnectionID)
 	if !ok {
 		handlers.PendingSessionStarts.Put(accountID, pending)
+		setETagLogicalClock(responseWriter, head)
 		return
 	}
 	err = backendmqtt.PublishBabyStationAnnouncement(handlers.MQTTClient, accountID, backendmqtt.BabyStationsPayload{
 		Type:     backendmqtt.AnnouncementType,
 		AtMillis: pending.StartedAt.UnixMilli(),
 		Announcement: backendmqtt.SessionAnnouncement{
-			ClientID:        connection.ClientID,
-			ConnectionID:    pending.ConnectionID,
-			SessionID:       pending.SessionID,
-			Name:            pending.Name,
-			StartedAtMillis: pending.StartedAt.UnixMilli(),
+			ClientID:             connection.ClientID,
+			ConnectionID:         pending.ConnectionID,
+			SessionID:            pending.SessionID,
+			Name:                 pending.Name,
+			StartedAtMillis:      pending.StartedAt.UnixMilli(),
+			ExpectedLogicalClock: pending.ExpectedLogicalClock,
 		},
 	})
 	if err != nil {
 		return
 	}
-	// TODO set header with logical clock of the start event
-}
-
-type EndSessionEventData struct {
-	ID string `json:"id"`
+	// The start event's own logical clock reaches the client as the
+	// session.started event on /events.
+	setETagLogicalClock(responseWriter, head)
 }
 
 func (handlers *Handlers) EndSession(responseWriter http.ResponseWriter, request *http.Request) {
@@ -123,16 +139,42 @@ func (handlers *Handlers) EndSession(responseWriter http.ResponseWriter, request
 	ctx := request.Context()
 	vars := mux.Vars(request)
 	sessionID := vars["session_id"]
-	// TODO check we know about the session?
-	// expect a header with the logical clock of the start event
-	_, err = handlers.EventLog.Append(ctx, eventlog.AppendInput{
-		Type:      EventTypeSessionEnded,
-		AccountID: contextx.GetAccountID(ctx),
-		Data:      fatal.UnlessMarshalJSON(&EndSessionEventData{ID: sessionID}),
+	accountID := contextx.GetAccountID(ctx)
+	expectedLogicalClock, err := getIfMatchLogicalClock(request)
+	if err != nil {
+		return
+	}
+	event, err := handlers.EventLog.Append(ctx, eventlog.AppendInput{
+		Type:                 sessions.EventTypeEnded,
+		AccountID:            accountID,
+		StreamKey:            sessions.StreamKey(accountID, sessionID),
+		Data:                 fatal.UnlessMarshalJSON(&sessions.EventEnded{ID: sessionID}),
+		ExpectedLogicalClock: expectedLogicalClock,
 	})
 	if err != nil {
 		return
 	}
+	setETagLogicalClock(responseWriter, event.LogicalClock)
+}
+
+// getIfMatchLogicalClock reads the logical clock the client expects the
+// session's stream to be at, or nil if the request has no If-Match header.
+func getIfMatchLogicalClock(request *http.Request) (logicalClock *int64, err error) {
+	value := request.Header.Get("If-Match")
+	if value == "" {
+		return
+	}
+	parsed, err := strconv.ParseInt(strings.Trim(value, `"`), 10, 64)
+	if err != nil {
+		err = merry.Prepend(err, "invalid If-Match header").WithHTTPCode(http.StatusBadRequest)
+		return
+	}
+	logicalClock = &parsed
+	return
+}
+
+func setETagLogicalClock(responseWriter http.ResponseWriter, logicalClock int64) {
+	responseWriter.Header().Set("ETag", strconv.Quote(strconv.FormatInt(logicalClock, 10)))
 }
 
 type SessionProjection struct {
@@ -140,19 +182,27 @@ type SessionProjection struct {
 	Head         int64
 }
 
-func (projection *SessionProjection) ApplyEvent(ctx context.Context, event *eventlog.Event) {
+func (projection *SessionProjection) ApplyEvent(ctx context.Context, event *eventlog.Event) (err error) {
 	switch event.Type {
-	case EventTypeSessionStarted:
-		projection.applySessionStartedEvent(event)
-	case EventTypeSessionEnded:
-		projection.applySessionEndedEvent(event)
+	case sessions.EventTypeStarted:
+		err = projection.applySessionStartedEvent(event)
+	case sessions.EventTypeEnded:
+		err = projection.applySessionEndedEvent(event)
+	case accounts.EventTypeAccountDeleted:
+		projection.applyAccountDeletedEvent(event)
+	}
+	if err != nil {
+		return
 	}
 	projection.Head = event.LogicalClock
+	return
 }
 
-func (projection *SessionProjection) applySessionStartedEvent(event *eventlog.Event) {
-	var data StartSessionEventData
-	fatal.UnlessUnmarshalJSON(event.Data, &data)
+func (projection *SessionProjection) applySessionStartedEvent(event *eventlog.Event) (err error) {
+	data, err := eventschema.DecodeEvent[sessions.EventStarted](event)
+	if err != nil {
+		return
+	}
 	projection.SessionStore.Put(&sessions.Session{
 		AccountID:        event.AccountID,
 		ID:               data.ID,
@@ -160,10 +210,55 @@ func (projection *SessionProjection) applySessionStartedEvent(event *eventlog.Ev
 		HostConnectionID: data.HostConnectionID,
 		StartedAt:        data.StartedAt,
 	})
+	return
 }
 
-func (projection *SessionProjection) applySessionEndedEvent(event *eventlog.Event) {
-	var data EndSessionEventData
-	fatal.UnlessUnmarshalJSON(event.Data, &data)
+func (projection *SessionProjection) applySessionEndedEvent(event *eventlog.Event) (err error) {
+	data, err := eventschema.DecodeEvent[sessions.EventEnded](event)
+	if err != nil {
+		return
+	}
 	projection.SessionStore.Remove(event.AccountID, data.ID)
+	return
+}
+
+func (projection *SessionProjection) applyAccountDeletedEvent(event *eventlog.Event) {
+	for _, session := range projection.SessionStore.List(event.AccountID) {
+		projection.SessionStore.Remove(event.AccountID, session.ID)
+	}
+}
+
+const SessionProjectionSnapshotSchemaVersion = 1
+
+type sessionProjectionSnapshotSession struct {
+	AccountID        string    `json:"account_id"`
+	ID               string    `json:"id"`
+	Name             string    `json:"name"`
+	HostConnectionID string    `json:"host_connection_id"`
+	StartedAt        time.Time `json:"started_at"`
+}
+
+func (projection *SessionProjection) MarshalSnapshot(ctx context.Context) (cursor int64, data []byte, err error) {
+	storedSessions := projection.SessionStore.ListAll()
+	snapshotSessions := make([]sessionProjectionSnapshotSession, len(storedSessions))
+	for i, session := range storedSessions {
+		snapshotSessions[i] = sessionProjectionSnapshotSession(*session)
+	}
+	data, err = json.Marshal(snapshotSessions)
+	cursor = projection.Head
+	return
+}
+
+func (projection *SessionProjection) UnmarshalSnapshot(ctx context.Context, cursor int64, data []byte) (err error) {
+	var snapshotSessions []sessionProjectionSnapshotSession
+	err = json.Unmarshal(data, &snapshotSessions)
+	if err != nil {
+		return
+	}
+	for _, session := range snapshotSessions {
+		storedSession := sessions.Session(session)
+		projection.SessionStore.Put(&storedSession)
+	}
+	projection.Head = cursor
+	return
 }
@@ -1,20 +1,161 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"runtime"
 	"testing"
+	"time"
 
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/backendmqtt"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/contextx"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventschema"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/sessions"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/sessionstore"
 )
 
+func TestEndSessionIfMatch(t *testing.T) {
+	Convey("TestEndSessionIfMatch", t, func() {
+		ctx := context.Background()
+		folderPath, err := os.MkdirTemp("testdata", "TestEndSessionIfMatch-*")
+		So(err, ShouldBeNil)
+		handlers := Handlers{
+			EventLog: eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
+				FolderPath: folderPath,
+			}),
+		}
+		accountID := uuid.NewV4().String()
+		sessionID := uuid.NewV4().String()
+		started, err := handlers.EventLog.Append(ctx, eventlog.AppendInput{
+			Type:      sessions.EventTypeStarted,
+			AccountID: accountID,
+			StreamKey: sessions.StreamKey(accountID, sessionID),
+			Data:      []byte("{}"),
+		})
+		So(err, ShouldBeNil)
+		_, err = handlers.EventLog.Append(ctx, eventlog.AppendInput{
+			Type: eventschema.EventTypeServerStarted,
+			Data: []byte("null"),
+		})
+		So(err, ShouldBeNil)
+		endSession := func(ifMatch string) *httptest.ResponseRecorder {
+			request := httptest.NewRequest(http.MethodDelete, "/sessions/"+sessionID, nil)
+			request = request.WithContext(contextx.WithAccountID(request.Context(), accountID))
+			request = mux.SetURLVars(request, map[string]string{"session_id": sessionID})
+			if ifMatch != "" {
+				request.Header.Set("If-Match", ifMatch)
+			}
+			recorder := httptest.NewRecorder()
+			handlers.EndSession(recorder, request)
+			return recorder
+		}
+		Convey("stale", func() {
+			recorder := endSession(`"0"`)
+			So(recorder.Code, ShouldEqual, http.StatusConflict)
+		})
+		Convey("invalid", func() {
+			recorder := endSession("abc")
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+		})
+		Convey("current", func() {
+			recorder := endSession(`"1"`)
+			So(started.LogicalClock, ShouldEqual, 1)
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Header().Get("ETag"), ShouldEqual, `"3"`)
+			Convey("again", func() {
+				recorder := endSession(`"1"`)
+				So(recorder.Code, ShouldEqual, http.StatusConflict)
+			})
+		})
+		Convey("unconditional", func() {
+			recorder := endSession("")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+		})
+	})
+}
+
+func TestStartSessionIfMatch(t *testing.T) {
+	Convey("TestStartSessionIfMatch", t, func() {
+		ctx := context.Background()
+		folderPath, err := os.MkdirTemp("testdata", "TestStartSessionIfMatch-*")
+		So(err, ShouldBeNil)
+		handlers := Handlers{
+			EventLog: eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
+				FolderPath: folderPath,
+			}),
+			ConnectionRegistry:   backendmqtt.NewConnectionRegistry(),
+			PendingSessionStarts: backendmqtt.NewPendingSessionStarts(),
+		}
+		accountID := uuid.NewV4().String()
+		sessionID := uuid.NewV4().String()
+		connectionID := uuid.NewV4().String()
+		_, err = handlers.EventLog.Append(ctx, eventlog.AppendInput{
+			Type: eventschema.EventTypeServerStarted,
+			Data: []byte("null"),
+		})
+		So(err, ShouldBeNil)
+		startSession := func(ifMatch string) *httptest.ResponseRecorder {
+			body := fatal.UnlessMarshalJSON(sessions.EventStarted{
+				ID:               sessionID,
+				Name:             "nursery",
+				HostConnectionID: connectionID,
+				StartedAt:        time.Now(),
+			})
+			request := httptest.NewRequest(http.MethodPut, "/sessions/"+sessionID, bytes.NewReader(body))
+			request = request.WithContext(contextx.WithAccountID(request.Context(), accountID))
+			request = mux.SetURLVars(request, map[string]string{"session_id": sessionID})
+			if ifMatch != "" {
+				request.Header.Set("If-Match", ifMatch)
+			}
+			recorder := httptest.NewRecorder()
+			handlers.StartSession(recorder, request)
+			return recorder
+		}
+		Convey("new session", func() {
+			recorder := startSession(`"0"`)
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Header().Get("ETag"), ShouldEqual, `"0"`)
+			pending, ok := handlers.PendingSessionStarts.Get(accountID, connectionID)
+			So(ok, ShouldBeTrue)
+			So(*pending.ExpectedLogicalClock, ShouldEqual, 0)
+		})
+		Convey("invalid", func() {
+			recorder := startSession("abc")
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+		})
+		Convey("started already", func() {
+			_, err := handlers.EventLog.Append(ctx, eventlog.AppendInput{
+				Type:      sessions.EventTypeStarted,
+				AccountID: accountID,
+				StreamKey: sessions.StreamKey(accountID, sessionID),
+				Data:      []byte("{}"),
+			})
+			So(err, ShouldBeNil)
+			recorder := startSession(`"0"`)
+			So(recorder.Code, ShouldEqual, http.StatusConflict)
+			_, ok := handlers.PendingSessionStarts.Get(accountID, connectionID)
+			So(ok, ShouldBeFalse)
+			recorder = startSession("")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Header().Get("ETag"), ShouldEqual, `"2"`)
+		})
+	})
+}
+
 func BenchmarkSessionProjectionRealData(b *testing.B) {
 	ctx := context.Background()
 	eventLogPath := findBackendRealEventLogPath(b)
-	log := eventlog.NewFileEventLog(&eventlog.NewFileEventLogInput{
+	log := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
 		FolderPath: eventLogPath,
 	})
 	b.ReportAllocs()
@@ -68,8 +209,8 @@ func findBackendRealEventLogPath(tb testing.TB) string {
 		goModPath := filepath.Join(dir, "go.mod")
 		if _, err := os.Stat(goModPath); err == nil {
 			eventLogPath := filepath.Join(dir, "eventlog")
-			eventsPath := filepath.Join(eventLogPath, eventlog.EventsFileName)
-			if _, err := os.Stat(eventsPath); err == nil {
+			metadataPath := filepath.Join(eventLogPath, eventlog.MetadataFileName)
+			if _, err := os.Stat(metadataPath); err == nil {
 				return eventLogPath
 			}
 			tb.Skipf("real eventlog not found at %s", eventLogPath)
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/accounts"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/babystationlist"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/sessionlist"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/sessions"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/sessionstore"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/store"
+)
+
+func TestSnapshotsExcludeAccountData(t *testing.T) {
+	Convey("TestSnapshotsExcludeAccountData", t, func() {
+		ctx := context.Background()
+		folderPath, err := os.MkdirTemp("testdata", "TestSnapshotsExcludeAccountData-*")
+		So(err, ShouldBeNil)
+		log := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
+			FolderPath: folderPath,
+		})
+		accountID := uuid.NewV4().String()
+		user := accounts.NewUser(&accounts.NewUserInput{
+			Email:              "snapshot@example.com",
+			Password:           "password",
+			PasswordHashParams: accounts.PasswordHashParams{Iterations: 1, KeyLength: 32},
+		})
+		appendEvent := func(eventType string, data interface{}) {
+			_, err := log.Append(ctx, eventlog.AppendInput{
+				Type:      eventType,
+				AccountID: accountID,
+				Data:      fatal.UnlessMarshalJSON(data),
+			})
+			So(err, ShouldBeNil)
+		}
+		appendEvent(accounts.EventTypeAccountCreated, accounts.Account{ID: accountID, User: user})
+		appendEvent(sessions.EventTypeStarted, sessions.EventStarted{
+			ID:               uuid.NewV4().String(),
+			Name:             "nursery",
+			HostConnectionID: uuid.NewV4().String(),
+			StartedAt:        time.Now(),
+		})
+		sessionProjection := &SessionProjection{
+			SessionStore: new(sessionstore.InMemory),
+		}
+		iterator := log.GetEventIterator(ctx, eventlog.GetEventIteratorInput{})
+		for iterator.Next(ctx) {
+			So(sessionProjection.ApplyEvent(ctx, iterator.Event()), ShouldBeNil)
+		}
+		So(iterator.Err(), ShouldBeNil)
+		snapshotters := map[string]eventlog.Snapshotter{
+			"sessionlist": sessionlist.New(ctx, sessionlist.NewInput{
+				Log: log,
+			}),
+			"babystationlist": babystationlist.New(babystationlist.NewInput{
+				EventLog: log,
+			}),
+			"usagestats": NewUsageStats(ctx, NewUsageStatsInput{
+				Log: log,
+			}),
+			"sessions": sessionProjection,
+		}
+		snapshotStore := store.NewMemoryStore()
+		for key, snapshotter := range snapshotters {
+			_, err := eventlog.SaveSnapshot(ctx, eventlog.SaveSnapshotInput{
+				Snapshots:   newSnapshots(snapshotStore, key, 1),
+				Snapshotter: snapshotter,
+			})
+			So(err, ShouldBeNil)
+		}
+		keys, err := snapshotStore.List(ctx, "")
+		So(err, ShouldBeNil)
+		So(keys, ShouldHaveLength, len(snapshotters))
+		for _, key := range keys {
+			data, err := snapshotStore.Get(ctx, key)
+			So(err, ShouldBeNil)
+			So(bytes.Contains(data, []byte(user.Email)), ShouldBeFalse)
+		}
+	})
+}
@@ -0,0 +1,509 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/compaction"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+)
+
+const usage = `eventlog inspects the folder of a FileEventLog. It never writes to the
+folder, so it is safe to point at the event log of a running backend.
+
+usage:
+  eventlog tail    [flags]  print events as they are appended
+  eventlog grep    [flags]  print the events that match the filters
+  eventlog stats   [flags]  count the events that match by type and account
+  eventlog export  [flags]  write the events that match as JSONL or CSV
+  eventlog compact [flags]  copy the log to a new folder without old connection churn
+  eventlog verify  [flags]  check the hash chain and report the first broken link
+  eventlog import  [flags]  copy events.jsonl or the log to a new IndexedEventLog folder
+
+Run eventlog <command> -h for the flags of a command.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	command, args := os.Args[1], os.Args[2:]
+	var err error
+	switch command {
+	case "tail":
+		err = runTail(ctx, args)
+	case "grep":
+		err = runGrep(ctx, args)
+	case "stats":
+		err = runStats(ctx, args)
+	case "export":
+		err = runExport(ctx, args)
+	case "compact":
+		err = runCompact(ctx, args)
+	case "verify":
+		err = runVerify(ctx, args, os.Stdout)
+	case "import":
+		err = runImport(ctx, args)
+	case "-h", "-help", "--help", "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// filterFlags are the flags shared by every command.
+type filterFlags struct {
+	folderPath     string
+	types          string
+	accountID      string
+	fromCursor     int64
+	toLogicalClock int64
+	since          timeFlag
+	until          timeFlag
+}
+
+func newFlagSet(name string, filter *filterFlags) *flag.FlagSet {
+	flagSet := flag.NewFlagSet(name, flag.ExitOnError)
+	flagSet.StringVar(&filter.folderPath, "folder-path", os.Getenv("FILE_EVENT_LOG_FOLDER_PATH"), "Path of the FileEventLog folder, defaults to $FILE_EVENT_LOG_FOLDER_PATH")
+	flagSet.StringVar(&filter.types, "type", "", "Comma separated event types to include")
+	flagSet.StringVar(&filter.accountID, "account-id", "", "Only include events of this account")
+	flagSet.Int64Var(&filter.fromCursor, "from", 0, "Only include events after this logical clock")
+	flagSet.Int64Var(&filter.toLogicalClock, "to", 0, "Only include events up to this logical clock, 0 for no limit")
+	flagSet.Var(&filter.since, "since", "Only include events at or after this time, as RFC 3339 or YYYY-MM-DD")
+	flagSet.Var(&filter.until, "until", "Only include events before this time, as RFC 3339 or YYYY-MM-DD")
+	return flagSet
+}
+
+func (filter *filterFlags) openEventLog(pollInterval time.Duration) (*eventlog.ReadOnlyFileEventLog, error) {
+	if filter.folderPath == "" {
+		return nil, errors.New("-folder-path must be set")
+	}
+	return eventlog.NewReadOnlyFileEventLog(&eventlog.NewReadOnlyFileEventLogInput{
+		FolderPath:   filter.folderPath,
+		PollInterval: pollInterval,
+	})
+}
+
+func (filter *filterFlags) getEventIteratorInput() eventlog.GetEventIteratorInput {
+	input := eventlog.GetEventIteratorInput{
+		FromCursor: filter.fromCursor,
+		AccountID:  filter.accountID,
+	}
+	if filter.types != "" {
+		input.Types = strings.Split(filter.types, ",")
+	}
+	return input
+}
+
+func (filter *filterFlags) inTimeRange(event *eventlog.Event) bool {
+	if !filter.since.IsZero() && event.UnixTimestamp < filter.since.Unix() {
+		return false
+	}
+	if !filter.until.IsZero() && event.UnixTimestamp >= filter.until.Unix() {
+		return false
+	}
+	return true
+}
+
+// forEach calls fn for every event that matches the filters, stopping after
+// -to.
+func (filter *filterFlags) forEach(ctx context.Context, iterator eventlog.EventIterator, fn func(event *eventlog.Event) error) error {
+	for iterator.Next(ctx) {
+		event := iterator.Event()
+		if filter.toLogicalClock != 0 && event.LogicalClock > filter.toLogicalClock {
+			return nil
+		}
+		if !filter.inTimeRange(event) {
+			continue
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return iterator.Err()
+}
+
+type timeFlag struct {
+	time.Time
+}
+
+func (flag *timeFlag) String() string {
+	if flag.IsZero() {
+		return ""
+	}
+	return flag.Format(time.RFC3339)
+}
+
+func (flag *timeFlag) Set(value string) (err error) {
+	flag.Time, err = time.Parse(time.RFC3339, value)
+	if err == nil {
+		return
+	}
+	flag.Time, err = time.ParseInLocation(time.DateOnly, value, time.Local)
+	return
+}
+
+func runTail(ctx context.Context, args []string) error {
+	var filter filterFlags
+	flagSet := newFlagSet("tail", &filter)
+	n := flagSet.Int64("n", 10, "Start this many logical clocks before the end of the log, ignored if -from is set")
+	pollInterval := flagSet.Duration("poll-interval", eventlog.DefaultPollInterval, "How often to check the folder for new events")
+	flagSet.Parse(args)
+	eventLog, err := filter.openEventLog(*pollInterval)
+	if err != nil {
+		return err
+	}
+	if filter.fromCursor == 0 {
+		filter.fromCursor = max(eventLog.Cursor()-*n, 0)
+	}
+	input := filter.getEventIteratorInput()
+	iterator := eventlog.Follow(ctx, eventlog.FollowInput{
+		EventLog:   eventLog,
+		FromCursor: input.FromCursor,
+		Types:      input.Types,
+		AccountID:  input.AccountID,
+	})
+	return filter.forEach(ctx, iterator, func(event *eventlog.Event) error {
+		return writeEventLine(os.Stdout, event)
+	})
+}
+
+func runGrep(ctx context.Context, args []string) error {
+	var filter filterFlags
+	flagSet := newFlagSet("grep", &filter)
+	flagSet.Parse(args)
+	eventLog, err := filter.openEventLog(0)
+	if err != nil {
+		return err
+	}
+	iterator := eventLog.GetEventIterator(ctx, filter.getEventIteratorInput())
+	return filter.forEach(ctx, iterator, func(event *eventlog.Event) error {
+		return writeEventLine(os.Stdout, event)
+	})
+}
+
+func writeEventLine(writer io.Writer, event *eventlog.Event) error {
+	accountID := event.AccountID
+	if accountID == "" {
+		accountID = "-"
+	}
+	_, err := fmt.Fprintf(writer, "%d\t%s\t%s\t%s\t%s\n",
+		event.LogicalClock,
+		time.Unix(event.UnixTimestamp, 0).Format(time.RFC3339),
+		event.Type,
+		accountID,
+		event.Data,
+	)
+	return err
+}
+
+func runStats(ctx context.Context, args []string) error {
+	var filter filterFlags
+	flagSet := newFlagSet("stats", &filter)
+	top := flagSet.Int("top", 20, "Number of accounts to list, 0 lists every account")
+	flagSet.Parse(args)
+	eventLog, err := filter.openEventLog(0)
+	if err != nil {
+		return err
+	}
+	iterator := eventLog.GetEventIterator(ctx, filter.getEventIteratorInput())
+	stats := newStats()
+	err = filter.forEach(ctx, iterator, func(event *eventlog.Event) error {
+		stats.add(event)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return stats.write(os.Stdout, *top)
+}
+
+type stats struct {
+	count            int
+	first            *eventlog.Event
+	last             *eventlog.Event
+	countByType      map[string]int
+	countByAccountID map[string]int
+}
+
+func newStats() *stats {
+	return &stats{
+		countByType:      make(map[string]int),
+		countByAccountID: make(map[string]int),
+	}
+}
+
+func (stats *stats) add(event *eventlog.Event) {
+	if stats.first == nil {
+		stats.first = event
+	}
+	stats.last = event
+	stats.count++
+	stats.countByType[event.Type]++
+	if event.AccountID != "" {
+		stats.countByAccountID[event.AccountID]++
+	}
+}
+
+func (stats *stats) write(writer io.Writer, top int) error {
+	tabWriter := tabwriter.NewWriter(writer, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tabWriter, "events\t%d\n", stats.count)
+	if stats.count != 0 {
+		fmt.Fprintf(tabWriter, "logical clocks\t%d to %d\n", stats.first.LogicalClock, stats.last.LogicalClock)
+		fmt.Fprintf(tabWriter, "time\t%s to %s\n",
+			time.Unix(stats.first.UnixTimestamp, 0).Format(time.RFC3339),
+			time.Unix(stats.last.UnixTimestamp, 0).Format(time.RFC3339),
+		)
+	}
+	fmt.Fprintf(tabWriter, "\nby type\t\n")
+	for _, entry := range sortCounts(stats.countByType, 0) {
+		fmt.Fprintf(tabWriter, "  %s\t%d\n", entry.key, entry.count)
+	}
+	fmt.Fprintf(tabWriter, "\nby account (%d accounts)\t\n", len(stats.countByAccountID))
+	for _, entry := range sortCounts(stats.countByAccountID, top) {
+		fmt.Fprintf(tabWriter, "  %s\t%d\n", entry.key, entry.count)
+	}
+	return tabWriter.Flush()
+}
+
+type countEntry struct {
+	key   string
+	count int
+}
+
+// sortCounts orders counts from largest to smallest, keeping the first limit
+// unless limit is 0.
+func sortCounts(countByKey map[string]int, limit int) (entries []countEntry) {
+	for key, count := range countByKey {
+		entries = append(entries, countEntry{key: key, count: count})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].count != entries[j].count {
+			return entries[i].count > entries[j].count
+		}
+		return entries[i].key < entries[j].key
+	})
+	if limit != 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return
+}
+
+func runExport(ctx context.Context, args []string) (err error) {
+	var filter filterFlags
+	flagSet := newFlagSet("export", &filter)
+	format := flagSet.String("format", "jsonl", "Output format, jsonl or csv")
+	outputFilePath := flagSet.String("output", "", "File to write to, defaults to stdout")
+	flagSet.Parse(args)
+	eventLog, err := filter.openEventLog(0)
+	if err != nil {
+		return
+	}
+	var writer io.Writer = os.Stdout
+	if *outputFilePath != "" {
+		file, err := os.Create(*outputFilePath)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+		}()
+		writer = file
+	}
+	exporter, err := newExporter(*format, writer)
+	if err != nil {
+		return
+	}
+	iterator := eventLog.GetEventIterator(ctx, filter.getEventIteratorInput())
+	err = filter.forEach(ctx, iterator, exporter.write)
+	if err != nil {
+		return
+	}
+	return exporter.flush()
+}
+
+type exporter interface {
+	write(event *eventlog.Event) error
+	flush() error
+}
+
+func newExporter(format string, writer io.Writer) (exporter, error) {
+	switch format {
+	case "jsonl":
+		return &jsonlExporter{encoder: json.NewEncoder(writer)}, nil
+	case "csv":
+		exporter := &csvExporter{writer: csv.NewWriter(writer)}
+		return exporter, exporter.writer.Write(csvHeader)
+	default:
+		return nil, fmt.Errorf("unknown format %q, expected jsonl or csv", format)
+	}
+}
+
+type jsonlExporter struct {
+	encoder *json.Encoder
+}
+
+func (exporter *jsonlExporter) write(event *eventlog.Event) error {
+	return exporter.encoder.Encode(event)
+}
+
+func (exporter *jsonlExporter) flush() error {
+	return nil
+}
+
+var csvHeader = []string{"logical_clock", "id", "type", "account_id", "stream_key", "unix_timestamp", "data"}
+
+type csvExporter struct {
+	writer *csv.Writer
+}
+
+func (exporter *csvExporter) write(event *eventlog.Event) error {
+	return exporter.writer.Write([]string{
+		strconv.FormatInt(event.LogicalClock, 10),
+		event.ID,
+		event.Type,
+		event.AccountID,
+		event.StreamKey,
+		strconv.FormatInt(event.UnixTimestamp, 10),
+		string(event.Data),
+	})
+}
+
+func (exporter *csvExporter) flush() error {
+	exporter.writer.Flush()
+	return exporter.writer.Error()
+}
+
+const defaultRetention = 30 * 24 * time.Hour
+
+// runCompact writes the compacted log to -output-folder-path. The backend
+// has to be stopped and the folders swapped by hand for it to take effect,
+// and any events appended in the meantime would be lost.
+func runCompact(ctx context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("compact", flag.ExitOnError)
+	folderPath := flagSet.String("folder-path", os.Getenv("FILE_EVENT_LOG_FOLDER_PATH"), "Path of the FileEventLog folder, defaults to $FILE_EVENT_LOG_FOLDER_PATH")
+	outputFolderPath := flagSet.String("output-folder-path", "", "Path of the folder to write the compacted log to")
+	retention := flagSet.Duration("retention", defaultRetention, "Connection events newer than this are kept")
+	flagSet.Parse(args)
+	if *outputFolderPath == "" {
+		return errors.New("-output-folder-path must be set")
+	}
+	filter := filterFlags{folderPath: *folderPath}
+	eventLog, err := filter.openEventLog(0)
+	if err != nil {
+		return err
+	}
+	report, err := compaction.Compact(ctx, compaction.CompactInput{
+		EventLog:    eventLog,
+		FolderPath:  *outputFolderPath,
+		RetainAfter: time.Now().Add(-*retention),
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("compacted %s into %s up to logical clock %d: kept %d events, dropped %d\n",
+		*folderPath, *outputFolderPath, report.Cursor, report.Kept, report.Dropped)
+	if report.Rebase != nil {
+		fmt.Printf("relinked the hash chain, the source chain head at logical clock %d was %s\n",
+			report.Rebase.SourceCursor, report.Rebase.SourceHead)
+	}
+	return nil
+}
+
+func runVerify(ctx context.Context, args []string, writer io.Writer) error {
+	flagSet := flag.NewFlagSet("verify", flag.ExitOnError)
+	folderPath := flagSet.String("folder-path", os.Getenv("FILE_EVENT_LOG_FOLDER_PATH"), "Path of the FileEventLog folder, defaults to $FILE_EVENT_LOG_FOLDER_PATH")
+	flagSet.Parse(args)
+	filter := filterFlags{folderPath: *folderPath}
+	eventLog, err := filter.openEventLog(0)
+	if err != nil {
+		return err
+	}
+	report, err := eventlog.VerifyHashChain(ctx, eventLog)
+	if err != nil {
+		return err
+	}
+	if report.ChainedEvents == 0 {
+		return fmt.Errorf("none of the %d events are hash chained", report.Events)
+	}
+	_, err = fmt.Fprintf(writer, "verified %d events up to logical clock %d, %d of them hash chained\n",
+		report.Events, report.Cursor, report.ChainedEvents)
+	if err != nil {
+		return err
+	}
+	// A compaction relinks the chain, so the chain only vouches for the log
+	// since then. The source head is what to check against an earlier copy.
+	for _, rebase := range report.Rebases {
+		_, err = fmt.Fprintf(writer, "chain re-based by compaction at %s: the source chain head at logical clock %d was %s\n",
+			time.Unix(rebase.UnixTimestamp, 0).UTC().Format(time.RFC3339), rebase.SourceCursor, rebase.SourceHead)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runImport copies a single file log, JSONL written by export or the folder
+// of a FileEventLog into a new IndexedEventLog folder. As with compact, the
+// backend has to be stopped for the copy to be complete.
+func runImport(ctx context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("import", flag.ExitOnError)
+	eventsFilePath := flagSet.String("events-file-path", "", "Path of an "+eventlog.EventsFileName+" file or of JSONL written by export")
+	folderPath := flagSet.String("folder-path", "", "Path of a FileEventLog folder to import instead of -events-file-path")
+	outputFolderPath := flagSet.String("output-folder-path", "", "Path of the folder to write the IndexedEventLog to")
+	flagSet.Parse(args)
+	if *outputFolderPath == "" {
+		return errors.New("-output-folder-path must be set")
+	}
+	var events eventlog.EventIterator
+	switch {
+	case *eventsFilePath != "":
+		file, err := os.Open(*eventsFilePath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		events = eventlog.NewJSONLEventIterator(file)
+	case *folderPath != "":
+		filter := filterFlags{folderPath: *folderPath}
+		eventLog, err := filter.openEventLog(0)
+		if err != nil {
+			return err
+		}
+		events = eventLog.GetEventIterator(ctx, eventlog.GetEventIteratorInput{})
+	default:
+		return errors.New("one of -events-file-path or -folder-path must be set")
+	}
+	report, err := eventlog.ImportIndexedEventLog(ctx, eventlog.ImportIndexedEventLogInput{
+		Events:     events,
+		FolderPath: *outputFolderPath,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("imported %d events into %s up to logical clock %d, skipped %d out of order\n",
+		report.Imported, *outputFolderPath, report.Cursor, report.Skipped)
+	return nil
+}
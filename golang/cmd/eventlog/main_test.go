@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+)
+
+func TestEventLogCommands(t *testing.T) {
+	ctx := context.Background()
+	Convey("TestEventLogCommands", t, func() {
+		folderPath, err := os.MkdirTemp("testdata", "TestEventLogCommands-*")
+		So(err, ShouldBeNil)
+		outputFolderPath, err := os.MkdirTemp("testdata", "TestEventLogCommandsOutput-*")
+		So(err, ShouldBeNil)
+		writer := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
+			FolderPath: folderPath,
+		})
+		for i := 0; i < 12; i++ {
+			_, err := writer.Append(ctx, eventlog.AppendInput{
+				Type:      []string{"client.connected", "client.disconnected", "session.started"}[i%3],
+				AccountID: []string{"account-1", "account-2"}[i%2],
+				Data:      fatal.UnlessMarshalJSON(map[string]int{"i": i}),
+			})
+			So(err, ShouldBeNil)
+		}
+		Convey("export csv", func() {
+			outputFilePath := filepath.Join(outputFolderPath, "export.csv")
+			err := runExport(ctx, []string{
+				"-folder-path", folderPath,
+				"-format", "csv",
+				"-output", outputFilePath,
+				"-type", "client.connected,session.started",
+				"-account-id", "account-1",
+				"-to", "10",
+			})
+			So(err, ShouldBeNil)
+			data, err := os.ReadFile(outputFilePath)
+			So(err, ShouldBeNil)
+			records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+			So(err, ShouldBeNil)
+			So(records, ShouldHaveLength, 5)
+			So(records[0], ShouldResemble, csvHeader)
+			So(records[1][0], ShouldEqual, "1")
+			So(records[1][2], ShouldEqual, "client.connected")
+			So(records[1][6], ShouldEqual, `{"i":0}`)
+			So(records[2][0], ShouldEqual, "3")
+			So(records[3][0], ShouldEqual, "7")
+			So(records[4][0], ShouldEqual, "9")
+		})
+		Convey("export jsonl", func() {
+			outputFilePath := filepath.Join(outputFolderPath, "export.jsonl")
+			err := runExport(ctx, []string{
+				"-folder-path", folderPath,
+				"-output", outputFilePath,
+				"-from", "9",
+			})
+			So(err, ShouldBeNil)
+			data, err := os.ReadFile(outputFilePath)
+			So(err, ShouldBeNil)
+			So(bytes.Count(data, []byte("\n")), ShouldEqual, 3)
+		})
+		Convey("compact", func() {
+			compactedFolderPath := filepath.Join(outputFolderPath, "compacted")
+			err := runCompact(ctx, []string{
+				"-folder-path", folderPath,
+				"-output-folder-path", compactedFolderPath,
+			})
+			So(err, ShouldBeNil)
+			compacted, err := eventlog.NewReadOnlyFileEventLog(&eventlog.NewReadOnlyFileEventLogInput{
+				FolderPath: compactedFolderPath,
+			})
+			So(err, ShouldBeNil)
+			So(compacted.Cursor(), ShouldEqual, 12)
+			err = runCompact(ctx, []string{
+				"-folder-path", folderPath,
+				"-output-folder-path", compactedFolderPath,
+			})
+			So(err, ShouldWrap, eventlog.ErrCompactFolderNotEmpty)
+		})
+		Convey("import", func() {
+			importedFolderPath := filepath.Join(outputFolderPath, "imported")
+			err := runImport(ctx, []string{
+				"-folder-path", folderPath,
+				"-output-folder-path", importedFolderPath,
+			})
+			So(err, ShouldBeNil)
+			exportFilePath := filepath.Join(outputFolderPath, "export.jsonl")
+			err = runExport(ctx, []string{
+				"-folder-path", folderPath,
+				"-output", exportFilePath,
+			})
+			So(err, ShouldBeNil)
+			reimportedFolderPath := filepath.Join(outputFolderPath, "reimported")
+			err = runImport(ctx, []string{
+				"-events-file-path", exportFilePath,
+				"-output-folder-path", reimportedFolderPath,
+			})
+			So(err, ShouldBeNil)
+			for _, folderPath := range []string{importedFolderPath, reimportedFolderPath} {
+				imported := eventlog.NewIndexedEventLogOrFatal(&eventlog.NewIndexedEventLogInput{
+					FolderPath: folderPath,
+				})
+				head, err := eventlog.GetStreamHead(ctx, imported, "", "")
+				So(err, ShouldBeNil)
+				So(head, ShouldEqual, 12)
+			}
+			err = runImport(ctx, []string{
+				"-folder-path", folderPath,
+				"-output-folder-path", importedFolderPath,
+			})
+			So(err, ShouldWrap, eventlog.ErrImportFolderNotEmpty)
+		})
+		Convey("verify", func() {
+			var output bytes.Buffer
+			err := runVerify(ctx, []string{"-folder-path", folderPath}, &output)
+			So(err, ShouldNotBeNil)
+			chainedFolderPath, err := os.MkdirTemp("testdata", "TestEventLogCommandsChained-*")
+			So(err, ShouldBeNil)
+			chained := eventlog.NewHashChainDecoratorOrFatal(ctx, eventlog.NewHashChainDecoratorInput{
+				Decorated: eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
+					FolderPath: chainedFolderPath,
+				}),
+			})
+			for i := 0; i < 3; i++ {
+				_, err := chained.Append(ctx, eventlog.AppendInput{
+					Type: "account.created",
+					Data: fatal.UnlessMarshalJSON(map[string]int{"i": i}),
+				})
+				So(err, ShouldBeNil)
+			}
+			err = runVerify(ctx, []string{"-folder-path", chainedFolderPath}, &output)
+			So(err, ShouldBeNil)
+			So(output.String(), ShouldContainSubstring, "verified 3 events up to logical clock 3")
+			So(output.String(), ShouldNotContainSubstring, "re-based")
+			compactedFolderPath := filepath.Join(chainedFolderPath, "compacted")
+			err = runCompact(ctx, []string{
+				"-folder-path", chainedFolderPath,
+				"-output-folder-path", compactedFolderPath,
+			})
+			So(err, ShouldBeNil)
+			output.Reset()
+			err = runVerify(ctx, []string{"-folder-path", compactedFolderPath}, &output)
+			So(err, ShouldBeNil)
+			So(output.String(), ShouldContainSubstring, "chain re-based by compaction")
+			So(output.String(), ShouldContainSubstring, "the source chain head at logical clock 3 was")
+		})
+		Convey("stats", func() {
+			eventLog, err := eventlog.NewReadOnlyFileEventLog(&eventlog.NewReadOnlyFileEventLogInput{
+				FolderPath: folderPath,
+			})
+			So(err, ShouldBeNil)
+			stats := newStats()
+			iterator := eventLog.GetEventIterator(ctx, eventlog.GetEventIteratorInput{})
+			for iterator.Next(ctx) {
+				stats.add(iterator.Event())
+			}
+			So(stats.count, ShouldEqual, 12)
+			So(stats.countByType["session.started"], ShouldEqual, 4)
+			So(stats.countByAccountID["account-2"], ShouldEqual, 6)
+			var output bytes.Buffer
+			So(stats.write(&output, 1), ShouldBeNil)
+			So(output.String(), ShouldContainSubstring, "logical clocks  1 to 12")
+			So(output.String(), ShouldContainSubstring, "by account (2 accounts)")
+			So(output.String(), ShouldNotContainSubstring, "account-2")
+		})
+	})
+}
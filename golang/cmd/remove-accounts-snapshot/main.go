@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/store"
+)
+
+// remove-accounts-snapshot deletes the snapshot of the accounts projection
+// from a backend's snapshot folder. The projection is no longer snapshotted,
+// as it holds emails and password hashes that are only kept encrypted under
+// each account's data key, but a snapshot written by an older backend stays
+// readable until it is removed. Run it once against each snapshot folder.
+func main() {
+	ctx := context.Background()
+	folderPath := flag.String("snapshot-folder-path", os.Getenv("SNAPSHOT_FOLDER_PATH"), "Path of the snapshot folder, defaults to $SNAPSHOT_FOLDER_PATH")
+	flag.Parse()
+
+	if *folderPath == "" {
+		fmt.Fprintln(os.Stderr, "-snapshot-folder-path must be set")
+		os.Exit(1)
+	}
+	if _, err := os.Stat(*folderPath); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	snapshotStore := store.NewFileSystemStore(&store.NewFileSystemStoreInput{
+		Root: *folderPath,
+	})
+	err := snapshotStore.Delete(ctx, "accounts")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Println("removed the accounts snapshot from", *folderPath)
+}
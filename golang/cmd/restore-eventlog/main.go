@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/store"
+)
+
+// restore-eventlog rebuilds a FileEventLog folder from the chunks shipped by
+// eventlog.Backup. The chunks are read from S3, or from a FileSystemStore
+// folder when -backup-folder-path is set.
+func main() {
+	ctx := context.Background()
+	folderPath := flag.String("folder-path", "", "Path of the FileEventLog folder to create")
+	bucket := flag.String("s3-bucket", "", "Bucket the event log was backed up to")
+	prefix := flag.String("s3-prefix", "eventlog/", "Key prefix the event log was backed up under")
+	backupFolderPath := flag.String("backup-folder-path", "", "Read the backup from a FileSystemStore folder instead of S3")
+	toLogicalClock := flag.Int64("to", 0, "Last logical clock to restore, 0 restores everything")
+	flag.Parse()
+
+	if *folderPath == "" {
+		fmt.Fprintln(os.Stderr, "-folder-path must be set")
+		os.Exit(1)
+	}
+	backupStore, err := newBackupStore(ctx, *bucket, *prefix, *backupFolderPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	cursor, err := eventlog.RestoreFileEventLog(ctx, eventlog.RestoreFileEventLogInput{
+		Store:          backupStore,
+		FolderPath:     *folderPath,
+		ToLogicalClock: *toLogicalClock,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("restored %s up to logical clock %d\n", *folderPath, cursor)
+}
+
+func newBackupStore(ctx context.Context, bucket string, prefix string, backupFolderPath string) (store.Store, error) {
+	if backupFolderPath != "" {
+		return store.NewFileSystemStore(&store.NewFileSystemStoreInput{
+			Root: backupFolderPath,
+		}), nil
+	}
+	if bucket == "" {
+		return nil, fmt.Errorf("one of -s3-bucket or -backup-folder-path must be set")
+	}
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return store.NewS3Store(&store.NewS3StoreInput{
+		Client: s3.NewFromConfig(cfg),
+		Bucket: bucket,
+		Prefix: prefix,
+	}), nil
+}
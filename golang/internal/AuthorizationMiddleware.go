@@ -13,13 +13,29 @@ import (
 	"github.com/gorilla/mux"
 )
 
+// Authorize checks the claims of a verified access token for a request.
+type Authorize func(request *http.Request, claims *Claims) error
+
 type AuthorizationMiddleware struct {
-	Key interface{}
+	Key       interface{}
+	Authorize Authorize
 }
 
+// NewAuthorizationMiddleware only lets through the access tokens of users,
+// for their own account.
 func NewAuthorizationMiddleware(key interface{}) *AuthorizationMiddleware {
 	return &AuthorizationMiddleware{
-		Key: key,
+		Key:       key,
+		Authorize: AuthorizeUser,
+	}
+}
+
+// NewServiceAuthorizationMiddleware only lets through the access tokens of
+// another service, such as a standby backend, rather than those of users.
+func NewServiceAuthorizationMiddleware(key interface{}, service string) *AuthorizationMiddleware {
+	return &AuthorizationMiddleware{
+		Key:       key,
+		Authorize: AuthorizeService(service),
 	}
 }
 
@@ -43,18 +59,8 @@ func (middleware *AuthorizationMiddleware) Middleware(next http.Handler) http.Ha
 			err = merry.Prepend(err, "failed to parse access token").WithUserMessage("unauthorized").WithHTTPCode(http.StatusUnauthorized)
 			return
 		}
-		if claims.Subject.Service != "iam" {
-			err = merry.New("wrong subject service").WithUserMessage("unauthorized").WithHTTPCode(http.StatusUnauthorized)
-			return
-		}
-		if claims.Subject.ResourceType != "user" {
-			err = merry.New("wrong subject resource type").WithUserMessage("unauthorized").WithHTTPCode(http.StatusUnauthorized)
-			return
-		}
-		vars := mux.Vars(request)
-		accountID := vars["account_id"]
-		if accountID != "" && accountID != "current" && claims.Subject.AccountID != accountID {
-			err = merry.New("forbidden").WithHTTPCode(http.StatusForbidden)
+		err = middleware.Authorize(request, &claims)
+		if err != nil {
 			return
 		}
 		ctx := request.Context()
@@ -63,6 +69,34 @@ func (middleware *AuthorizationMiddleware) Middleware(next http.Handler) http.Ha
 	})
 }
 
+// AuthorizeUser accepts users, and only for their own account when the route
+// has an account_id.
+func AuthorizeUser(request *http.Request, claims *Claims) error {
+	if claims.Subject.Service != "iam" {
+		return merry.New("wrong subject service").WithUserMessage("unauthorized").WithHTTPCode(http.StatusUnauthorized)
+	}
+	if claims.Subject.ResourceType != "user" {
+		return merry.New("wrong subject resource type").WithUserMessage("unauthorized").WithHTTPCode(http.StatusUnauthorized)
+	}
+	vars := mux.Vars(request)
+	accountID := vars["account_id"]
+	if accountID != "" && accountID != "current" && claims.Subject.AccountID != accountID {
+		return merry.New("forbidden").WithHTTPCode(http.StatusForbidden)
+	}
+	return nil
+}
+
+// AuthorizeService accepts the access tokens whose subject service is
+// service.
+func AuthorizeService(service string) Authorize {
+	return func(request *http.Request, claims *Claims) error {
+		if claims.Subject.Service != service {
+			return merry.New("wrong subject service").WithUserMessage("unauthorized").WithHTTPCode(http.StatusUnauthorized)
+		}
+		return nil
+	}
+}
+
 func (middleware *AuthorizationMiddleware) getAccessToken(request *http.Request) (accessToken string, err error) {
 	accessToken, ok := middleware.getAccessTokenFromAuthorizationHeader(request)
 	if ok {
@@ -22,13 +22,15 @@ type User struct {
 type NewUserInput struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	// PasswordHashParams defaults to DefaultPasswordHashParams.
+	PasswordHashParams PasswordHashParams `json:"-"`
 }
 
 func NewUser(input *NewUserInput) (user *User) {
 	passwordSalt := make([]byte, 32)
 	_, err := rand.Read(passwordSalt)
 	fatal.OnError(err)
-	passwordHash := calculatePasswordHash(input.Password, passwordSalt)
+	passwordHash := hashPassword(input.Password, passwordSalt, input.PasswordHashParams)
 	user = &User{
 		ID:           uuid.NewV4().String(),
 		Email:        input.Email,
@@ -2,110 +2,140 @@ package accounts
 
 import (
 	"context"
-	"crypto/sha256"
-	"encoding/json"
-	"io"
+	"errors"
 	"net/http"
 
 	"github.com/ansel1/merry"
 
 	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/store"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/store2"
 )
 
+var ErrAccountNotFound = merry.New("account not found").WithHTTPCode(http.StatusNotFound)
+
+var ErrEmailInUse = merry.New("email already in use").WithHTTPCode(http.StatusBadRequest)
+
+const emailIndex = "email"
+
+// The accounts, their email index and the cursor share a backend under these
+// prefixes, so that no account ID can collide with an email or the cursor.
+const (
+	accountKeyPrefix = "account/"
+	indexKeyPrefix   = "index/"
+	cursorKeyPrefix  = "cursor/"
+)
+
+const cursorKey = "accounts"
+
+// AccountStore keeps accounts under their ID, indexed by email.
 type AccountStore struct {
-	Store store.Store
+	Store store2.IndexedStore[string, Account]
+	// cursors holds the logical clock of the last event applied to Store.
+	cursors store2.Store[string, int64]
 }
 
-func (store *AccountStore) Put(ctx context.Context, account *Account) (err error) {
-	existingAccount, err := store.Get(ctx, account.ID)
-	if merry.HTTPCode(err) == http.StatusOK {
-		return store.update(ctx, existingAccount, account)
+// NewAccountStore stores accounts and their email index as JSON in backend,
+// which can be a store.MemoryStore or, wrapped in a
+// store.EncryptingDecorator, a store.FileSystemStore or store.S3Store. An
+// account and its index entries are written in one batch if backend supports
+// it.
+func NewAccountStore(backend store.Store) *AccountStore {
+	return &AccountStore{
+		Store: store2.IndexingDecorator[string, Account]{
+			Decorated: store2.JSONEncodingDecorator[string, Account]{
+				Decorated: store2.StoreAdapter[string]{
+					Decorated: backend,
+					EncodeKey: store2.PrefixKey[string](accountKeyPrefix),
+				},
+			},
+			Indexes: map[string]func(account Account) string{
+				emailIndex: func(account Account) string {
+					return account.User.Email
+				},
+			},
+			Entries: store2.JSONEncodingDecorator[store2.IndexKey, string]{
+				Decorated: store2.StoreAdapter[store2.IndexKey]{
+					Decorated: backend,
+					EncodeKey: store2.PrefixKey[store2.IndexKey](indexKeyPrefix),
+				},
+			},
+			Atomically: store2.BatchWrites(backend),
+		},
+		cursors: store2.JSONEncodingDecorator[string, int64]{
+			Decorated: store2.StoreAdapter[string]{
+				Decorated: backend,
+				EncodeKey: store2.PrefixKey[string](cursorKeyPrefix),
+			},
+		},
 	}
-	return store.create(ctx, account)
 }
 
-func (store *AccountStore) create(ctx context.Context, account *Account) (err error) {
-	err = store.checkEmail(ctx, account.User.Email)
-	if err != nil {
-		return
+// GetCursor returns the logical clock of the last event applied to the
+// store, or 0 if none has been.
+func (store *AccountStore) GetCursor(ctx context.Context) (cursor int64, err error) {
+	cursor, err = store.cursors.Get(ctx, cursorKey)
+	if errors.As(err, new(store2.ErrNotFound)) {
+		return 0, nil
 	}
-	store.write(ctx, account)
 	return
 }
 
-func (store *AccountStore) update(ctx context.Context, existingAccount *Account, account *Account) (err error) {
-	if existingAccount.User.Email != account.User.Email {
-		err = store.checkEmail(ctx, account.User.Email)
-		if err != nil {
-			return
-		}
-		err = store.Store.Delete(ctx, existingAccount.User.Email)
-		fatal.OnError(err)
+// PutCursor records that the event at cursor has been applied. It is written
+// after the event's changes, so events can be applied again after a crash;
+// applying an account event twice has the same effect as applying it once.
+func (store *AccountStore) PutCursor(ctx context.Context, cursor int64) (err error) {
+	return store.cursors.Put(ctx, cursorKey, cursor)
+}
+
+func (store *AccountStore) Put(ctx context.Context, account *Account) (err error) {
+	err = store.Store.Put(ctx, account.ID, *account)
+	if errors.As(err, new(store2.ErrUniqueConstraint)) {
+		err = ErrEmailInUse.Here()
+		return
 	}
-	store.write(ctx, account)
+	fatal.OnError(err)
 	return
 }
 
 func (store *AccountStore) checkEmail(ctx context.Context, email string) (err error) {
-	_, err = store.Store.Get(ctx, email)
+	_, err = store.GetByEmail(ctx, email)
 	if err == nil {
-		err = merry.New("email already in use").WithHTTPCode(http.StatusBadRequest)
+		err = ErrEmailInUse.Here()
 		return
 	}
-	fatal.Unless(merry.HTTPCode(err) == http.StatusNotFound, "unexpected error")
+	fatal.Unless(merry.Is(err, ErrAccountNotFound), "unexpected error")
 	err = nil
 	return
 }
 
-func (store *AccountStore) write(ctx context.Context, account *Account) {
-	data, err := json.Marshal(account)
-	fatal.OnError(err)
-	err = store.Store.Put(ctx, account.ID, data)
-	fatal.OnError(err)
-	err = store.Store.Put(ctx, account.User.Email, data)
-	fatal.OnError(err)
-}
-
 func (store *AccountStore) Get(ctx context.Context, accountID string) (account *Account, err error) {
-	return store.get(ctx, accountID)
+	value, err := store.Store.Get(ctx, accountID)
+	return accountOrNotFound(value, err)
 }
 
 func (store *AccountStore) GetByEmail(ctx context.Context, email string) (account *Account, err error) {
-	return store.get(ctx, email)
+	value, err := store.Store.GetBy(ctx, emailIndex, email)
+	return accountOrNotFound(value, err)
 }
 
-func (store *AccountStore) get(ctx context.Context, key string) (account *Account, err error) {
-	data, err := store.Store.Get(ctx, key)
+func accountOrNotFound(value Account, err error) (account *Account, _ error) {
+	if errors.As(err, new(store2.ErrNotFound)) {
+		return nil, ErrAccountNotFound.Here()
+	}
 	if err != nil {
-		return
+		return nil, err
 	}
-	account = new(Account)
-	err = json.Unmarshal(data, account)
-	fatal.OnError(err)
-	return
+	return &value, nil
 }
 
 func (store *AccountStore) Remove(ctx context.Context, accountID string) (err error) {
-	account, err := store.Get(ctx, accountID)
+	_, err = store.Get(ctx, accountID)
 	if err != nil {
 		return
 	}
 	err = store.Store.Delete(ctx, accountID)
 	fatal.OnError(err)
-	err = store.Store.Delete(ctx, account.User.Email)
-	fatal.OnError(err)
-	return
-}
-
-func calculatePasswordHash(password string, salt []byte) (passwordHash []byte) {
-	var err error
-	hash := sha256.New()
-	_, err = hash.Write(salt)
-	fatal.OnError(err)
-	_, err = io.WriteString(hash, password)
-	fatal.OnError(err)
-	passwordHash = hash.Sum(nil)
 	return
 }
 
@@ -122,13 +152,5 @@ func (store *AccountStore) UpdatePassword(ctx context.Context, input *UpdatePass
 	}
 	account.User.PasswordSalt = input.PasswordSalt
 	account.User.PasswordHash = input.PasswordHash
-	data, err := json.Marshal(account)
-	if err != nil {
-		return
-	}
-	err = store.Store.Put(ctx, account.ID, data)
-	fatal.OnError(err)
-	err = store.Store.Put(ctx, account.User.Email, data)
-	fatal.OnError(err)
-	return nil
+	return store.Put(ctx, account)
 }
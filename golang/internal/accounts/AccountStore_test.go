@@ -0,0 +1,44 @@
+package accounts_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/accounts"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/store"
+)
+
+func TestAccountStore(t *testing.T) {
+	Convey("TestAccountStore", t, func() {
+		ctx := context.Background()
+		accountStore := accounts.NewAccountStore(store.NewMemoryStore())
+		newAccount := func(id string, email string) *accounts.Account {
+			return &accounts.Account{
+				ID:   id,
+				User: &accounts.User{ID: id, Email: email},
+			}
+		}
+		Convey("IDs don't collide with emails or the cursor", func() {
+			So(accountStore.PutCursor(ctx, 5), ShouldBeNil)
+			first := newAccount("foo@example.com", "bar@example.com")
+			second := newAccount("cursor", "foo@example.com")
+			third := newAccount("email/bar@example.com", "baz@example.com")
+			for _, account := range []*accounts.Account{first, second, third} {
+				So(accountStore.Put(ctx, account), ShouldBeNil)
+			}
+			for _, account := range []*accounts.Account{first, second, third} {
+				stored, err := accountStore.Get(ctx, account.ID)
+				So(err, ShouldBeNil)
+				So(stored, ShouldResemble, account)
+				stored, err = accountStore.GetByEmail(ctx, account.User.Email)
+				So(err, ShouldBeNil)
+				So(stored, ShouldResemble, account)
+			}
+			cursor, err := accountStore.GetCursor(ctx)
+			So(err, ShouldBeNil)
+			So(cursor, ShouldEqual, 5)
+		})
+	})
+}
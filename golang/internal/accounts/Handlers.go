@@ -1,7 +1,7 @@
 package accounts
 
 import (
-	"bytes"
+	"context"
 	"crypto/rsa"
 	"encoding/json"
 	"net/http"
@@ -41,6 +41,8 @@ type Handlers struct {
 	AnonymousAccessTokenDuration time.Duration
 	PasswordResetTokens          *resetpassword.Tokens
 	Mailer                       Mailer
+	// PasswordHashParams defaults to DefaultPasswordHashParams.
+	PasswordHashParams PasswordHashParams
 }
 
 func (handlers *Handlers) AddRoutes(router *mux.Router) {
@@ -208,8 +210,9 @@ func (handlers *Handlers) CreateAccount(responseWriter http.ResponseWriter, requ
 		return
 	}
 	user := NewUser(&NewUserInput{
-		Email:    input.Email,
-		Password: input.Password,
+		Email:              input.Email,
+		Password:           input.Password,
+		PasswordHashParams: handlers.PasswordHashParams,
 	})
 	account := Account{
 		ID:   uuid.NewV4().String(),
@@ -218,8 +221,9 @@ func (handlers *Handlers) CreateAccount(responseWriter http.ResponseWriter, requ
 	data, err := json.Marshal(account)
 	fatal.OnError(err)
 	_, err = handlers.EventLog.Append(ctx, eventlog.AppendInput{
-		Type: EventTypeAccountCreated,
-		Data: data,
+		Type:      EventTypeAccountCreated,
+		AccountID: account.ID,
+		Data:      data,
 	})
 	fatal.OnError(err)
 	responseWriter.Header().Set("Content-Type", "application/json")
@@ -275,15 +279,22 @@ func (handlers *Handlers) GetTokenUsingPasswordGrant(responseWriter http.Respons
 		return
 	}
 	account, err := handlers.AccountStore.GetByEmail(ctx, email)
-	if err != nil {
+	if merry.Is(err, ErrAccountNotFound) {
+		verifyDummyPassword(password, handlers.PasswordHashParams)
 		err = merry.New("account not found").WithUserMessage("unauthorized").WithHTTPCode(http.StatusUnauthorized)
 		return
 	}
-	passwordHash := calculatePasswordHash(password, account.User.PasswordSalt)
-	if !bytes.Equal(passwordHash, account.User.PasswordHash) {
+	if err != nil {
+		return
+	}
+	ok, needsRehash := verifyPassword(password, account.User.PasswordSalt, account.User.PasswordHash, handlers.PasswordHashParams)
+	if !ok {
 		err = merry.New("wrong password").WithUserMessage("unauthorized").WithHTTPCode(http.StatusUnauthorized)
 		return
 	}
+	if needsRehash {
+		handlers.upgradePasswordHash(ctx, account, password)
+	}
 	output := AccessTokenOutput{
 		TokenType:   "Bearer",
 		AccessToken: handlers.createAccessToken(account),
@@ -294,6 +305,24 @@ func (handlers *Handlers) GetTokenUsingPasswordGrant(responseWriter http.Respons
 	json.NewEncoder(responseWriter).Encode(output)
 }
 
+// upgradePasswordHash replaces a legacy or weaker hash once the password is
+// known to be right. A failure is logged rather than failing the login, as
+// the old hash still works.
+func (handlers *Handlers) upgradePasswordHash(ctx context.Context, account *Account, password string) {
+	payload := PasswordHashUpgradedData{
+		PreviousPasswordHash: account.User.PasswordHash,
+		PasswordHash:         hashPassword(password, account.User.PasswordSalt, handlers.PasswordHashParams),
+	}
+	_, err := handlers.EventLog.Append(ctx, eventlog.AppendInput{
+		Type:      EventTypeAccountPasswordHashUpgraded,
+		AccountID: account.ID,
+		Data:      fatal.UnlessMarshalJSON(payload),
+	})
+	if err != nil {
+		logx.Warnln("failed to upgrade password hash:", err)
+	}
+}
+
 func (handlers *Handlers) GetTokenUsingRefreshTokenGrant(responseWriter http.ResponseWriter, request *http.Request) {
 	var err error
 	defer func() {
@@ -381,15 +410,34 @@ func (handlers *Handlers) GetAccount(responseWriter http.ResponseWriter, request
 	json.NewEncoder(responseWriter).Encode(account)
 }
 
+// AccountEraser is implemented by an event log that can make an account's
+// events unreadable, such as eventlog.EncryptingDecorator.
+type AccountEraser interface {
+	EraseAccount(ctx context.Context, accountID string) (err error)
+}
+
+// DeleteAccount appends account.deleted, which the projections apply. The
+// account's events are erased by ApplyAccountDeletedEvent.
 func (handlers *Handlers) DeleteAccount(responseWriter http.ResponseWriter, request *http.Request) {
+	var err error
+	defer func() {
+		if err != nil {
+			logx.Warnln(err)
+			httpx.Error(responseWriter, err)
+			return
+		}
+	}()
 	ctx := request.Context()
 	accountID := contextx.GetAccountID(ctx)
-	err := handlers.AccountStore.Remove(ctx, accountID)
+	_, err = handlers.AccountStore.Get(ctx, accountID)
 	if err != nil {
-		logx.Warnln(err)
-		httpx.Error(responseWriter, err)
 		return
 	}
+	_, err = handlers.EventLog.Append(ctx, eventlog.AppendInput{
+		Type:      EventTypeAccountDeleted,
+		AccountID: accountID,
+		Data:      fatal.UnlessMarshalJSON(AccountDeletedData{}),
+	})
 }
 
 func (handlers *Handlers) createAnonymousAccessToken(remoteAddress string, scope string) (accessToken string) {
@@ -4,10 +4,13 @@ import (
 	"bytes"
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 
@@ -26,11 +29,9 @@ func TestHandlers(t *testing.T) {
 	Convey("TestHandlers", t, func() {
 		ctx := context.Background()
 		handlers := accounts.Handlers{
-			CookieDomain: "localhost",
-			EventLog:     newEventLog(ctx),
-			AccountStore: &accounts.AccountStore{
-				Store: store.NewMemoryStore(),
-			},
+			CookieDomain:                 "localhost",
+			EventLog:                     newEventLog(ctx),
+			AccountStore:                 accounts.NewAccountStore(store.NewMemoryStore()),
 			SigningMethod:                jwt.SigningMethodHS256,
 			Key:                          generateKey(),
 			AccessTokenDuration:          1 * time.Hour,
@@ -150,6 +151,138 @@ func TestHandlers(t *testing.T) {
 	})
 }
 
+func TestPasswordGrant(t *testing.T) {
+	Convey("TestPasswordGrant", t, func() {
+		ctx := context.Background()
+		handlers := accounts.Handlers{
+			EventLog:            newEventLog(ctx),
+			AccountStore:        accounts.NewAccountStore(store.NewMemoryStore()),
+			SigningMethod:       jwt.SigningMethodHS256,
+			Key:                 generateKey(),
+			AccessTokenDuration: 1 * time.Hour,
+			UsedTokens:          accounts.NewUsedTokens(),
+			PasswordHashParams: accounts.PasswordHashParams{
+				Iterations: 1000,
+				KeyLength:  32,
+			},
+		}
+		go eventlog.Project(ctx, eventlog.ProjectInput{
+			EventLog:   handlers.EventLog,
+			FromCursor: 0,
+			Apply:      handlers.ApplyEvent,
+		})
+		router := mux.NewRouter()
+		handlers.AddRoutes(router.NewRoute().Subrouter())
+		server := httptest.NewServer(router)
+		defer server.Close()
+		getTokenFor := func(email string, password string) int {
+			response, err := server.Client().PostForm(server.URL+"/token", url.Values{
+				"grant_type": {"password"},
+				"username":   {email},
+				"password":   {password},
+			})
+			So(err, ShouldBeNil)
+			response.Body.Close()
+			return response.StatusCode
+		}
+		getToken := func(password string) int {
+			return getTokenFor("test@example.com", password)
+		}
+		getPasswordHash := func() string {
+			account, err := handlers.AccountStore.GetByEmail(ctx, "test@example.com")
+			So(err, ShouldBeNil)
+			return string(account.User.PasswordHash)
+		}
+		password := "passwordpasswordpassword"
+		salt := generateKey()
+		legacyHash := sha256.Sum256(append(append([]byte(nil), salt...), password...))
+		account := accounts.Account{
+			ID: "account-id",
+			User: &accounts.User{
+				ID:           "user-id",
+				Email:        "test@example.com",
+				PasswordSalt: salt,
+				PasswordHash: legacyHash[:],
+			},
+		}
+		_, err := handlers.EventLog.Append(ctx, eventlog.AppendInput{
+			Type:      accounts.EventTypeAccountCreated,
+			AccountID: account.ID,
+			Data:      fatal.UnlessMarshalJSON(account),
+		})
+		So(err, ShouldBeNil)
+		time.Sleep(10 * time.Millisecond)
+		So(getToken("wrong password"), ShouldEqual, http.StatusUnauthorized)
+		So(getTokenFor("unknown@example.com", password), ShouldEqual, http.StatusUnauthorized)
+		So(getPasswordHash(), ShouldEqual, string(legacyHash[:]))
+		Convey("a legacy hash is upgraded", func() {
+			So(getToken(password), ShouldEqual, http.StatusOK)
+			time.Sleep(10 * time.Millisecond)
+			So(getPasswordHash(), ShouldStartWith, "$pbkdf2-sha256$i=1000$")
+			So(getToken(password), ShouldEqual, http.StatusOK)
+			So(getToken("wrong password"), ShouldEqual, http.StatusUnauthorized)
+			Convey("raising the iterations upgrades it again", func() {
+				handlers.PasswordHashParams.Iterations = 2000
+				So(getToken(password), ShouldEqual, http.StatusOK)
+				time.Sleep(10 * time.Millisecond)
+				So(getPasswordHash(), ShouldStartWith, "$pbkdf2-sha256$i=2000$")
+			})
+		})
+		Convey("an upgrade of a hash that has been reset is ignored", func() {
+			_, err := handlers.EventLog.Append(ctx, eventlog.AppendInput{
+				Type:      accounts.EventTypeAccountPasswordReset,
+				AccountID: account.ID,
+				Data: fatal.UnlessMarshalJSON(accounts.PasswordResetData{
+					Email:        account.User.Email,
+					PasswordSalt: salt,
+					PasswordHash: []byte("reset"),
+				}),
+			})
+			So(err, ShouldBeNil)
+			_, err = handlers.EventLog.Append(ctx, eventlog.AppendInput{
+				Type:      accounts.EventTypeAccountPasswordHashUpgraded,
+				AccountID: account.ID,
+				Data: fatal.UnlessMarshalJSON(accounts.PasswordHashUpgradedData{
+					PreviousPasswordHash: legacyHash[:],
+					PasswordHash:         []byte("upgraded"),
+				}),
+			})
+			So(err, ShouldBeNil)
+			time.Sleep(10 * time.Millisecond)
+			So(getPasswordHash(), ShouldEqual, "reset")
+		})
+	})
+}
+
+// unreadableStore fails every read, as a store would on an I/O error.
+type unreadableStore struct {
+	*store.MemoryStore
+}
+
+func (unreadable unreadableStore) Get(ctx context.Context, key string) ([]byte, error) {
+	return nil, errors.New("unreadable")
+}
+
+func TestPasswordGrantStoreError(t *testing.T) {
+	Convey("TestPasswordGrantStoreError", t, func() {
+		handlers := accounts.Handlers{
+			AccountStore: accounts.NewAccountStore(unreadableStore{MemoryStore: store.NewMemoryStore()}),
+		}
+		router := mux.NewRouter()
+		handlers.AddRoutes(router.NewRoute().Subrouter())
+		server := httptest.NewServer(router)
+		defer server.Close()
+		response, err := server.Client().PostForm(server.URL+"/token", url.Values{
+			"grant_type": {"password"},
+			"username":   {"test@example.com"},
+			"password":   {"password"},
+		})
+		So(err, ShouldBeNil)
+		response.Body.Close()
+		So(response.StatusCode, ShouldEqual, http.StatusInternalServerError)
+	})
+}
+
 func generateKey() (key []byte) {
 	key = make([]byte, 32)
 	_, err := io.ReadFull(rand.Reader, key)
@@ -0,0 +1,119 @@
+package accounts
+
+import (
+	"bytes"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+)
+
+// PasswordHashParams tunes PBKDF2-HMAC-SHA256. Each hash records the
+// parameters it was made with, so they can be raised without invalidating
+// existing hashes, which are upgraded on the next login.
+type PasswordHashParams struct {
+	Iterations int
+	KeyLength  int
+}
+
+// DefaultPasswordHashParams follows the OWASP recommendation for
+// PBKDF2-HMAC-SHA256.
+var DefaultPasswordHashParams = PasswordHashParams{
+	Iterations: 600000,
+	KeyLength:  32,
+}
+
+func (params PasswordHashParams) orDefault() PasswordHashParams {
+	if params == (PasswordHashParams{}) {
+		return DefaultPasswordHashParams
+	}
+	return params
+}
+
+const pbkdf2SHA256Prefix = "$pbkdf2-sha256$"
+
+// hashPassword returns $pbkdf2-sha256$i=<iterations>$<base64 key>. Hashes
+// without the prefix are SHA-256 over the salt and password.
+func hashPassword(password string, salt []byte, params PasswordHashParams) (passwordHash []byte) {
+	params = params.orDefault()
+	key, err := pbkdf2.Key(sha256.New, password, salt, params.Iterations, params.KeyLength)
+	fatal.OnError(err)
+	return []byte(pbkdf2SHA256Prefix + "i=" + strconv.Itoa(params.Iterations) + "$" + base64.RawStdEncoding.EncodeToString(key))
+}
+
+// verifyPassword reports whether password matches passwordHash, and whether
+// passwordHash is weaker than one made with params and should be replaced.
+func verifyPassword(password string, salt []byte, passwordHash []byte, params PasswordHashParams) (ok bool, needsRehash bool) {
+	params = params.orDefault()
+	encoded, isPBKDF2 := bytes.CutPrefix(passwordHash, []byte(pbkdf2SHA256Prefix))
+	if !isPBKDF2 {
+		ok = subtle.ConstantTimeCompare(calculateLegacyPasswordHash(password, salt), passwordHash) == 1
+		return ok, true
+	}
+	iterations, expectedKey, valid := parsePBKDF2SHA256(string(encoded))
+	if !valid {
+		return false, false
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(expectedKey))
+	if err != nil {
+		return false, false
+	}
+	ok = subtle.ConstantTimeCompare(key, expectedKey) == 1
+	needsRehash = iterations < params.Iterations || len(expectedKey) < params.KeyLength
+	return
+}
+
+// dummyPasswordHashes holds a hash for each PasswordHashParams, to verify
+// passwords against when there is no account, so that a login takes as long
+// whether or not the email has an account.
+var dummyPasswordHashes sync.Map
+
+var dummyPasswordSalt = make([]byte, 32)
+
+// verifyDummyPassword does the work of verifyPassword for an email without an
+// account.
+func verifyDummyPassword(password string, params PasswordHashParams) {
+	params = params.orDefault()
+	dummyPasswordHash, ok := dummyPasswordHashes.Load(params)
+	if !ok {
+		dummyPasswordHash, _ = dummyPasswordHashes.LoadOrStore(params, hashPassword("", dummyPasswordSalt, params))
+	}
+	verifyPassword(password, dummyPasswordSalt, dummyPasswordHash.([]byte), params)
+}
+
+func parsePBKDF2SHA256(encoded string) (iterations int, key []byte, valid bool) {
+	encodedIterations, encodedKey, found := strings.Cut(encoded, "$")
+	if !found {
+		return
+	}
+	encodedIterations, found = strings.CutPrefix(encodedIterations, "i=")
+	if !found {
+		return
+	}
+	iterations, err := strconv.Atoi(encodedIterations)
+	if err != nil || iterations < 1 {
+		return
+	}
+	key, err = base64.RawStdEncoding.DecodeString(encodedKey)
+	if err != nil || len(key) == 0 {
+		return
+	}
+	return iterations, key, true
+}
+
+func calculateLegacyPasswordHash(password string, salt []byte) (passwordHash []byte) {
+	var err error
+	hash := sha256.New()
+	_, err = hash.Write(salt)
+	fatal.OnError(err)
+	_, err = io.WriteString(hash, password)
+	fatal.OnError(err)
+	passwordHash = hash.Sum(nil)
+	return
+}
@@ -1,31 +1,58 @@
 package accounts
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
+
+	"github.com/ansel1/merry"
 
 	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
-	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventschema"
 )
 
 const EventTypeAccountCreated = "account.created"
 const EventTypeAccountPasswordReset = "account.password_reset"
 
-func (handlers *Handlers) ApplyEvent(ctx context.Context, event *eventlog.Event) {
+// EventTypeAccountPasswordHashUpgraded replaces the password hash of the
+// account with a stronger hash of the same password.
+const EventTypeAccountPasswordHashUpgraded = "account.password_hash_upgraded"
+
+// EventTypeAccountDeleted is appended with the account's ID as its AccountID.
+// Every projection that keeps per-account state drops it on this event.
+const EventTypeAccountDeleted = "account.deleted"
+
+type AccountDeletedData struct{}
+
+func init() {
+	eventschema.Register[Account](eventschema.Default, EventTypeAccountCreated, 1)
+	eventschema.Register[PasswordResetData](eventschema.Default, EventTypeAccountPasswordReset, 1)
+	eventschema.Register[PasswordHashUpgradedData](eventschema.Default, EventTypeAccountPasswordHashUpgraded, 1)
+	eventschema.Register[AccountDeletedData](eventschema.Default, EventTypeAccountDeleted, 1)
+}
+
+func (handlers *Handlers) ApplyEvent(ctx context.Context, event *eventlog.Event) (err error) {
 	switch event.Type {
 	case EventTypeAccountCreated:
-		handlers.ApplyAccountCreatedEvent(ctx, event)
+		err = handlers.ApplyAccountCreatedEvent(ctx, event)
 	case EventTypeAccountPasswordReset:
-		handlers.ApplyAccountPasswordResetEvent(ctx, event)
+		err = handlers.ApplyAccountPasswordResetEvent(ctx, event)
+	case EventTypeAccountPasswordHashUpgraded:
+		err = handlers.ApplyAccountPasswordHashUpgradedEvent(ctx, event)
+	case EventTypeAccountDeleted:
+		err = handlers.ApplyAccountDeletedEvent(ctx, event)
 	}
+	if err != nil {
+		return
+	}
+	return handlers.AccountStore.PutCursor(ctx, event.LogicalClock)
 }
 
-func (handlers *Handlers) ApplyAccountCreatedEvent(ctx context.Context, event *eventlog.Event) {
-	var account Account
-	err := json.Unmarshal(event.Data, &account)
-	fatal.OnError(err)
-	err = handlers.AccountStore.Put(ctx, &account)
-	fatal.OnError(err)
+func (handlers *Handlers) ApplyAccountCreatedEvent(ctx context.Context, event *eventlog.Event) (err error) {
+	account, err := eventschema.DecodeEvent[Account](event)
+	if err != nil {
+		return
+	}
+	return handlers.AccountStore.Put(ctx, &account)
 }
 
 type PasswordResetData struct {
@@ -34,14 +61,57 @@ type PasswordResetData struct {
 	PasswordHash []byte `json:"password_hash"`
 }
 
-func (handlers *Handlers) ApplyAccountPasswordResetEvent(ctx context.Context, event *eventlog.Event) {
-	var data PasswordResetData
-	err := json.Unmarshal(event.Data, &data)
-	fatal.OnError(err)
-	err = handlers.AccountStore.UpdatePassword(ctx, &UpdatePasswordInput{
+func (handlers *Handlers) ApplyAccountPasswordResetEvent(ctx context.Context, event *eventlog.Event) (err error) {
+	data, err := eventschema.DecodeEvent[PasswordResetData](event)
+	if err != nil {
+		return
+	}
+	return handlers.AccountStore.UpdatePassword(ctx, &UpdatePasswordInput{
 		Email:        data.Email,
 		PasswordSalt: data.PasswordSalt,
 		PasswordHash: data.PasswordHash,
 	})
-	fatal.OnError(err)
+}
+
+type PasswordHashUpgradedData struct {
+	PreviousPasswordHash []byte `json:"previous_password_hash"`
+	PasswordHash         []byte `json:"password_hash"`
+}
+
+// ApplyAccountPasswordHashUpgradedEvent ignores an upgrade of a hash that has
+// since been replaced, such as by a password reset.
+func (handlers *Handlers) ApplyAccountPasswordHashUpgradedEvent(ctx context.Context, event *eventlog.Event) (err error) {
+	data, err := eventschema.DecodeEvent[PasswordHashUpgradedData](event)
+	if err != nil {
+		return
+	}
+	account, err := handlers.AccountStore.Get(ctx, event.AccountID)
+	if merry.Is(err, ErrAccountNotFound) {
+		return nil
+	}
+	if err != nil {
+		return
+	}
+	if !bytes.Equal(account.User.PasswordHash, data.PreviousPasswordHash) {
+		return
+	}
+	account.User.PasswordHash = data.PasswordHash
+	return handlers.AccountStore.Put(ctx, account)
+}
+
+// ApplyAccountDeletedEvent removes the account. Refresh tokens are only
+// honoured for accounts in the store, so this also invalidates them. It then
+// erases the account's events if the event log supports it. Both steps are
+// repeated when the event is applied again, so an erase that did not finish
+// is finished on replay.
+func (handlers *Handlers) ApplyAccountDeletedEvent(ctx context.Context, event *eventlog.Event) (err error) {
+	err = handlers.AccountStore.Remove(ctx, event.AccountID)
+	if err != nil && !merry.Is(err, ErrAccountNotFound) {
+		return
+	}
+	eraser, ok := handlers.EventLog.(AccountEraser)
+	if !ok {
+		return nil
+	}
+	return eraser.EraseAccount(ctx, event.AccountID)
 }
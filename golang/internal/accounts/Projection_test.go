@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ansel1/merry"
 	uuid "github.com/satori/go.uuid"
 	. "github.com/smartystreets/goconvey/convey"
 
@@ -19,10 +20,8 @@ func TestProjection(t *testing.T) {
 	Convey("Projection", t, func() {
 		ctx := context.Background()
 		handlers := accounts.Handlers{
-			EventLog: newEventLog(ctx),
-			AccountStore: &accounts.AccountStore{
-				Store: store.NewMemoryStore(),
-			},
+			EventLog:     newEventLog(ctx),
+			AccountStore: accounts.NewAccountStore(store.NewMemoryStore()),
 		}
 		go eventlog.Project(ctx, eventlog.ProjectInput{
 			EventLog:   handlers.EventLog,
@@ -51,6 +50,35 @@ func TestProjection(t *testing.T) {
 			So(account, ShouldResemble, &expectedAccount)
 		})
 
+		Convey("delete account", func() {
+			account := accounts.Account{
+				ID: uuid.NewV4().String(),
+				User: accounts.NewUser(&accounts.NewUserInput{
+					Email:    "test@example.com",
+					Password: uuid.NewV4().String(),
+				}),
+			}
+			data, err := json.Marshal(&account)
+			So(err, ShouldBeNil)
+			_, err = handlers.EventLog.Append(ctx, eventlog.AppendInput{
+				Type:      accounts.EventTypeAccountCreated,
+				AccountID: account.ID,
+				Data:      data,
+			})
+			So(err, ShouldBeNil)
+			_, err = handlers.EventLog.Append(ctx, eventlog.AppendInput{
+				Type:      accounts.EventTypeAccountDeleted,
+				AccountID: account.ID,
+				Data:      []byte("{}"),
+			})
+			So(err, ShouldBeNil)
+			time.Sleep(10 * time.Millisecond)
+			_, err = handlers.AccountStore.Get(ctx, account.ID)
+			So(merry.Is(err, accounts.ErrAccountNotFound), ShouldBeTrue)
+			_, err = handlers.AccountStore.GetByEmail(ctx, account.User.Email)
+			So(merry.Is(err, accounts.ErrAccountNotFound), ShouldBeTrue)
+		})
+
 		Convey("reset password", func() {
 			// First create an account
 			user := accounts.NewUser(&accounts.NewUserInput{
@@ -96,10 +124,122 @@ func TestProjection(t *testing.T) {
 	})
 }
 
+func TestProjectionPersistentStore(t *testing.T) {
+	Convey("Projection with a persistent store", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		folderPath, err := os.MkdirTemp("testdata", "accounts-*")
+		So(err, ShouldBeNil)
+		newAccountStore := func() *accounts.AccountStore {
+			return accounts.NewAccountStore(store.NewEncryptingDecorator(&store.NewEncryptingDecoratorInput{
+				Store: store.NewFileSystemStore(&store.NewFileSystemStoreInput{
+					Root: folderPath,
+				}),
+				Key: []byte("0123456789abcdef"),
+			}))
+		}
+		handlers := accounts.Handlers{
+			EventLog:     newEventLog(ctx),
+			AccountStore: newAccountStore(),
+		}
+		cursor, err := handlers.AccountStore.GetCursor(ctx)
+		So(err, ShouldBeNil)
+		So(cursor, ShouldEqual, 0)
+		go eventlog.Project(ctx, eventlog.ProjectInput{
+			EventLog:   handlers.EventLog,
+			FromCursor: cursor,
+			Apply:      handlers.ApplyEvent,
+		})
+		account := accounts.Account{
+			ID: uuid.NewV4().String(),
+			User: accounts.NewUser(&accounts.NewUserInput{
+				Email:    "test@example.com",
+				Password: uuid.NewV4().String(),
+			}),
+		}
+		data, err := json.Marshal(&account)
+		So(err, ShouldBeNil)
+		event, err := handlers.EventLog.Append(ctx, eventlog.AppendInput{
+			Type: accounts.EventTypeAccountCreated,
+			Data: data,
+		})
+		So(err, ShouldBeNil)
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+		Convey("restart", func() {
+			accountStore := newAccountStore()
+			cursor, err := accountStore.GetCursor(context.Background())
+			So(err, ShouldBeNil)
+			So(cursor, ShouldEqual, event.LogicalClock)
+			restoredAccount, err := accountStore.GetByEmail(context.Background(), account.User.Email)
+			So(err, ShouldBeNil)
+			So(restoredAccount, ShouldResemble, &account)
+		})
+	})
+}
+
+func TestProjectionErasesDeletedAccount(t *testing.T) {
+	Convey("TestProjectionErasesDeletedAccount", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		decorator := eventlog.NewEncryptingDecorator(eventlog.NewEncryptingDecoratorInput{
+			Decorated: newEventLog(ctx),
+			DataKeys: eventlog.NewDataKeys(eventlog.NewDataKeysInput{
+				Store:     store.NewMemoryStore(),
+				MasterKey: []byte("0123456789abcdef0123456789abcdef"),
+			}),
+			Types: []string{accounts.EventTypeAccountCreated},
+		})
+		account := accounts.Account{
+			ID: uuid.NewV4().String(),
+			User: accounts.NewUser(&accounts.NewUserInput{
+				Email:    "test@example.com",
+				Password: uuid.NewV4().String(),
+			}),
+		}
+		data, err := json.Marshal(&account)
+		So(err, ShouldBeNil)
+		_, err = decorator.Append(ctx, eventlog.AppendInput{
+			Type:      accounts.EventTypeAccountCreated,
+			AccountID: account.ID,
+			Data:      data,
+		})
+		So(err, ShouldBeNil)
+		_, err = decorator.Append(ctx, eventlog.AppendInput{
+			Type:      accounts.EventTypeAccountDeleted,
+			AccountID: account.ID,
+			Data:      []byte("{}"),
+		})
+		So(err, ShouldBeNil)
+		countAccountEvents := func() (count int) {
+			iterator := decorator.GetEventIterator(ctx, eventlog.GetEventIteratorInput{
+				AccountID: account.ID,
+			})
+			for iterator.Next(ctx) {
+				count++
+			}
+			So(iterator.Err(), ShouldBeNil)
+			return
+		}
+		So(countAccountEvents(), ShouldEqual, 2)
+		handlers := accounts.Handlers{
+			EventLog:     decorator,
+			AccountStore: accounts.NewAccountStore(store.NewMemoryStore()),
+		}
+		go eventlog.Project(ctx, eventlog.ProjectInput{
+			EventLog:   handlers.EventLog,
+			FromCursor: 0,
+			Apply:      handlers.ApplyEvent,
+		})
+		time.Sleep(10 * time.Millisecond)
+		So(countAccountEvents(), ShouldEqual, 1)
+	})
+}
+
 func newEventLog(ctx context.Context) eventlog.EventLog {
 	folderPath, err := os.MkdirTemp("testdata", "eventlog-*")
 	So(err, ShouldBeNil)
-	return eventlog.NewFileEventLog(&eventlog.NewFileEventLogInput{
+	return eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
 		FolderPath: folderPath,
 	})
 }
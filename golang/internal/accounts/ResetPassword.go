@@ -129,15 +129,22 @@ func (handlers *Handlers) ResetPassword(responseWriter http.ResponseWriter, requ
 			log.Println("Error generating salt:", err)
 			return
 		}
-		passwordHash := calculatePasswordHash(input.Password, salt)
+		var account *Account
+		account, err = handlers.AccountStore.GetByEmail(ctx, email)
+		if err != nil {
+			log.Println("Error finding account:", err)
+			return
+		}
+		passwordHash := hashPassword(input.Password, salt, handlers.PasswordHashParams)
 		payload := PasswordResetData{
 			Email:        email,
 			PasswordSalt: salt,
 			PasswordHash: passwordHash,
 		}
 		_, err = handlers.EventLog.Append(ctx, eventlog.AppendInput{
-			Type: EventTypeAccountPasswordReset,
-			Data: fatal.UnlessMarshalJSON(payload),
+			Type:      EventTypeAccountPasswordReset,
+			AccountID: account.ID,
+			Data:      fatal.UnlessMarshalJSON(payload),
 		})
 		fatal.OnError(err)
 	})
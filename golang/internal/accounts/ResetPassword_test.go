@@ -28,10 +28,8 @@ func TestResetPassword(t *testing.T) {
 		email := "test@example.com"
 		mailer := new(MockPasswordResetMailer)
 		handlers := accounts.Handlers{
-			EventLog: newEventLog(ctx),
-			AccountStore: &accounts.AccountStore{
-				Store: store.NewMemoryStore(),
-			},
+			EventLog:                     newEventLog(ctx),
+			AccountStore:                 accounts.NewAccountStore(store.NewMemoryStore()),
 			SigningMethod:                jwt.SigningMethodHS256,
 			Key:                          generateKey(),
 			UsedTokens:                   accounts.NewUsedTokens(),
@@ -2,14 +2,16 @@ package babystationlist
 
 import (
 	"context"
-	"encoding/json"
+	"sync"
 	"time"
 
+	"github.com/Ryan-A-B/beddybytes/golang/internal/accounts"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/connections"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/contextx"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
-	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventschema"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/logx"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/sessions"
 )
 
 type Snapshot struct {
@@ -59,6 +61,7 @@ type BabyStationConnection struct {
 }
 
 type BabyStationList struct {
+	mutex               sync.Mutex
 	eventLog            eventlog.EventLog
 	cursor              int64
 	snapshotByAccountID map[string]*Snapshot
@@ -81,8 +84,13 @@ type GetSnapshotOutput struct {
 	*Snapshot
 }
 
+// GetSnapshot returns an error, such as eventlog.ErrCanceled when ctx is
+// done, if it can't catch up with the log.
 func (babyStationList *BabyStationList) GetSnapshot(ctx context.Context) (output GetSnapshotOutput, err error) {
-	babyStationList.catchup(ctx)
+	err = babyStationList.catchup(ctx)
+	if err != nil {
+		return
+	}
 	output.Cursor = babyStationList.cursor
 	accountID := contextx.GetAccountID(ctx)
 	snapshot, ok := babyStationList.snapshotByAccountID[accountID]
@@ -99,42 +107,63 @@ type Connection struct {
 	RequestID string `json:"request_id"`
 }
 
-func (babyStationList *BabyStationList) catchup(ctx context.Context) {
+// catchup applies the events since the cursor, stopping at the first error
+// from the log or from apply, so that the events from there are applied by
+// the next call.
+func (babyStationList *BabyStationList) catchup(ctx context.Context) (err error) {
+	babyStationList.mutex.Lock()
+	defer babyStationList.mutex.Unlock()
 	eventIterator := babyStationList.eventLog.GetEventIterator(ctx, eventlog.GetEventIteratorInput{
 		FromCursor: babyStationList.cursor,
+		Types:      eventTypes,
 	})
 	for eventIterator.Next(ctx) {
 		event := eventIterator.Event()
-		babyStationList.apply(event)
+		err = babyStationList.apply(event)
+		if err != nil {
+			return
+		}
 		babyStationList.cursor = event.LogicalClock
 	}
-	err := eventIterator.Err()
-	if err != nil {
-		panic(err)
-	}
+	return eventIterator.Err()
+}
+
+// eventTypes lists the types handled by apply.
+var eventTypes = []string{
+	sessions.EventTypeStarted,
+	sessions.EventTypeEnded,
+	connections.EventTypeConnected,
+	connections.EventTypeDisconnected,
+	connections.EventTypeReconnectTimeout,
+	eventschema.EventTypeServerStarted,
+	accounts.EventTypeAccountDeleted,
 }
 
-func (babyStationList *BabyStationList) apply(event *eventlog.Event) {
+func (babyStationList *BabyStationList) apply(event *eventlog.Event) (err error) {
 	switch event.Type {
-	case EventTypeSessionStarted:
-		babyStationList.applySessionStarted(event)
-	case EventTypeSessionEnded:
-		babyStationList.applySessionEnded(event)
+	case sessions.EventTypeStarted:
+		err = babyStationList.applySessionStarted(event)
+	case sessions.EventTypeEnded:
+		err = babyStationList.applySessionEnded(event)
 	case connections.EventTypeConnected:
-		babyStationList.applyConnected(event)
+		err = babyStationList.applyConnected(event)
 	case connections.EventTypeDisconnected:
-		babyStationList.applyDisconnected(event)
+		err = babyStationList.applyDisconnected(event)
 	case connections.EventTypeReconnectTimeout:
-		babyStationList.applyReconnectTimeout(event)
-	case EventTypeServerStarted:
+		err = babyStationList.applyReconnectTimeout(event)
+	case eventschema.EventTypeServerStarted:
 		babyStationList.applyServerStarted()
+	case accounts.EventTypeAccountDeleted:
+		delete(babyStationList.snapshotByAccountID, event.AccountID)
 	}
+	return
 }
 
-func (babyStationList *BabyStationList) applySessionStarted(event *eventlog.Event) {
-	var data StartSessionEventData
-	err := json.Unmarshal(event.Data, &data)
-	fatal.OnError(err)
+func (babyStationList *BabyStationList) applySessionStarted(event *eventlog.Event) error {
+	data, err := eventschema.DecodeEvent[sessions.EventStarted](event)
+	if err != nil {
+		return err
+	}
 	session := Session{
 		AccountID:        event.AccountID,
 		ID:               data.ID,
@@ -145,17 +174,23 @@ func (babyStationList *BabyStationList) applySessionStarted(event *eventlog.Even
 	snapshot := babyStationList.getOrCreateSnapshot(event.AccountID)
 	snapshot.SessionByID[data.ID] = &session
 	snapshot.SessionIDByConnectionID[data.HostConnectionID] = data.ID
+	return nil
 }
 
-func (babyStationList *BabyStationList) applySessionEnded(event *eventlog.Event) {
-	var data EndSessionEventData
-	err := json.Unmarshal(event.Data, &data)
-	fatal.OnError(err)
+func (babyStationList *BabyStationList) applySessionEnded(event *eventlog.Event) error {
+	data, err := eventschema.DecodeEvent[sessions.EventEnded](event)
+	if err != nil {
+		return err
+	}
 	babyStationList.deleteSession(event.AccountID, data.ID)
+	return nil
 }
 
 func (babyStationList *BabyStationList) deleteSession(accountID string, sessionID string) {
-	snapshot := babyStationList.getOrCreateSnapshot(accountID)
+	snapshot, ok := babyStationList.snapshotByAccountID[accountID]
+	if !ok {
+		return
+	}
 	session, ok := snapshot.SessionByID[sessionID]
 	if !ok {
 		return
@@ -163,10 +198,11 @@ func (babyStationList *BabyStationList) deleteSession(accountID string, sessionI
 	snapshot.deleteSession(session)
 }
 
-func (babyStationList *BabyStationList) applyConnected(event *eventlog.Event) {
-	var data connections.EventConnected
-	err := json.Unmarshal(event.Data, &data)
-	fatal.OnError(err)
+func (babyStationList *BabyStationList) applyConnected(event *eventlog.Event) error {
+	data, err := eventschema.DecodeEvent[connections.EventConnected](event)
+	if err != nil {
+		return err
+	}
 	snapshot := babyStationList.getOrCreateSnapshot(event.AccountID)
 	disconnectedSession, reconnectingSameSession := snapshot.DisconnectedSessionByConnectionID[data.ConnectionID]
 	if reconnectingSameSession && disconnectedSession.ClientID == data.ClientID {
@@ -180,13 +216,18 @@ func (babyStationList *BabyStationList) applyConnected(event *eventlog.Event) {
 		RequestID: data.RequestID,
 	}
 	snapshot.ConnectionByID[data.ConnectionID] = &connection
+	return nil
 }
 
-func (babyStationList *BabyStationList) applyDisconnected(event *eventlog.Event) {
-	var data connections.EventDisconnected
-	err := json.Unmarshal(event.Data, &data)
-	fatal.OnError(err)
-	snapshot := babyStationList.getOrCreateSnapshot(event.AccountID)
+func (babyStationList *BabyStationList) applyDisconnected(event *eventlog.Event) error {
+	data, err := eventschema.DecodeEvent[connections.EventDisconnected](event)
+	if err != nil {
+		return err
+	}
+	snapshot, ok := babyStationList.snapshotByAccountID[event.AccountID]
+	if !ok {
+		return nil
+	}
 	connection, ok := snapshot.ConnectionByID[data.ConnectionID]
 	if ok && connection.RequestID == data.RequestID {
 		if sessionID, ok := snapshot.SessionIDByConnectionID[data.ConnectionID]; ok {
@@ -203,14 +244,20 @@ func (babyStationList *BabyStationList) applyDisconnected(event *eventlog.Event)
 		}
 	}
 	delete(snapshot.ConnectionByID, data.ConnectionID)
+	return nil
 }
 
-func (babyStationList *BabyStationList) applyReconnectTimeout(event *eventlog.Event) {
-	var data connections.EventReconnectTimeout
-	err := json.Unmarshal(event.Data, &data)
-	fatal.OnError(err)
-	snapshot := babyStationList.getOrCreateSnapshot(event.AccountID)
+func (babyStationList *BabyStationList) applyReconnectTimeout(event *eventlog.Event) error {
+	data, err := eventschema.DecodeEvent[connections.EventReconnectTimeout](event)
+	if err != nil {
+		return err
+	}
+	snapshot, ok := babyStationList.snapshotByAccountID[event.AccountID]
+	if !ok {
+		return nil
+	}
 	snapshot.deleteSessionsAndConnectionsForClient(data.ClientID)
+	return nil
 }
 
 func (babyStationList *BabyStationList) applyServerStarted() {
@@ -270,21 +317,6 @@ func (babystationlist *BabyStationList) createSnapshot() *Snapshot {
 	}
 }
 
-// Copied from golang/cmd/backend/sessions.go
-const EventTypeSessionStarted = "session.started"
-const EventTypeSessionEnded = "session.ended"
-
-type StartSessionEventData struct {
-	ID               string    `json:"id"`
-	Name             string    `json:"name"`
-	HostConnectionID string    `json:"host_connection_id"`
-	StartedAt        time.Time `json:"started_at"`
-}
-
-type EndSessionEventData struct {
-	ID string `json:"id"`
-}
-
 type Session struct {
 	AccountID        string    `json:"-"`
 	ID               string    `json:"id"`
@@ -298,6 +330,3 @@ type DisconnectedSession struct {
 	SessionID    string `json:"session_id"`
 	ConnectionID string `json:"connection_id"`
 }
-
-// Copied from golang/cmd/backend/server.go
-const EventTypeServerStarted = "server.started"
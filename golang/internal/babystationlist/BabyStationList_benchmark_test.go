@@ -13,7 +13,7 @@ import (
 func BenchmarkBabyStationListRealData(b *testing.B) {
 	ctx := context.Background()
 	eventLogPath := findRealEventLogPath(b)
-	log := eventlog.NewFileEventLog(&eventlog.NewFileEventLogInput{
+	log := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
 		FolderPath: eventLogPath,
 	})
 	b.ReportAllocs()
@@ -22,7 +22,9 @@ func BenchmarkBabyStationListRealData(b *testing.B) {
 		babyStationList := New(NewInput{
 			EventLog: log,
 		})
-		babyStationList.catchup(ctx)
+		if err := babyStationList.catchup(ctx); err != nil {
+			b.Fatal(err)
+		}
 	}
 	b.StopTimer()
 
@@ -33,7 +35,9 @@ func BenchmarkBabyStationListRealData(b *testing.B) {
 	babyStationList := New(NewInput{
 		EventLog: log,
 	})
-	babyStationList.catchup(ctx)
+	if err := babyStationList.catchup(ctx); err != nil {
+		b.Fatal(err)
+	}
 
 	runtime.GC()
 	var after runtime.MemStats
@@ -66,8 +70,8 @@ func findRealEventLogPath(tb testing.TB) string {
 		goModPath := filepath.Join(dir, "go.mod")
 		if _, err := os.Stat(goModPath); err == nil {
 			eventLogPath := filepath.Join(dir, "eventlog")
-			eventsPath := filepath.Join(eventLogPath, eventlog.EventsFileName)
-			if _, err := os.Stat(eventsPath); err == nil {
+			metadataPath := filepath.Join(eventLogPath, eventlog.MetadataFileName)
+			if _, err := os.Stat(metadataPath); err == nil {
 				return eventLogPath
 			}
 			tb.Skipf("real eventlog not found at %s", eventLogPath)
@@ -8,11 +8,15 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Ryan-A-B/beddybytes/golang/internal/accounts"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/babystationlist"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/connections"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/contextx"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventschema"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/sessions"
+	"github.com/ansel1/merry"
 	uuid "github.com/satori/go.uuid"
 	. "github.com/smartystreets/goconvey/convey"
 )
@@ -24,7 +28,7 @@ func TestBabyStationList(t *testing.T) {
 		ctx = contextx.WithAccountID(ctx, accountID)
 		folderPath, err := os.MkdirTemp("testdata", "TestBabyStationList-*")
 		So(err, ShouldBeNil)
-		eventLog := eventlog.NewFileEventLog(&eventlog.NewFileEventLogInput{
+		eventLog := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
 			FolderPath: folderPath,
 		})
 		babyStationList := babystationlist.New(babystationlist.NewInput{
@@ -36,6 +40,26 @@ func TestBabyStationList(t *testing.T) {
 			So(output.Snapshot.List(), ShouldHaveLength, 0)
 			So(output.Cursor, ShouldEqual, 0)
 		})
+		Convey("A canceled request returns an error rather than panicking", func() {
+			_, err := eventLog.Append(ctx, eventlog.AppendInput{
+				Type:      sessions.EventTypeStarted,
+				AccountID: accountID,
+				Data: fatal.UnlessMarshalJSON(sessions.EventStarted{
+					ID:               uuid.NewV4().String(),
+					Name:             "test",
+					HostConnectionID: uuid.NewV4().String(),
+					StartedAt:        time.Now(),
+				}),
+			})
+			So(err, ShouldBeNil)
+			canceledCtx, cancel := context.WithCancel(ctx)
+			cancel()
+			_, err = babyStationList.GetSnapshot(canceledCtx)
+			So(merry.Is(err, eventlog.ErrCanceled), ShouldBeTrue)
+			output, err := babyStationList.GetSnapshot(ctx)
+			So(err, ShouldBeNil)
+			So(output.Cursor, ShouldEqual, 1)
+		})
 		Convey("Snapshot list skips connections without matching sessions", func() {
 			connectionID := uuid.NewV4().String()
 			snapshot := &babystationlist.Snapshot{
@@ -58,14 +82,14 @@ func TestBabyStationList(t *testing.T) {
 			requestID := uuid.NewV4().String()
 			sessionName := "Test Session"
 			sessionStartedAt := time.Now()
-			session := babystationlist.StartSessionEventData{
+			session := sessions.EventStarted{
 				ID:               uuid.NewV4().String(),
 				Name:             sessionName,
 				HostConnectionID: connectionID,
 				StartedAt:        sessionStartedAt,
 			}
 			_, err = eventLog.Append(ctx, eventlog.AppendInput{
-				Type:      babystationlist.EventTypeSessionStarted,
+				Type:      sessions.EventTypeStarted,
 				AccountID: accountID,
 				Data:      fatal.UnlessMarshalJSON(session),
 			})
@@ -144,7 +168,7 @@ func TestBabyStationList(t *testing.T) {
 				})
 				Convey("When the server restarts the baby station list should be empty", func() {
 					_, err = eventLog.Append(ctx, eventlog.AppendInput{
-						Type:      babystationlist.EventTypeServerStarted,
+						Type:      eventschema.EventTypeServerStarted,
 						AccountID: accountID,
 						Data:      nil,
 					})
@@ -157,6 +181,20 @@ func TestBabyStationList(t *testing.T) {
 					So(output.Snapshot.List(), ShouldHaveLength, 0)
 					So(output.Cursor, ShouldEqual, 3)
 				})
+				Convey("When the account is deleted the baby station list should be empty", func() {
+					_, err = eventLog.Append(ctx, eventlog.AppendInput{
+						Type:      accounts.EventTypeAccountDeleted,
+						AccountID: accountID,
+						Data:      []byte("{}"),
+					})
+					So(err, ShouldBeNil)
+					output, err = babyStationList.GetSnapshot(ctx)
+					So(err, ShouldBeNil)
+					So(output.Snapshot.SessionByID, ShouldHaveLength, 0)
+					So(output.Snapshot.ConnectionByID, ShouldHaveLength, 0)
+					So(output.Snapshot.List(), ShouldHaveLength, 0)
+					So(output.Cursor, ShouldEqual, 3)
+				})
 				Convey("Baby station should not be listed for another account", func() {
 					otherAccountID := uuid.NewV4().String()
 					otherSessionList := babystationlist.New(babystationlist.NewInput{
@@ -179,14 +217,14 @@ func TestBabyStationList(t *testing.T) {
 			connectionID1 := uuid.NewV4().String()
 			sessionName1 := "Test Session 1"
 			sessionStartedAt1 := time.Now()
-			session1 := babystationlist.StartSessionEventData{
+			session1 := sessions.EventStarted{
 				ID:               uuid.NewV4().String(),
 				Name:             sessionName1,
 				HostConnectionID: connectionID1,
 				StartedAt:        sessionStartedAt1,
 			}
 			_, err := eventLog.Append(ctx, eventlog.AppendInput{
-				Type:      babystationlist.EventTypeSessionStarted,
+				Type:      sessions.EventTypeStarted,
 				AccountID: accountID,
 				Data:      fatal.UnlessMarshalJSON(session1),
 			})
@@ -197,14 +235,14 @@ func TestBabyStationList(t *testing.T) {
 			sessionID2 := uuid.NewV4().String()
 			sessionName2 := "Test Session 2"
 			sessionStartedAt2 := time.Now()
-			session2 := babystationlist.StartSessionEventData{
+			session2 := sessions.EventStarted{
 				ID:               sessionID2,
 				Name:             sessionName2,
 				HostConnectionID: connectionID2,
 				StartedAt:        sessionStartedAt2,
 			}
 			_, err = eventLog.Append(ctx, eventlog.AppendInput{
-				Type:      babystationlist.EventTypeSessionStarted,
+				Type:      sessions.EventTypeStarted,
 				AccountID: accountID,
 				Data:      fatal.UnlessMarshalJSON(session2),
 			})
@@ -265,14 +303,14 @@ func TestBabyStationList(t *testing.T) {
 			clientID := uuid.NewV4().String()
 			connectionID := uuid.NewV4().String()
 			requestID := uuid.NewV4().String()
-			session := babystationlist.StartSessionEventData{
+			session := sessions.EventStarted{
 				ID:               uuid.NewV4().String(),
 				Name:             "clean disconnect session",
 				HostConnectionID: connectionID,
 				StartedAt:        time.Now(),
 			}
 			_, err := eventLog.Append(ctx, eventlog.AppendInput{
-				Type:      babystationlist.EventTypeSessionStarted,
+				Type:      sessions.EventTypeStarted,
 				AccountID: accountID,
 				Data:      fatal.UnlessMarshalJSON(session),
 			})
@@ -318,14 +356,14 @@ func TestBabyStationList(t *testing.T) {
 			clientID := uuid.NewV4().String()
 			connectionID := uuid.NewV4().String()
 			requestID := uuid.NewV4().String()
-			session := babystationlist.StartSessionEventData{
+			session := sessions.EventStarted{
 				ID:               uuid.NewV4().String(),
 				Name:             "timeout session",
 				HostConnectionID: connectionID,
 				StartedAt:        time.Now(),
 			}
 			_, err := eventLog.Append(ctx, eventlog.AppendInput{
-				Type:      babystationlist.EventTypeSessionStarted,
+				Type:      sessions.EventTypeStarted,
 				AccountID: accountID,
 				Data:      fatal.UnlessMarshalJSON(session),
 			})
@@ -381,14 +419,14 @@ func TestBabyStationList(t *testing.T) {
 			clientID := uuid.NewV4().String()
 			oldConnectionID := uuid.NewV4().String()
 			oldRequestID := uuid.NewV4().String()
-			oldSession := babystationlist.StartSessionEventData{
+			oldSession := sessions.EventStarted{
 				ID:               uuid.NewV4().String(),
 				Name:             "old session",
 				HostConnectionID: oldConnectionID,
 				StartedAt:        time.Now(),
 			}
 			_, err := eventLog.Append(ctx, eventlog.AppendInput{
-				Type:      babystationlist.EventTypeSessionStarted,
+				Type:      sessions.EventTypeStarted,
 				AccountID: accountID,
 				Data:      fatal.UnlessMarshalJSON(oldSession),
 			})
@@ -415,14 +453,14 @@ func TestBabyStationList(t *testing.T) {
 			So(err, ShouldBeNil)
 
 			newConnectionID := uuid.NewV4().String()
-			newSession := babystationlist.StartSessionEventData{
+			newSession := sessions.EventStarted{
 				ID:               uuid.NewV4().String(),
 				Name:             "new session",
 				HostConnectionID: newConnectionID,
 				StartedAt:        time.Now(),
 			}
 			_, err = eventLog.Append(ctx, eventlog.AppendInput{
-				Type:      babystationlist.EventTypeSessionStarted,
+				Type:      sessions.EventTypeStarted,
 				AccountID: accountID,
 				Data:      fatal.UnlessMarshalJSON(newSession),
 			})
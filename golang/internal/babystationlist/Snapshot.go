@@ -0,0 +1,74 @@
+package babystationlist
+
+import (
+	"context"
+	"encoding/json"
+)
+
+const SnapshotSchemaVersion = 1
+
+type snapshotState struct {
+	SnapshotByAccountID map[string]*snapshotAccount `json:"snapshot_by_account_id"`
+}
+
+type snapshotAccount struct {
+	SessionByID                       map[string]*Session             `json:"session_by_id"`
+	SessionIDByConnectionID           map[string]string               `json:"session_id_by_connection_id"`
+	ConnectionByID                    map[string]*Connection          `json:"connection_by_id"`
+	DisconnectedSessionByConnectionID map[string]*DisconnectedSession `json:"disconnected_session_by_connection_id"`
+}
+
+func (babyStationList *BabyStationList) MarshalSnapshot(ctx context.Context) (cursor int64, data []byte, err error) {
+	err = babyStationList.catchup(ctx)
+	if err != nil {
+		return
+	}
+	babyStationList.mutex.Lock()
+	defer babyStationList.mutex.Unlock()
+	state := snapshotState{
+		SnapshotByAccountID: make(map[string]*snapshotAccount, len(babyStationList.snapshotByAccountID)),
+	}
+	for accountID, snapshot := range babyStationList.snapshotByAccountID {
+		state.SnapshotByAccountID[accountID] = &snapshotAccount{
+			SessionByID:                       snapshot.SessionByID,
+			SessionIDByConnectionID:           snapshot.SessionIDByConnectionID,
+			ConnectionByID:                    snapshot.ConnectionByID,
+			DisconnectedSessionByConnectionID: snapshot.DisconnectedSessionByConnectionID,
+		}
+	}
+	data, err = json.Marshal(state)
+	cursor = babyStationList.cursor
+	return
+}
+
+func (babyStationList *BabyStationList) UnmarshalSnapshot(ctx context.Context, cursor int64, data []byte) (err error) {
+	var state snapshotState
+	err = json.Unmarshal(data, &state)
+	if err != nil {
+		return
+	}
+	snapshotByAccountID := make(map[string]*Snapshot, len(state.SnapshotByAccountID))
+	for accountID, account := range state.SnapshotByAccountID {
+		snapshot := babyStationList.createSnapshot()
+		for sessionID, session := range account.SessionByID {
+			// Session.AccountID is not serialised
+			session.AccountID = accountID
+			snapshot.SessionByID[sessionID] = session
+		}
+		for connectionID, sessionID := range account.SessionIDByConnectionID {
+			snapshot.SessionIDByConnectionID[connectionID] = sessionID
+		}
+		for connectionID, connection := range account.ConnectionByID {
+			snapshot.ConnectionByID[connectionID] = connection
+		}
+		for connectionID, disconnectedSession := range account.DisconnectedSessionByConnectionID {
+			snapshot.DisconnectedSessionByConnectionID[connectionID] = disconnectedSession
+		}
+		snapshotByAccountID[accountID] = snapshot
+	}
+	babyStationList.mutex.Lock()
+	defer babyStationList.mutex.Unlock()
+	babyStationList.cursor = cursor
+	babyStationList.snapshotByAccountID = snapshotByAccountID
+	return
+}
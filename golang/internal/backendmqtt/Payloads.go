@@ -97,6 +97,9 @@ type SessionAnnouncement struct {
 	SessionID       string `json:"session_id"`
 	Name            string `json:"name"`
 	StartedAtMillis int64  `json:"started_at_millis"`
+	// ExpectedLogicalClock is the If-Match of the request that started the
+	// session, which the start event is appended with.
+	ExpectedLogicalClock *int64 `json:"expected_logical_clock,omitempty"`
 }
 
 type ParentStationsPayload struct {
@@ -117,10 +120,11 @@ type ControlInboxPayload struct {
 }
 
 type PendingSessionStart struct {
-	SessionID    string
-	Name         string
-	ConnectionID string
-	StartedAt    time.Time
+	SessionID            string
+	Name                 string
+	ConnectionID         string
+	StartedAt            time.Time
+	ExpectedLogicalClock *int64
 }
 
 func (payload BabyStationsPayload) Session() sessions.Session {
@@ -18,7 +18,7 @@ func TestReconnectTimeoutScheduler(t *testing.T) {
 		folderPath, err := os.MkdirTemp("", "TestReconnectTimeoutScheduler-*")
 		So(err, ShouldBeNil)
 		log := eventlog.NewThreadSafeDecorator(&eventlog.NewThreadSafeDecoratorInput{
-			Decorated: eventlog.NewFileEventLog(&eventlog.NewFileEventLogInput{
+			Decorated: eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
 				FolderPath: folderPath,
 			}),
 		})
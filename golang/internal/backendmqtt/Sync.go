@@ -3,6 +3,7 @@ package backendmqtt
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"regexp"
 	"time"
 
@@ -14,6 +15,7 @@ import (
 	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/logx"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/mqttx"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/sessions"
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 )
 
@@ -64,7 +66,11 @@ func handleClientStatusMessage(client mqtt.Client, message mqtt.Message, input R
 			ConnectionID: payload.ConnectionID,
 			RequestID:    payload.RequestID,
 		})
-		if err := input.ConnectionStore.Put(context.Background(), connection); err != nil && err != connectionstore.ErrDuplicate {
+		err := input.ConnectionStore.Put(context.Background(), connection)
+		if err == connectionstore.ErrAccountDeleted {
+			return
+		}
+		if err != nil && err != connectionstore.ErrDuplicate {
 			logx.Warnln(err)
 			return
 		}
@@ -72,15 +78,16 @@ func handleClientStatusMessage(client mqtt.Client, message mqtt.Message, input R
 		if !ok {
 			return
 		}
-		err := PublishBabyStationAnnouncement(input.MQTTClient, accountID, BabyStationsPayload{
+		err = PublishBabyStationAnnouncement(input.MQTTClient, accountID, BabyStationsPayload{
 			Type:     AnnouncementType,
 			AtMillis: pending.StartedAt.UnixMilli(),
 			Announcement: SessionAnnouncement{
-				ClientID:        clientID,
-				ConnectionID:    pending.ConnectionID,
-				SessionID:       pending.SessionID,
-				Name:            pending.Name,
-				StartedAtMillis: pending.StartedAt.UnixMilli(),
+				ClientID:             clientID,
+				ConnectionID:         pending.ConnectionID,
+				SessionID:            pending.SessionID,
+				Name:                 pending.Name,
+				StartedAtMillis:      pending.StartedAt.UnixMilli(),
+				ExpectedLogicalClock: pending.ExpectedLogicalClock,
 			},
 		})
 		if err != nil {
@@ -97,7 +104,11 @@ func handleClientStatusMessage(client mqtt.Client, message mqtt.Message, input R
 			ConnectionID: payload.ConnectionID,
 			RequestID:    payload.RequestID,
 		})
-		if err := input.ConnectionStore.Delete(context.Background(), connection); err != nil && err != connectionstore.ErrDuplicate {
+		err := input.ConnectionStore.Delete(context.Background(), connection)
+		if err == connectionstore.ErrAccountDeleted {
+			return
+		}
+		if err != nil && err != connectionstore.ErrDuplicate {
 			logx.Warnln(err)
 			return
 		}
@@ -150,10 +161,17 @@ func handleBabyStationAnnouncementMessage(message mqtt.Message, input RunBabySta
 	}
 	data := fatal.UnlessMarshalJSON(payload.Session())
 	_, err := input.EventLog.Append(context.Background(), eventlog.AppendInput{
-		Type:      "session.started",
-		AccountID: accountID,
-		Data:      data,
+		Type:                 "session.started",
+		AccountID:            accountID,
+		StreamKey:            sessions.StreamKey(accountID, payload.Announcement.SessionID),
+		Data:                 data,
+		ExpectedLogicalClock: payload.Announcement.ExpectedLogicalClock,
 	})
+	var conflict *eventlog.ConflictError
+	if errors.As(err, &conflict) {
+		logx.Warnln("session was not started:", err)
+		return
+	}
 	if err != nil {
 		logx.Errorln(err)
 	}
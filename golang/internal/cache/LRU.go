@@ -0,0 +1,173 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LRU is a goroutine-safe cache that evicts the least recently used entries
+// once it holds more than MaxEntries entries or MaxBytes bytes. Entries
+// expire after TTL, and misses can be cached for NotFoundTTL so that
+// repeated lookups of a missing key don't reach the backend.
+type LRU[K comparable, V any] struct {
+	mutex       sync.Mutex
+	maxEntries  int
+	maxBytes    int64
+	size        func(value V) int64
+	ttl         time.Duration
+	notFoundTTL time.Duration
+	now         func() time.Time
+	elements    map[K]*list.Element
+	order       *list.List
+	bytes       int64
+	stats       Stats
+}
+
+type entry[K comparable, V any] struct {
+	key       K
+	value     V
+	found     bool
+	size      int64
+	expiresAt time.Time
+}
+
+type NewLRUInput[V any] struct {
+	// MaxEntries and MaxBytes bound the cache. Zero means unbounded.
+	MaxEntries int
+	MaxBytes   int64
+	// Size is the size of a value in bytes, for MaxBytes.
+	Size func(value V) int64
+	// TTL is how long a value is cached. Zero means until it is evicted.
+	TTL time.Duration
+	// NotFoundTTL is how long a miss is cached. Zero means misses aren't
+	// cached.
+	NotFoundTTL time.Duration
+	// Now is for tests and defaults to time.Now.
+	Now func() time.Time
+}
+
+type Stats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+	Entries   int   `json:"entries"`
+	Bytes     int64 `json:"bytes"`
+}
+
+func NewLRU[K comparable, V any](input NewLRUInput[V]) *LRU[K, V] {
+	if input.Size == nil {
+		input.Size = func(value V) int64 { return 0 }
+	}
+	if input.Now == nil {
+		input.Now = time.Now
+	}
+	return &LRU[K, V]{
+		maxEntries:  input.MaxEntries,
+		maxBytes:    input.MaxBytes,
+		size:        input.Size,
+		ttl:         input.TTL,
+		notFoundTTL: input.NotFoundTTL,
+		now:         input.Now,
+		elements:    make(map[K]*list.Element),
+		order:       list.New(),
+	}
+}
+
+// Get returns cached as false if the key isn't in the cache, and found as
+// false if it is cached as missing.
+func (lru *LRU[K, V]) Get(key K) (value V, found bool, cached bool) {
+	lru.mutex.Lock()
+	defer lru.mutex.Unlock()
+	element, ok := lru.elements[key]
+	if !ok {
+		lru.stats.Misses++
+		return
+	}
+	entry := element.Value.(*entry[K, V])
+	if !entry.expiresAt.IsZero() && !lru.now().Before(entry.expiresAt) {
+		lru.remove(element)
+		lru.stats.Misses++
+		return
+	}
+	lru.order.MoveToFront(element)
+	lru.stats.Hits++
+	return entry.value, entry.found, true
+}
+
+func (lru *LRU[K, V]) Put(key K, value V) {
+	lru.put(&entry[K, V]{
+		key:       key,
+		value:     value,
+		found:     true,
+		size:      lru.size(value),
+		expiresAt: lru.expiresAt(lru.ttl),
+	})
+}
+
+// PutNotFound caches that the key is missing, unless NotFoundTTL is zero.
+func (lru *LRU[K, V]) PutNotFound(key K) {
+	if lru.notFoundTTL == 0 {
+		return
+	}
+	lru.put(&entry[K, V]{
+		key:       key,
+		expiresAt: lru.expiresAt(lru.notFoundTTL),
+	})
+}
+
+func (lru *LRU[K, V]) Delete(key K) {
+	lru.mutex.Lock()
+	defer lru.mutex.Unlock()
+	element, ok := lru.elements[key]
+	if ok {
+		lru.remove(element)
+	}
+}
+
+func (lru *LRU[K, V]) Stats() Stats {
+	lru.mutex.Lock()
+	defer lru.mutex.Unlock()
+	stats := lru.stats
+	stats.Entries = lru.order.Len()
+	stats.Bytes = lru.bytes
+	return stats
+}
+
+func (lru *LRU[K, V]) expiresAt(ttl time.Duration) time.Time {
+	if ttl == 0 {
+		return time.Time{}
+	}
+	return lru.now().Add(ttl)
+}
+
+func (lru *LRU[K, V]) put(entry *entry[K, V]) {
+	lru.mutex.Lock()
+	defer lru.mutex.Unlock()
+	element, ok := lru.elements[entry.key]
+	if ok {
+		lru.remove(element)
+	}
+	if lru.maxBytes != 0 && entry.size > lru.maxBytes {
+		return
+	}
+	lru.elements[entry.key] = lru.order.PushFront(entry)
+	lru.bytes += entry.size
+	for lru.full() {
+		lru.remove(lru.order.Back())
+		lru.stats.Evictions++
+	}
+}
+
+func (lru *LRU[K, V]) full() bool {
+	if lru.maxEntries != 0 && lru.order.Len() > lru.maxEntries {
+		return true
+	}
+	return lru.maxBytes != 0 && lru.bytes > lru.maxBytes
+}
+
+func (lru *LRU[K, V]) remove(element *list.Element) {
+	entry := lru.order.Remove(element).(*entry[K, V])
+	delete(lru.elements, entry.key)
+	lru.bytes -= entry.size
+}
@@ -0,0 +1,93 @@
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/cache"
+)
+
+func TestLRU(t *testing.T) {
+	Convey("TestLRU", t, func() {
+		now := time.Unix(0, 0)
+		newLRU := func(input cache.NewLRUInput[string]) *cache.LRU[string, string] {
+			input.Size = func(value string) int64 {
+				return int64(len(value))
+			}
+			input.Now = func() time.Time {
+				return now
+			}
+			return cache.NewLRU[string](input)
+		}
+		cached := func(lru *cache.LRU[string, string], key string) bool {
+			_, _, cached := lru.Get(key)
+			return cached
+		}
+		Convey("max entries", func() {
+			lru := newLRU(cache.NewLRUInput[string]{MaxEntries: 2})
+			lru.Put("a", "a")
+			lru.Put("b", "b")
+			value, found, ok := lru.Get("a")
+			So(ok, ShouldBeTrue)
+			So(found, ShouldBeTrue)
+			So(value, ShouldEqual, "a")
+			lru.Put("c", "c")
+			So(cached(lru, "b"), ShouldBeFalse)
+			So(cached(lru, "a"), ShouldBeTrue)
+			So(cached(lru, "c"), ShouldBeTrue)
+			So(lru.Stats(), ShouldResemble, cache.Stats{
+				Hits:      3,
+				Misses:    1,
+				Evictions: 1,
+				Entries:   2,
+				Bytes:     2,
+			})
+		})
+		Convey("max bytes", func() {
+			lru := newLRU(cache.NewLRUInput[string]{MaxBytes: 10})
+			lru.Put("a", "aaaa")
+			lru.Put("b", "bbbb")
+			lru.Put("c", "cccc")
+			So(cached(lru, "a"), ShouldBeFalse)
+			So(lru.Stats().Bytes, ShouldEqual, 8)
+			Convey("larger than the cache", func() {
+				lru.Put("b", "bbbbbbbbbbbb")
+				So(cached(lru, "b"), ShouldBeFalse)
+				So(cached(lru, "c"), ShouldBeTrue)
+				So(lru.Stats().Bytes, ShouldEqual, 4)
+			})
+		})
+		Convey("TTL", func() {
+			lru := newLRU(cache.NewLRUInput[string]{TTL: time.Minute})
+			lru.Put("a", "a")
+			now = now.Add(time.Minute - time.Nanosecond)
+			So(cached(lru, "a"), ShouldBeTrue)
+			now = now.Add(time.Nanosecond)
+			So(cached(lru, "a"), ShouldBeFalse)
+			So(lru.Stats().Entries, ShouldEqual, 0)
+		})
+		Convey("not found", func() {
+			lru := newLRU(cache.NewLRUInput[string]{NotFoundTTL: time.Second})
+			lru.PutNotFound("a")
+			_, found, ok := lru.Get("a")
+			So(ok, ShouldBeTrue)
+			So(found, ShouldBeFalse)
+			now = now.Add(time.Second)
+			So(cached(lru, "a"), ShouldBeFalse)
+			Convey("disabled", func() {
+				lru := newLRU(cache.NewLRUInput[string]{})
+				lru.PutNotFound("a")
+				So(cached(lru, "a"), ShouldBeFalse)
+			})
+		})
+		Convey("Delete", func() {
+			lru := newLRU(cache.NewLRUInput[string]{})
+			lru.Put("a", "a")
+			lru.Delete("a")
+			So(cached(lru, "a"), ShouldBeFalse)
+			So(lru.Stats().Bytes, ShouldEqual, 0)
+		})
+	})
+}
@@ -0,0 +1,104 @@
+package compaction
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/connections"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventschema"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/sessions"
+)
+
+// ChurnTypes are the connection events that compaction can drop.
+var ChurnTypes = []string{
+	connections.EventTypeConnected,
+	connections.EventTypeDisconnected,
+	connections.EventTypeReconnectTimeout,
+}
+
+type CompactInput struct {
+	EventLog       eventlog.EventLog
+	FolderPath     string
+	MaxSegmentSize int64
+	IndexInterval  int64
+	// RetainAfter is the start of the retention window. Nothing at or after
+	// it is dropped.
+	RetainAfter time.Time
+}
+
+// Compact writes a copy of the log to a new FileEventLog folder without the
+// connection churn that no projection can observe any more.
+//
+// Every projection forgets the connections it has seen on server.started, so
+// the churn before the last server.started outside the retention window is
+// dropped. The exception is the connections that host a session: the session
+// list and usage stats carry a session's disconnect time across a restart,
+// so their events are kept. Account, session and server events are never
+// dropped.
+func Compact(ctx context.Context, input CompactInput) (report eventlog.CompactionReport, err error) {
+	plan, err := newPlan(ctx, input.EventLog, input.RetainAfter)
+	if err != nil {
+		return
+	}
+	return eventlog.CompactFileEventLog(ctx, eventlog.CompactFileEventLogInput{
+		EventLog:       input.EventLog,
+		FolderPath:     input.FolderPath,
+		MaxSegmentSize: input.MaxSegmentSize,
+		IndexInterval:  input.IndexInterval,
+		Before:         plan.before,
+		Drop:           plan.drop,
+	})
+}
+
+type plan struct {
+	// before is the logical clock of the last server.started outside the
+	// retention window, 0 if there is none.
+	before               int64
+	hostConnectionKeySet map[string]struct{}
+}
+
+func newPlan(ctx context.Context, eventLog eventlog.EventLog, retainAfter time.Time) (*plan, error) {
+	plan := &plan{
+		hostConnectionKeySet: make(map[string]struct{}),
+	}
+	iterator := eventLog.GetEventIterator(ctx, eventlog.GetEventIteratorInput{
+		Types: []string{eventschema.EventTypeServerStarted, sessions.EventTypeStarted},
+	})
+	for iterator.Next(ctx) {
+		event := iterator.Event()
+		switch event.Type {
+		case eventschema.EventTypeServerStarted:
+			if event.UnixTimestamp < retainAfter.Unix() {
+				plan.before = event.LogicalClock
+			}
+		case sessions.EventTypeStarted:
+			data, err := eventschema.Decode[sessions.EventStarted](eventschema.Default, event)
+			if err != nil {
+				return nil, err
+			}
+			plan.hostConnectionKeySet[connectionKey(event.AccountID, data.HostConnectionID)] = struct{}{}
+		}
+	}
+	return plan, iterator.Err()
+}
+
+func (plan *plan) drop(event *eventlog.Event) bool {
+	if !slices.Contains(ChurnTypes, event.Type) {
+		return false
+	}
+	var data struct {
+		ConnectionID string `json:"connection_id"`
+	}
+	if json.Unmarshal(event.Data, &data) != nil {
+		return false
+	}
+	_, hostsSession := plan.hostConnectionKeySet[connectionKey(event.AccountID, data.ConnectionID)]
+	return !hostsSession
+}
+
+func connectionKey(accountID string, connectionID string) string {
+	return accountID + "\x00" + connectionID
+}
@@ -0,0 +1,235 @@
+package compaction_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/babystationlist"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/compaction"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/connections"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/contextx"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventschema"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/sessionlist"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/sessions"
+)
+
+type logWriter struct {
+	ctx      context.Context
+	eventLog eventlog.EventLog
+}
+
+func (writer *logWriter) append(eventType string, accountID string, data any) {
+	_, err := writer.eventLog.Append(writer.ctx, eventlog.AppendInput{
+		Type:      eventType,
+		AccountID: accountID,
+		Data:      fatal.UnlessMarshalJSON(data),
+	})
+	So(err, ShouldBeNil)
+}
+
+func (writer *logWriter) connected(accountID string, clientID string, connectionID string) {
+	writer.append(connections.EventTypeConnected, accountID, connections.EventConnected{
+		ClientID:     clientID,
+		ConnectionID: connectionID,
+		RequestID:    "request-" + connectionID,
+	})
+}
+
+func (writer *logWriter) disconnected(accountID string, clientID string, connectionID string, reason string) {
+	writer.append(connections.EventTypeDisconnected, accountID, connections.EventDisconnected{
+		ClientID:     clientID,
+		ConnectionID: connectionID,
+		RequestID:    "request-" + connectionID,
+		Reason:       reason,
+	})
+}
+
+func (writer *logWriter) reconnectTimeout(accountID string, clientID string, connectionID string) {
+	writer.append(connections.EventTypeReconnectTimeout, accountID, connections.EventReconnectTimeout{
+		ClientID:     clientID,
+		ConnectionID: connectionID,
+		RequestID:    "request-" + connectionID,
+	})
+}
+
+func (writer *logWriter) sessionStarted(accountID string, sessionID string, hostConnectionID string) {
+	writer.append(sessions.EventTypeStarted, accountID, sessions.EventStarted{
+		ID:               sessionID,
+		Name:             "Nursery " + sessionID,
+		HostConnectionID: hostConnectionID,
+		StartedAt:        time.Unix(1700000000, 0).UTC(),
+	})
+}
+
+func (writer *logWriter) sessionEnded(accountID string, sessionID string) {
+	writer.append(sessions.EventTypeEnded, accountID, sessions.EventEnded{
+		ID: sessionID,
+	})
+}
+
+func (writer *logWriter) serverStarted() {
+	writer.append(eventschema.EventTypeServerStarted, "", eventschema.ServerStarted{})
+}
+
+type projections struct {
+	sessionListByAccountID     map[string]sessionlist.ListOutput
+	babyStationListByAccountID map[string]babystationlist.GetSnapshotOutput
+}
+
+func project(ctx context.Context, eventLog eventlog.EventLog, accountIDs []string) projections {
+	sessionList := sessionlist.New(ctx, sessionlist.NewInput{
+		Log: eventLog,
+	})
+	babyStationList := babystationlist.New(babystationlist.NewInput{
+		EventLog: eventLog,
+	})
+	result := projections{
+		sessionListByAccountID:     make(map[string]sessionlist.ListOutput),
+		babyStationListByAccountID: make(map[string]babystationlist.GetSnapshotOutput),
+	}
+	for _, accountID := range accountIDs {
+		ctx := contextx.WithAccountID(ctx, accountID)
+		result.sessionListByAccountID[accountID] = sessionList.List(ctx)
+		snapshot, err := babyStationList.GetSnapshot(ctx)
+		So(err, ShouldBeNil)
+		result.babyStationListByAccountID[accountID] = snapshot
+	}
+	return result
+}
+
+func readEvents(ctx context.Context, eventLog eventlog.EventLog, fromCursor int64) (events []*eventlog.Event) {
+	iterator := eventLog.GetEventIterator(ctx, eventlog.GetEventIteratorInput{
+		FromCursor: fromCursor,
+	})
+	for iterator.Next(ctx) {
+		events = append(events, iterator.Event())
+	}
+	So(iterator.Err(), ShouldBeNil)
+	return
+}
+
+func TestCompact(t *testing.T) {
+	ctx := context.Background()
+	Convey("TestCompact", t, func() {
+		sourceFolderPath, err := os.MkdirTemp("testdata", "TestCompactSource-*")
+		So(err, ShouldBeNil)
+		folderPath, err := os.MkdirTemp("testdata", "TestCompact-*")
+		So(err, ShouldBeNil)
+		source := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
+			FolderPath:     sourceFolderPath,
+			MaxSegmentSize: 1024,
+			IndexInterval:  256,
+		})
+		writer := &logWriter{ctx: ctx, eventLog: source}
+		accountIDs := []string{"account-1", "account-2"}
+
+		writer.append("account.created", "account-1", map[string]string{"email": "parent@example.com"})
+		writer.connected("account-1", "baby-1", "host-1")
+		writer.sessionStarted("account-1", "session-1", "host-1")
+		for i := 0; i < 5; i++ {
+			writer.connected("account-1", "parent-1", "parent-1-a")
+			writer.disconnected("account-1", "parent-1", "parent-1-a", connections.DisconnectReasonClean)
+		}
+		writer.disconnected("account-1", "baby-1", "host-1", connections.DisconnectReasonUnexpected)
+		writer.connected("account-2", "baby-2", "host-2")
+		writer.sessionStarted("account-2", "session-2", "host-2")
+		writer.connected("account-2", "parent-2", "parent-2-a")
+		writer.reconnectTimeout("account-2", "parent-2", "parent-2-a")
+		writer.serverStarted()
+		writer.connected("account-1", "parent-1", "parent-1-b")
+		writer.disconnected("account-1", "parent-1", "parent-1-b", connections.DisconnectReasonUnexpected)
+		writer.connected("account-2", "baby-2", "host-2")
+		writer.serverStarted()
+		retainAfter := time.Now().Add(time.Hour)
+		writer.connected("account-1", "parent-1", "parent-1-c")
+		writer.connected("account-1", "baby-3", "host-3")
+		writer.sessionStarted("account-1", "session-3", "host-3")
+		writer.connected("account-2", "baby-2", "host-2")
+		writer.sessionEnded("account-2", "session-2")
+		original := readEvents(ctx, source, 0)
+		before := project(ctx, source, accountIDs)
+
+		Convey("outside the retention window", func() {
+			report, err := compaction.Compact(ctx, compaction.CompactInput{
+				EventLog:       source,
+				FolderPath:     folderPath,
+				MaxSegmentSize: 1024,
+				IndexInterval:  256,
+				RetainAfter:    retainAfter,
+			})
+			So(err, ShouldBeNil)
+			So(report.Dropped, ShouldEqual, 14)
+			So(report.Kept, ShouldEqual, len(original)-14)
+			So(report.Cursor, ShouldEqual, original[len(original)-1].LogicalClock)
+			compacted := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
+				FolderPath: folderPath,
+			})
+			So(project(ctx, compacted, accountIDs), ShouldResemble, before)
+			Convey("keeps logical clocks", func() {
+				eventByLogicalClock := make(map[int64]*eventlog.Event)
+				for _, event := range original {
+					eventByLogicalClock[event.LogicalClock] = event
+				}
+				events := readEvents(ctx, compacted, 0)
+				So(events, ShouldHaveLength, report.Kept)
+				for _, event := range events {
+					So(event, ShouldResemble, eventByLogicalClock[event.LogicalClock])
+				}
+				for _, fromCursor := range []int64{3, 10, 20} {
+					var expected []*eventlog.Event
+					for _, event := range events {
+						if event.LogicalClock > fromCursor {
+							expected = append(expected, event)
+						}
+					}
+					So(readEvents(ctx, compacted, fromCursor), ShouldResemble, expected)
+				}
+			})
+			Convey("keeps account, session and server events", func() {
+				countByType := make(map[string]int)
+				for _, event := range readEvents(ctx, compacted, 0) {
+					countByType[event.Type]++
+				}
+				So(countByType["account.created"], ShouldEqual, 1)
+				So(countByType[sessions.EventTypeStarted], ShouldEqual, 3)
+				So(countByType[sessions.EventTypeEnded], ShouldEqual, 1)
+				So(countByType[eventschema.EventTypeServerStarted], ShouldEqual, 2)
+				So(countByType[connections.EventTypeReconnectTimeout], ShouldEqual, 0)
+			})
+			Convey("carries on from the same cursor", func() {
+				event, err := compacted.Append(ctx, eventlog.AppendInput{
+					Type: "test",
+				})
+				So(err, ShouldBeNil)
+				So(event.LogicalClock, ShouldEqual, report.Cursor+1)
+			})
+			Convey("refuses a folder that holds a log", func() {
+				_, err := compaction.Compact(ctx, compaction.CompactInput{
+					EventLog:    source,
+					FolderPath:  folderPath,
+					RetainAfter: retainAfter,
+				})
+				So(err, ShouldWrap, eventlog.ErrCompactFolderNotEmpty)
+			})
+		})
+		Convey("inside the retention window", func() {
+			report, err := compaction.Compact(ctx, compaction.CompactInput{
+				EventLog:    source,
+				FolderPath:  folderPath,
+				RetainAfter: time.Now().Add(-time.Hour),
+			})
+			So(err, ShouldBeNil)
+			So(report.Dropped, ShouldEqual, 0)
+			compacted := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
+				FolderPath: folderPath,
+			})
+			So(readEvents(ctx, compacted, 0), ShouldResemble, original)
+		})
+	})
+}
@@ -1,5 +1,11 @@
 package connections
 
+import (
+	"encoding/json"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventschema"
+)
+
 const EventTypeConnected string = "client.connected"
 const EventTypeDisconnected string = "client.disconnected"
 const EventTypeReconnectTimeout string = "client.reconnect_timeout"
@@ -20,8 +26,61 @@ type EventDisconnected struct {
 	Reason       string `json:"reason,omitempty"`
 }
 
+// EventDisconnectedV1 is the payload written by the websocket backend before
+// disconnects were reported over MQTT. It is upcast to EventDisconnected on
+// read.
+type EventDisconnectedV1 struct {
+	ClientID           string `json:"client_id"`
+	ConnectionID       string `json:"connection_id"`
+	RequestID          string `json:"request_id"`
+	WebSocketCloseCode int    `json:"web_socket_close_code"`
+}
+
 type EventReconnectTimeout struct {
 	ClientID     string `json:"client_id"`
 	ConnectionID string `json:"connection_id"`
 	RequestID    string `json:"request_id"`
 }
+
+func init() {
+	eventschema.Register[EventConnected](eventschema.Default, EventTypeConnected, 1)
+	eventschema.Register[EventDisconnected](eventschema.Default, EventTypeDisconnected, 2)
+	eventschema.Default.RegisterVersionDetector(EventTypeDisconnected, detectDisconnectedVersion)
+	eventschema.Default.RegisterUpcaster(EventTypeDisconnected, 1, upcastDisconnectedV1)
+	eventschema.Register[EventReconnectTimeout](eventschema.Default, EventTypeReconnectTimeout, 1)
+}
+
+func detectDisconnectedVersion(data json.RawMessage) int {
+	var probe struct {
+		Reason             *string `json:"reason"`
+		WebSocketCloseCode *int    `json:"web_socket_close_code"`
+	}
+	if json.Unmarshal(data, &probe) == nil && probe.Reason == nil && probe.WebSocketCloseCode != nil {
+		return 1
+	}
+	return 2
+}
+
+func upcastDisconnectedV1(data json.RawMessage) (json.RawMessage, error) {
+	var v1 EventDisconnectedV1
+	err := json.Unmarshal(data, &v1)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(EventDisconnected{
+		ClientID:     v1.ClientID,
+		ConnectionID: v1.ConnectionID,
+		RequestID:    v1.RequestID,
+		Reason:       DisconnectReasonFromCloseCode(v1.WebSocketCloseCode),
+	})
+}
+
+// DisconnectReasonFromCloseCode treats normal closure and going away as clean.
+func DisconnectReasonFromCloseCode(closeCode int) string {
+	switch closeCode {
+	case 1000, 1001:
+		return DisconnectReasonClean
+	default:
+		return DisconnectReasonUnexpected
+	}
+}
@@ -5,15 +5,24 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"sync"
 
+	"github.com/Ryan-A-B/beddybytes/golang/internal/accounts"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/connections"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventschema"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
 )
 
 var ErrDuplicate = errors.New("duplicate")
 
+// ErrAccountDeleted is returned for a connection of a deleted account, so
+// that it doesn't bring back state that deleting the account purged.
+var ErrAccountDeleted = errors.New("account deleted")
+
 type Connection struct {
 	ID        string
 	AccountID string
@@ -29,8 +38,14 @@ type Decider struct {
 	cursor               int64
 	mutex                sync.Mutex
 	applyFuncByEventType map[string]ApplyFunc
+	eventTypes           []string
 	connectedKeySet      map[string]struct{}
 	disconnectedKeySet   map[string]struct{}
+	// headByAccountID is the head of each account's connections stream. One
+	// stream per account rather than per connection keeps the logs from
+	// having to remember the head of every connection there has been.
+	headByAccountID   map[string]int64
+	deletedAccountIDs map[string]struct{}
 }
 
 type NewDeciderInput struct {
@@ -42,52 +57,33 @@ func NewDecider(input NewDeciderInput) *Decider {
 		eventLog:           input.EventLog,
 		connectedKeySet:    make(map[string]struct{}),
 		disconnectedKeySet: make(map[string]struct{}),
+		headByAccountID:    make(map[string]int64),
+		deletedAccountIDs:  make(map[string]struct{}),
 	}
 	decider.applyFuncByEventType = map[string]ApplyFunc{
 		connections.EventTypeConnected:    decider.applyConnected,
 		connections.EventTypeDisconnected: decider.applyDisconnected,
+		accounts.EventTypeAccountDeleted:  decider.applyAccountDeleted,
 	}
+	decider.eventTypes = slices.Collect(maps.Keys(decider.applyFuncByEventType))
 	return decider
 }
 
 func (decider *Decider) Put(ctx context.Context, connection Connection) error {
 	decider.mutex.Lock()
 	defer decider.mutex.Unlock()
-	err := decider.catchUp(ctx)
-	if err != nil {
-		return err
-	}
-	connectionKey := decider.getKey(connection)
-	_, found := decider.connectedKeySet[connectionKey]
-	if found {
-		return ErrDuplicate
-	}
 	data, err := json.Marshal(connections.EventConnected{
 		ClientID:     connection.ClientID,
 		ConnectionID: connection.ID,
 		RequestID:    connection.RequestID,
 	})
 	fatal.OnError(err)
-	_, err = decider.eventLog.Append(ctx, eventlog.AppendInput{
-		Type:      connections.EventTypeConnected,
-		AccountID: connection.AccountID,
-		Data:      data,
-	})
-	return err
+	return decider.appendUnlessFound(ctx, connection, decider.connectedKeySet, connections.EventTypeConnected, data)
 }
 
 func (decider *Decider) Delete(ctx context.Context, connection Connection) error {
 	decider.mutex.Lock()
 	defer decider.mutex.Unlock()
-	err := decider.catchUp(ctx)
-	if err != nil {
-		return err
-	}
-	connectionKey := decider.getKey(connection)
-	_, found := decider.disconnectedKeySet[connectionKey]
-	if found {
-		return ErrDuplicate
-	}
 	data, err := json.Marshal(connections.EventDisconnected{
 		ClientID:     connection.ClientID,
 		ConnectionID: connection.ID,
@@ -95,17 +91,48 @@ func (decider *Decider) Delete(ctx context.Context, connection Connection) error
 		Reason:       connection.Reason,
 	})
 	fatal.OnError(err)
-	_, err = decider.eventLog.Append(ctx, eventlog.AppendInput{
-		Type:      connections.EventTypeDisconnected,
-		AccountID: connection.AccountID,
-		Data:      data,
-	})
-	return err
+	return decider.appendUnlessFound(ctx, connection, decider.disconnectedKeySet, connections.EventTypeDisconnected, data)
+}
+
+const maxConflictRetries = 3
+
+// appendUnlessFound appends an event to the connections stream of the
+// account unless the connection's key is already in keySet. The append
+// expects the stream to be where the decider last saw it, so if another
+// writer got there first the decider catches up and decides again instead of
+// appending a duplicate.
+func (decider *Decider) appendUnlessFound(ctx context.Context, connection Connection, keySet map[string]struct{}, eventType string, data json.RawMessage) error {
+	key := decider.getKey(connection)
+	for attempt := 0; ; attempt++ {
+		err := decider.catchUp(ctx)
+		if err != nil {
+			return err
+		}
+		if _, deleted := decider.deletedAccountIDs[connection.AccountID]; deleted {
+			return ErrAccountDeleted
+		}
+		if _, found := keySet[key]; found {
+			return ErrDuplicate
+		}
+		_, err = decider.eventLog.Append(ctx, eventlog.AppendInput{
+			Type:                 eventType,
+			AccountID:            connection.AccountID,
+			StreamKey:            getStreamKey(connection.AccountID),
+			Data:                 data,
+			ExpectedLogicalClock: eventlog.ExpectLogicalClock(decider.headByAccountID[connection.AccountID]),
+		})
+		var conflict *eventlog.ConflictError
+		if errors.As(err, &conflict) && attempt < maxConflictRetries {
+			continue
+		}
+		return err
+	}
 }
 
 func (decider *Decider) catchUp(ctx context.Context) error {
 	iterator := decider.eventLog.GetEventIterator(ctx, eventlog.GetEventIteratorInput{
 		FromCursor: decider.cursor,
+		Types:      decider.eventTypes,
 	})
 	for iterator.Next(ctx) {
 		event := iterator.Event()
@@ -126,14 +153,18 @@ func (decider *Decider) apply(ctx context.Context, event *eventlog.Event) error
 	if err != nil {
 		return err
 	}
+	if event.StreamKey == getStreamKey(event.AccountID) {
+		decider.headByAccountID[event.AccountID] = event.LogicalClock
+	}
 	decider.cursor = event.LogicalClock
 	return nil
 }
 
 func (decider *Decider) applyConnected(ctx context.Context, event *eventlog.Event) error {
-	var data connections.EventConnected
-	err := json.Unmarshal(event.Data, &data)
-	fatal.OnError(err)
+	data, err := eventschema.DecodeEvent[connections.EventConnected](event)
+	if err != nil {
+		return err
+	}
 	connection := Connection{
 		ID:        data.ConnectionID,
 		AccountID: event.AccountID,
@@ -146,9 +177,10 @@ func (decider *Decider) applyConnected(ctx context.Context, event *eventlog.Even
 }
 
 func (decider *Decider) applyDisconnected(ctx context.Context, event *eventlog.Event) error {
-	var data connections.EventDisconnected
-	err := json.Unmarshal(event.Data, &data)
-	fatal.OnError(err)
+	data, err := eventschema.DecodeEvent[connections.EventDisconnected](event)
+	if err != nil {
+		return err
+	}
 	connection := Connection{
 		ID:        data.ConnectionID,
 		AccountID: event.AccountID,
@@ -160,6 +192,33 @@ func (decider *Decider) applyDisconnected(ctx context.Context, event *eventlog.E
 	return nil
 }
 
+// applyAccountDeleted forgets the account's connections and refuses any
+// more, such as the disconnect of a connection closed by the deletion.
+func (decider *Decider) applyAccountDeleted(ctx context.Context, event *eventlog.Event) error {
+	decider.deletedAccountIDs[event.AccountID] = struct{}{}
+	keyPrefix := getAccountKeyPrefix(event.AccountID)
+	for _, keySet := range []map[string]struct{}{decider.connectedKeySet, decider.disconnectedKeySet} {
+		for key := range keySet {
+			if strings.HasPrefix(key, keyPrefix) {
+				delete(keySet, key)
+			}
+		}
+	}
+	delete(decider.headByAccountID, event.AccountID)
+	return nil
+}
+
 func (decider *Decider) getKey(connection Connection) string {
-	return fmt.Sprintf("accounts/%s/clients/%s/connections/%s/requests/%s", connection.AccountID, connection.ClientID, connection.ID, connection.RequestID)
+	return getAccountKeyPrefix(connection.AccountID) + fmt.Sprintf("clients/%s/connections/%s/requests/%s", connection.ClientID, connection.ID, connection.RequestID)
+}
+
+func getAccountKeyPrefix(accountID string) string {
+	return "accounts/" + accountID + "/"
+}
+
+// getStreamKey is the stream of an account's connection events. Events
+// appended before it was introduced have a stream per connection, which
+// nothing appends to any more.
+func getStreamKey(accountID string) string {
+	return getAccountKeyPrefix(accountID) + "connections"
 }
@@ -4,12 +4,19 @@ import (
 	"context"
 	"os"
 	"testing"
+	"time"
 
 	uuid "github.com/satori/go.uuid"
 	. "github.com/smartystreets/goconvey/convey"
 
+	"github.com/Ryan-A-B/beddybytes/golang/internal/accounts"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/babystationlist"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/connections"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/connectionstore"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/sessionlist"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/sessions"
 )
 
 func TestDecider(t *testing.T) {
@@ -17,7 +24,7 @@ func TestDecider(t *testing.T) {
 		ctx := context.Background()
 		folderPath, err := os.MkdirTemp("testdata", "TestDecider-*")
 		So(err, ShouldBeNil)
-		eventLog := eventlog.NewFileEventLog(&eventlog.NewFileEventLogInput{
+		eventLog := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
 			FolderPath: folderPath,
 		})
 		decider := connectionstore.NewDecider(connectionstore.NewDeciderInput{
@@ -87,5 +94,84 @@ func TestDecider(t *testing.T) {
 				})
 			})
 		})
+		Convey("Second Decider", func() {
+			connection := connectionstore.Connection{
+				ID:        uuid.NewV4().String(),
+				AccountID: uuid.NewV4().String(),
+				ClientID:  uuid.NewV4().String(),
+				RequestID: uuid.NewV4().String(),
+			}
+			other := connectionstore.NewDecider(connectionstore.NewDeciderInput{
+				EventLog: eventLog,
+			})
+			err = other.Delete(ctx, connection)
+			So(err, ShouldBeNil)
+			err = decider.Put(ctx, connection)
+			So(err, ShouldBeNil)
+			err = other.Put(ctx, connection)
+			So(err, ShouldEqual, connectionstore.ErrDuplicate)
+			err = decider.Delete(ctx, connection)
+			So(err, ShouldEqual, connectionstore.ErrDuplicate)
+		})
+		Convey("Deleted account", func() {
+			accountID := uuid.NewV4().String()
+			connection := connectionstore.Connection{
+				ID:        uuid.NewV4().String(),
+				AccountID: accountID,
+				ClientID:  uuid.NewV4().String(),
+				RequestID: uuid.NewV4().String(),
+			}
+			err = decider.Put(ctx, connection)
+			So(err, ShouldBeNil)
+			appendEvent := func(eventType string, data interface{}) {
+				_, err := eventLog.Append(ctx, eventlog.AppendInput{
+					Type:      eventType,
+					AccountID: accountID,
+					Data:      fatal.UnlessMarshalJSON(data),
+				})
+				So(err, ShouldBeNil)
+			}
+			appendEvent(sessions.EventTypeStarted, sessions.EventStarted{
+				ID:               uuid.NewV4().String(),
+				Name:             "nursery",
+				HostConnectionID: connection.ID,
+				StartedAt:        time.Now(),
+			})
+			appendEvent(accounts.EventTypeAccountDeleted, accounts.AccountDeletedData{})
+			Convey("disconnect after delete leaves no projection state", func() {
+				err = decider.Delete(ctx, connection)
+				So(err, ShouldEqual, connectionstore.ErrAccountDeleted)
+				err = decider.Put(ctx, connection)
+				So(err, ShouldEqual, connectionstore.ErrAccountDeleted)
+				// A reconnect timeout scheduled before the deletion.
+				appendEvent(connections.EventTypeReconnectTimeout, connections.EventReconnectTimeout{
+					ClientID:     connection.ClientID,
+					ConnectionID: connection.ID,
+					RequestID:    connection.RequestID,
+				})
+				snapshotters := []eventlog.Snapshotter{
+					babystationlist.New(babystationlist.NewInput{
+						EventLog: eventLog,
+					}),
+					sessionlist.New(ctx, sessionlist.NewInput{
+						Log: eventLog,
+					}),
+				}
+				for _, snapshotter := range snapshotters {
+					_, data, err := snapshotter.MarshalSnapshot(ctx)
+					So(err, ShouldBeNil)
+					So(string(data), ShouldNotContainSubstring, accountID)
+				}
+			})
+			Convey("other accounts are unaffected", func() {
+				err = decider.Put(ctx, connectionstore.Connection{
+					ID:        uuid.NewV4().String(),
+					AccountID: uuid.NewV4().String(),
+					ClientID:  uuid.NewV4().String(),
+					RequestID: uuid.NewV4().String(),
+				})
+				So(err, ShouldBeNil)
+			})
+		})
 	})
 }
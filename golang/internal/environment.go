@@ -4,6 +4,7 @@ import (
 	"log"
 	"net/url"
 	"os"
+	"strconv"
 
 	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
 )
@@ -24,6 +25,18 @@ func EnvStringOrFatal(key string) string {
 	return value
 }
 
+func EnvInt64OrDefault(key string, defaultValue int64) int64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		log.Fatalf("%s must be an integer: %s", key, err)
+	}
+	return parsed
+}
+
 func EnvURLOrFatal(key string) *url.URL {
 	value := EnvStringOrFatal(key)
 	target, err := url.Parse(value)
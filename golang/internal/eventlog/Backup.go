@@ -0,0 +1,377 @@
+package eventlog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ansel1/merry"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/logx"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/store"
+)
+
+const BackupManifestKey = "manifest"
+
+const DefaultBackupMaxChunkSize = 4 * 1024 * 1024
+
+// BackupManifest lists the chunks that have been shipped, in order. A chunk
+// is a JSONL range of events. Only the last chunk is ever overwritten, with
+// more events appended to it, until it reaches the maximum chunk size, so the
+// manifest grows by one chunk per maximum chunk size shipped.
+//
+// It also carries the hash chain record of the log, as read when the chunks
+// were shipped, so that a restored log knows where its chain started.
+type BackupManifest struct {
+	Chunks           []BackupChunk     `json:"chunks"`
+	HashChainStart   int64             `json:"hash_chain_start,omitempty"`
+	HashChainRebases []HashChainRebase `json:"hash_chain_rebases,omitempty"`
+}
+
+type BackupChunk struct {
+	Key               string `json:"key"`
+	FirstLogicalClock int64  `json:"first_logical_clock"`
+	LastLogicalClock  int64  `json:"last_logical_clock"`
+	Count             int    `json:"count"`
+	// Size is the length of the listed events. The chunk object may be
+	// longer if a ship was interrupted after putting it. Chunks shipped
+	// before Size was recorded have none and are never extended.
+	Size int `json:"size,omitempty"`
+}
+
+func (manifest *BackupManifest) lastLogicalClock() int64 {
+	if len(manifest.Chunks) == 0 {
+		return 0
+	}
+	return manifest.Chunks[len(manifest.Chunks)-1].LastLogicalClock
+}
+
+func backupChunkKey(firstLogicalClock int64) string {
+	return fmt.Sprintf("chunks/%020d", firstLogicalClock)
+}
+
+func loadBackupManifest(ctx context.Context, backupStore store.Store) (manifest *BackupManifest, err error) {
+	manifest = new(BackupManifest)
+	data, err := backupStore.Get(ctx, BackupManifestKey)
+	if merry.HTTPCode(err) == http.StatusNotFound {
+		err = nil
+		return
+	}
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(data, manifest)
+	return
+}
+
+// Backup ships the events of a log to a store, typically a store.S3Store, in
+// chunks of about maxChunkSize bytes.
+type Backup struct {
+	eventLog     EventLog
+	hashChain    HashChainStarter
+	store        store.Store
+	maxChunkSize int
+	manifest     *BackupManifest
+	// tail is the data of the last chunk while it is smaller than
+	// maxChunkSize, which the next ship appends to.
+	tail []byte
+}
+
+type NewBackupInput struct {
+	EventLog EventLog
+	// HashChain is the log that records where its hash chain started, for
+	// when EventLog is a decorator of it. It defaults to EventLog if that is a
+	// HashChainStarter.
+	HashChain    HashChainStarter
+	Store        store.Store
+	MaxChunkSize int
+}
+
+func NewBackup(ctx context.Context, input NewBackupInput) (backup *Backup, err error) {
+	maxChunkSize := input.MaxChunkSize
+	if maxChunkSize == 0 {
+		maxChunkSize = DefaultBackupMaxChunkSize
+	}
+	manifest, err := loadBackupManifest(ctx, input.Store)
+	if err != nil {
+		return
+	}
+	hashChain := input.HashChain
+	if hashChain == nil {
+		hashChain, _ = input.EventLog.(HashChainStarter)
+	}
+	backup = &Backup{
+		eventLog:     input.EventLog,
+		hashChain:    hashChain,
+		store:        input.Store,
+		maxChunkSize: maxChunkSize,
+		manifest:     manifest,
+	}
+	err = backup.loadTail(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (backup *Backup) loadTail(ctx context.Context) (err error) {
+	chunk, ok := backup.lastChunk()
+	if !ok || chunk.Size == 0 || chunk.Size >= backup.maxChunkSize {
+		return
+	}
+	data, err := backup.store.Get(ctx, chunk.Key)
+	if err != nil {
+		return
+	}
+	if len(data) < chunk.Size {
+		return merry.Errorf("backup chunk %s is shorter than its %d bytes", chunk.Key, chunk.Size)
+	}
+	backup.tail = data[:chunk.Size]
+	return
+}
+
+func (backup *Backup) lastChunk() (chunk BackupChunk, ok bool) {
+	if len(backup.manifest.Chunks) == 0 {
+		return
+	}
+	return backup.manifest.Chunks[len(backup.manifest.Chunks)-1], true
+}
+
+// Cursor is the logical clock of the last event that has been shipped.
+func (backup *Backup) Cursor() int64 {
+	return backup.manifest.lastLogicalClock()
+}
+
+// Ship uploads every event after the last shipped chunk, appending to the
+// last chunk until it reaches maxChunkSize. Each chunk is put before the
+// manifest that lists it, so an interrupted ship leaves at worst a chunk
+// with events the manifest doesn't list, which the next attempt overwrites.
+func (backup *Backup) Ship(ctx context.Context) (count int, err error) {
+	iterator := backup.eventLog.GetEventIterator(ctx, GetEventIteratorInput{
+		FromCursor: backup.Cursor(),
+	})
+	var buffer bytes.Buffer
+	chunk := BackupChunk{}
+	replace := backup.tail != nil
+	if replace {
+		chunk, _ = backup.lastChunk()
+		buffer.Write(backup.tail)
+	}
+	pending := 0
+	for iterator.Next(ctx) {
+		event := iterator.Event()
+		data, err := json.Marshal(event)
+		if err != nil {
+			return count, err
+		}
+		if chunk.Count == 0 {
+			chunk.FirstLogicalClock = event.LogicalClock
+		}
+		buffer.Write(data)
+		buffer.WriteByte('\n')
+		chunk.LastLogicalClock = event.LogicalClock
+		chunk.Count++
+		pending++
+		if buffer.Len() >= backup.maxChunkSize {
+			err = backup.putChunk(ctx, chunk, buffer.Bytes(), replace)
+			if err != nil {
+				return count, err
+			}
+			count += pending
+			pending = 0
+			buffer = bytes.Buffer{}
+			chunk = BackupChunk{}
+			replace = false
+		}
+	}
+	err = iterator.Err()
+	if err != nil {
+		return
+	}
+	if pending == 0 {
+		return
+	}
+	err = backup.putChunk(ctx, chunk, buffer.Bytes(), replace)
+	if err != nil {
+		return
+	}
+	count += pending
+	return
+}
+
+// putChunk puts a chunk and lists it in the manifest, in place of the last
+// chunk if replace is set.
+func (backup *Backup) putChunk(ctx context.Context, chunk BackupChunk, data []byte, replace bool) (err error) {
+	chunk.Key = backupChunkKey(chunk.FirstLogicalClock)
+	chunk.Size = len(data)
+	err = backup.store.Put(ctx, chunk.Key, data)
+	if err != nil {
+		return
+	}
+	chunks := backup.manifest.Chunks
+	if replace {
+		chunks = chunks[:len(chunks)-1]
+	}
+	manifest := BackupManifest{
+		Chunks:           append(chunks[:len(chunks):len(chunks)], chunk),
+		HashChainStart:   backup.manifest.HashChainStart,
+		HashChainRebases: backup.manifest.HashChainRebases,
+	}
+	if backup.hashChain != nil {
+		manifest.HashChainStart, err = backup.hashChain.HashChainStart()
+		if err != nil {
+			return
+		}
+		manifest.HashChainRebases, err = backup.hashChain.HashChainRebases()
+		if err != nil {
+			return
+		}
+	}
+	manifestData, err := json.Marshal(manifest)
+	if err != nil {
+		return
+	}
+	err = backup.store.Put(ctx, BackupManifestKey, manifestData)
+	if err != nil {
+		return
+	}
+	backup.manifest = &manifest
+	backup.tail = nil
+	if chunk.Size < backup.maxChunkSize {
+		backup.tail = data
+	}
+	return
+}
+
+// Run ships new events every interval until ctx is done.
+func (backup *Backup) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		count, err := backup.Ship(ctx)
+		if err != nil {
+			logx.Errorln("failed to back up eventlog:", err)
+			continue
+		}
+		if count != 0 {
+			logx.Infof("backed up %d events up to %d\n", count, backup.Cursor())
+		}
+	}
+}
+
+var ErrRestoreFolderNotEmpty = merry.New("restore folder already contains an event log").WithHTTPCode(http.StatusConflict)
+
+type RestoreFileEventLogInput struct {
+	Store          store.Store
+	FolderPath     string
+	MaxSegmentSize int64
+	IndexInterval  int64
+	// ToLogicalClock is the last event to restore. Zero restores everything.
+	ToLogicalClock int64
+}
+
+// RestoreFileEventLog rebuilds a FileEventLog folder from the chunks written
+// by Backup, preserving event IDs and logical clocks, and the record of where
+// the hash chain started if the restored events reach it. It refuses to
+// write into a folder that already holds a log.
+func RestoreFileEventLog(ctx context.Context, input RestoreFileEventLogInput) (cursor int64, err error) {
+	err = os.MkdirAll(input.FolderPath, 0755)
+	if err != nil {
+		return
+	}
+	exists, err := fileEventLogExists(input.FolderPath)
+	if err != nil {
+		return
+	}
+	if exists {
+		err = ErrRestoreFolderNotEmpty.Here()
+		return
+	}
+	manifest, err := loadBackupManifest(ctx, input.Store)
+	if err != nil {
+		return
+	}
+	eventLog, err := NewFileEventLog(&NewFileEventLogInput{
+		FolderPath:     input.FolderPath,
+		MaxSegmentSize: input.MaxSegmentSize,
+		IndexInterval:  input.IndexInterval,
+	})
+	if err != nil {
+		return
+	}
+	for _, chunk := range manifest.Chunks {
+		if input.ToLogicalClock != 0 && chunk.FirstLogicalClock > input.ToLogicalClock {
+			break
+		}
+		data, err := input.Store.Get(ctx, chunk.Key)
+		if err != nil {
+			return cursor, err
+		}
+		if chunk.Size != 0 && chunk.Size < len(data) {
+			data = data[:chunk.Size]
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(nil, len(data)+1)
+		for scanner.Scan() {
+			var event Event
+			err = json.Unmarshal(scanner.Bytes(), &event)
+			if err != nil {
+				return cursor, err
+			}
+			if input.ToLogicalClock != 0 && event.LogicalClock > input.ToLogicalClock {
+				break
+			}
+			if event.LogicalClock <= cursor {
+				continue
+			}
+			err = eventLog.write([]*Event{&event})
+			if err != nil {
+				return cursor, err
+			}
+			cursor = event.LogicalClock
+		}
+		if err = scanner.Err(); err != nil {
+			return cursor, err
+		}
+	}
+	err = eventLog.sync()
+	if err != nil {
+		return
+	}
+	eventLog.cursor = cursor
+	if manifest.HashChainStart != 0 && manifest.HashChainStart <= cursor {
+		err = writeHashChainMetadata(input.FolderPath, hashChainMetadata{
+			Start:   manifest.HashChainStart,
+			Rebases: manifest.HashChainRebases,
+		})
+		if err != nil {
+			return
+		}
+	}
+	err = writeFileEventLogMetadata(filepath.Join(input.FolderPath, MetadataFileName), &FileEventLogMetadata{
+		Cursor: cursor,
+	})
+	return
+}
+
+func fileEventLogExists(folderPath string) (exists bool, err error) {
+	for _, fileName := range []string{MetadataFileName, EventsFileName} {
+		if _, err := os.Stat(filepath.Join(folderPath, fileName)); err == nil {
+			return true, nil
+		}
+	}
+	firstLogicalClocks, err := listSegmentFirstLogicalClocks(folderPath)
+	exists = len(firstLogicalClocks) != 0
+	return
+}
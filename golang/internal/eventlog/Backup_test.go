@@ -0,0 +1,208 @@
+package eventlog_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/store"
+)
+
+func TestBackup(t *testing.T) {
+	ctx := context.Background()
+	Convey("TestBackup", t, func() {
+		folderPath, err := os.MkdirTemp("testdata", "TestBackup-*")
+		So(err, ShouldBeNil)
+		source := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
+			FolderPath: filepath.Join(folderPath),
+		})
+		events := make([]*eventlog.Event, 0)
+		appendEvents := func(n int) {
+			for i := 0; i < n; i++ {
+				event, err := source.Append(ctx, eventlog.AppendInput{
+					Type:      "test",
+					AccountID: uuid.NewV4().String(),
+					Data:      fatal.UnlessMarshalJSON(uuid.NewV4().String()),
+				})
+				So(err, ShouldBeNil)
+				events = append(events, event)
+			}
+		}
+		backupFolderPath, err := os.MkdirTemp("testdata", "TestBackupStore-*")
+		So(err, ShouldBeNil)
+		stores := map[string]store.Store{
+			"memory": store.NewMemoryStore(),
+			"file system": store.NewFileSystemStore(&store.NewFileSystemStoreInput{
+				Root: backupFolderPath,
+			}),
+		}
+		for name, backupStore := range stores {
+			Convey(name, func() {
+				backup, err := eventlog.NewBackup(ctx, eventlog.NewBackupInput{
+					EventLog:     source,
+					Store:        backupStore,
+					MaxChunkSize: 1024,
+				})
+				So(err, ShouldBeNil)
+				count, err := backup.Ship(ctx)
+				So(err, ShouldBeNil)
+				So(count, ShouldEqual, 0)
+				appendEvents(30)
+				count, err = backup.Ship(ctx)
+				So(err, ShouldBeNil)
+				So(count, ShouldEqual, 30)
+				appendEvents(5)
+				resumed, err := eventlog.NewBackup(ctx, eventlog.NewBackupInput{
+					EventLog:     source,
+					Store:        backupStore,
+					MaxChunkSize: 1024,
+				})
+				So(err, ShouldBeNil)
+				So(resumed.Cursor(), ShouldEqual, 30)
+				count, err = resumed.Ship(ctx)
+				So(err, ShouldBeNil)
+				So(count, ShouldEqual, 5)
+				So(resumed.Cursor(), ShouldEqual, 35)
+				assertRestored := func(toLogicalClock int64, expected []*eventlog.Event) {
+					restoreFolderPath, err := os.MkdirTemp("testdata", "TestBackupRestore-*")
+					So(err, ShouldBeNil)
+					cursor, err := eventlog.RestoreFileEventLog(ctx, eventlog.RestoreFileEventLogInput{
+						Store:          backupStore,
+						FolderPath:     restoreFolderPath,
+						MaxSegmentSize: 2048,
+						ToLogicalClock: toLogicalClock,
+					})
+					So(err, ShouldBeNil)
+					So(cursor, ShouldEqual, len(expected))
+					restored := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
+						FolderPath: restoreFolderPath,
+					})
+					So(restored.Recovery().Repaired(), ShouldBeFalse)
+					iterator := restored.GetEventIterator(ctx, eventlog.GetEventIteratorInput{
+						FromCursor: 0,
+					})
+					for _, event := range expected {
+						So(iterator.Next(ctx), ShouldBeTrue)
+						So(iterator.Event(), ShouldResemble, event)
+					}
+					So(iterator.Next(ctx), ShouldBeFalse)
+					_, err = eventlog.RestoreFileEventLog(ctx, eventlog.RestoreFileEventLogInput{
+						Store:      backupStore,
+						FolderPath: restoreFolderPath,
+					})
+					So(err, ShouldWrap, eventlog.ErrRestoreFolderNotEmpty)
+				}
+				Convey("restore everything", func() {
+					assertRestored(0, events)
+				})
+				Convey("restore up to a logical clock", func() {
+					assertRestored(17, events[:17])
+				})
+				Convey("frequent ships", func() {
+					for i := 0; i < 30; i++ {
+						appendEvents(1)
+						count, err := resumed.Ship(ctx)
+						So(err, ShouldBeNil)
+						So(count, ShouldEqual, 1)
+					}
+					data, err := backupStore.Get(ctx, eventlog.BackupManifestKey)
+					So(err, ShouldBeNil)
+					var manifest eventlog.BackupManifest
+					So(json.Unmarshal(data, &manifest), ShouldBeNil)
+					size := 0
+					for i, chunk := range manifest.Chunks {
+						if i < len(manifest.Chunks)-1 {
+							So(chunk.Size, ShouldBeGreaterThanOrEqualTo, 1024)
+						}
+						size += chunk.Size
+					}
+					So(len(manifest.Chunks), ShouldBeLessThanOrEqualTo, size/1024+1)
+					assertRestored(0, events)
+				})
+			})
+		}
+	})
+}
+
+func TestBackupHashChain(t *testing.T) {
+	ctx := context.Background()
+	Convey("TestBackupHashChain", t, func() {
+		folderPath, err := os.MkdirTemp("testdata", "TestBackup-*")
+		So(err, ShouldBeNil)
+		source := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
+			FolderPath: folderPath,
+		})
+		appendEvents := func(eventLog eventlog.EventLog, n int) {
+			for i := 0; i < n; i++ {
+				_, err := eventLog.Append(ctx, eventlog.AppendInput{
+					Type: "test",
+					Data: fatal.UnlessMarshalJSON(i),
+				})
+				So(err, ShouldBeNil)
+			}
+		}
+		appendEvents(source, 2)
+		decorator := eventlog.NewHashChainDecoratorOrFatal(ctx, eventlog.NewHashChainDecoratorInput{
+			Decorated: source,
+		})
+		appendEvents(decorator, 3)
+		backupStore := store.NewMemoryStore()
+		backup, err := eventlog.NewBackup(ctx, eventlog.NewBackupInput{
+			EventLog:  decorator,
+			HashChain: source,
+			Store:     backupStore,
+		})
+		So(err, ShouldBeNil)
+		_, err = backup.Ship(ctx)
+		So(err, ShouldBeNil)
+		restore := func(toLogicalClock int64) *eventlog.FileEventLog {
+			restoreFolderPath, err := os.MkdirTemp("testdata", "TestBackupRestore-*")
+			So(err, ShouldBeNil)
+			_, err = eventlog.RestoreFileEventLog(ctx, eventlog.RestoreFileEventLogInput{
+				Store:          backupStore,
+				FolderPath:     restoreFolderPath,
+				ToLogicalClock: toLogicalClock,
+			})
+			So(err, ShouldBeNil)
+			return eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
+				FolderPath: restoreFolderPath,
+			})
+		}
+		Convey("restore the chain start", func() {
+			restored := restore(0)
+			start, err := restored.HashChainStart()
+			So(err, ShouldBeNil)
+			So(start, ShouldEqual, 3)
+			report, err := eventlog.VerifyHashChain(ctx, restored)
+			So(err, ShouldBeNil)
+			So(report.ChainedEvents, ShouldEqual, 3)
+			Convey("previous hashes stripped", func() {
+				segments := restored.Segments()
+				filePath := segments[len(segments)-1].FilePath
+				data, err := os.ReadFile(filePath)
+				So(err, ShouldBeNil)
+				data = regexp.MustCompile(`,"previous_hash":"[0-9a-f]*"`).ReplaceAll(data, nil)
+				So(os.WriteFile(filePath, data, 0644), ShouldBeNil)
+				_, err = eventlog.VerifyHashChain(ctx, restored)
+				var brokenLink *eventlog.BrokenLinkError
+				So(errors.As(err, &brokenLink), ShouldBeTrue)
+				So(brokenLink.LogicalClock, ShouldEqual, 3)
+			})
+		})
+		Convey("restore before the chain started", func() {
+			restored := restore(2)
+			start, err := restored.HashChainStart()
+			So(err, ShouldBeNil)
+			So(start, ShouldEqual, 0)
+		})
+	})
+}
@@ -2,14 +2,21 @@ package eventlog_test
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"testing"
 
+	uuid "github.com/satori/go.uuid"
+	. "github.com/smartystreets/goconvey/convey"
+
 	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
 )
 
 type CachingDecoratorFactory struct {
-	pattern string
+	pattern   string
+	maxEvents int
+	maxBytes  int64
 }
 
 func (factory *CachingDecoratorFactory) Create(ctx context.Context) eventlog.EventLog {
@@ -18,9 +25,11 @@ func (factory *CachingDecoratorFactory) Create(ctx context.Context) eventlog.Eve
 		panic(err)
 	}
 	eventLog, err := eventlog.NewCachingDecorator(ctx, eventlog.NewCachingDecoratorInput{
-		Decorated: eventlog.NewFileEventLog(&eventlog.NewFileEventLogInput{
+		Decorated: eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
 			FolderPath: folderPath,
 		}),
+		MaxEvents: factory.maxEvents,
+		MaxBytes:  factory.maxBytes,
 	})
 	if err != nil {
 		panic(err)
@@ -34,6 +43,114 @@ func TestCachingDecorator(t *testing.T) {
 	})
 }
 
+func TestBoundedCachingDecorator(t *testing.T) {
+	testEventLog(t, &CachingDecoratorFactory{
+		pattern:   "TestBoundedCachingDecorator-*",
+		maxEvents: 10,
+	})
+	testEventLog(t, &CachingDecoratorFactory{
+		pattern:  "TestBoundedCachingDecorator-*",
+		maxBytes: 4096,
+	})
+	ctx := context.Background()
+	Convey("iterate across the cached window", t, func() {
+		folderPath, err := os.MkdirTemp("testdata", "TestBoundedCachingDecorator-*")
+		So(err, ShouldBeNil)
+		decorated := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
+			FolderPath: folderPath,
+		})
+		accountIDs := []string{uuid.NewV4().String(), uuid.NewV4().String()}
+		events := make([]*eventlog.Event, 0)
+		appendEvents := func(eventLog eventlog.EventLog, n int) {
+			for i := 0; i < n; i++ {
+				event, err := eventLog.Append(ctx, eventlog.AppendInput{
+					Type:      fmt.Sprintf("test.%d", i%3),
+					AccountID: accountIDs[i%2],
+					Data:      fatal.UnlessMarshalJSON(i),
+				})
+				So(err, ShouldBeNil)
+				events = append(events, event)
+			}
+		}
+		appendEvents(decorated, 20)
+		eventLog, err := eventlog.NewCachingDecorator(ctx, eventlog.NewCachingDecoratorInput{
+			Decorated: decorated,
+			MaxEvents: 8,
+		})
+		So(err, ShouldBeNil)
+		So(eventLog.Cursor(), ShouldEqual, 20)
+		appendEvents(eventLog, 10)
+		So(eventLog.Cursor(), ShouldEqual, 30)
+		assertIterates := func(input eventlog.GetEventIteratorInput, matches func(event *eventlog.Event) bool) {
+			for cursor := int64(0); cursor <= int64(len(events)); cursor++ {
+				input.FromCursor = cursor
+				iterator := eventLog.GetEventIterator(ctx, input)
+				for _, event := range events[cursor:] {
+					if !matches(event) {
+						continue
+					}
+					So(iterator.Next(ctx), ShouldBeTrue)
+					So(iterator.Event(), ShouldResemble, event)
+				}
+				So(iterator.Next(ctx), ShouldBeFalse)
+				So(iterator.Err(), ShouldBeNil)
+			}
+		}
+		Convey("unfiltered", func() {
+			assertIterates(eventlog.GetEventIteratorInput{}, func(event *eventlog.Event) bool {
+				return true
+			})
+		})
+		Convey("types", func() {
+			assertIterates(eventlog.GetEventIteratorInput{
+				Types: []string{"test.0", "test.2"},
+			}, func(event *eventlog.Event) bool {
+				return event.Type != "test.1"
+			})
+		})
+		Convey("account", func() {
+			assertIterates(eventlog.GetEventIteratorInput{
+				AccountID: accountIDs[1],
+			}, func(event *eventlog.Event) bool {
+				return event.AccountID == accountIDs[1]
+			})
+		})
+		Convey("iterator outlives eviction", func() {
+			iterator := eventLog.GetEventIterator(ctx, eventlog.GetEventIteratorInput{
+				FromCursor: 25,
+			})
+			expected := events[25:]
+			appendEvents(eventLog, 20)
+			for _, event := range expected {
+				So(iterator.Next(ctx), ShouldBeTrue)
+				So(iterator.Event(), ShouldResemble, event)
+			}
+			So(iterator.Next(ctx), ShouldBeFalse)
+		})
+		Convey("fallback reads close their files", func() {
+			countOpenFiles := func() int {
+				entries, err := os.ReadDir("/proc/self/fd")
+				if err != nil {
+					SkipSo(err, ShouldBeNil)
+					return -1
+				}
+				return len(entries)
+			}
+			before := countOpenFiles()
+			if before == -1 {
+				return
+			}
+			for i := 0; i < 200; i++ {
+				iterator := eventLog.GetEventIterator(ctx, eventlog.GetEventIteratorInput{})
+				for iterator.Next(ctx) {
+				}
+				So(iterator.Err(), ShouldBeNil)
+			}
+			So(countOpenFiles(), ShouldBeLessThanOrEqualTo, before)
+		})
+	})
+}
+
 func BenchmarkCachingDecoratorAppend(b *testing.B) {
 	benchmarkAppend(b, &CachingDecoratorFactory{
 		pattern: "BenchmarkCachingDecorator-*",
@@ -2,17 +2,40 @@ package eventlog
 
 import (
 	"context"
+	"slices"
+	"sort"
 
 	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
 )
 
+// eventOverhead approximates the memory used by an Event beyond its strings
+// and data, for MaxBytes accounting.
+const eventOverhead = 128
+
+// CachingDecorator keeps the most recent events in memory. When MaxEvents or
+// MaxBytes is set, older events are evicted and iterators that start before
+// the cached window read them from the decorated log before switching to
+// the cache.
 type CachingDecorator struct {
 	decorated EventLog
+	maxEvents int
+	maxBytes  int64
 	events    []*Event
+	// evicted is the number of events evicted so far, and so the absolute
+	// position of events[0]. Positions in the indexes are absolute.
+	evicted                 int
+	dead                    int
+	size                    int64
+	lastEvictedLogicalClock int64
+	positionsByAccountID    map[string][]int
+	positionsByType         map[string][]int
 }
 
 type NewCachingDecoratorInput struct {
 	Decorated EventLog
+	// MaxEvents and MaxBytes bound the cache. Zero means unbounded.
+	MaxEvents int
+	MaxBytes  int64
 }
 
 func NewCachingDecorator(ctx context.Context, input NewCachingDecoratorInput) (decorator *CachingDecorator, err error) {
@@ -20,23 +43,28 @@ func NewCachingDecorator(ctx context.Context, input NewCachingDecoratorInput) (d
 	iterator := input.Decorated.GetEventIterator(ctx, GetEventIteratorInput{
 		FromCursor: 0,
 	})
+	decorator = &CachingDecorator{
+		decorated:            input.Decorated,
+		maxEvents:            input.MaxEvents,
+		maxBytes:             input.MaxBytes,
+		events:               events,
+		positionsByAccountID: make(map[string][]int),
+		positionsByType:      make(map[string][]int),
+	}
 	lastLogicalClock := int64(0)
 	for iterator.Next(ctx) {
 		event := iterator.Event()
 		if event.LogicalClock <= lastLogicalClock {
 			continue
 		}
-		events = append(events, event)
+		decorator.add(event)
 		lastLogicalClock = event.LogicalClock
 	}
 	err = iterator.Err()
 	if err != nil {
+		decorator = nil
 		return
 	}
-	decorator = &CachingDecorator{
-		decorated: input.Decorated,
-		events:    events,
-	}
 	return
 }
 
@@ -51,15 +79,172 @@ func (decorator *CachingDecorator) Append(ctx context.Context, input AppendInput
 	if err != nil {
 		return
 	}
-	decorator.events = append(decorator.events, event)
+	decorator.add(event)
+	return
+}
+
+func (decorator *CachingDecorator) AppendBatch(ctx context.Context, inputs []AppendInput) (events []*Event, err error) {
+	events, err = decorator.decorated.AppendBatch(ctx, inputs)
+	if err != nil {
+		return
+	}
+	for _, event := range events {
+		decorator.add(event)
+	}
 	return
 }
 
+func (decorator *CachingDecorator) add(event *Event) {
+	position := decorator.evicted + len(decorator.events)
+	decorator.events = append(decorator.events, event)
+	decorator.size += eventSize(event)
+	if event.AccountID != "" {
+		decorator.positionsByAccountID[event.AccountID] = append(decorator.positionsByAccountID[event.AccountID], position)
+	}
+	decorator.positionsByType[event.Type] = append(decorator.positionsByType[event.Type], position)
+	for decorator.full() {
+		decorator.evict()
+	}
+}
+
+func (decorator *CachingDecorator) full() bool {
+	if len(decorator.events) <= 1 {
+		return false
+	}
+	if decorator.maxEvents != 0 && len(decorator.events) > decorator.maxEvents {
+		return true
+	}
+	return decorator.maxBytes != 0 && decorator.size > decorator.maxBytes
+}
+
+// evict drops the oldest cached event. The events slice is resliced rather
+// than cleared so that iterators already holding it are unaffected, and is
+// copied once the dead prefix outgrows the live window so that the evicted
+// events can be collected.
+func (decorator *CachingDecorator) evict() {
+	event := decorator.events[0]
+	decorator.events = decorator.events[1:]
+	decorator.evicted++
+	decorator.dead++
+	decorator.size -= eventSize(event)
+	decorator.lastEvictedLogicalClock = event.LogicalClock
+	if decorator.dead > len(decorator.events) {
+		decorator.events = slices.Clone(decorator.events)
+		decorator.dead = 0
+	}
+	if event.AccountID != "" {
+		popPosition(decorator.positionsByAccountID, event.AccountID)
+	}
+	popPosition(decorator.positionsByType, event.Type)
+}
+
+func popPosition(positionsByKey map[string][]int, key string) {
+	positions := positionsByKey[key][1:]
+	if len(positions) == 0 {
+		delete(positionsByKey, key)
+		return
+	}
+	positionsByKey[key] = positions
+}
+
+func eventSize(event *Event) int64 {
+	return int64(len(event.ID)+len(event.Type)+len(event.AccountID)+len(event.StreamKey)+len(event.Data)) + eventOverhead
+}
+
+// GetEventIterator reads from the cache when it holds every event after
+// input.FromCursor. Otherwise the events up to the last evicted one are read
+// from the decorated log and the rest from the cache.
 func (decorator *CachingDecorator) GetEventIterator(ctx context.Context, input GetEventIteratorInput) (iterator EventIterator) {
-	return &SliceEventIterator{
-		events: decorator.events,
-		index:  input.FromCursor - 1,
+	if input.FromCursor >= decorator.lastEvictedLogicalClock {
+		return decorator.getCachedEventIterator(input)
+	}
+	fromCache := input
+	fromCache.FromCursor = decorator.lastEvictedLogicalClock
+	return &CompositeEventIterator{
+		iterators: []EventIterator{
+			&limitedEventIterator{
+				decorated:      decorator.decorated.GetEventIterator(ctx, input),
+				toLogicalClock: decorator.lastEvictedLogicalClock,
+			},
+			decorator.getCachedEventIterator(fromCache),
+		},
+	}
+}
+
+// getCachedEventIterator uses the account and type indexes to visit only the
+// events that can match the input. The filter is still applied while
+// iterating because only one index is used at a time. Streams are not
+// indexed, so a filter on the stream alone visits every cached event.
+func (decorator *CachingDecorator) getCachedEventIterator(input GetEventIteratorInput) (iterator EventIterator) {
+	events := decorator.events
+	filter := newEventFilter(input)
+	if filter == nil || (input.AccountID == "" && filter.types == nil) {
+		return filterEventIterator(&SliceEventIterator{
+			events: events,
+			index: int64(sort.Search(len(events), func(i int) bool {
+				return events[i].LogicalClock > input.FromCursor
+			})) - 1,
+		}, input)
+	}
+	var candidates [][]int
+	switch {
+	case input.AccountID != "":
+		candidates = append(candidates, decorator.positionsByAccountID[input.AccountID])
+		for _, eventType := range input.UnscopedTypes {
+			candidates = append(candidates, decorator.positionsByType[eventType])
+		}
+	default:
+		for eventType := range filter.types {
+			candidates = append(candidates, decorator.positionsByType[eventType])
+		}
 	}
+	base := decorator.evicted
+	for i, positions := range candidates {
+		candidates[i] = positions[sort.Search(len(positions), func(j int) bool {
+			return events[positions[j]-base].LogicalClock > input.FromCursor
+		}):]
+	}
+	return filterEventIterator(&PositionEventIterator{
+		events:    events,
+		base:      base,
+		positions: mergePositions(candidates),
+		index:     -1,
+	}, input)
+}
+
+// mergePositions merges sorted lists of positions into one sorted list
+// without duplicates.
+func mergePositions(lists [][]int) (merged []int) {
+	if len(lists) == 1 {
+		return lists[0]
+	}
+	for {
+		next := -1
+		for _, positions := range lists {
+			if len(positions) != 0 && (next == -1 || positions[0] < next) {
+				next = positions[0]
+			}
+		}
+		if next == -1 {
+			return
+		}
+		for i, positions := range lists {
+			if len(positions) != 0 && positions[0] == next {
+				lists[i] = positions[1:]
+			}
+		}
+		merged = append(merged, next)
+	}
+}
+
+// Cursor is the logical clock of the last cached event. The cache always
+// keeps the last event, so it is the cursor of the decorated log as long as
+// it is only appended to through the decorator.
+func (decorator *CachingDecorator) Cursor() int64 {
+	if len(decorator.events) == 0 {
+		return decorator.lastEvictedLogicalClock
+	}
+	return decorator.events[len(decorator.events)-1].LogicalClock
 }
 
 func (decorator *CachingDecorator) Wait(ctx context.Context) <-chan struct{} {
@@ -83,3 +268,25 @@ func (iterator *SliceEventIterator) Event() *Event {
 func (iterator *SliceEventIterator) Err() error {
 	return nil
 }
+
+// PositionEventIterator visits the events at the given absolute positions.
+// base is the absolute position of events[0].
+type PositionEventIterator struct {
+	events    []*Event
+	base      int
+	positions []int
+	index     int
+}
+
+func (iterator *PositionEventIterator) Next(ctx context.Context) bool {
+	iterator.index++
+	return iterator.index < len(iterator.positions)
+}
+
+func (iterator *PositionEventIterator) Event() *Event {
+	return iterator.events[iterator.positions[iterator.index]-iterator.base]
+}
+
+func (iterator *PositionEventIterator) Err() error {
+	return nil
+}
@@ -0,0 +1,129 @@
+package eventlog
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ansel1/merry"
+)
+
+var ErrCompactFolderNotEmpty = merry.New("compact folder already contains an event log").WithHTTPCode(http.StatusConflict)
+
+type CompactFileEventLogInput struct {
+	EventLog       EventLog
+	FolderPath     string
+	MaxSegmentSize int64
+	IndexInterval  int64
+	// Before is the first logical clock that is always kept. Drop is only
+	// asked about the events before it.
+	Before int64
+	Drop   func(event *Event) bool
+}
+
+type CompactionReport struct {
+	Cursor  int64
+	Kept    int
+	Dropped int
+	// Rebase is set when the log was hash chained, and records the verified
+	// head of the source chain that the kept events were relinked from.
+	Rebase *HashChainRebase
+}
+
+// CompactFileEventLog copies the events of a log into a new FileEventLog
+// folder, leaving out the ones Drop reports. Kept events keep their IDs and
+// logical clocks, so the compacted log has gaps rather than renumbered
+// events and cursors handed out by the original stay valid. Only events
+// before Before can be dropped, which keeps the last event and with it the
+// cursor. A hash chain is verified as it is read and relinked over the kept
+// events, and the head of the source chain is recorded in the new folder
+// with any earlier rebases of the source, so that VerifyHashChain reports
+// where the chain was relinked. It refuses to write into a folder that
+// already holds a log.
+func CompactFileEventLog(ctx context.Context, input CompactFileEventLogInput) (report CompactionReport, err error) {
+	err = os.MkdirAll(input.FolderPath, 0755)
+	if err != nil {
+		return
+	}
+	exists, err := fileEventLogExists(input.FolderPath)
+	if err != nil {
+		return
+	}
+	if exists {
+		err = ErrCompactFolderNotEmpty.Here()
+		return
+	}
+	eventLog, err := NewFileEventLog(&NewFileEventLogInput{
+		FolderPath:     input.FolderPath,
+		MaxSegmentSize: input.MaxSegmentSize,
+		IndexInterval:  input.IndexInterval,
+	})
+	if err != nil {
+		return
+	}
+	var rebases []HashChainRebase
+	if starter, ok := input.EventLog.(HashChainStarter); ok {
+		rebases, err = starter.HashChainRebases()
+		if err != nil {
+			return
+		}
+	}
+	iterator := newHashChainIterator(ctx, input.EventLog, 0)
+	head := GenesisHash
+	var chainStart, sourceCursor int64
+	for iterator.Next(ctx) {
+		event := iterator.Event()
+		sourceCursor = event.LogicalClock
+		if event.LogicalClock < input.Before && input.Drop(event) {
+			report.Dropped++
+			continue
+		}
+		if event.PreviousHash != "" {
+			if chainStart == 0 {
+				chainStart = event.LogicalClock
+			}
+			relinked := *event
+			relinked.PreviousHash = head
+			event = &relinked
+		}
+		head = nextHash(head, event)
+		err = eventLog.write([]*Event{event})
+		if err != nil {
+			return
+		}
+		report.Kept++
+		report.Cursor = event.LogicalClock
+	}
+	err = iterator.Err()
+	if err != nil {
+		return
+	}
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	err = eventLog.sync()
+	if err != nil {
+		return
+	}
+	eventLog.cursor = report.Cursor
+	if chainStart != 0 {
+		report.Rebase = &HashChainRebase{
+			SourceCursor:  sourceCursor,
+			SourceHead:    iterator.head,
+			UnixTimestamp: time.Now().Unix(),
+		}
+		err = writeHashChainMetadata(input.FolderPath, hashChainMetadata{
+			Start:   chainStart,
+			Rebases: append(rebases, *report.Rebase),
+		})
+		if err != nil {
+			return
+		}
+	}
+	err = writeFileEventLogMetadata(filepath.Join(input.FolderPath, MetadataFileName), &FileEventLogMetadata{
+		Cursor: report.Cursor,
+	})
+	return
+}
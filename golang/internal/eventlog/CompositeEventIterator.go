@@ -1,7 +1,16 @@
 package eventlog
 
-import "context"
+import (
+	"context"
+	"io"
 
+	"github.com/ansel1/merry"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/logx"
+)
+
+// CompositeEventIterator reads its iterators one after the other, skipping
+// any event at or before the last one it returned.
 type CompositeEventIterator struct {
 	iterators []EventIterator
 	event     *Event
@@ -10,6 +19,10 @@ type CompositeEventIterator struct {
 }
 
 func (iterator *CompositeEventIterator) Next(ctx context.Context) bool {
+	if iterator.err != nil && !merry.Is(iterator.err, ErrCorruptEvent) {
+		return false
+	}
+	iterator.err = nil
 	firstIterator := iterator.iterators[0]
 	if ok := firstIterator.Next(ctx); !ok {
 		err := firstIterator.Err()
@@ -25,7 +38,7 @@ func (iterator *CompositeEventIterator) Next(ctx context.Context) bool {
 		return iterator.Next(ctx)
 	}
 	event := firstIterator.Event()
-	if event.LogicalClock < iterator.head {
+	if event.LogicalClock <= iterator.head {
 		return iterator.Next(ctx)
 	}
 	iterator.event = event
@@ -40,3 +53,44 @@ func (iterator *CompositeEventIterator) Event() *Event {
 func (iterator *CompositeEventIterator) Err() error {
 	return iterator.err
 }
+
+// limitedEventIterator stops before the first event after toLogicalClock,
+// closing the decorated iterator, which isn't read to its end.
+type limitedEventIterator struct {
+	decorated      EventIterator
+	toLogicalClock int64
+	done           bool
+}
+
+func (iterator *limitedEventIterator) Next(ctx context.Context) bool {
+	if iterator.done || !iterator.decorated.Next(ctx) {
+		return false
+	}
+	if iterator.decorated.Event().LogicalClock <= iterator.toLogicalClock {
+		return true
+	}
+	iterator.done = true
+	err := closeEventIterator(iterator.decorated)
+	if err != nil {
+		logx.Warnln("failed to close event iterator:", err)
+	}
+	return false
+}
+
+func (iterator *limitedEventIterator) Event() *Event {
+	return iterator.decorated.Event()
+}
+
+func (iterator *limitedEventIterator) Err() error {
+	return iterator.decorated.Err()
+}
+
+// closeEventIterator releases the files of an iterator that is abandoned
+// before its end. Iterators that hold none don't implement io.Closer.
+func closeEventIterator(iterator EventIterator) error {
+	closer, ok := iterator.(io.Closer)
+	if !ok {
+		return nil
+	}
+	return closer.Close()
+}
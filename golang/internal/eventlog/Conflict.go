@@ -0,0 +1,93 @@
+package eventlog
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/ansel1/merry"
+)
+
+// ConflictError is returned by Append when AppendInput.ExpectedLogicalClock
+// does not match the last event in the stream. It is wrapped with
+// http.StatusConflict so that httpx.Error responds with a 409.
+type ConflictError struct {
+	StreamKey            string
+	ExpectedLogicalClock int64
+	ActualLogicalClock   int64
+}
+
+func (err *ConflictError) Error() string {
+	stream := "log"
+	if err.StreamKey != "" {
+		stream = "stream " + err.StreamKey
+	}
+	return fmt.Sprintf("%s is at logical clock %d, expected %d", stream, err.ActualLogicalClock, err.ExpectedLogicalClock)
+}
+
+// checkExpectedLogicalClock returns a conflict if input expects a logical
+// clock other than head.
+func checkExpectedLogicalClock(input AppendInput, head int64) error {
+	if input.ExpectedLogicalClock == nil || *input.ExpectedLogicalClock == head {
+		return nil
+	}
+	return merry.Wrap(&ConflictError{
+		StreamKey:            input.StreamKey,
+		ExpectedLogicalClock: *input.ExpectedLogicalClock,
+		ActualLogicalClock:   head,
+	}).WithHTTPCode(http.StatusConflict)
+}
+
+// checkBatch checks each expected logical clock against a log at cursor as it
+// will be once the events before it in the batch have been appended.
+// streamHead is only asked about streams the batch has not appended to yet.
+func checkBatch(cursor int64, inputs []AppendInput, streamHead func(input AppendInput) (int64, error)) (err error) {
+	var pendingByStreamKey map[string]int64
+	for i, input := range inputs {
+		if input.ExpectedLogicalClock != nil {
+			head := cursor + int64(i)
+			if input.StreamKey != "" {
+				var ok bool
+				head, ok = pendingByStreamKey[input.StreamKey]
+				if !ok {
+					head, err = streamHead(input)
+					if err != nil {
+						return
+					}
+				}
+			}
+			err = checkExpectedLogicalClock(input, head)
+			if err != nil {
+				return
+			}
+		}
+		if input.StreamKey != "" {
+			if pendingByStreamKey == nil {
+				pendingByStreamKey = make(map[string]int64)
+			}
+			pendingByStreamKey[input.StreamKey] = cursor + int64(i) + 1
+		}
+	}
+	return
+}
+
+// GetStreamHead returns the logical clock of the last event in a stream, or
+// of the whole log if streamKey is empty. It is the value to pass as
+// AppendInput.ExpectedLogicalClock. It reads the events of the stream's
+// account, so accountID should be given for any stream that belongs to one.
+func GetStreamHead(ctx context.Context, eventLog EventLog, accountID string, streamKey string) (head int64, err error) {
+	if streamKey == "" {
+		head = eventLog.Cursor()
+		return
+	}
+	iterator := eventLog.GetEventIterator(ctx, GetEventIteratorInput{
+		FromCursor: 0,
+		AccountID:  accountID,
+		StreamKey:  streamKey,
+	})
+	for iterator.Next(ctx) {
+		head = iterator.Event().LogicalClock
+	}
+	err = iterator.Err()
+	return
+}
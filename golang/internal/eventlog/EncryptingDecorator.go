@@ -0,0 +1,285 @@
+package eventlog
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/ansel1/merry"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/store"
+)
+
+var ErrMissingAccountID = merry.New("encrypted event types must have an account ID").WithHTTPCode(http.StatusBadRequest)
+
+const dataKeySize = 32
+
+// DataKeys holds a data key per account, wrapped by a master key. Deleting
+// an account's data key makes the events encrypted with it unreadable.
+type DataKeys struct {
+	store     store.Store
+	masterKey []byte
+	mutex     sync.Mutex
+	// keyByAccountID caches unwrapped keys.
+	keyByAccountID map[string][]byte
+}
+
+type NewDataKeysInput struct {
+	Store     store.Store
+	MasterKey []byte
+}
+
+func NewDataKeys(input NewDataKeysInput) *DataKeys {
+	return &DataKeys{
+		store:          input.Store,
+		masterKey:      input.MasterKey,
+		keyByAccountID: make(map[string][]byte),
+	}
+}
+
+func dataKeyKey(accountID string) string {
+	return "data_keys/" + accountID
+}
+
+// get returns the data key of an account, creating it if create is set. It
+// returns store.ErrNotFound if the account has no key.
+func (keys *DataKeys) get(ctx context.Context, accountID string, create bool) (key []byte, err error) {
+	keys.mutex.Lock()
+	defer keys.mutex.Unlock()
+	key, ok := keys.keyByAccountID[accountID]
+	if ok {
+		return
+	}
+	wrappedKey, err := keys.store.Get(ctx, dataKeyKey(accountID))
+	if merry.Is(err, store.ErrNotFound) && create {
+		key = make([]byte, dataKeySize)
+		_, err = rand.Read(key)
+		fatal.OnError(err)
+		err = keys.store.Put(ctx, dataKeyKey(accountID), sealAESGCM(keys.masterKey, key, []byte(accountID)))
+		if err != nil {
+			return nil, err
+		}
+		keys.keyByAccountID[accountID] = key
+		return
+	}
+	if err != nil {
+		return
+	}
+	key, err = openAESGCM(keys.masterKey, wrappedKey, []byte(accountID))
+	if err != nil {
+		return
+	}
+	keys.keyByAccountID[accountID] = key
+	return
+}
+
+// Delete erases the data key of an account.
+func (keys *DataKeys) Delete(ctx context.Context, accountID string) (err error) {
+	keys.mutex.Lock()
+	defer keys.mutex.Unlock()
+	delete(keys.keyByAccountID, accountID)
+	return keys.store.Delete(ctx, dataKeyKey(accountID))
+}
+
+// sealAESGCM encrypts with AES-GCM and prepends the nonce.
+func sealAESGCM(key []byte, plaintext []byte, additionalData []byte) []byte {
+	aead := newGCM(key)
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	_, err := rand.Read(nonce)
+	fatal.OnError(err)
+	return aead.Seal(nonce, nonce, plaintext, additionalData)
+}
+
+func openAESGCM(key []byte, ciphertext []byte, additionalData []byte) (plaintext []byte, err error) {
+	aead := newGCM(key)
+	if len(ciphertext) < aead.NonceSize() {
+		err = merry.New("ciphertext is too short")
+		return
+	}
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	plaintext, err = aead.Open(nil, nonce, sealed, additionalData)
+	if err != nil {
+		err = merry.Wrap(err)
+	}
+	return
+}
+
+func newGCM(key []byte) cipher.AEAD {
+	block, err := aes.NewCipher(key)
+	fatal.OnError(err)
+	aead, err := cipher.NewGCM(block)
+	fatal.OnError(err)
+	return aead
+}
+
+// encryptedData replaces the Data of an encrypted event.
+type encryptedData struct {
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// EncryptingDecorator encrypts the Data of events of the configured types
+// with the data key of their account. Iterators decrypt them, and skip the
+// events of accounts whose data key has been deleted. Events written before
+// their type was configured are returned as they are.
+type EncryptingDecorator struct {
+	decorated EventLog
+	dataKeys  *DataKeys
+	typeSet   map[string]struct{}
+}
+
+type NewEncryptingDecoratorInput struct {
+	Decorated EventLog
+	DataKeys  *DataKeys
+	Types     []string
+}
+
+func NewEncryptingDecorator(input NewEncryptingDecoratorInput) *EncryptingDecorator {
+	return &EncryptingDecorator{
+		decorated: input.Decorated,
+		dataKeys:  input.DataKeys,
+		typeSet:   newTypeSet(input.Types),
+	}
+}
+
+func (decorator *EncryptingDecorator) encrypts(eventType string) bool {
+	_, ok := decorator.typeSet[eventType]
+	return ok
+}
+
+func encryptionAdditionalData(eventType string, accountID string) []byte {
+	return []byte(eventType + "\x00" + accountID)
+}
+
+func (decorator *EncryptingDecorator) Append(ctx context.Context, input AppendInput) (event *Event, err error) {
+	events, err := decorator.AppendBatch(ctx, []AppendInput{input})
+	if err != nil {
+		return
+	}
+	event = events[0]
+	return
+}
+
+// AppendBatch returns the events with their Data in plaintext.
+func (decorator *EncryptingDecorator) AppendBatch(ctx context.Context, inputs []AppendInput) (events []*Event, err error) {
+	encrypted := make([]AppendInput, len(inputs))
+	for i, input := range inputs {
+		encrypted[i] = input
+		if !decorator.encrypts(input.Type) {
+			continue
+		}
+		if input.AccountID == "" {
+			err = ErrMissingAccountID.Here()
+			return
+		}
+		key, err := decorator.dataKeys.get(ctx, input.AccountID, true)
+		if err != nil {
+			return nil, err
+		}
+		encrypted[i].Data, err = json.Marshal(encryptedData{
+			Ciphertext: sealAESGCM(key, input.Data, encryptionAdditionalData(input.Type, input.AccountID)),
+		})
+		fatal.OnError(err)
+	}
+	events, err = decorator.decorated.AppendBatch(ctx, encrypted)
+	if err != nil {
+		return
+	}
+	for i, event := range events {
+		if !decorator.encrypts(event.Type) {
+			continue
+		}
+		decrypted := *event
+		decrypted.Data = inputs[i].Data
+		events[i] = &decrypted
+	}
+	return
+}
+
+func (decorator *EncryptingDecorator) GetEventIterator(ctx context.Context, input GetEventIteratorInput) EventIterator {
+	return &decryptingEventIterator{
+		decorated: decorator.decorated.GetEventIterator(ctx, input),
+		decorator: decorator,
+	}
+}
+
+func (decorator *EncryptingDecorator) Wait(ctx context.Context) <-chan struct{} {
+	return decorator.decorated.Wait(ctx)
+}
+
+func (decorator *EncryptingDecorator) Cursor() int64 {
+	return decorator.decorated.Cursor()
+}
+
+// EraseAccount deletes the data key of an account, so that its encrypted
+// events are skipped from then on.
+func (decorator *EncryptingDecorator) EraseAccount(ctx context.Context, accountID string) (err error) {
+	return decorator.dataKeys.Delete(ctx, accountID)
+}
+
+// decrypt returns ok false if the event belongs to an erased account.
+func (decorator *EncryptingDecorator) decrypt(ctx context.Context, event *Event) (decrypted *Event, ok bool, err error) {
+	if !decorator.encrypts(event.Type) {
+		return event, true, nil
+	}
+	var data encryptedData
+	if json.Unmarshal(event.Data, &data) != nil || data.Ciphertext == nil {
+		return event, true, nil
+	}
+	key, err := decorator.dataKeys.get(ctx, event.AccountID, false)
+	if merry.Is(err, store.ErrNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return
+	}
+	plaintext, err := openAESGCM(key, data.Ciphertext, encryptionAdditionalData(event.Type, event.AccountID))
+	if err != nil {
+		err = merry.Prependf(err, "decrypt event %d", event.LogicalClock)
+		return
+	}
+	decrypted = new(Event)
+	*decrypted = *event
+	decrypted.Data = plaintext
+	return decrypted, true, nil
+}
+
+type decryptingEventIterator struct {
+	decorated EventIterator
+	decorator *EncryptingDecorator
+	event     *Event
+	err       error
+}
+
+func (iterator *decryptingEventIterator) Next(ctx context.Context) bool {
+	if iterator.err != nil {
+		return false
+	}
+	for iterator.decorated.Next(ctx) {
+		event, ok, err := iterator.decorator.decrypt(ctx, iterator.decorated.Event())
+		if err != nil {
+			iterator.err = err
+			return false
+		}
+		if ok {
+			iterator.event = event
+			return true
+		}
+	}
+	return false
+}
+
+func (iterator *decryptingEventIterator) Event() *Event {
+	return iterator.event
+}
+
+func (iterator *decryptingEventIterator) Err() error {
+	if iterator.err != nil {
+		return iterator.err
+	}
+	return iterator.decorated.Err()
+}
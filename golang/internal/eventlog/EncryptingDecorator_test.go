@@ -0,0 +1,132 @@
+package eventlog_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/store"
+)
+
+var testMasterKey = []byte("0123456789abcdef0123456789abcdef")
+
+type EncryptingDecoratorFactory struct{}
+
+func (factory *EncryptingDecoratorFactory) Create(ctx context.Context) eventlog.EventLog {
+	folderPath, err := os.MkdirTemp("testdata", "TestEncryptingDecorator-*")
+	if err != nil {
+		panic(err)
+	}
+	return eventlog.NewEncryptingDecorator(eventlog.NewEncryptingDecoratorInput{
+		Decorated: eventlog.NewCachingDecoratorOrFatal(ctx, eventlog.NewCachingDecoratorInput{
+			Decorated: eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
+				FolderPath: folderPath,
+			}),
+		}),
+		DataKeys: eventlog.NewDataKeys(eventlog.NewDataKeysInput{
+			Store:     store.NewMemoryStore(),
+			MasterKey: testMasterKey,
+		}),
+		Types: []string{"a", "b"},
+	})
+}
+
+func TestEncryptingDecoratorEventLog(t *testing.T) {
+	testEventLog(t, new(EncryptingDecoratorFactory))
+}
+
+func TestEncryptingDecorator(t *testing.T) {
+	ctx := context.Background()
+	Convey("TestEncryptingDecorator", t, func() {
+		folderPath, err := os.MkdirTemp("testdata", "TestEncryptingDecorator-*")
+		So(err, ShouldBeNil)
+		fileEventLog := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
+			FolderPath: folderPath,
+		})
+		keyStore := store.NewMemoryStore()
+		newDecorator := func(masterKey []byte) *eventlog.EncryptingDecorator {
+			return eventlog.NewEncryptingDecorator(eventlog.NewEncryptingDecoratorInput{
+				Decorated: fileEventLog,
+				DataKeys: eventlog.NewDataKeys(eventlog.NewDataKeysInput{
+					Store:     keyStore,
+					MasterKey: masterKey,
+				}),
+				Types: []string{"account.created"},
+			})
+		}
+		decorator := newDecorator(testMasterKey)
+		readAll := func(eventLog eventlog.EventLog) (events []*eventlog.Event, err error) {
+			iterator := eventLog.GetEventIterator(ctx, eventlog.GetEventIteratorInput{})
+			for iterator.Next(ctx) {
+				events = append(events, iterator.Event())
+			}
+			err = iterator.Err()
+			return
+		}
+		legacy, err := fileEventLog.Append(ctx, eventlog.AppendInput{
+			Type:      "account.created",
+			AccountID: "account-0",
+			Data:      fatal.UnlessMarshalJSON(map[string]string{"email": "legacy@example.com"}),
+		})
+		So(err, ShouldBeNil)
+		appended := make([]*eventlog.Event, 0)
+		for _, accountID := range []string{"account-1", "account-2"} {
+			event, err := decorator.Append(ctx, eventlog.AppendInput{
+				Type:      "account.created",
+				AccountID: accountID,
+				Data:      fatal.UnlessMarshalJSON(map[string]string{"email": accountID + "@example.com"}),
+			})
+			So(err, ShouldBeNil)
+			appended = append(appended, event)
+		}
+		unencrypted, err := decorator.Append(ctx, eventlog.AppendInput{
+			Type:      "session.started",
+			AccountID: "account-1",
+			Data:      fatal.UnlessMarshalJSON(map[string]string{"name": "Nursery"}),
+		})
+		So(err, ShouldBeNil)
+		Convey("encrypted at rest", func() {
+			segments := fileEventLog.Segments()
+			data, err := os.ReadFile(segments[0].FilePath)
+			So(err, ShouldBeNil)
+			So(bytes.Contains(data, []byte("legacy@example.com")), ShouldBeTrue)
+			So(bytes.Contains(data, []byte("account-1@example.com")), ShouldBeFalse)
+			So(bytes.Contains(data, []byte("Nursery")), ShouldBeTrue)
+		})
+		Convey("decrypted on read", func() {
+			events, err := readAll(decorator)
+			So(err, ShouldBeNil)
+			So(events, ShouldResemble, []*eventlog.Event{legacy, appended[0], appended[1], unencrypted})
+			Convey("after a restart", func() {
+				events, err := readAll(newDecorator(testMasterKey))
+				So(err, ShouldBeNil)
+				So(events, ShouldResemble, []*eventlog.Event{legacy, appended[0], appended[1], unencrypted})
+			})
+		})
+		Convey("erase an account", func() {
+			So(decorator.EraseAccount(ctx, "account-1"), ShouldBeNil)
+			events, err := readAll(decorator)
+			So(err, ShouldBeNil)
+			So(events, ShouldResemble, []*eventlog.Event{legacy, appended[1], unencrypted})
+			events, err = readAll(newDecorator(testMasterKey))
+			So(err, ShouldBeNil)
+			So(events, ShouldHaveLength, 3)
+		})
+		Convey("wrong master key", func() {
+			_, err := readAll(newDecorator([]byte("fedcba9876543210fedcba9876543210")))
+			So(err, ShouldNotBeNil)
+		})
+		Convey("missing account", func() {
+			_, err := decorator.Append(ctx, eventlog.AppendInput{
+				Type: "account.created",
+				Data: fatal.UnlessMarshalJSON(map[string]string{}),
+			})
+			So(err, ShouldWrap, eventlog.ErrMissingAccountID)
+		})
+	})
+}
@@ -0,0 +1,33 @@
+package eventlog
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ansel1/merry"
+)
+
+// ErrCorruptEvent is reported by an iterator for a stored event that cannot
+// be decoded. Calling Next again skips the event and carries on after it.
+var ErrCorruptEvent = merry.New("corrupt event")
+
+// ErrClosed is returned by a log that has been closed. A log closes itself
+// when a write fails, as what it holds in memory may no longer match the
+// disk. Opening it again recovers.
+var ErrClosed = merry.New("event log is closed").WithHTTPCode(http.StatusServiceUnavailable)
+
+// ErrCanceled is returned by an append, and reported by an iterator, that
+// stopped because its context was done. It wraps the context's error.
+var ErrCanceled = merry.New("event log operation canceled")
+
+func corruptEvent(err error, format string, args ...interface{}) error {
+	return merry.Prependf(ErrCorruptEvent, format+": %v", append(args, err)...).WithCause(err)
+}
+
+func canceled(ctx context.Context) error {
+	err := ctx.Err()
+	if err == nil {
+		return nil
+	}
+	return ErrCanceled.Here().WithCause(err)
+}
@@ -3,8 +3,13 @@ package eventlog
 import (
 	"context"
 	"encoding/json"
+	"net/http"
+	"time"
 
-	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+	"github.com/ansel1/merry"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/logx"
 )
 
 // Add Resource URN to event log
@@ -14,48 +19,248 @@ type Event struct {
 	ID            string          `json:"id"`
 	Type          string          `json:"type"`
 	AccountID     string          `json:"account_id,omitempty"`
+	StreamKey     string          `json:"stream_key,omitempty"`
 	LogicalClock  int64           `json:"logical_clock"`
 	UnixTimestamp int64           `json:"unix_timestamp"`
 	Data          json.RawMessage `json:"data"`
+	// PreviousHash links the event to the ones before it when the log is
+	// written through a HashChainDecorator.
+	PreviousHash string `json:"previous_hash,omitempty"`
 }
 
 type AppendInput struct {
 	Type      string
 	AccountID string
+	// StreamKey optionally groups related events, such as the events of one
+	// session, so that ExpectedLogicalClock can be checked per stream. The
+	// events of a stream belong to one account, and logs look up the head of
+	// a stream they no longer keep in memory among the events of AccountID.
+	StreamKey string
 	Data      json.RawMessage
+	// ExpectedLogicalClock is optional. When set, Append returns a
+	// *ConflictError unless the last event in StreamKey, or in the whole log
+	// if StreamKey is empty, has this logical clock. Zero expects an empty
+	// stream.
+	ExpectedLogicalClock *int64
+	// PreviousHash is set by HashChainDecorator and stored on the event.
+	PreviousHash string
+	// ID, LogicalClock and UnixTimestamp are assigned by the log when zero.
+	// HashChainDecorator sets them so that they are part of the hash, and the
+	// append fails with a *ConflictError if LogicalClock isn't the next one.
+	ID            string
+	LogicalClock  int64
+	UnixTimestamp int64
+}
+
+// event returns the event input describes, with whatever ID, logical clock
+// and timestamp it has been assigned.
+func (input *AppendInput) event() *Event {
+	return &Event{
+		ID:            input.ID,
+		Type:          input.Type,
+		AccountID:     input.AccountID,
+		StreamKey:     input.StreamKey,
+		LogicalClock:  input.LogicalClock,
+		UnixTimestamp: input.UnixTimestamp,
+		Data:          input.Data,
+		PreviousHash:  input.PreviousHash,
+	}
+}
+
+// newEvents assigns the events of a batch appended to a log at cursor
+// the next logical clocks, new IDs and the current time, unless the inputs
+// already have them.
+func newEvents(cursor int64, inputs []AppendInput) (events []*Event, err error) {
+	unixTimestamp := time.Now().Unix()
+	events = make([]*Event, len(inputs))
+	for i, input := range inputs {
+		event := input.event()
+		logicalClock := cursor + int64(i) + 1
+		if event.LogicalClock != 0 && event.LogicalClock != logicalClock {
+			err = merry.Wrap(&ConflictError{
+				ExpectedLogicalClock: event.LogicalClock - 1,
+				ActualLogicalClock:   logicalClock - 1,
+			}).WithHTTPCode(http.StatusConflict)
+			return nil, err
+		}
+		event.LogicalClock = logicalClock
+		if event.ID == "" {
+			event.ID = uuid.NewV4().String()
+		}
+		if event.UnixTimestamp == 0 {
+			event.UnixTimestamp = unixTimestamp
+		}
+		events[i] = event
+	}
+	return
+}
+
+// ExpectLogicalClock is a convenience for setting
+// AppendInput.ExpectedLogicalClock.
+func ExpectLogicalClock(logicalClock int64) *int64 {
+	return &logicalClock
 }
 
 type GetEventIteratorInput struct {
 	FromCursor int64
+	// Types restricts the iterator to events of these types. Empty means
+	// every type.
+	Types []string
+	// AccountID restricts the iterator to the events of one account, plus
+	// any events whose type is in UnscopedTypes, such as server.started,
+	// which belong to no account.
+	AccountID     string
+	UnscopedTypes []string
+	// StreamKey restricts the iterator to the events appended with this
+	// stream key.
+	StreamKey string
 }
 
 type EventLog interface {
 	Append(ctx context.Context, input AppendInput) (event *Event, err error)
+	// AppendBatch appends the events atomically with consecutive logical
+	// clocks and wakes Wait subscribers once.
+	AppendBatch(ctx context.Context, inputs []AppendInput) (events []*Event, err error)
 	GetEventIterator(ctx context.Context, input GetEventIteratorInput) (iterator EventIterator)
 	Wait(ctx context.Context) <-chan struct{}
+	// Cursor is the logical clock of the last event, known without reading
+	// the log.
+	Cursor() int64
 }
 
+// EventIterator reads events until Next returns false, after which Err
+// reports why it stopped, or nil at the end of the log. After
+// ErrCorruptEvent, Next may be called again to carry on after the corrupt
+// event.
 type EventIterator interface {
 	Next(ctx context.Context) bool
 	Event() *Event
 	Err() error
 }
 
+// ErrorPolicy decides what Project does when reading the log, or applying an
+// event, fails.
+type ErrorPolicy int
+
+const (
+	// ErrorPolicyHalt stops the projection and returns the error.
+	ErrorPolicyHalt ErrorPolicy = iota
+	// ErrorPolicySkip leaves corrupt events, including those Apply reports as
+	// ErrCorruptEvent, out of the projection and halts on any other error.
+	ErrorPolicySkip
+	// ErrorPolicyRetry follows the log again from the last applied event
+	// after RetryInterval, for errors that may pass, such as a closed log
+	// being reopened. Corrupt events are retried too, so it never gets
+	// past one.
+	ErrorPolicyRetry
+)
+
+const DefaultProjectRetryInterval = 5 * time.Second
+
 type ProjectInput struct {
 	EventLog   EventLog
 	FromCursor int64
-	Apply      func(ctx context.Context, event *Event)
+	// Apply returns an error wrapping ErrCorruptEvent for an event whose
+	// payload it cannot decode.
+	Apply func(ctx context.Context, event *Event) error
+	// Snapshots is optional. When set, the projection is restored from the
+	// latest snapshot before following the log, and a new snapshot is saved
+	// at most once every SnapshotInterval.
+	Snapshots        *SnapshotStore
+	Snapshotter      Snapshotter
+	SnapshotInterval time.Duration
+	OnError          ErrorPolicy
+	// RetryInterval is used with ErrorPolicyRetry and defaults to
+	// DefaultProjectRetryInterval.
+	RetryInterval time.Duration
 }
 
-func Project(ctx context.Context, input ProjectInput) {
+// Project applies the log to a projection until ctx is done, when it returns
+// ErrCanceled, or until reading the log or applying an event fails and
+// OnError halts it.
+func Project(ctx context.Context, input ProjectInput) (err error) {
+	if input.RetryInterval == 0 {
+		input.RetryInterval = DefaultProjectRetryInterval
+	}
+	fromCursor := input.FromCursor
+	if input.Snapshots != nil {
+		cursor, err := RestoreSnapshot(ctx, RestoreSnapshotInput{
+			Snapshots:   input.Snapshots,
+			Snapshotter: input.Snapshotter,
+		})
+		if err != nil {
+			logx.Warnln("failed to restore snapshot, replaying from", fromCursor, err)
+		}
+		if cursor > fromCursor {
+			fromCursor = cursor
+		}
+		defer saveProjectSnapshot(context.WithoutCancel(ctx), input)
+	}
 	iterator := Follow(ctx, FollowInput{
 		EventLog:   input.EventLog,
-		FromCursor: input.FromCursor,
+		FromCursor: fromCursor,
 	})
-	for iterator.Next(ctx) {
-		input.Apply(ctx, iterator.Event())
+	lastSnapshotAt := time.Now()
+	for {
+		var event *Event
+		err = nil
+		for iterator.Next(ctx) {
+			event = iterator.Event()
+			err = input.Apply(ctx, event)
+			if err != nil {
+				break
+			}
+			fromCursor = event.LogicalClock
+			if input.Snapshots == nil || time.Since(lastSnapshotAt) < input.SnapshotInterval {
+				continue
+			}
+			saveProjectSnapshot(ctx, input)
+			lastSnapshotAt = time.Now()
+		}
+		applyFailed := err != nil
+		if !applyFailed {
+			err = iterator.Err()
+		}
+		if err == nil {
+			err = canceled(ctx)
+		}
+		if err == nil || merry.Is(err, ErrCanceled) {
+			return
+		}
+		switch input.OnError {
+		case ErrorPolicySkip:
+			if !merry.Is(err, ErrCorruptEvent) {
+				return
+			}
+			logx.Warnln("skipping event:", err)
+			if applyFailed {
+				fromCursor = event.LogicalClock
+			}
+		case ErrorPolicyRetry:
+			logx.Warnln("projection failed, retrying from", fromCursor, err)
+			select {
+			case <-ctx.Done():
+				return canceled(ctx)
+			case <-time.After(input.RetryInterval):
+			}
+			iterator = Follow(ctx, FollowInput{
+				EventLog:   input.EventLog,
+				FromCursor: fromCursor,
+			})
+		default:
+			return
+		}
+	}
+}
+
+func saveProjectSnapshot(ctx context.Context, input ProjectInput) {
+	_, err := SaveSnapshot(ctx, SaveSnapshotInput{
+		Snapshots:   input.Snapshots,
+		Snapshotter: input.Snapshotter,
+	})
+	if err != nil {
+		logx.Errorln(err)
 	}
-	fatal.OnError(iterator.Err())
 }
 
 type StreamToChannelInput struct {
@@ -64,12 +269,12 @@ type StreamToChannelInput struct {
 	C          chan *Event
 }
 
-func StreamToChannel(ctx context.Context, input StreamToChannelInput) {
+func StreamToChannel(ctx context.Context, input StreamToChannelInput) (err error) {
 	iterator := input.EventLog.GetEventIterator(ctx, GetEventIteratorInput{
 		FromCursor: input.FromCursor,
 	})
 	for iterator.Next(ctx) {
 		input.C <- iterator.Event()
 	}
-	fatal.OnError(iterator.Err())
+	return iterator.Err()
 }
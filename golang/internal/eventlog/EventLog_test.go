@@ -1,10 +1,16 @@
 package eventlog_test
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
 	"testing"
+	"time"
 
+	"github.com/ansel1/merry"
 	uuid "github.com/satori/go.uuid"
 	. "github.com/smartystreets/goconvey/convey"
 
@@ -79,6 +85,216 @@ func testEventLog(t *testing.T, factory EventLogFactory) {
 			So(iterator.Err(), ShouldBeNil)
 		})
 	})
+	Convey("filter", t, func() {
+		eventLog := factory.Create(ctx)
+		accountIDs := []string{uuid.NewV4().String(), uuid.NewV4().String()}
+		types := []string{"a", "b", "c"}
+		events := make([]*eventlog.Event, 0)
+		for i := 0; i < 60; i++ {
+			input := eventlog.AppendInput{
+				Type:      types[i%len(types)],
+				AccountID: accountIDs[i%len(accountIDs)],
+				Data:      fatal.UnlessMarshalJSON(i),
+			}
+			if i%7 == 0 {
+				input = eventlog.AppendInput{
+					Type: "global",
+					Data: fatal.UnlessMarshalJSON(i),
+				}
+			}
+			event, err := eventLog.Append(ctx, input)
+			So(err, ShouldBeNil)
+			events = append(events, event)
+		}
+		assertFiltered := func(input eventlog.GetEventIteratorInput, matches func(event *eventlog.Event) bool) {
+			iterator := eventLog.GetEventIterator(ctx, input)
+			count := 0
+			for _, event := range events {
+				if event.LogicalClock <= input.FromCursor || !matches(event) {
+					continue
+				}
+				So(iterator.Next(ctx), ShouldBeTrue)
+				So(iterator.Event(), ShouldResemble, event)
+				count++
+			}
+			So(iterator.Next(ctx), ShouldBeFalse)
+			So(iterator.Err(), ShouldBeNil)
+			So(count, ShouldBeGreaterThan, 0)
+		}
+		fromCursor := events[0].LogicalClock - 1
+		Convey("types", func() {
+			assertFiltered(eventlog.GetEventIteratorInput{
+				FromCursor: fromCursor,
+				Types:      []string{"a", "global"},
+			}, func(event *eventlog.Event) bool {
+				return event.Type == "a" || event.Type == "global"
+			})
+		})
+		Convey("account", func() {
+			assertFiltered(eventlog.GetEventIteratorInput{
+				FromCursor: fromCursor,
+				AccountID:  accountIDs[0],
+			}, func(event *eventlog.Event) bool {
+				return event.AccountID == accountIDs[0]
+			})
+		})
+		Convey("account with unscoped types", func() {
+			assertFiltered(eventlog.GetEventIteratorInput{
+				FromCursor:    fromCursor + 20,
+				AccountID:     accountIDs[1],
+				UnscopedTypes: []string{"global"},
+			}, func(event *eventlog.Event) bool {
+				return event.AccountID == accountIDs[1] || event.Type == "global"
+			})
+		})
+		Convey("account and types", func() {
+			assertFiltered(eventlog.GetEventIteratorInput{
+				FromCursor: fromCursor + 31,
+				Types:      []string{"b"},
+				AccountID:  accountIDs[0],
+			}, func(event *eventlog.Event) bool {
+				return event.AccountID == accountIDs[0] && event.Type == "b"
+			})
+		})
+	})
+	Convey("expected logical clock", t, func() {
+		eventLog := factory.Create(ctx)
+		streamKey := uuid.NewV4().String()
+		appendInput := func(streamKey string, expected int64) eventlog.AppendInput {
+			return eventlog.AppendInput{
+				Type:                 "test",
+				StreamKey:            streamKey,
+				Data:                 fatal.UnlessMarshalJSON(nil),
+				ExpectedLogicalClock: eventlog.ExpectLogicalClock(expected),
+			}
+		}
+		first, err := eventLog.Append(ctx, appendInput(streamKey, 0))
+		So(err, ShouldBeNil)
+		So(first.StreamKey, ShouldEqual, streamKey)
+		other, err := eventLog.Append(ctx, appendInput("", first.LogicalClock))
+		So(err, ShouldBeNil)
+		Convey("global conflict", func() {
+			_, err := eventLog.Append(ctx, appendInput("", first.LogicalClock))
+			var conflict *eventlog.ConflictError
+			So(errors.As(err, &conflict), ShouldBeTrue)
+			So(conflict.ExpectedLogicalClock, ShouldEqual, first.LogicalClock)
+			So(conflict.ActualLogicalClock, ShouldEqual, other.LogicalClock)
+			So(merry.HTTPCode(err), ShouldEqual, http.StatusConflict)
+		})
+		Convey("stream ignores other events", func() {
+			second, err := eventLog.Append(ctx, appendInput(streamKey, first.LogicalClock))
+			So(err, ShouldBeNil)
+			head, err := eventlog.GetStreamHead(ctx, eventLog, "", streamKey)
+			So(err, ShouldBeNil)
+			So(head, ShouldEqual, second.LogicalClock)
+			Convey("stream conflict", func() {
+				_, err := eventLog.Append(ctx, appendInput(streamKey, first.LogicalClock))
+				var conflict *eventlog.ConflictError
+				So(errors.As(err, &conflict), ShouldBeTrue)
+				So(conflict.StreamKey, ShouldEqual, streamKey)
+				So(conflict.ActualLogicalClock, ShouldEqual, second.LogicalClock)
+				head, err := eventlog.GetStreamHead(ctx, eventLog, "", streamKey)
+				So(err, ShouldBeNil)
+				So(head, ShouldEqual, second.LogicalClock)
+			})
+		})
+		Convey("new stream expects zero", func() {
+			_, err := eventLog.Append(ctx, appendInput(uuid.NewV4().String(), first.LogicalClock))
+			So(err, ShouldNotBeNil)
+			_, err = eventLog.Append(ctx, appendInput(uuid.NewV4().String(), 0))
+			So(err, ShouldBeNil)
+		})
+	})
+	Convey("append batch", t, func() {
+		eventLog := factory.Create(ctx)
+		before, err := eventLog.Append(ctx, eventlog.AppendInput{
+			Type: "test",
+			Data: fatal.UnlessMarshalJSON(nil),
+		})
+		So(err, ShouldBeNil)
+		streamKey := uuid.NewV4().String()
+		inputs := make([]eventlog.AppendInput, 5)
+		for i := range inputs {
+			inputs[i] = eventlog.AppendInput{
+				Type:                 "test",
+				AccountID:            uuid.NewV4().String(),
+				StreamKey:            streamKey,
+				Data:                 fatal.UnlessMarshalJSON(i),
+				ExpectedLogicalClock: eventlog.ExpectLogicalClock(0),
+			}
+			if i != 0 {
+				inputs[i].ExpectedLogicalClock = eventlog.ExpectLogicalClock(before.LogicalClock + int64(i))
+			}
+		}
+		waitC := eventLog.Wait(ctx)
+		events, err := eventLog.AppendBatch(ctx, inputs)
+		So(err, ShouldBeNil)
+		So(events, ShouldHaveLength, len(inputs))
+		for i, event := range events {
+			So(event.LogicalClock, ShouldEqual, before.LogicalClock+int64(i)+1)
+			So(event.AccountID, ShouldEqual, inputs[i].AccountID)
+			So(event.Data, ShouldResemble, inputs[i].Data)
+		}
+		select {
+		case <-waitC:
+		default:
+			t.Error("Wait was not woken by AppendBatch")
+		}
+		select {
+		case <-eventLog.Wait(ctx):
+			t.Error("Wait was woken more than once by AppendBatch")
+		default:
+		}
+		iterator := eventLog.GetEventIterator(ctx, eventlog.GetEventIteratorInput{
+			FromCursor: before.LogicalClock,
+		})
+		for _, event := range events {
+			So(iterator.Next(ctx), ShouldBeTrue)
+			So(iterator.Event(), ShouldResemble, event)
+		}
+		So(iterator.Next(ctx), ShouldBeFalse)
+		Convey("conflict writes nothing", func() {
+			_, err := eventLog.AppendBatch(ctx, []eventlog.AppendInput{
+				{
+					Type: "test",
+					Data: fatal.UnlessMarshalJSON(nil),
+				},
+				{
+					Type:                 "test",
+					StreamKey:            streamKey,
+					Data:                 fatal.UnlessMarshalJSON(nil),
+					ExpectedLogicalClock: eventlog.ExpectLogicalClock(before.LogicalClock),
+				},
+			})
+			var conflict *eventlog.ConflictError
+			So(errors.As(err, &conflict), ShouldBeTrue)
+			So(conflict.ActualLogicalClock, ShouldEqual, events[len(events)-1].LogicalClock)
+			iterator := eventLog.GetEventIterator(ctx, eventlog.GetEventIteratorInput{
+				FromCursor: events[len(events)-1].LogicalClock,
+			})
+			So(iterator.Next(ctx), ShouldBeFalse)
+			event, err := eventLog.Append(ctx, eventlog.AppendInput{
+				Type: "test",
+				Data: fatal.UnlessMarshalJSON(nil),
+			})
+			So(err, ShouldBeNil)
+			So(event.LogicalClock, ShouldEqual, events[len(events)-1].LogicalClock+1)
+		})
+	})
+	Convey("canceled", t, func() {
+		eventLog := factory.Create(ctx)
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err := eventLog.Append(ctx, eventlog.AppendInput{
+			Type: "test",
+			Data: fatal.UnlessMarshalJSON(nil),
+		})
+		So(merry.Is(err, eventlog.ErrCanceled), ShouldBeTrue)
+		So(errors.Is(err, context.Canceled), ShouldBeTrue)
+		iterator := eventLog.GetEventIterator(context.Background(), eventlog.GetEventIteratorInput{})
+		So(iterator.Next(context.Background()), ShouldBeFalse)
+		So(iterator.Err(), ShouldBeNil)
+	})
 	// Convey("append while iterating", t, func() {
 	// 	eventLog := factory.Create(ctx)
 	// 	iterator := eventLog.GetEventIterator(ctx, eventlog.GetEventIteratorInput{
@@ -103,6 +319,124 @@ func testEventLog(t *testing.T, factory EventLogFactory) {
 	// })
 }
 
+// corruptLine overwrites the line at index in filePath so that it no longer
+// decodes, keeping its length.
+func corruptLine(filePath string, index int) {
+	data, err := os.ReadFile(filePath)
+	So(err, ShouldBeNil)
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	line := lines[index]
+	copy(line, bytes.Repeat([]byte("#"), len(line)-1))
+	So(os.WriteFile(filePath, data, 0644), ShouldBeNil)
+}
+
+func TestProjectErrorPolicy(t *testing.T) {
+	ctx := context.Background()
+	Convey("TestProjectErrorPolicy", t, func() {
+		folderPath, err := os.MkdirTemp("testdata", "TestProjectErrorPolicy-*")
+		So(err, ShouldBeNil)
+		eventLog := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
+			FolderPath: folderPath,
+		})
+		for i := 0; i < 3; i++ {
+			_, err = eventLog.Append(ctx, eventlog.AppendInput{
+				Type: "test",
+				Data: fatal.UnlessMarshalJSON(i),
+			})
+			So(err, ShouldBeNil)
+		}
+		segments := eventLog.Segments()
+		corruptLine(segments[0].FilePath, 1)
+		project := func(policy eventlog.ErrorPolicy) (applied []int64, err error) {
+			ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+			defer cancel()
+			err = eventlog.Project(ctx, eventlog.ProjectInput{
+				EventLog: eventLog,
+				Apply: func(ctx context.Context, event *eventlog.Event) error {
+					applied = append(applied, event.LogicalClock)
+					return nil
+				},
+				OnError:       policy,
+				RetryInterval: 10 * time.Millisecond,
+			})
+			return
+		}
+		Convey("halt", func() {
+			applied, err := project(eventlog.ErrorPolicyHalt)
+			So(merry.Is(err, eventlog.ErrCorruptEvent), ShouldBeTrue)
+			So(applied, ShouldResemble, []int64{1})
+		})
+		Convey("skip", func() {
+			applied, err := project(eventlog.ErrorPolicySkip)
+			So(merry.Is(err, eventlog.ErrCanceled), ShouldBeTrue)
+			So(applied, ShouldResemble, []int64{1, 3})
+		})
+		Convey("retry", func() {
+			applied, err := project(eventlog.ErrorPolicyRetry)
+			So(merry.Is(err, eventlog.ErrCanceled), ShouldBeTrue)
+			So(applied, ShouldResemble, []int64{1})
+		})
+	})
+}
+
+func TestProjectApplyError(t *testing.T) {
+	ctx := context.Background()
+	Convey("TestProjectApplyError", t, func() {
+		folderPath, err := os.MkdirTemp("testdata", "TestProjectApplyError-*")
+		So(err, ShouldBeNil)
+		eventLog := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
+			FolderPath: folderPath,
+		})
+		for i := 0; i < 3; i++ {
+			_, err = eventLog.Append(ctx, eventlog.AppendInput{
+				Type: "test",
+				Data: fatal.UnlessMarshalJSON(i),
+			})
+			So(err, ShouldBeNil)
+		}
+		project := func(policy eventlog.ErrorPolicy, applyErr error) (applied []int64, err error) {
+			ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+			defer cancel()
+			err = eventlog.Project(ctx, eventlog.ProjectInput{
+				EventLog: eventLog,
+				Apply: func(ctx context.Context, event *eventlog.Event) error {
+					if event.LogicalClock == 2 {
+						return applyErr
+					}
+					applied = append(applied, event.LogicalClock)
+					return nil
+				},
+				OnError:       policy,
+				RetryInterval: 10 * time.Millisecond,
+			})
+			return
+		}
+		corrupt := merry.Prepend(eventlog.ErrCorruptEvent, "undecodable payload")
+		Convey("halt", func() {
+			applied, err := project(eventlog.ErrorPolicyHalt, corrupt)
+			So(merry.Is(err, eventlog.ErrCorruptEvent), ShouldBeTrue)
+			So(applied, ShouldResemble, []int64{1})
+		})
+		Convey("skip", func() {
+			applied, err := project(eventlog.ErrorPolicySkip, corrupt)
+			So(merry.Is(err, eventlog.ErrCanceled), ShouldBeTrue)
+			So(applied, ShouldResemble, []int64{1, 3})
+		})
+		Convey("skip halts on other errors", func() {
+			applied, err := project(eventlog.ErrorPolicySkip, errors.New("store unavailable"))
+			So(err, ShouldNotBeNil)
+			So(merry.Is(err, eventlog.ErrCanceled), ShouldBeFalse)
+			So(applied, ShouldResemble, []int64{1})
+		})
+		Convey("retry", func() {
+			applied, err := project(eventlog.ErrorPolicyRetry, corrupt)
+			So(merry.Is(err, eventlog.ErrCanceled), ShouldBeTrue)
+			So(applied[0], ShouldEqual, 1)
+			So(applied, ShouldNotContain, int64(3))
+		})
+	})
+}
+
 func benchmarkAppend(b *testing.B, factory EventLogFactory) {
 	ctx := context.Background()
 	eventLog := factory.Create(ctx)
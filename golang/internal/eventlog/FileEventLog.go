@@ -8,118 +8,546 @@ import (
 	"log"
 	"os"
 	"path/filepath"
-	"time"
+	"sort"
 
-	uuid "github.com/satori/go.uuid"
+	"github.com/ansel1/merry"
 
 	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/logx"
 )
 
 const MetadataFileName = "metadata.json"
+
+// EventsFileName is the single file used by logs written before segmentation.
+// It is migrated into segments the first time the log is opened.
 const EventsFileName = "events.jsonl"
+const MigratedEventsFileName = EventsFileName + ".migrated"
 
 type FileEventLog struct {
-	folderPath string
-	file       *os.File
-	cursor     int64
-	waitC      chan struct{}
+	folderPath        string
+	maxSegmentSize    int64
+	indexInterval     int64
+	segments          []*segment
+	file              *os.File
+	indexFile         *os.File
+	size              int64
+	lastIndexedOffset int64
+	cursor            int64
+	waitC             chan struct{}
+	recovery          RecoveryReport
+	// err is set once the log is closed.
+	err error
+	// streamHeads holds the streams appended to since the log was opened,
+	// for appends that expect a stream head.
+	streamHeads *streamHeads
 }
 
 type NewFileEventLogInput struct {
-	FolderPath string
+	FolderPath     string
+	MaxSegmentSize int64
+	IndexInterval  int64
+	// MaxStreamHeads bounds the stream heads kept in memory. The head of a
+	// stream that isn't kept is found by reading the log.
+	MaxStreamHeads int
 }
 
-func NewFileEventLog(input *NewFileEventLogInput) *FileEventLog {
+// NewFileEventLog opens the log in input.FolderPath, migrating a single file
+// log into segments and recovering from an unclean shutdown.
+func NewFileEventLog(input *NewFileEventLogInput) (eventLog *FileEventLog, err error) {
+	maxSegmentSize := input.MaxSegmentSize
+	if maxSegmentSize == 0 {
+		maxSegmentSize = DefaultMaxSegmentSize
+	}
+	indexInterval := input.IndexInterval
+	if indexInterval == 0 {
+		indexInterval = DefaultIndexInterval
+	}
 	metadataFilePath := filepath.Join(input.FolderPath, MetadataFileName)
-	metadata := readFileEventLogMetadata(metadataFilePath)
-	eventsFilePath := filepath.Join(input.FolderPath, EventsFileName)
-	file, err := os.OpenFile(eventsFilePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	metadata, err := readFileEventLogMetadata(metadataFilePath)
+	if err != nil {
+		return
+	}
+	eventLog = &FileEventLog{
+		folderPath:     input.FolderPath,
+		maxSegmentSize: maxSegmentSize,
+		indexInterval:  indexInterval,
+		cursor:         metadata.Cursor,
+		waitC:          make(chan struct{}),
+		streamHeads:    newStreamHeads(input.MaxStreamHeads, 0),
+	}
+	legacyFilePath := filepath.Join(input.FolderPath, EventsFileName)
+	if _, statErr := os.Stat(legacyFilePath); statErr == nil {
+		err = migrateSingleFileEventLog(eventLog, legacyFilePath)
+	} else {
+		err = eventLog.recover(metadataFilePath)
+	}
+	if err != nil {
+		return nil, merry.Prepend(err, input.FolderPath)
+	}
+	// The streams of the events already in the log are looked up when an
+	// append expects their head.
+	eventLog.streamHeads.from = eventLog.cursor
+	return
+}
+
+func NewFileEventLogOrFatal(input *NewFileEventLogInput) *FileEventLog {
+	eventLog, err := NewFileEventLog(input)
 	fatal.OnError(err)
-	return &FileEventLog{
-		folderPath: input.FolderPath,
-		file:       file,
-		cursor:     metadata.Cursor,
-		waitC:      make(chan struct{}),
+	return eventLog
+}
+
+// recover repairs the damage an unclean shutdown can leave behind: a partial
+// record at the end of the active segment, a torn or stale index, and a
+// metadata cursor that disagrees with the last event on disk.
+func (log *FileEventLog) recover(metadataFilePath string) (err error) {
+	report := &log.recovery
+	report.MetadataCursor = log.cursor
+	lastLogicalClock, err := recoverActiveSegment(log.folderPath, report)
+	if err != nil {
+		return
+	}
+	err = log.openSegments()
+	if err != nil {
+		return
+	}
+	if len(log.segments) != 0 {
+		log.cursor = lastLogicalClock
+	}
+	report.RecoveredCursor = log.cursor
+	if report.MetadataCursor != report.RecoveredCursor {
+		err = writeFileEventLogMetadata(metadataFilePath, &FileEventLogMetadata{
+			Cursor: log.cursor,
+		})
+		if err != nil {
+			return
+		}
+	}
+	logRecoveryReport(report)
+	return
+}
+
+// Recovery reports what was repaired when the log was opened.
+func (log *FileEventLog) Recovery() RecoveryReport {
+	return log.recovery
+}
+
+func (log *FileEventLog) openSegments() (err error) {
+	firstLogicalClocks, err := listSegmentFirstLogicalClocks(log.folderPath)
+	if err != nil {
+		return
+	}
+	for _, firstLogicalClock := range firstLogicalClocks {
+		segment, size, rebuilt, err := loadSegment(log.folderPath, firstLogicalClock, log.indexInterval)
+		if err != nil {
+			return err
+		}
+		if rebuilt {
+			log.recovery.RebuiltIndexFilePaths = append(log.recovery.RebuiltIndexFilePaths, filepath.Join(log.folderPath, indexFileName(firstLogicalClock)))
+		}
+		log.segments = append(log.segments, segment)
+		log.size = size
+	}
+	if len(log.segments) == 0 {
+		return
+	}
+	active := log.segments[len(log.segments)-1]
+	err = log.openActiveSegmentFiles(active.firstLogicalClock)
+	if err != nil {
+		return
+	}
+	log.lastIndexedOffset = 0
+	if len(active.index) != 0 {
+		log.lastIndexedOffset = active.index[len(active.index)-1].Offset
+	}
+	return
+}
+
+func (log *FileEventLog) openActiveSegmentFiles(firstLogicalClock int64) (err error) {
+	segmentFilePath := filepath.Join(log.folderPath, segmentFileName(firstLogicalClock))
+	file, err := os.OpenFile(segmentFilePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	indexFilePath := filepath.Join(log.folderPath, indexFileName(firstLogicalClock))
+	indexFile, err := os.OpenFile(indexFilePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		file.Close()
+		return
+	}
+	log.file = file
+	log.indexFile = indexFile
+	return
+}
+
+func (log *FileEventLog) roll(firstLogicalClock int64) (err error) {
+	err = log.closeActiveSegmentFiles()
+	if err != nil {
+		return
+	}
+	err = log.openActiveSegmentFiles(firstLogicalClock)
+	if err != nil {
+		return
+	}
+	log.segments = append(log.segments, &segment{
+		firstLogicalClock: firstLogicalClock,
+	})
+	log.size = 0
+	log.lastIndexedOffset = 0
+	return
+}
+
+func (log *FileEventLog) closeActiveSegmentFiles() (err error) {
+	if log.file == nil {
+		return
+	}
+	err = log.file.Close()
+	indexErr := log.indexFile.Close()
+	if err == nil {
+		err = indexErr
+	}
+	log.file = nil
+	log.indexFile = nil
+	return
+}
+
+// Close closes the files of the active segment. Appends and new iterators
+// return ErrClosed afterwards.
+func (log *FileEventLog) Close() (err error) {
+	if log.err != nil {
+		return
 	}
+	log.err = ErrClosed.Here()
+	return log.closeActiveSegmentFiles()
+}
+
+// fail closes the log after a failed write, as the active segment may hold
+// part of a batch that its state in memory does not know about. Opening the
+// log again truncates it. The events of the batch may or may not have been
+// stored.
+func (log *FileEventLog) fail(err error) error {
+	logx.Errorln("closing event log after a failed write:", err)
+	log.closeActiveSegmentFiles()
+	log.err = merry.Prependf(ErrClosed, "write failed: %v", err).WithCause(err)
+	return log.err
 }
 
 func (log *FileEventLog) Append(ctx context.Context, input AppendInput) (event *Event, err error) {
-	log.cursor++
-	event = &Event{
-		ID:            uuid.NewV4().String(),
-		Type:          input.Type,
-		AccountID:     input.AccountID,
-		LogicalClock:  log.cursor,
-		UnixTimestamp: time.Now().Unix(),
-		Data:          input.Data,
-	}
-	err = json.NewEncoder(log.file).Encode(event)
-	fatal.OnError(err)
+	events, err := log.AppendBatch(ctx, []AppendInput{input})
+	if err != nil {
+		return
+	}
+	event = events[0]
+	return
+}
+
+// AppendBatch writes every event before a single sync and metadata update,
+// and wakes Wait subscribers once. If any expected logical clock conflicts
+// nothing is written.
+func (log *FileEventLog) AppendBatch(ctx context.Context, inputs []AppendInput) (events []*Event, err error) {
+	if len(inputs) == 0 {
+		return
+	}
+	err = log.check(ctx)
+	if err != nil {
+		return
+	}
+	err = checkBatch(log.cursor, inputs, func(input AppendInput) (int64, error) {
+		return log.streamHeads.get(ctx, log, input)
+	})
+	if err != nil {
+		return
+	}
+	events, err = newEvents(log.cursor, inputs)
+	if err != nil {
+		return
+	}
+	err = log.commit(events)
+	if err != nil {
+		events = nil
+		return
+	}
+	return
+}
+
+// check returns ErrClosed or ErrCanceled if an append cannot go ahead.
+func (log *FileEventLog) check(ctx context.Context) error {
+	if log.err != nil {
+		return log.err
+	}
+	return canceled(ctx)
+}
+
+// Replicate appends events from another log as they are.
+func (log *FileEventLog) Replicate(ctx context.Context, events []*Event) (err error) {
+	if len(events) == 0 {
+		return
+	}
+	err = log.check(ctx)
+	if err != nil {
+		return
+	}
+	err = checkReplicated(log.cursor, events)
+	if err != nil {
+		return
+	}
+	return log.commit(events)
+}
+
+// commit writes a batch, syncs it, moves the cursor to its last event and
+// wakes Wait subscribers. The log is closed if any of it fails.
+func (log *FileEventLog) commit(events []*Event) (err error) {
+	err = log.write(events)
+	if err != nil {
+		return log.fail(err)
+	}
+	log.cursor = events[len(events)-1].LogicalClock
 	err = log.file.Sync()
-	fatal.OnError(err)
+	if err != nil {
+		return log.fail(err)
+	}
 	metadataFilePath := filepath.Join(log.folderPath, MetadataFileName)
-	writeFileEventLogMetadata(metadataFilePath, &FileEventLogMetadata{
+	err = writeFileEventLogMetadata(metadataFilePath, &FileEventLogMetadata{
 		Cursor: log.cursor,
 	})
+	if err != nil {
+		return log.fail(err)
+	}
 	close(log.waitC)
 	log.waitC = make(chan struct{})
 	return
 }
 
+// Cursor is the logical clock of the last event.
+func (log *FileEventLog) Cursor() int64 {
+	return log.cursor
+}
+
+func (log *FileEventLog) HashChainStart() (int64, error) {
+	return readHashChainStart(log.folderPath)
+}
+
+func (log *FileEventLog) HashChainRebases() ([]HashChainRebase, error) {
+	return readHashChainRebases(log.folderPath)
+}
+
+func (log *FileEventLog) StartHashChain(logicalClock int64) error {
+	return writeHashChainStart(log.folderPath, logicalClock)
+}
+
+// fileEventRecord is the on-disk form of an event. BatchRemaining counts the
+// events of the same batch that follow this one, so recovery can discard a
+// batch that was only partly written.
+type fileEventRecord struct {
+	*Event
+	BatchRemaining int `json:"batch_remaining,omitempty"`
+}
+
+// write appends a batch of events to the active segment, first rolling to a
+// new segment if the active one has reached maxSegmentSize. A batch is never
+// split across segments. It does not sync.
+func (log *FileEventLog) write(events []*Event) (err error) {
+	if log.file == nil || log.size >= log.maxSegmentSize {
+		err = log.roll(events[0].LogicalClock)
+		if err != nil {
+			return
+		}
+	}
+	active := log.segments[len(log.segments)-1]
+	var indexData, data []byte
+	size := log.size
+	for i, event := range events {
+		record, err := json.Marshal(fileEventRecord{
+			Event:          event,
+			BatchRemaining: len(events) - 1 - i,
+		})
+		if err != nil {
+			return err
+		}
+		if size == 0 || size-log.lastIndexedOffset >= log.indexInterval {
+			entry := indexEntry{
+				LogicalClock: event.LogicalClock,
+				Offset:       size,
+			}
+			indexData = append(indexData, encodeIndexEntry(entry)...)
+			active.index = append(active.index, entry)
+			log.lastIndexedOffset = size
+		}
+		data = append(data, record...)
+		data = append(data, '\n')
+		size += int64(len(record)) + 1
+		log.streamHeads.add(event)
+	}
+	if len(indexData) != 0 {
+		_, err = log.indexFile.Write(indexData)
+		if err != nil {
+			return
+		}
+	}
+	n, err := log.file.Write(data)
+	log.size += int64(n)
+	return
+}
+
 func (log *FileEventLog) GetEventIterator(ctx context.Context, input GetEventIteratorInput) EventIterator {
-	if input.FromCursor < 0 {
+	if log.err != nil {
+		return &errorEventIterator{err: log.err}
+	}
+	if input.FromCursor < 0 || len(log.segments) == 0 {
 		return new(NullEventIterator)
 	}
-	filePath := filepath.Join(log.folderPath, EventsFileName)
-	file, err := os.Open(filePath)
-	fatal.OnError(err)
-	reader := bufio.NewReaderSize(file, 256*1024)
-	scanner := bufio.NewScanner(reader)
-	for i := int64(0); i < input.FromCursor; i++ {
-		ok := scanner.Scan()
-		if !ok {
-			fatal.OnError(scanner.Err())
-			return new(NullEventIterator)
-		}
+	target := input.FromCursor + 1
+	i := sort.Search(len(log.segments), func(i int) bool {
+		return log.segments[i].firstLogicalClock > target
+	}) - 1
+	if i < 0 {
+		i = 0
+	}
+	firstLogicalClocks := make([]int64, 0, len(log.segments)-i)
+	for _, segment := range log.segments[i:] {
+		firstLogicalClocks = append(firstLogicalClocks, segment.firstLogicalClock)
 	}
-	return &FileEventIterator{
-		closer:  file,
-		scanner: scanner,
+	iterator := &FileEventIterator{
+		folderPath:         log.folderPath,
+		firstLogicalClocks: firstLogicalClocks,
+		fromCursor:         input.FromCursor,
+		lastSegmentSize:    -1,
 	}
+	iterator.err = iterator.open(log.segments[i].seek(target))
+	return filterEventIterator(iterator, input)
 }
 
+// Wait is only woken by appends through this instance. Other processes
+// follow the folder through a ReadOnlyFileEventLog, whose Wait polls it.
 func (log *FileEventLog) Wait(ctx context.Context) <-chan struct{} {
 	return log.waitC
 }
 
+// Segments lists the segments of the log in order. Every segment except the
+// last is sealed and will not be written to again.
+func (log *FileEventLog) Segments() (segments []SegmentInfo) {
+	segments = make([]SegmentInfo, len(log.segments))
+	for i, segment := range log.segments {
+		segments[i] = SegmentInfo{
+			FirstLogicalClock: segment.firstLogicalClock,
+			FilePath:          filepath.Join(log.folderPath, segmentFileName(segment.firstLogicalClock)),
+			IndexFilePath:     filepath.Join(log.folderPath, indexFileName(segment.firstLogicalClock)),
+			Sealed:            i < len(log.segments)-1,
+		}
+	}
+	return
+}
+
 type FileEventIterator struct {
-	closer  io.Closer
-	scanner *bufio.Scanner
+	folderPath         string
+	firstLogicalClocks []int64
+	fromCursor         int64
+	// lastSegmentSize limits how much of the last segment is read. It is
+	// negative if the whole segment is read.
+	lastSegmentSize int64
+	filePath        string
+	offset          int64
+	closer          io.Closer
+	scanner         *bufio.Scanner
 
 	event *Event
+	err   error
+}
+
+func (iterator *FileEventIterator) open(offset int64) (err error) {
+	firstLogicalClock := iterator.firstLogicalClocks[0]
+	iterator.firstLogicalClocks = iterator.firstLogicalClocks[1:]
+	iterator.filePath = filepath.Join(iterator.folderPath, segmentFileName(firstLogicalClock))
+	iterator.offset = offset
+	file, err := os.Open(iterator.filePath)
+	if err != nil {
+		return
+	}
+	_, err = file.Seek(offset, io.SeekStart)
+	if err != nil {
+		file.Close()
+		return
+	}
+	iterator.closer = file
+	var reader io.Reader = file
+	if len(iterator.firstLogicalClocks) == 0 && iterator.lastSegmentSize >= 0 {
+		reader = io.LimitReader(file, iterator.lastSegmentSize-offset)
+	}
+	iterator.scanner = bufio.NewScanner(bufio.NewReaderSize(reader, 256*1024))
+	return
 }
 
+// Next carries on after a corrupt event when called again, but stops for
+// good on any other error.
 func (iterator *FileEventIterator) Next(ctx context.Context) bool {
-	select {
-	case <-ctx.Done():
+	if iterator.err != nil && !merry.Is(iterator.err, ErrCorruptEvent) {
 		return false
-	default:
-	}
-	var err error
-	ok := iterator.scanner.Scan()
-	if !ok {
-		err = iterator.scanner.Err()
-		fatal.OnError(err)
-		err = iterator.closer.Close()
-		fatal.OnError(err)
+	}
+	iterator.err = nil
+	if iterator.closer == nil {
 		return false
 	}
-	data := iterator.scanner.Bytes()
-	var event Event
-	err = json.Unmarshal(data, &event)
-	fatal.OnError(err)
-	iterator.event = &event
-	return true
+	if err := canceled(ctx); err != nil {
+		iterator.fail(err)
+		return false
+	}
+	for {
+		ok := iterator.scanner.Scan()
+		if !ok {
+			err := iterator.scanner.Err()
+			if err != nil {
+				iterator.fail(merry.Prepend(err, iterator.filePath))
+				return false
+			}
+			err = iterator.closer.Close()
+			iterator.closer = nil
+			if err != nil {
+				iterator.fail(err)
+				return false
+			}
+			if len(iterator.firstLogicalClocks) == 0 {
+				return false
+			}
+			err = iterator.open(0)
+			if err != nil {
+				iterator.fail(err)
+				return false
+			}
+			continue
+		}
+		data := iterator.scanner.Bytes()
+		offset := iterator.offset
+		iterator.offset += int64(len(data)) + 1
+		var event Event
+		err := json.Unmarshal(data, &event)
+		if err != nil {
+			iterator.err = corruptEvent(err, "%s at offset %d", iterator.filePath, offset)
+			return false
+		}
+		if event.LogicalClock <= iterator.fromCursor {
+			continue
+		}
+		iterator.event = &event
+		return true
+	}
+}
+
+func (iterator *FileEventIterator) fail(err error) {
+	iterator.err = err
+	if iterator.closer != nil {
+		iterator.closer.Close()
+		iterator.closer = nil
+	}
+}
+
+// Close closes the segment being read, ending the iterator.
+func (iterator *FileEventIterator) Close() (err error) {
+	iterator.firstLogicalClocks = nil
+	if iterator.closer == nil {
+		return
+	}
+	err = iterator.closer.Close()
+	iterator.closer = nil
+	return
 }
 
 func (iterator *FileEventIterator) Event() *Event {
@@ -127,35 +555,128 @@ func (iterator *FileEventIterator) Event() *Event {
 }
 
 func (iterator *FileEventIterator) Err() error {
-	return nil
+	return iterator.err
+}
+
+// migrateSingleFileEventLog copies the events of a pre-segmentation log into
+// segments and renames the original file once every event has been written.
+// Segments left behind by an interrupted migration are discarded first.
+func migrateSingleFileEventLog(eventLog *FileEventLog, legacyFilePath string) (err error) {
+	log.Println("Notice: migrating", legacyFilePath, "to segments")
+	err = removeSegments(eventLog.folderPath)
+	if err != nil {
+		return
+	}
+	file, err := os.Open(legacyFilePath)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(bufio.NewReaderSize(file, 256*1024))
+	lastLogicalClock := int64(0)
+	skipped := 0
+	for scanner.Scan() {
+		var event Event
+		err = json.Unmarshal(scanner.Bytes(), &event)
+		if err != nil {
+			return corruptEvent(err, "%s", legacyFilePath)
+		}
+		if event.LogicalClock <= lastLogicalClock {
+			skipped++
+			continue
+		}
+		err = eventLog.write([]*Event{&event})
+		if err != nil {
+			return
+		}
+		lastLogicalClock = event.LogicalClock
+	}
+	err = scanner.Err()
+	if err != nil {
+		return
+	}
+	err = eventLog.sync()
+	if err != nil {
+		return
+	}
+	if skipped != 0 {
+		log.Println("Notice: skipped", skipped, "out of order events during migration")
+	}
+	if eventLog.cursor < lastLogicalClock {
+		eventLog.cursor = lastLogicalClock
+		err = writeFileEventLogMetadata(filepath.Join(eventLog.folderPath, MetadataFileName), &FileEventLogMetadata{
+			Cursor: eventLog.cursor,
+		})
+		if err != nil {
+			return
+		}
+	}
+	return os.Rename(legacyFilePath, filepath.Join(eventLog.folderPath, MigratedEventsFileName))
+}
+
+// sync syncs the files of the active segment, if there is one.
+func (log *FileEventLog) sync() (err error) {
+	if log.file == nil {
+		return
+	}
+	err = log.file.Sync()
+	if err != nil {
+		return
+	}
+	return log.indexFile.Sync()
 }
 
 type FileEventLogMetadata struct {
 	Cursor int64 `json:"cursor"`
 }
 
-func readFileEventLogMetadata(filePath string) (metadata *FileEventLogMetadata) {
+func readFileEventLogMetadata(filePath string) (metadata *FileEventLogMetadata, err error) {
 	metadata = new(FileEventLogMetadata)
 	file, err := os.Open(filePath)
 	switch {
 	case err == nil:
 		defer file.Close()
-		metadata = new(FileEventLogMetadata)
 		err = json.NewDecoder(file).Decode(metadata)
-		fatal.OnError(err)
+		if err != nil {
+			// A crash during a non-atomic write can leave the file torn. The
+			// cursor is recovered from the segments instead.
+			log.Println("Notice: unreadable metadata file,", err)
+			metadata = new(FileEventLogMetadata)
+			err = nil
+		}
 	case os.IsNotExist(err):
 		log.Println("Notice: no metadata file found, starting from scratch")
-	default:
-		fatal.OnError(err)
+		err = nil
 	}
 	return
 }
 
 func writeFileEventLogMetadata(filePath string, metadata *FileEventLogMetadata) (err error) {
-	file, err := os.Create(filePath)
-	fatal.OnError(err)
-	defer file.Close()
-	err = json.NewEncoder(file).Encode(metadata)
-	fatal.OnError(err)
-	return
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return
+	}
+	return writeFileAtomically(filePath, append(data, '\n'))
+}
+
+// writeFileAtomically writes data to a temporary file, syncs it and renames it
+// over filePath so that readers see either the old or the new contents.
+func writeFileAtomically(filePath string, data []byte) (err error) {
+	temporaryFilePath := filePath + ".tmp"
+	file, err := os.Create(temporaryFilePath)
+	if err != nil {
+		return
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return
+	}
+	return os.Rename(temporaryFilePath, filePath)
 }
@@ -1,15 +1,26 @@
 package eventlog_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/ansel1/merry"
+	uuid "github.com/satori/go.uuid"
+	. "github.com/smartystreets/goconvey/convey"
+
 	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
 )
 
 type FileEventLogFactory struct {
-	pattern string
+	pattern        string
+	maxSegmentSize int64
+	indexInterval  int64
 }
 
 func (factory *FileEventLogFactory) Create(ctx context.Context) eventlog.EventLog {
@@ -17,8 +28,10 @@ func (factory *FileEventLogFactory) Create(ctx context.Context) eventlog.EventLo
 	if err != nil {
 		panic(err)
 	}
-	eventLog := eventlog.NewFileEventLog(&eventlog.NewFileEventLogInput{
-		FolderPath: folderPath,
+	eventLog := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
+		FolderPath:     folderPath,
+		MaxSegmentSize: factory.maxSegmentSize,
+		IndexInterval:  factory.indexInterval,
 	})
 	return eventLog
 }
@@ -29,6 +42,322 @@ func TestFileEventLog(t *testing.T) {
 	})
 }
 
+func TestFileEventLogSmallSegments(t *testing.T) {
+	testEventLog(t, &FileEventLogFactory{
+		pattern:        "TestFileEventLogSmallSegments-*",
+		maxSegmentSize: 1024,
+		indexInterval:  256,
+	})
+}
+
+func TestFileEventLogSegments(t *testing.T) {
+	ctx := context.Background()
+	Convey("TestFileEventLogSegments", t, func() {
+		folderPath, err := os.MkdirTemp("testdata", "TestFileEventLogSegments-*")
+		So(err, ShouldBeNil)
+		input := eventlog.NewFileEventLogInput{
+			FolderPath:     folderPath,
+			MaxSegmentSize: 1024,
+			IndexInterval:  256,
+		}
+		eventLog := eventlog.NewFileEventLogOrFatal(&input)
+		n := 100
+		events := make([]*eventlog.Event, n)
+		for i := 0; i < n; i++ {
+			event, err := eventLog.Append(ctx, eventlog.AppendInput{
+				Type: "test",
+				Data: fatal.UnlessMarshalJSON(uuid.NewV4().String()),
+			})
+			So(err, ShouldBeNil)
+			events[i] = event
+		}
+		segments := eventLog.Segments()
+		So(len(segments), ShouldBeGreaterThan, 1)
+		So(segments[0].FirstLogicalClock, ShouldEqual, 1)
+		for i, segment := range segments {
+			So(segment.Sealed, ShouldEqual, i < len(segments)-1)
+			_, err := os.Stat(segment.FilePath)
+			So(err, ShouldBeNil)
+			_, err = os.Stat(segment.IndexFilePath)
+			So(err, ShouldBeNil)
+		}
+		assertIteratesFrom := func(eventLog eventlog.EventLog, fromCursor int64) {
+			iterator := eventLog.GetEventIterator(ctx, eventlog.GetEventIteratorInput{
+				FromCursor: fromCursor,
+			})
+			for _, expected := range events[fromCursor:] {
+				So(iterator.Next(ctx), ShouldBeTrue)
+				So(iterator.Event(), ShouldResemble, expected)
+			}
+			So(iterator.Next(ctx), ShouldBeFalse)
+			So(iterator.Err(), ShouldBeNil)
+		}
+		Convey("iterate from every cursor", func() {
+			for fromCursor := int64(0); fromCursor <= int64(n); fromCursor++ {
+				assertIteratesFrom(eventLog, fromCursor)
+			}
+		})
+		Convey("reopen", func() {
+			reopened := eventlog.NewFileEventLogOrFatal(&input)
+			So(reopened.Segments(), ShouldResemble, segments)
+			assertIteratesFrom(reopened, 37)
+			event, err := reopened.Append(ctx, eventlog.AppendInput{
+				Type: "test",
+				Data: fatal.UnlessMarshalJSON(nil),
+			})
+			So(err, ShouldBeNil)
+			So(event.LogicalClock, ShouldEqual, n+1)
+		})
+		Convey("rebuild missing index", func() {
+			err := os.Remove(segments[0].IndexFilePath)
+			So(err, ShouldBeNil)
+			reopened := eventlog.NewFileEventLogOrFatal(&input)
+			_, err = os.Stat(segments[0].IndexFilePath)
+			So(err, ShouldBeNil)
+			assertIteratesFrom(reopened, 3)
+		})
+	})
+}
+
+func TestFileEventLogMigration(t *testing.T) {
+	ctx := context.Background()
+	Convey("TestFileEventLogMigration", t, func() {
+		folderPath, err := os.MkdirTemp("testdata", "TestFileEventLogMigration-*")
+		So(err, ShouldBeNil)
+		n := 50
+		events := make([]*eventlog.Event, 0, n)
+		file, err := os.Create(filepath.Join(folderPath, eventlog.EventsFileName))
+		So(err, ShouldBeNil)
+		encoder := json.NewEncoder(file)
+		for i := 1; i <= n; i++ {
+			event := &eventlog.Event{
+				ID:            uuid.NewV4().String(),
+				Type:          "test",
+				LogicalClock:  int64(i),
+				UnixTimestamp: int64(i),
+				Data:          fatal.UnlessMarshalJSON(uuid.NewV4().String()),
+			}
+			So(encoder.Encode(event), ShouldBeNil)
+			events = append(events, event)
+			if i == n/2 {
+				So(encoder.Encode(event), ShouldBeNil)
+			}
+		}
+		So(file.Close(), ShouldBeNil)
+		metadata, err := json.Marshal(eventlog.FileEventLogMetadata{Cursor: int64(n)})
+		So(err, ShouldBeNil)
+		err = os.WriteFile(filepath.Join(folderPath, eventlog.MetadataFileName), metadata, 0644)
+		So(err, ShouldBeNil)
+		eventLog := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
+			FolderPath:     folderPath,
+			MaxSegmentSize: 1024,
+		})
+		_, err = os.Stat(filepath.Join(folderPath, eventlog.EventsFileName))
+		So(os.IsNotExist(err), ShouldBeTrue)
+		_, err = os.Stat(filepath.Join(folderPath, eventlog.MigratedEventsFileName))
+		So(err, ShouldBeNil)
+		So(len(eventLog.Segments()), ShouldBeGreaterThan, 1)
+		iterator := eventLog.GetEventIterator(ctx, eventlog.GetEventIteratorInput{
+			FromCursor: 0,
+		})
+		for _, expected := range events {
+			So(iterator.Next(ctx), ShouldBeTrue)
+			So(iterator.Event(), ShouldResemble, expected)
+		}
+		So(iterator.Next(ctx), ShouldBeFalse)
+		event, err := eventLog.Append(ctx, eventlog.AppendInput{
+			Type: "test",
+			Data: fatal.UnlessMarshalJSON(nil),
+		})
+		So(err, ShouldBeNil)
+		So(event.LogicalClock, ShouldEqual, n+1)
+	})
+}
+
+func TestFileEventLogRecovery(t *testing.T) {
+	ctx := context.Background()
+	Convey("TestFileEventLogRecovery", t, func() {
+		folderPath, err := os.MkdirTemp("testdata", "TestFileEventLogRecovery-*")
+		So(err, ShouldBeNil)
+		input := eventlog.NewFileEventLogInput{
+			FolderPath:     folderPath,
+			MaxSegmentSize: 1024,
+			IndexInterval:  256,
+		}
+		eventLog := eventlog.NewFileEventLogOrFatal(&input)
+		n := 40
+		events := make([]*eventlog.Event, n)
+		for i := 0; i < n; i++ {
+			event, err := eventLog.Append(ctx, eventlog.AppendInput{
+				Type: "test",
+				Data: fatal.UnlessMarshalJSON(uuid.NewV4().String()),
+			})
+			So(err, ShouldBeNil)
+			events[i] = event
+		}
+		segments := eventLog.Segments()
+		active := segments[len(segments)-1]
+		metadataFilePath := filepath.Join(folderPath, eventlog.MetadataFileName)
+		writeMetadata := func(cursor int64) {
+			data, err := json.Marshal(eventlog.FileEventLogMetadata{Cursor: cursor})
+			So(err, ShouldBeNil)
+			So(os.WriteFile(metadataFilePath, data, 0644), ShouldBeNil)
+		}
+		appendToFile := func(filePath string, data []byte) {
+			file, err := os.OpenFile(filePath, os.O_APPEND|os.O_WRONLY, 0644)
+			So(err, ShouldBeNil)
+			_, err = file.Write(data)
+			So(err, ShouldBeNil)
+			So(file.Close(), ShouldBeNil)
+		}
+		assertRecovered := func(reopened *eventlog.FileEventLog) {
+			iterator := reopened.GetEventIterator(ctx, eventlog.GetEventIteratorInput{
+				FromCursor: 0,
+			})
+			for _, expected := range events {
+				So(iterator.Next(ctx), ShouldBeTrue)
+				So(iterator.Event(), ShouldResemble, expected)
+			}
+			So(iterator.Next(ctx), ShouldBeFalse)
+			event, err := reopened.Append(ctx, eventlog.AppendInput{
+				Type: "test",
+				Data: fatal.UnlessMarshalJSON(nil),
+			})
+			So(err, ShouldBeNil)
+			So(event.LogicalClock, ShouldEqual, n+1)
+			again := eventlog.NewFileEventLogOrFatal(&input)
+			So(again.Recovery().Repaired(), ShouldBeFalse)
+		}
+		Convey("clean", func() {
+			reopened := eventlog.NewFileEventLogOrFatal(&input)
+			So(reopened.Recovery().Repaired(), ShouldBeFalse)
+			assertRecovered(reopened)
+		})
+		Convey("torn record", func() {
+			info, err := os.Stat(active.FilePath)
+			So(err, ShouldBeNil)
+			torn := []byte(`{"id":"` + uuid.NewV4().String() + `","type":"te`)
+			appendToFile(active.FilePath, torn)
+			appendToFile(active.IndexFilePath, []byte{0, 0, 0})
+			writeMetadata(int64(n + 1))
+			reopened := eventlog.NewFileEventLogOrFatal(&input)
+			report := reopened.Recovery()
+			So(report.Repaired(), ShouldBeTrue)
+			So(report.TruncatedSegments, ShouldResemble, []eventlog.TruncatedSegment{{
+				FilePath:       active.FilePath,
+				Size:           info.Size(),
+				TruncatedBytes: int64(len(torn)),
+			}})
+			So(report.RebuiltIndexFilePaths, ShouldResemble, []string{active.IndexFilePath})
+			So(report.MetadataCursor, ShouldEqual, n+1)
+			So(report.RecoveredCursor, ShouldEqual, n)
+			assertRecovered(reopened)
+		})
+		Convey("zero filled tail", func() {
+			appendToFile(active.FilePath, make([]byte, 64))
+			reopened := eventlog.NewFileEventLogOrFatal(&input)
+			report := reopened.Recovery()
+			So(report.TruncatedSegments, ShouldHaveLength, 1)
+			So(report.TruncatedSegments[0].TruncatedBytes, ShouldEqual, 64)
+			assertRecovered(reopened)
+		})
+		Convey("metadata behind the last event", func() {
+			writeMetadata(int64(n - 1))
+			reopened := eventlog.NewFileEventLogOrFatal(&input)
+			report := reopened.Recovery()
+			So(report.TruncatedSegments, ShouldBeEmpty)
+			So(report.MetadataCursor, ShouldEqual, n-1)
+			So(report.RecoveredCursor, ShouldEqual, n)
+			assertRecovered(reopened)
+		})
+		Convey("torn metadata", func() {
+			So(os.WriteFile(metadataFilePath, []byte(`{"curs`), 0644), ShouldBeNil)
+			reopened := eventlog.NewFileEventLogOrFatal(&input)
+			So(reopened.Recovery().RecoveredCursor, ShouldEqual, n)
+			assertRecovered(reopened)
+		})
+		Convey("partial batch", func() {
+			batch, err := eventLog.AppendBatch(ctx, []eventlog.AppendInput{
+				{Type: "test", Data: fatal.UnlessMarshalJSON(1)},
+				{Type: "test", Data: fatal.UnlessMarshalJSON(2)},
+				{Type: "test", Data: fatal.UnlessMarshalJSON(3)},
+			})
+			So(err, ShouldBeNil)
+			segments := eventLog.Segments()
+			active := segments[len(segments)-1]
+			data, err := os.ReadFile(active.FilePath)
+			So(err, ShouldBeNil)
+			lastLine := bytes.LastIndexByte(data[:len(data)-1], '\n') + 1
+			So(os.Truncate(active.FilePath, int64(lastLine)), ShouldBeNil)
+			reopened := eventlog.NewFileEventLogOrFatal(&input)
+			report := reopened.Recovery()
+			So(report.TruncatedSegments, ShouldHaveLength, 1)
+			So(report.MetadataCursor, ShouldEqual, batch[2].LogicalClock)
+			So(report.RecoveredCursor, ShouldEqual, n)
+			assertRecovered(reopened)
+		})
+		Convey("empty segment after roll", func() {
+			emptyFilePath := filepath.Join(folderPath, fmt.Sprintf("%020d%s", n+1, eventlog.SegmentFileExtension))
+			So(os.WriteFile(emptyFilePath, nil, 0644), ShouldBeNil)
+			reopened := eventlog.NewFileEventLogOrFatal(&input)
+			report := reopened.Recovery()
+			So(report.RemovedSegmentFilePaths, ShouldResemble, []string{emptyFilePath})
+			So(reopened.Segments(), ShouldResemble, segments)
+			assertRecovered(reopened)
+		})
+	})
+}
+
+func TestFileEventLogErrors(t *testing.T) {
+	ctx := context.Background()
+	Convey("TestFileEventLogErrors", t, func() {
+		folderPath, err := os.MkdirTemp("testdata", "TestFileEventLogErrors-*")
+		So(err, ShouldBeNil)
+		eventLog := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
+			FolderPath: folderPath,
+		})
+		for i := 0; i < 3; i++ {
+			_, err = eventLog.Append(ctx, eventlog.AppendInput{
+				Type: "test",
+				Data: fatal.UnlessMarshalJSON(i),
+			})
+			So(err, ShouldBeNil)
+		}
+		Convey("corrupt event", func() {
+			corruptLine(eventLog.Segments()[0].FilePath, 1)
+			iterator := eventLog.GetEventIterator(ctx, eventlog.GetEventIteratorInput{})
+			So(iterator.Next(ctx), ShouldBeTrue)
+			So(iterator.Event().LogicalClock, ShouldEqual, 1)
+			So(iterator.Next(ctx), ShouldBeFalse)
+			So(merry.Is(iterator.Err(), eventlog.ErrCorruptEvent), ShouldBeTrue)
+			So(iterator.Next(ctx), ShouldBeTrue)
+			So(iterator.Event().LogicalClock, ShouldEqual, 3)
+			So(iterator.Next(ctx), ShouldBeFalse)
+			So(iterator.Err(), ShouldBeNil)
+		})
+		Convey("closed", func() {
+			So(eventLog.Close(), ShouldBeNil)
+			_, err := eventLog.Append(ctx, eventlog.AppendInput{
+				Type: "test",
+				Data: fatal.UnlessMarshalJSON(nil),
+			})
+			So(merry.Is(err, eventlog.ErrClosed), ShouldBeTrue)
+			iterator := eventLog.GetEventIterator(ctx, eventlog.GetEventIteratorInput{})
+			So(iterator.Next(ctx), ShouldBeFalse)
+			So(merry.Is(iterator.Err(), eventlog.ErrClosed), ShouldBeTrue)
+			reopened := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
+				FolderPath: folderPath,
+			})
+			event, err := reopened.Append(ctx, eventlog.AppendInput{
+				Type: "test",
+				Data: fatal.UnlessMarshalJSON(nil),
+			})
+			So(err, ShouldBeNil)
+			So(event.LogicalClock, ShouldEqual, 4)
+		})
+	})
+}
+
 func BenchmarkFileEventLogAppend(b *testing.B) {
 	benchmarkAppend(b, &FileEventLogFactory{
 		pattern: "BenchmarkFileEventLog-*",
@@ -0,0 +1,17 @@
+//go:build !unix
+
+package eventlog
+
+import (
+	"os"
+)
+
+// lockFile does nothing where flock is not available, so only one process
+// may append to an IndexedEventLog at a time.
+func lockFile(file *os.File) error {
+	return nil
+}
+
+func unlockFile(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,90 @@
+package eventlog
+
+import "context"
+
+// eventFilter is the filtering part of GetEventIteratorInput. Backends that
+// cannot use an index wrap their iterator in a FilteringEventIterator.
+type eventFilter struct {
+	types         map[string]struct{}
+	accountID     string
+	unscopedTypes map[string]struct{}
+	streamKey     string
+}
+
+// newEventFilter returns nil if the input does not filter anything.
+func newEventFilter(input GetEventIteratorInput) *eventFilter {
+	if len(input.Types) == 0 && input.AccountID == "" && input.StreamKey == "" {
+		return nil
+	}
+	return &eventFilter{
+		types:         newTypeSet(input.Types),
+		accountID:     input.AccountID,
+		unscopedTypes: newTypeSet(input.UnscopedTypes),
+		streamKey:     input.StreamKey,
+	}
+}
+
+func newTypeSet(types []string) map[string]struct{} {
+	if len(types) == 0 {
+		return nil
+	}
+	typeSet := make(map[string]struct{}, len(types))
+	for _, eventType := range types {
+		typeSet[eventType] = struct{}{}
+	}
+	return typeSet
+}
+
+func (filter *eventFilter) matches(event *Event) bool {
+	if filter.types != nil {
+		if _, ok := filter.types[event.Type]; !ok {
+			return false
+		}
+	}
+	if filter.streamKey != "" && event.StreamKey != filter.streamKey {
+		return false
+	}
+	if filter.accountID == "" || event.AccountID == filter.accountID {
+		return true
+	}
+	_, ok := filter.unscopedTypes[event.Type]
+	return ok
+}
+
+// filterEventIterator returns iterator unchanged if input does not filter.
+func filterEventIterator(iterator EventIterator, input GetEventIteratorInput) EventIterator {
+	filter := newEventFilter(input)
+	if filter == nil {
+		return iterator
+	}
+	return &FilteringEventIterator{
+		decorated: iterator,
+		filter:    filter,
+	}
+}
+
+type FilteringEventIterator struct {
+	decorated EventIterator
+	filter    *eventFilter
+}
+
+func (iterator *FilteringEventIterator) Next(ctx context.Context) bool {
+	for iterator.decorated.Next(ctx) {
+		if iterator.filter.matches(iterator.decorated.Event()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (iterator *FilteringEventIterator) Close() error {
+	return closeEventIterator(iterator.decorated)
+}
+
+func (iterator *FilteringEventIterator) Event() *Event {
+	return iterator.decorated.Event()
+}
+
+func (iterator *FilteringEventIterator) Err() error {
+	return iterator.decorated.Err()
+}
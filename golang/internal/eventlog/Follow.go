@@ -7,44 +7,73 @@ import (
 type FollowInput struct {
 	EventLog   EventLog
 	FromCursor int64
+	// Types, AccountID, UnscopedTypes and StreamKey filter events as they do
+	// in GetEventIteratorInput.
+	Types         []string
+	AccountID     string
+	UnscopedTypes []string
+	StreamKey     string
 }
 
 func Follow(ctx context.Context, input FollowInput) EventIterator {
-	eventIterator := input.EventLog.GetEventIterator(ctx, GetEventIteratorInput{
-		FromCursor: input.FromCursor,
-	})
-	return &FollowingEventIterator{
-		eventLog:      input.EventLog,
-		eventIterator: eventIterator,
-		waitC:         input.EventLog.Wait(ctx),
-		cursor:        input.FromCursor,
+	getEventIteratorInput := GetEventIteratorInput{
+		FromCursor:    input.FromCursor,
+		Types:         input.Types,
+		AccountID:     input.AccountID,
+		UnscopedTypes: input.UnscopedTypes,
+		StreamKey:     input.StreamKey,
 	}
+	iterator := &FollowingEventIterator{
+		eventLog:              input.EventLog,
+		waitC:                 input.EventLog.Wait(ctx),
+		getEventIteratorInput: getEventIteratorInput,
+	}
+	iterator.read(ctx)
+	return iterator
 }
 
+// FollowingEventIterator keeps its position in the log apart from the last
+// event it returned, so that a filtered follower does not read the events
+// it filtered out again each time it wakes.
 type FollowingEventIterator struct {
 	eventLog      EventLog
 	eventIterator EventIterator
-	waitC         <-chan struct{}
-	cursor        int64
+	// head is the cursor of the log when eventIterator was created, which
+	// eventIterator has read up to once it runs out of events.
+	head                  int64
+	waitC                 <-chan struct{}
+	getEventIteratorInput GetEventIteratorInput
+	err                   error
+}
+
+func (iterator *FollowingEventIterator) read(ctx context.Context) {
+	iterator.head = iterator.eventLog.Cursor()
+	iterator.eventIterator = iterator.eventLog.GetEventIterator(ctx, iterator.getEventIteratorInput)
 }
 
+// Next blocks until the log has a new event or ctx is done, in which case
+// Err reports ErrCanceled. After ErrCorruptEvent, Next carries on after the
+// corrupt event.
 func (iterator *FollowingEventIterator) Next(ctx context.Context) bool {
+	iterator.err = nil
 	if iterator.eventIterator.Next(ctx) {
 		event := iterator.eventIterator.Event()
-		iterator.cursor = event.LogicalClock
+		iterator.getEventIteratorInput.FromCursor = event.LogicalClock
 		return true
 	}
 	if iterator.eventIterator.Err() != nil {
 		return false
 	}
+	if iterator.head > iterator.getEventIteratorInput.FromCursor {
+		iterator.getEventIteratorInput.FromCursor = iterator.head
+	}
 	select {
 	case <-ctx.Done():
+		iterator.err = canceled(ctx)
 		return false
 	case <-iterator.waitC:
 		iterator.waitC = iterator.eventLog.Wait(ctx)
-		iterator.eventIterator = iterator.eventLog.GetEventIterator(ctx, GetEventIteratorInput{
-			FromCursor: iterator.cursor,
-		})
+		iterator.read(ctx)
 		return iterator.Next(ctx)
 	}
 }
@@ -54,5 +83,8 @@ func (iterator *FollowingEventIterator) Event() *Event {
 }
 
 func (iterator *FollowingEventIterator) Err() error {
+	if iterator.err != nil {
+		return iterator.err
+	}
 	return iterator.eventIterator.Err()
 }
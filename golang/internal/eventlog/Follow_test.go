@@ -3,23 +3,26 @@ package eventlog_test
 import (
 	"context"
 	"encoding/json"
-	"os"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/ansel1/merry"
 	uuid "github.com/satori/go.uuid"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
 func TestFollow(t *testing.T) {
+	testFollow(t, &FileEventLogFactory{
+		pattern: "TestFollow-*",
+	})
+}
+
+func testFollow(t *testing.T, factory EventLogFactory) {
 	Convey("Follow", t, func() {
-		folderPath, err := os.MkdirTemp("testdata", "TestFollow-*")
-		So(err, ShouldBeNil)
-		eventLog := eventlog.NewFileEventLog(&eventlog.NewFileEventLogInput{
-			FolderPath: folderPath,
-		})
 		ctx := context.Background()
+		eventLog := factory.Create(ctx)
 		accountID := uuid.NewV4().String()
 		Convey("empty log", func() {
 			ctx, cancel := context.WithCancel(ctx)
@@ -40,7 +43,8 @@ func TestFollow(t *testing.T) {
 				cancel()
 				next := <-nextC
 				So(next, ShouldBeFalse)
-				So(iterator.Err(), ShouldBeNil)
+				So(merry.Is(iterator.Err(), eventlog.ErrCanceled), ShouldBeTrue)
+				So(errors.Is(iterator.Err(), context.Canceled), ShouldBeTrue)
 			})
 			Convey("append", func() {
 				eventType := uuid.NewV4().String()
@@ -61,6 +65,84 @@ func TestFollow(t *testing.T) {
 				So(event.LogicalClock, ShouldEqual, 1)
 			})
 		})
+		Convey("filtered", func() {
+			iterator := eventlog.Follow(ctx, eventlog.FollowInput{
+				EventLog:      eventLog,
+				FromCursor:    0,
+				AccountID:     accountID,
+				UnscopedTypes: []string{"global"},
+			})
+			nextC := make(chan bool)
+			go func() {
+				nextC <- iterator.Next(ctx)
+			}()
+			_, err := eventLog.Append(ctx, eventlog.AppendInput{
+				Type:      "test",
+				AccountID: uuid.NewV4().String(),
+				Data:      json.RawMessage("null"),
+			})
+			So(err, ShouldBeNil)
+			select {
+			case <-nextC:
+				t.Fail()
+			case <-time.After(100 * time.Millisecond):
+			}
+			_, err = eventLog.Append(ctx, eventlog.AppendInput{
+				Type: "global",
+				Data: json.RawMessage("null"),
+			})
+			So(err, ShouldBeNil)
+			So(<-nextC, ShouldBeTrue)
+			So(iterator.Event().Type, ShouldEqual, "global")
+			So(iterator.Event().LogicalClock, ShouldEqual, 2)
+			go func() {
+				nextC <- iterator.Next(ctx)
+			}()
+			_, err = eventLog.Append(ctx, eventlog.AppendInput{
+				Type:      "test",
+				AccountID: accountID,
+				Data:      json.RawMessage("null"),
+			})
+			So(err, ShouldBeNil)
+			So(<-nextC, ShouldBeTrue)
+			So(iterator.Event().AccountID, ShouldEqual, accountID)
+			So(iterator.Event().LogicalClock, ShouldEqual, 3)
+		})
+		Convey("filtered events are not read again", func() {
+			appendAccountEvent := func(accountID string) {
+				_, err := eventLog.Append(ctx, eventlog.AppendInput{
+					Type:      "test",
+					AccountID: accountID,
+					Data:      json.RawMessage("null"),
+				})
+				So(err, ShouldBeNil)
+			}
+			appendAccountEvent(accountID)
+			for i := 0; i < 5; i++ {
+				appendAccountEvent(uuid.NewV4().String())
+			}
+			recording := &recordingEventLog{EventLog: eventLog}
+			iterator := eventlog.Follow(ctx, eventlog.FollowInput{
+				EventLog:   recording,
+				FromCursor: 0,
+				AccountID:  accountID,
+			})
+			So(iterator.Next(ctx), ShouldBeTrue)
+			So(iterator.Event().LogicalClock, ShouldEqual, 1)
+			nextC := make(chan bool)
+			go func() {
+				nextC <- iterator.Next(ctx)
+			}()
+			select {
+			case <-nextC:
+				t.Fail()
+			case <-time.After(100 * time.Millisecond):
+			}
+			appendAccountEvent(accountID)
+			So(<-nextC, ShouldBeTrue)
+			So(iterator.Event().LogicalClock, ShouldEqual, 7)
+			So(recording.fromCursors, ShouldResemble, []int64{0, 6})
+		})
 		Convey("one event", func() {
 			eventType := uuid.NewV4().String()
 			data, err := json.Marshal(uuid.NewV4().String())
@@ -100,7 +182,7 @@ func TestFollow(t *testing.T) {
 					cancel()
 					next := <-nextC
 					So(next, ShouldBeFalse)
-					So(iterator.Err(), ShouldBeNil)
+					So(merry.Is(iterator.Err(), eventlog.ErrCanceled), ShouldBeTrue)
 				})
 				Convey("append", func() {
 					eventType := uuid.NewV4().String()
@@ -140,7 +222,7 @@ func TestFollow(t *testing.T) {
 					cancel()
 					next := <-nextC
 					So(next, ShouldBeFalse)
-					So(iterator.Err(), ShouldBeNil)
+					So(merry.Is(iterator.Err(), eventlog.ErrCanceled), ShouldBeTrue)
 				})
 				Convey("append", func() {
 					eventType := uuid.NewV4().String()
@@ -164,3 +246,14 @@ func TestFollow(t *testing.T) {
 		})
 	})
 }
+
+// recordingEventLog records where each iterator of the log starts.
+type recordingEventLog struct {
+	eventlog.EventLog
+	fromCursors []int64
+}
+
+func (log *recordingEventLog) GetEventIterator(ctx context.Context, input eventlog.GetEventIteratorInput) eventlog.EventIterator {
+	log.fromCursors = append(log.fromCursors, input.FromCursor)
+	return log.EventLog.GetEventIterator(ctx, input)
+}
@@ -0,0 +1,383 @@
+package eventlog
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ansel1/merry"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+)
+
+// GenesisHash is the previous hash of the first event of a log.
+var GenesisHash = strings.Repeat("0", sha256.Size*2)
+
+// BrokenLinkError is returned by the iterators of a HashChainDecorator when
+// an event does not carry the hash of the events before it.
+type BrokenLinkError struct {
+	LogicalClock         int64
+	ExpectedPreviousHash string
+	PreviousHash         string
+}
+
+func (err *BrokenLinkError) Error() string {
+	if err.PreviousHash == "" {
+		return fmt.Sprintf("hash chain is broken at logical clock %d: event has no previous hash", err.LogicalClock)
+	}
+	return fmt.Sprintf("hash chain is broken at logical clock %d: previous hash is %s, expected %s", err.LogicalClock, err.PreviousHash, err.ExpectedPreviousHash)
+}
+
+// hashChainLink is what is hashed for each event: every field, with the
+// hash of the events before it.
+type hashChainLink struct {
+	PreviousHash  string          `json:"previous_hash"`
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	AccountID     string          `json:"account_id"`
+	StreamKey     string          `json:"stream_key"`
+	LogicalClock  int64           `json:"logical_clock"`
+	UnixTimestamp int64           `json:"unix_timestamp"`
+	Data          json.RawMessage `json:"data"`
+}
+
+func nextHash(previousHash string, event *Event) string {
+	link, err := json.Marshal(hashChainLink{
+		PreviousHash:  previousHash,
+		ID:            event.ID,
+		Type:          event.Type,
+		AccountID:     event.AccountID,
+		StreamKey:     event.StreamKey,
+		LogicalClock:  event.LogicalClock,
+		UnixTimestamp: event.UnixTimestamp,
+		Data:          event.Data,
+	})
+	fatal.OnError(err)
+	sum := sha256.Sum256(link)
+	return hex.EncodeToString(sum[:])
+}
+
+// HashChainFileName holds the logical clock of the first hash chained event
+// of a log folder, and the compactions that relinked its chain.
+const HashChainFileName = "hash_chain.json"
+
+// HashChainStarter is implemented by logs that keep the logical clock of
+// their first hash chained event, after which every event has to be chained.
+// Without it, a log with the previous hash stripped from every event would
+// verify as one written before the chain started.
+type HashChainStarter interface {
+	// HashChainStart is zero until StartHashChain is called.
+	HashChainStart() (logicalClock int64, err error)
+	StartHashChain(logicalClock int64) error
+	// HashChainRebases lists the compactions that relinked the chain, oldest
+	// first.
+	HashChainRebases() (rebases []HashChainRebase, err error)
+}
+
+// HashChainRebase records that compaction relinked a chain over the events
+// it kept. The source log verified up to SourceCursor, where the head of its
+// chain was SourceHead. The relinked chain can't show that the source wasn't
+// tampered with before it was compacted, but SourceHead can be checked
+// against a copy of the source or a head recorded before the compaction.
+type HashChainRebase struct {
+	SourceCursor  int64  `json:"source_cursor"`
+	SourceHead    string `json:"source_head"`
+	UnixTimestamp int64  `json:"unix_timestamp"`
+}
+
+type hashChainMetadata struct {
+	Start   int64             `json:"start"`
+	Rebases []HashChainRebase `json:"rebases,omitempty"`
+}
+
+func readHashChainMetadata(folderPath string) (metadata hashChainMetadata, err error) {
+	data, err := os.ReadFile(filepath.Join(folderPath, HashChainFileName))
+	if os.IsNotExist(err) {
+		err = nil
+		return
+	}
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(data, &metadata)
+	if err != nil {
+		err = merry.Prependf(err, "unreadable %s", HashChainFileName)
+		return
+	}
+	return
+}
+
+func writeHashChainMetadata(folderPath string, metadata hashChainMetadata) (err error) {
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return
+	}
+	return writeFileAtomically(filepath.Join(folderPath, HashChainFileName), append(data, '\n'))
+}
+
+func readHashChainStart(folderPath string) (logicalClock int64, err error) {
+	metadata, err := readHashChainMetadata(folderPath)
+	logicalClock = metadata.Start
+	return
+}
+
+func readHashChainRebases(folderPath string) (rebases []HashChainRebase, err error) {
+	metadata, err := readHashChainMetadata(folderPath)
+	rebases = metadata.Rebases
+	return
+}
+
+// writeHashChainStart keeps the rebases already recorded.
+func writeHashChainStart(folderPath string, logicalClock int64) (err error) {
+	metadata, err := readHashChainMetadata(folderPath)
+	if err != nil {
+		return
+	}
+	metadata.Start = logicalClock
+	return writeHashChainMetadata(folderPath, metadata)
+}
+
+// HashChainDecorator stores in each appended event a hash of every event
+// before it, so that editing, removing or reordering events breaks the
+// chain. Iterators verify the chain as they go and stop with a
+// *BrokenLinkError at the first broken link. The decorator assigns the ID,
+// logical clock and timestamp of each event so that they are hashed too, so
+// the decorated log must only be appended to through it.
+//
+// Events appended before the decorator was introduced have no previous hash
+// and are accepted until the first event that has one, which covers them.
+// If the decorated log is a HashChainStarter, it records where the chain
+// started, and events after that without a previous hash are broken links.
+type HashChainDecorator struct {
+	decorated EventLog
+	head      string
+	cursor    int64
+	started   bool
+}
+
+type NewHashChainDecoratorInput struct {
+	Decorated EventLog
+}
+
+// NewHashChainDecorator verifies the whole log to find the hash to link the
+// next event to. It records the start of a chain that was appended without a
+// record, such as one replicated from a primary.
+func NewHashChainDecorator(ctx context.Context, input NewHashChainDecoratorInput) (decorator *HashChainDecorator, err error) {
+	iterator := newHashChainIterator(ctx, input.Decorated, 0)
+	var cursor int64
+	for iterator.Next(ctx) {
+		cursor = iterator.Event().LogicalClock
+	}
+	err = iterator.Err()
+	if err != nil {
+		return
+	}
+	decorator = &HashChainDecorator{
+		decorated: input.Decorated,
+		head:      iterator.head,
+		cursor:    cursor,
+		started:   iterator.start != 0,
+	}
+	if iterator.chained && iterator.recordedStart == 0 {
+		err = decorator.start(iterator.start)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+func NewHashChainDecoratorOrFatal(ctx context.Context, input NewHashChainDecoratorInput) *HashChainDecorator {
+	decorator, err := NewHashChainDecorator(ctx, input)
+	fatal.OnError(err)
+	return decorator
+}
+
+func (decorator *HashChainDecorator) Append(ctx context.Context, input AppendInput) (event *Event, err error) {
+	events, err := decorator.AppendBatch(ctx, []AppendInput{input})
+	if err != nil {
+		return
+	}
+	event = events[0]
+	return
+}
+
+// AppendBatch records the start of the chain before its first event is
+// appended, so that the record never follows chained events.
+func (decorator *HashChainDecorator) AppendBatch(ctx context.Context, inputs []AppendInput) (events []*Event, err error) {
+	if len(inputs) == 0 {
+		return
+	}
+	if !decorator.started {
+		err = decorator.start(decorator.cursor + 1)
+		if err != nil {
+			return
+		}
+	}
+	linked := make([]AppendInput, len(inputs))
+	head := decorator.head
+	unixTimestamp := time.Now().Unix()
+	for i, input := range inputs {
+		input.ID = uuid.NewV4().String()
+		input.LogicalClock = decorator.cursor + int64(i) + 1
+		input.UnixTimestamp = unixTimestamp
+		input.PreviousHash = head
+		head = nextHash(head, input.event())
+		linked[i] = input
+	}
+	events, err = decorator.decorated.AppendBatch(ctx, linked)
+	if err != nil {
+		return
+	}
+	decorator.head = head
+	decorator.cursor = events[len(events)-1].LogicalClock
+	return
+}
+
+func (decorator *HashChainDecorator) start(logicalClock int64) (err error) {
+	starter, ok := decorator.decorated.(HashChainStarter)
+	if ok {
+		err = starter.StartHashChain(logicalClock)
+		if err != nil {
+			return
+		}
+	}
+	decorator.started = true
+	return
+}
+
+// GetEventIterator verifies the chain from FromCursor. The link of the first
+// event read is taken on trust, so only iterators from the start of the log
+// verify every event.
+func (decorator *HashChainDecorator) GetEventIterator(ctx context.Context, input GetEventIteratorInput) EventIterator {
+	return filterEventIterator(newHashChainIterator(ctx, decorator.decorated, input.FromCursor), input)
+}
+
+func (decorator *HashChainDecorator) Wait(ctx context.Context) <-chan struct{} {
+	return decorator.decorated.Wait(ctx)
+}
+
+func (decorator *HashChainDecorator) Cursor() int64 {
+	return decorator.decorated.Cursor()
+}
+
+type HashChainReport struct {
+	Cursor        int64
+	Events        int
+	ChainedEvents int
+	// Rebases lists the compactions that relinked the chain, if the log is a
+	// HashChainStarter.
+	Rebases []HashChainRebase
+}
+
+// VerifyHashChain reads the whole log and returns a *BrokenLinkError for the
+// first broken link. It does not need the log to be decorated, so it can
+// check a folder opened read only.
+func VerifyHashChain(ctx context.Context, eventLog EventLog) (report HashChainReport, err error) {
+	if starter, ok := eventLog.(HashChainStarter); ok {
+		report.Rebases, err = starter.HashChainRebases()
+		if err != nil {
+			return
+		}
+	}
+	iterator := newHashChainIterator(ctx, eventLog, 0)
+	for iterator.Next(ctx) {
+		event := iterator.Event()
+		report.Cursor = event.LogicalClock
+		report.Events++
+		if event.PreviousHash != "" {
+			report.ChainedEvents++
+		}
+	}
+	err = iterator.Err()
+	return
+}
+
+// hashChainIterator reads every event of the decorated log so that each one
+// can be checked against the one before it.
+type hashChainIterator struct {
+	decorated EventIterator
+	// head is the hash of the events read so far. It is empty when iterating
+	// from a cursor until an event with a previous hash is read, whose link is
+	// then trusted. chained is set once an event with a previous hash is read,
+	// after which every event must have one.
+	head    string
+	chained bool
+	// recordedStart is the start of the chain recorded by a HashChainStarter,
+	// from which every event must have a previous hash. start is the same, or
+	// the first event read with a previous hash when there is no record.
+	recordedStart int64
+	start         int64
+	err           error
+}
+
+func newHashChainIterator(ctx context.Context, eventLog EventLog, fromCursor int64) *hashChainIterator {
+	iterator := &hashChainIterator{
+		decorated: eventLog.GetEventIterator(ctx, GetEventIteratorInput{
+			FromCursor: fromCursor,
+		}),
+	}
+	if starter, ok := eventLog.(HashChainStarter); ok {
+		iterator.recordedStart, iterator.err = starter.HashChainStart()
+		iterator.start = iterator.recordedStart
+	}
+	if fromCursor == 0 {
+		iterator.head = GenesisHash
+	}
+	return iterator
+}
+
+func (iterator *hashChainIterator) Next(ctx context.Context) bool {
+	if iterator.err != nil || !iterator.decorated.Next(ctx) {
+		return false
+	}
+	event := iterator.decorated.Event()
+	if !iterator.linked(event) {
+		iterator.err = merry.Wrap(&BrokenLinkError{
+			LogicalClock:         event.LogicalClock,
+			ExpectedPreviousHash: iterator.head,
+			PreviousHash:         event.PreviousHash,
+		})
+		return false
+	}
+	if event.PreviousHash != "" {
+		if iterator.start == 0 {
+			iterator.start = event.LogicalClock
+		}
+		iterator.chained = true
+		iterator.head = event.PreviousHash
+	}
+	if iterator.head != "" {
+		iterator.head = nextHash(iterator.head, event)
+	}
+	return true
+}
+
+func (iterator *hashChainIterator) linked(event *Event) bool {
+	if event.PreviousHash == "" {
+		return !iterator.chained && (iterator.recordedStart == 0 || event.LogicalClock < iterator.recordedStart)
+	}
+	return iterator.head == "" || event.PreviousHash == iterator.head
+}
+
+func (iterator *hashChainIterator) Close() error {
+	return closeEventIterator(iterator.decorated)
+}
+
+func (iterator *hashChainIterator) Event() *Event {
+	return iterator.decorated.Event()
+}
+
+func (iterator *hashChainIterator) Err() error {
+	if iterator.err != nil {
+		return iterator.err
+	}
+	return iterator.decorated.Err()
+}
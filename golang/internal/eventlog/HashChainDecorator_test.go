@@ -0,0 +1,253 @@
+package eventlog_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+)
+
+type HashChainDecoratorFactory struct{}
+
+func (factory *HashChainDecoratorFactory) Create(ctx context.Context) eventlog.EventLog {
+	folderPath, err := os.MkdirTemp("testdata", "TestHashChainDecorator-*")
+	if err != nil {
+		panic(err)
+	}
+	eventLog, err := eventlog.NewCachingDecorator(ctx, eventlog.NewCachingDecoratorInput{
+		Decorated: eventlog.NewHashChainDecoratorOrFatal(ctx, eventlog.NewHashChainDecoratorInput{
+			Decorated: eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
+				FolderPath: folderPath,
+			}),
+		}),
+	})
+	if err != nil {
+		panic(err)
+	}
+	return eventLog
+}
+
+func TestHashChainDecoratorEventLog(t *testing.T) {
+	testEventLog(t, new(HashChainDecoratorFactory))
+}
+
+func TestHashChainDecorator(t *testing.T) {
+	ctx := context.Background()
+	Convey("TestHashChainDecorator", t, func() {
+		folderPath, err := os.MkdirTemp("testdata", "TestHashChainDecorator-*")
+		So(err, ShouldBeNil)
+		fileEventLog := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
+			FolderPath: folderPath,
+		})
+		appendEvents := func(eventLog eventlog.EventLog, from int, to int) {
+			for i := from; i < to; i++ {
+				_, err := eventLog.Append(ctx, eventlog.AppendInput{
+					Type: "test",
+					Data: fatal.UnlessMarshalJSON(fmt.Sprintf("event-%d", i)),
+				})
+				So(err, ShouldBeNil)
+			}
+		}
+		tamper := func(old string, new string) {
+			segments := fileEventLog.Segments()
+			filePath := segments[len(segments)-1].FilePath
+			data, err := os.ReadFile(filePath)
+			So(err, ShouldBeNil)
+			So(bytes.Contains(data, []byte(old)), ShouldBeTrue)
+			So(os.WriteFile(filePath, bytes.Replace(data, []byte(old), []byte(new), 1), 0644), ShouldBeNil)
+		}
+		assertBrokenAt := func(err error, logicalClock int64) {
+			var brokenLink *eventlog.BrokenLinkError
+			So(errors.As(err, &brokenLink), ShouldBeTrue)
+			So(brokenLink.LogicalClock, ShouldEqual, logicalClock)
+		}
+		Convey("new log", func() {
+			decorator, err := eventlog.NewHashChainDecorator(ctx, eventlog.NewHashChainDecoratorInput{
+				Decorated: fileEventLog,
+			})
+			So(err, ShouldBeNil)
+			appendEvents(decorator, 0, 5)
+			_, err = decorator.AppendBatch(ctx, []eventlog.AppendInput{
+				{Type: "test", Data: fatal.UnlessMarshalJSON("event-5")},
+				{Type: "test", Data: fatal.UnlessMarshalJSON("event-6")},
+			})
+			So(err, ShouldBeNil)
+			report, err := eventlog.VerifyHashChain(ctx, fileEventLog)
+			So(err, ShouldBeNil)
+			So(report, ShouldResemble, eventlog.HashChainReport{
+				Cursor:        7,
+				Events:        7,
+				ChainedEvents: 7,
+			})
+			iterator := fileEventLog.GetEventIterator(ctx, eventlog.GetEventIteratorInput{})
+			So(iterator.Next(ctx), ShouldBeTrue)
+			So(iterator.Event().PreviousHash, ShouldEqual, eventlog.GenesisHash)
+			Convey("reopen", func() {
+				reopened, err := eventlog.NewHashChainDecorator(ctx, eventlog.NewHashChainDecoratorInput{
+					Decorated: fileEventLog,
+				})
+				So(err, ShouldBeNil)
+				appendEvents(reopened, 7, 9)
+				report, err := eventlog.VerifyHashChain(ctx, fileEventLog)
+				So(err, ShouldBeNil)
+				So(report.ChainedEvents, ShouldEqual, 9)
+			})
+			Convey("edited event", func() {
+				tamper(`"event-3"`, `"event-X"`)
+				_, err := eventlog.VerifyHashChain(ctx, fileEventLog)
+				assertBrokenAt(err, 5)
+				_, err = eventlog.NewHashChainDecorator(ctx, eventlog.NewHashChainDecoratorInput{
+					Decorated: fileEventLog,
+				})
+				assertBrokenAt(err, 5)
+			})
+			Convey("edited timestamp", func() {
+				event := eventAt(ctx, fileEventLog, 3)
+				tamper(fmt.Sprintf(`"logical_clock":3,"unix_timestamp":%d`, event.UnixTimestamp),
+					fmt.Sprintf(`"logical_clock":3,"unix_timestamp":%d`, event.UnixTimestamp-3600))
+				_, err := eventlog.VerifyHashChain(ctx, fileEventLog)
+				assertBrokenAt(err, 4)
+			})
+			Convey("every link removed", func() {
+				segments := fileEventLog.Segments()
+				filePath := segments[len(segments)-1].FilePath
+				data, err := os.ReadFile(filePath)
+				So(err, ShouldBeNil)
+				data = regexp.MustCompile(`,"previous_hash":"[0-9a-f]*"`).ReplaceAll(data, nil)
+				So(os.WriteFile(filePath, data, 0644), ShouldBeNil)
+				_, err = eventlog.VerifyHashChain(ctx, fileEventLog)
+				assertBrokenAt(err, 1)
+				_, err = eventlog.NewHashChainDecorator(ctx, eventlog.NewHashChainDecoratorInput{
+					Decorated: fileEventLog,
+				})
+				assertBrokenAt(err, 1)
+			})
+			Convey("appended to behind the decorator", func() {
+				appendEvents(fileEventLog, 7, 8)
+				_, err := decorator.Append(ctx, eventlog.AppendInput{
+					Type: "test",
+					Data: fatal.UnlessMarshalJSON("event-8"),
+				})
+				var conflict *eventlog.ConflictError
+				So(errors.As(err, &conflict), ShouldBeTrue)
+				So(fileEventLog.Cursor(), ShouldEqual, 8)
+			})
+			Convey("checked by the caching decorator replay", func() {
+				tamper(`"event-6"`, `"event-X"`)
+				appendEvents(decorator, 7, 8)
+				_, err := eventlog.NewCachingDecorator(ctx, eventlog.NewCachingDecoratorInput{
+					Decorated: decorator,
+				})
+				assertBrokenAt(err, 8)
+			})
+			Convey("compacted", func() {
+				compactedFolderPath, err := os.MkdirTemp("testdata", "TestHashChainDecoratorCompacted-*")
+				So(err, ShouldBeNil)
+				report, err := eventlog.CompactFileEventLog(ctx, eventlog.CompactFileEventLogInput{
+					EventLog:   fileEventLog,
+					FolderPath: compactedFolderPath,
+					Before:     4,
+					Drop: func(event *eventlog.Event) bool {
+						return event.LogicalClock%2 == 0
+					},
+				})
+				So(err, ShouldBeNil)
+				So(report.Dropped, ShouldEqual, 1)
+				compacted := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
+					FolderPath: compactedFolderPath,
+				})
+				verified, err := eventlog.VerifyHashChain(ctx, compacted)
+				So(err, ShouldBeNil)
+				So(verified.ChainedEvents, ShouldEqual, 6)
+				So(verified.Rebases, ShouldHaveLength, 1)
+				So(verified.Rebases[0], ShouldResemble, *report.Rebase)
+				So(verified.Rebases[0].SourceCursor, ShouldEqual, 7)
+				next, err := decorator.Append(ctx, eventlog.AppendInput{
+					Type: "test",
+					Data: fatal.UnlessMarshalJSON("event-8"),
+				})
+				So(err, ShouldBeNil)
+				So(verified.Rebases[0].SourceHead, ShouldEqual, next.PreviousHash)
+				Convey("again", func() {
+					recompactedFolderPath, err := os.MkdirTemp("testdata", "TestHashChainDecoratorCompacted-*")
+					So(err, ShouldBeNil)
+					_, err = eventlog.CompactFileEventLog(ctx, eventlog.CompactFileEventLogInput{
+						EventLog:   compacted,
+						FolderPath: recompactedFolderPath,
+						Drop: func(event *eventlog.Event) bool {
+							return false
+						},
+					})
+					So(err, ShouldBeNil)
+					verified, err := eventlog.VerifyHashChain(ctx, eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
+						FolderPath: recompactedFolderPath,
+					}))
+					So(err, ShouldBeNil)
+					So(verified.Rebases, ShouldHaveLength, 2)
+					So(verified.Rebases[0], ShouldResemble, *report.Rebase)
+					So(verified.Rebases[1].SourceCursor, ShouldEqual, 7)
+				})
+			})
+			Convey("iterate from a cursor", func() {
+				tamper(`"event-1"`, `"event-X"`)
+				iterator := decorator.GetEventIterator(ctx, eventlog.GetEventIteratorInput{
+					FromCursor: 2,
+				})
+				count := 0
+				for iterator.Next(ctx) {
+					count++
+				}
+				So(iterator.Err(), ShouldBeNil)
+				So(count, ShouldEqual, 5)
+				iterator = decorator.GetEventIterator(ctx, eventlog.GetEventIteratorInput{
+					FromCursor: 1,
+				})
+				So(iterator.Next(ctx), ShouldBeTrue)
+				So(iterator.Next(ctx), ShouldBeFalse)
+				assertBrokenAt(iterator.Err(), 3)
+			})
+		})
+		Convey("existing log", func() {
+			appendEvents(fileEventLog, 0, 3)
+			decorator, err := eventlog.NewHashChainDecorator(ctx, eventlog.NewHashChainDecoratorInput{
+				Decorated: fileEventLog,
+			})
+			So(err, ShouldBeNil)
+			appendEvents(decorator, 3, 5)
+			report, err := eventlog.VerifyHashChain(ctx, fileEventLog)
+			So(err, ShouldBeNil)
+			So(report.Events, ShouldEqual, 5)
+			So(report.ChainedEvents, ShouldEqual, 2)
+			Convey("edited event before the chain", func() {
+				tamper(`"event-0"`, `"event-X"`)
+				_, err := eventlog.VerifyHashChain(ctx, fileEventLog)
+				assertBrokenAt(err, 4)
+			})
+			Convey("removed link", func() {
+				tamper(`,"previous_hash":"`+previousHashOf(ctx, fileEventLog, 5)+`"`, ``)
+				_, err := eventlog.VerifyHashChain(ctx, fileEventLog)
+				assertBrokenAt(err, 5)
+			})
+		})
+	})
+}
+
+func previousHashOf(ctx context.Context, eventLog eventlog.EventLog, logicalClock int64) string {
+	return eventAt(ctx, eventLog, logicalClock).PreviousHash
+}
+
+func eventAt(ctx context.Context, eventLog eventlog.EventLog, logicalClock int64) *eventlog.Event {
+	iterator := eventLog.GetEventIterator(ctx, eventlog.GetEventIteratorInput{
+		FromCursor: logicalClock - 1,
+	})
+	So(iterator.Next(ctx), ShouldBeTrue)
+	return iterator.Event()
+}
@@ -0,0 +1,945 @@
+package eventlog
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ansel1/merry"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/logx"
+)
+
+const IndexedDataFileName = "data.jsonl"
+const IndexedClockIndexFileName = "clock" + IndexFileExtension
+const IndexedCommitFileName = "commit.json"
+const IndexedLockFileName = "lock"
+const IndexesFolderName = "indexes"
+
+const (
+	typeIndexName    = "type"
+	accountIndexName = "account"
+)
+
+var ErrImportFolderNotEmpty = merry.New("import folder already contains an event log").WithHTTPCode(http.StatusConflict)
+
+// importFlushInterval bounds the index entries buffered by an import.
+const importFlushInterval = 4096
+
+// IndexedEventLog keeps every event in a single data file with a dense index
+// on logical clock and an index per type and account, so that filtered
+// iterators only read the events they return. There are too many streams to
+// index each one, so iterators over a stream read the events of its account,
+// or of the whole log, and the last logical clock of the streams appended to
+// most recently is kept in memory for appends that expect one.
+//
+// Several processes can open the same folder. Appends hold an exclusive lock
+// on the lock file, and are only visible once the commit file, which is
+// replaced atomically, has been updated. Readers take no lock: they read the
+// commit file and ignore anything written after it. Wait is woken at once by
+// appends made through this instance, and by polling the commit file for
+// appends made by other processes.
+//
+// Like FileEventLog it is not safe for concurrent use within a process.
+type IndexedEventLog struct {
+	folderPath   string
+	pollInterval time.Duration
+	lockFile     *os.File
+	mutex        sync.Mutex
+	waitC        chan struct{}
+	closed       bool
+	// cursor is the last commit read by an iterator or append, which Wait
+	// polls for commits after.
+	cursor int64
+	// streamHeads is brought up to date with the events committed since
+	// streamHeadsCursor, by any process, on each append that expects a
+	// stream head.
+	streamHeads       *streamHeads
+	streamHeadsCursor int64
+}
+
+type NewIndexedEventLogInput struct {
+	FolderPath   string
+	PollInterval time.Duration
+	// MaxStreamHeads bounds the stream heads kept in memory. The head of a
+	// stream that isn't kept is found through the index of its account.
+	MaxStreamHeads int
+}
+
+func NewIndexedEventLog(input *NewIndexedEventLogInput) (eventLog *IndexedEventLog, err error) {
+	for _, indexName := range []string{typeIndexName, accountIndexName} {
+		err = os.MkdirAll(filepath.Join(input.FolderPath, IndexesFolderName, indexName), 0755)
+		if err != nil {
+			return
+		}
+	}
+	pollInterval := input.PollInterval
+	if pollInterval == 0 {
+		pollInterval = DefaultPollInterval
+	}
+	lockFile, err := os.OpenFile(filepath.Join(input.FolderPath, IndexedLockFileName), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return
+	}
+	eventLog = &IndexedEventLog{
+		folderPath:   input.FolderPath,
+		pollInterval: pollInterval,
+		lockFile:     lockFile,
+		waitC:        make(chan struct{}),
+	}
+	commit, err := eventLog.lockAndRecover()
+	if err != nil {
+		lockFile.Close()
+		return nil, merry.Prepend(err, input.FolderPath)
+	}
+	defer eventLog.unlock()
+	eventLog.cursor = commit.Cursor
+	eventLog.streamHeads = newStreamHeads(input.MaxStreamHeads, commit.Cursor)
+	eventLog.streamHeadsCursor = commit.Cursor
+	return
+}
+
+func NewIndexedEventLogOrFatal(input *NewIndexedEventLogInput) *IndexedEventLog {
+	eventLog, err := NewIndexedEventLog(input)
+	fatal.OnError(err)
+	return eventLog
+}
+
+// indexedEventLogCommit is the last event visible to readers and the end of
+// its record in the data file.
+type indexedEventLogCommit struct {
+	Cursor int64 `json:"cursor"`
+	Size   int64 `json:"size"`
+}
+
+func readIndexedEventLogCommit(folderPath string) (commit indexedEventLogCommit, err error) {
+	data, err := os.ReadFile(filepath.Join(folderPath, IndexedCommitFileName))
+	if os.IsNotExist(err) {
+		err = nil
+		return
+	}
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(data, &commit)
+	if err != nil {
+		err = merry.Prependf(err, "unreadable %s", IndexedCommitFileName)
+		return
+	}
+	return
+}
+
+func writeIndexedEventLogCommit(folderPath string, commit indexedEventLogCommit) (err error) {
+	data, err := json.Marshal(commit)
+	if err != nil {
+		return
+	}
+	return writeFileAtomically(filepath.Join(folderPath, IndexedCommitFileName), append(data, '\n'))
+}
+
+func indexedEventLogExists(folderPath string) bool {
+	for _, fileName := range []string{IndexedCommitFileName, IndexedDataFileName} {
+		if _, err := os.Stat(filepath.Join(folderPath, fileName)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (log *IndexedEventLog) lock() (err error) {
+	if log.isClosed() {
+		return ErrClosed.Here()
+	}
+	return lockFile(log.lockFile)
+}
+
+func (log *IndexedEventLog) unlock() {
+	err := unlockFile(log.lockFile)
+	if err != nil {
+		logx.Errorln("failed to unlock", log.folderPath, err)
+	}
+}
+
+// lockAndRecover takes the lock and recovers from any append that did not
+// commit. It only holds the lock if it returns no error.
+func (log *IndexedEventLog) lockAndRecover() (commit indexedEventLogCommit, err error) {
+	err = log.lock()
+	if err != nil {
+		return
+	}
+	commit, err = readIndexedEventLogCommit(log.folderPath)
+	if err == nil {
+		err = log.recover(commit)
+	}
+	if err != nil {
+		log.unlock()
+		return
+	}
+	return
+}
+
+// Close releases the lock file. Appends and new iterators return ErrClosed
+// afterwards.
+func (log *IndexedEventLog) Close() (err error) {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+	if log.closed {
+		return
+	}
+	log.closed = true
+	return log.lockFile.Close()
+}
+
+func (log *IndexedEventLog) isClosed() bool {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+	return log.closed
+}
+
+func (log *IndexedEventLog) dataFilePath() string {
+	return filepath.Join(log.folderPath, IndexedDataFileName)
+}
+
+func (log *IndexedEventLog) clockIndexFilePath() string {
+	return filepath.Join(log.folderPath, IndexedClockIndexFileName)
+}
+
+// keyIndexFilePath names index files by a hash of their key, as types,
+// account IDs and stream keys are not all valid file names.
+func (log *IndexedEventLog) keyIndexFilePath(indexName string, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(log.folderPath, IndexesFolderName, indexName, hex.EncodeToString(sum[:])+IndexFileExtension)
+}
+
+// recover truncates what an append that never committed left behind. The
+// data file is always written and synced before the indexes, so there is
+// nothing to repair unless it is longer than the commit, and only the indexes
+// of the complete records after the commit can have entries to drop. It must
+// hold the lock.
+func (log *IndexedEventLog) recover(commit indexedEventLogCommit) (err error) {
+	info, err := os.Stat(log.dataFilePath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return
+	}
+	if info.Size() == commit.Size {
+		return
+	}
+	indexFilePaths, err := log.uncommittedIndexFilePaths(commit.Size, info.Size())
+	if err != nil {
+		return
+	}
+	logx.Warnln("truncating", info.Size()-commit.Size, "uncommitted bytes from", log.dataFilePath())
+	err = os.Truncate(log.dataFilePath(), commit.Size)
+	if err != nil {
+		return
+	}
+	for _, filePath := range indexFilePaths {
+		err = truncateIndex(filePath, commit.Cursor)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// uncommittedIndexFilePaths returns the indexes the records between size and
+// end may have entries in.
+func (log *IndexedEventLog) uncommittedIndexFilePaths(size int64, end int64) (filePaths []string, err error) {
+	file, err := os.Open(log.dataFilePath())
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	filePaths = []string{log.clockIndexFilePath()}
+	seen := make(map[string]struct{})
+	reader := bufio.NewReader(io.NewSectionReader(file, size, end-size))
+	for {
+		data, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// A torn record was never indexed.
+			return filePaths, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		var event Event
+		if json.Unmarshal(data, &event) != nil {
+			continue
+		}
+		for _, filePath := range log.keyIndexFilePaths(&event) {
+			if _, ok := seen[filePath]; !ok {
+				seen[filePath] = struct{}{}
+				filePaths = append(filePaths, filePath)
+			}
+		}
+	}
+}
+
+// keyIndexFilePaths returns the type and account indexes of an event.
+func (log *IndexedEventLog) keyIndexFilePaths(event *Event) (filePaths []string) {
+	filePaths = append(filePaths, log.keyIndexFilePath(typeIndexName, event.Type))
+	if event.AccountID != "" {
+		filePaths = append(filePaths, log.keyIndexFilePath(accountIndexName, event.AccountID))
+	}
+	return
+}
+
+// truncateIndex drops the entries after cursor, and any torn entry, from the
+// end of an index file.
+func truncateIndex(filePath string, cursor int64) (err error) {
+	index, complete, err := readIndex(filePath)
+	if err != nil {
+		return
+	}
+	n := sort.Search(len(index), func(i int) bool {
+		return index[i].LogicalClock > cursor
+	})
+	if complete && n == len(index) {
+		return
+	}
+	return os.Truncate(filePath, int64(n)*indexEntrySize)
+}
+
+func (log *IndexedEventLog) Append(ctx context.Context, input AppendInput) (event *Event, err error) {
+	events, err := log.AppendBatch(ctx, []AppendInput{input})
+	if err != nil {
+		return
+	}
+	event = events[0]
+	return
+}
+
+// AppendBatch writes every event and its index entries before a single
+// commit, and wakes Wait subscribers once. If any expected logical clock
+// conflicts nothing is written. A failed write is truncated by the next
+// append.
+func (log *IndexedEventLog) AppendBatch(ctx context.Context, inputs []AppendInput) (events []*Event, err error) {
+	if len(inputs) == 0 {
+		return
+	}
+	err = canceled(ctx)
+	if err != nil {
+		return
+	}
+	commit, err := log.lockAndRecover()
+	if err != nil {
+		return
+	}
+	defer log.unlock()
+	err = checkBatch(commit.Cursor, inputs, func(input AppendInput) (int64, error) {
+		return log.streamHead(ctx, commit.Cursor, input)
+	})
+	if err != nil {
+		return
+	}
+	events, err = newEvents(commit.Cursor, inputs)
+	if err != nil {
+		return
+	}
+	err = log.append(commit, events)
+	if err != nil {
+		events = nil
+		return
+	}
+	return
+}
+
+// Replicate appends events from another log as they are.
+func (log *IndexedEventLog) Replicate(ctx context.Context, events []*Event) (err error) {
+	if len(events) == 0 {
+		return
+	}
+	err = canceled(ctx)
+	if err != nil {
+		return
+	}
+	commit, err := log.lockAndRecover()
+	if err != nil {
+		return
+	}
+	defer log.unlock()
+	err = checkReplicated(commit.Cursor, events)
+	if err != nil {
+		return
+	}
+	return log.append(commit, events)
+}
+
+// append writes and commits a batch after commit and wakes Wait
+// subscribers. It must hold the lock.
+func (log *IndexedEventLog) append(commit indexedEventLogCommit, events []*Event) (err error) {
+	writer, err := log.newWriter(commit)
+	if err != nil {
+		return
+	}
+	err = writer.write(events)
+	if err != nil {
+		writer.file.Close()
+		return
+	}
+	err = writer.commit()
+	if err != nil {
+		return
+	}
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+	log.cursor = writer.next.Cursor
+	close(log.waitC)
+	log.waitC = make(chan struct{})
+	return
+}
+
+// Cursor is the logical clock of the last committed event, or the last one
+// read if the commit file cannot be read.
+func (log *IndexedEventLog) Cursor() int64 {
+	commit, err := readIndexedEventLogCommit(log.folderPath)
+	if err != nil {
+		logx.Warnln(err)
+		log.mutex.Lock()
+		defer log.mutex.Unlock()
+		return log.cursor
+	}
+	return commit.Cursor
+}
+
+func (log *IndexedEventLog) HashChainStart() (int64, error) {
+	return readHashChainStart(log.folderPath)
+}
+
+func (log *IndexedEventLog) HashChainRebases() ([]HashChainRebase, error) {
+	return readHashChainRebases(log.folderPath)
+}
+
+func (log *IndexedEventLog) StartHashChain(logicalClock int64) error {
+	return writeHashChainStart(log.folderPath, logicalClock)
+}
+
+// streamHead returns the logical clock of the last event in the stream of
+// input, first reading the events committed since the last call up to cursor.
+// It must hold the lock.
+func (log *IndexedEventLog) streamHead(ctx context.Context, cursor int64, input AppendInput) (head int64, err error) {
+	if log.streamHeadsCursor < cursor {
+		iterator := log.GetEventIterator(ctx, GetEventIteratorInput{
+			FromCursor: log.streamHeadsCursor,
+		})
+		for iterator.Next(ctx) {
+			event := iterator.Event()
+			log.streamHeads.add(event)
+			log.streamHeadsCursor = event.LogicalClock
+		}
+		err = iterator.Err()
+		if err != nil {
+			return
+		}
+	}
+	return log.streamHeads.get(ctx, log, input)
+}
+
+// indexedEventLogWriter appends records to the data file and buffers their
+// index entries until they are flushed. It is only used while holding the
+// lock.
+type indexedEventLogWriter struct {
+	log                 *IndexedEventLog
+	file                *os.File
+	next                indexedEventLogCommit
+	indexDataByFilePath map[string][]byte
+	clockIndexFilePath  string
+}
+
+func (log *IndexedEventLog) newWriter(commit indexedEventLogCommit) (writer *indexedEventLogWriter, err error) {
+	file, err := os.OpenFile(log.dataFilePath(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	writer = &indexedEventLogWriter{
+		log:                 log,
+		file:                file,
+		next:                commit,
+		indexDataByFilePath: make(map[string][]byte),
+		clockIndexFilePath:  log.clockIndexFilePath(),
+	}
+	return
+}
+
+// write appends the records of a batch without syncing.
+func (writer *indexedEventLogWriter) write(events []*Event) (err error) {
+	var data []byte
+	for _, event := range events {
+		record, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		entry := encodeIndexEntry(indexEntry{
+			LogicalClock: event.LogicalClock,
+			Offset:       writer.next.Size + int64(len(data)),
+		})
+		writer.addIndexEntry(writer.clockIndexFilePath, entry)
+		for _, filePath := range writer.log.keyIndexFilePaths(event) {
+			writer.addIndexEntry(filePath, entry)
+		}
+		data = append(data, record...)
+		data = append(data, '\n')
+	}
+	n, err := writer.file.Write(data)
+	if err != nil {
+		return
+	}
+	writer.next.Size += int64(n)
+	writer.next.Cursor = events[len(events)-1].LogicalClock
+	return
+}
+
+func (writer *indexedEventLogWriter) addIndexEntry(filePath string, entry []byte) {
+	writer.indexDataByFilePath[filePath] = append(writer.indexDataByFilePath[filePath], entry...)
+}
+
+// flush syncs the data file and then appends the buffered index entries, so
+// that an index never points at a record that could be lost.
+func (writer *indexedEventLogWriter) flush() (err error) {
+	err = writer.file.Sync()
+	if err != nil {
+		return
+	}
+	for filePath, indexData := range writer.indexDataByFilePath {
+		err = appendFile(filePath, indexData)
+		if err != nil {
+			return
+		}
+	}
+	clear(writer.indexDataByFilePath)
+	return
+}
+
+// appendFile appends data to a file and syncs it.
+func appendFile(filePath string, data []byte) (err error) {
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	return
+}
+
+// commit flushes and makes everything written visible to readers. It closes
+// the data file whether or not it succeeds.
+func (writer *indexedEventLogWriter) commit() (err error) {
+	err = writer.flush()
+	closeErr := writer.file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return
+	}
+	return writeIndexedEventLogCommit(writer.log.folderPath, writer.next)
+}
+
+// GetEventIterator reads the events committed when it is called. Iterators
+// without an index to use scan the data file from the event after
+// FromCursor, which they find through the clock index. The others read the
+// entries of the indexes for the account and its unscoped types, or the
+// types, in that order of preference, and only read those records.
+func (log *IndexedEventLog) GetEventIterator(ctx context.Context, input GetEventIteratorInput) EventIterator {
+	if log.isClosed() {
+		return &errorEventIterator{err: ErrClosed.Here()}
+	}
+	commit, err := readIndexedEventLogCommit(log.folderPath)
+	if err != nil {
+		return &errorEventIterator{err: err}
+	}
+	log.mutex.Lock()
+	log.cursor = commit.Cursor
+	log.mutex.Unlock()
+	if input.FromCursor < 0 || input.FromCursor >= commit.Cursor {
+		return new(NullEventIterator)
+	}
+	iterator := &IndexedEventIterator{
+		fromCursor: input.FromCursor,
+		filePath:   log.dataFilePath(),
+	}
+	indexFilePaths := log.indexFilePaths(input)
+	if indexFilePaths == nil {
+		offset, err := log.seek(input.FromCursor+1, commit.Size)
+		if err != nil {
+			return &errorEventIterator{err: err}
+		}
+		iterator.err = iterator.open(offset, commit.Size)
+		return filterEventIterator(iterator, input)
+	}
+	iterator.indexed = true
+	iterator.entries, err = readIndexEntries(indexFilePaths, input.FromCursor, commit.Cursor)
+	if err != nil {
+		return &errorEventIterator{err: err}
+	}
+	iterator.err = iterator.open(0, commit.Size)
+	return filterEventIterator(iterator, input)
+}
+
+func (log *IndexedEventLog) indexFilePaths(input GetEventIteratorInput) (filePaths []string) {
+	switch {
+	case input.AccountID != "":
+		filePaths = append(filePaths, log.keyIndexFilePath(accountIndexName, input.AccountID))
+		for _, eventType := range input.UnscopedTypes {
+			filePaths = append(filePaths, log.keyIndexFilePath(typeIndexName, eventType))
+		}
+	default:
+		for _, eventType := range input.Types {
+			filePaths = append(filePaths, log.keyIndexFilePath(typeIndexName, eventType))
+		}
+	}
+	return
+}
+
+// seek returns the offset of the first event at or after logicalClock, or
+// size if there is none. The clock index is searched in place, as it holds an
+// entry for every event.
+func (log *IndexedEventLog) seek(logicalClock int64, size int64) (offset int64, err error) {
+	file, err := os.Open(log.clockIndexFilePath())
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return
+	}
+	data := make([]byte, indexEntrySize)
+	readEntry := func(i int) indexEntry {
+		if err != nil {
+			return indexEntry{}
+		}
+		_, err = file.ReadAt(data, int64(i)*indexEntrySize)
+		return decodeIndexEntry(data)
+	}
+	n := int(info.Size() / indexEntrySize)
+	i := sort.Search(n, func(i int) bool {
+		return readEntry(i).LogicalClock >= logicalClock
+	})
+	if i == n {
+		return size, err
+	}
+	offset = readEntry(i).Offset
+	return
+}
+
+// readIndexEntries merges the entries of several indexes that fall after
+// fromCursor and up to cursor. An event can be in more than one of them.
+func readIndexEntries(filePaths []string, fromCursor int64, cursor int64) (entries []indexEntry, err error) {
+	for _, filePath := range filePaths {
+		index, _, err := readIndex(filePath)
+		if err != nil {
+			return nil, err
+		}
+		from := sort.Search(len(index), func(i int) bool {
+			return index[i].LogicalClock > fromCursor
+		})
+		to := sort.Search(len(index), func(i int) bool {
+			return index[i].LogicalClock > cursor
+		})
+		if from < to {
+			entries = append(entries, index[from:to]...)
+		}
+	}
+	if len(filePaths) == 1 {
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LogicalClock < entries[j].LogicalClock
+	})
+	deduplicated := entries[:0]
+	for _, entry := range entries {
+		if len(deduplicated) != 0 && deduplicated[len(deduplicated)-1].LogicalClock == entry.LogicalClock {
+			continue
+		}
+		deduplicated = append(deduplicated, entry)
+	}
+	return deduplicated, nil
+}
+
+// Wait returns a channel that is closed by the next append through this
+// instance, or once a poll finds a commit after the last one read.
+func (log *IndexedEventLog) Wait(ctx context.Context) <-chan struct{} {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+	waitC := log.waitC
+	go pollCursor(ctx, log.pollInterval, log.cursor, func() int64 {
+		commit, err := readIndexedEventLogCommit(log.folderPath)
+		if err != nil {
+			logx.Warnln(err)
+		}
+		return commit.Cursor
+	}, waitC, func() {
+		log.mutex.Lock()
+		defer log.mutex.Unlock()
+		if log.waitC == waitC {
+			close(log.waitC)
+			log.waitC = make(chan struct{})
+		}
+	})
+	return waitC
+}
+
+// IndexedEventIterator either scans the data file up to the commit it was
+// created at, or, if it has index entries, reads the record each one points
+// to.
+type IndexedEventIterator struct {
+	fromCursor int64
+	entries    []indexEntry
+	indexed    bool
+	filePath   string
+	file       *os.File
+	size       int64
+	// offset is where the last record read starts, and nextOffset where
+	// the one after it starts when scanning.
+	offset     int64
+	nextOffset int64
+	reader     *bufio.Reader
+	scanner    *bufio.Scanner
+
+	event *Event
+	err   error
+}
+
+func (iterator *IndexedEventIterator) open(offset int64, size int64) (err error) {
+	file, err := os.Open(iterator.filePath)
+	if err != nil {
+		return
+	}
+	iterator.file = file
+	iterator.size = size
+	iterator.nextOffset = offset
+	if iterator.indexed {
+		iterator.reader = bufio.NewReader(nil)
+		return
+	}
+	reader := io.NewSectionReader(file, offset, size-offset)
+	iterator.scanner = bufio.NewScanner(bufio.NewReaderSize(reader, 256*1024))
+	return
+}
+
+// Next carries on after a corrupt event when called again, but stops for
+// good on any other error.
+func (iterator *IndexedEventIterator) Next(ctx context.Context) bool {
+	if iterator.err != nil && !merry.Is(iterator.err, ErrCorruptEvent) {
+		return false
+	}
+	iterator.err = nil
+	if iterator.file == nil {
+		return false
+	}
+	if err := canceled(ctx); err != nil {
+		iterator.fail(err)
+		return false
+	}
+	for {
+		data, ok, err := iterator.next()
+		if err != nil {
+			iterator.fail(err)
+			return false
+		}
+		if !ok {
+			err = iterator.file.Close()
+			iterator.file = nil
+			if err != nil {
+				iterator.err = err
+			}
+			return false
+		}
+		var event Event
+		err = json.Unmarshal(data, &event)
+		if err != nil {
+			iterator.err = corruptEvent(err, "%s at offset %d", iterator.filePath, iterator.offset)
+			return false
+		}
+		if event.LogicalClock <= iterator.fromCursor {
+			continue
+		}
+		iterator.event = &event
+		return true
+	}
+}
+
+// next returns the next record and sets offset to where it starts.
+func (iterator *IndexedEventIterator) next() (data []byte, ok bool, err error) {
+	if !iterator.indexed {
+		if !iterator.scanner.Scan() {
+			return nil, false, iterator.scanner.Err()
+		}
+		data = iterator.scanner.Bytes()
+		iterator.offset = iterator.nextOffset
+		iterator.nextOffset += int64(len(data)) + 1
+		return data, true, nil
+	}
+	if len(iterator.entries) == 0 {
+		return
+	}
+	entry := iterator.entries[0]
+	iterator.entries = iterator.entries[1:]
+	iterator.offset = entry.Offset
+	iterator.reader.Reset(io.NewSectionReader(iterator.file, entry.Offset, iterator.size-entry.Offset))
+	data, err = iterator.reader.ReadBytes('\n')
+	if err == io.EOF {
+		// The index points at a record that is cut short, which is reported
+		// as corrupt rather than ending the iterator.
+		return data, true, nil
+	}
+	return data, err == nil, err
+}
+
+func (iterator *IndexedEventIterator) fail(err error) {
+	iterator.err = err
+	if iterator.file != nil {
+		iterator.file.Close()
+		iterator.file = nil
+	}
+}
+
+// Close closes the data file, ending the iterator.
+func (iterator *IndexedEventIterator) Close() (err error) {
+	if iterator.file == nil {
+		return
+	}
+	err = iterator.file.Close()
+	iterator.file = nil
+	return
+}
+
+func (iterator *IndexedEventIterator) Event() *Event {
+	return iterator.event
+}
+
+func (iterator *IndexedEventIterator) Err() error {
+	return iterator.err
+}
+
+type ImportIndexedEventLogInput struct {
+	Events     EventIterator
+	FolderPath string
+}
+
+type ImportReport struct {
+	Cursor   int64
+	Imported int
+	Skipped  int
+}
+
+// ImportIndexedEventLog writes events into a new IndexedEventLog folder,
+// keeping their IDs, logical clocks and timestamps. Events that are not after
+// the one before them are skipped, as they are when a single file log is
+// migrated to segments. Nothing is visible until every event has been
+// written. It refuses to write into a folder that already holds a log.
+func ImportIndexedEventLog(ctx context.Context, input ImportIndexedEventLogInput) (report ImportReport, err error) {
+	err = os.MkdirAll(input.FolderPath, 0755)
+	if err != nil {
+		return
+	}
+	if indexedEventLogExists(input.FolderPath) {
+		err = ErrImportFolderNotEmpty.Here()
+		return
+	}
+	eventLog, err := NewIndexedEventLog(&NewIndexedEventLogInput{
+		FolderPath: input.FolderPath,
+	})
+	if err != nil {
+		return
+	}
+	err = eventLog.lock()
+	if err != nil {
+		return
+	}
+	defer eventLog.unlock()
+	writer, err := eventLog.newWriter(indexedEventLogCommit{})
+	if err != nil {
+		return
+	}
+	for input.Events.Next(ctx) {
+		event := input.Events.Event()
+		if event.LogicalClock <= report.Cursor {
+			report.Skipped++
+			continue
+		}
+		err = writer.write([]*Event{event})
+		if err != nil {
+			break
+		}
+		report.Imported++
+		report.Cursor = event.LogicalClock
+		if report.Imported%importFlushInterval == 0 {
+			err = writer.flush()
+			if err != nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		err = input.Events.Err()
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		writer.file.Close()
+		return
+	}
+	err = writer.commit()
+	return
+}
+
+// JSONLEventIterator reads events from JSONL, such as a single file log
+// written before segmentation or the output of eventlog export.
+type JSONLEventIterator struct {
+	scanner *bufio.Scanner
+	event   *Event
+	err     error
+}
+
+func NewJSONLEventIterator(reader io.Reader) *JSONLEventIterator {
+	return &JSONLEventIterator{
+		scanner: bufio.NewScanner(bufio.NewReaderSize(reader, 256*1024)),
+	}
+}
+
+func (iterator *JSONLEventIterator) Next(ctx context.Context) bool {
+	if iterator.err != nil || ctx.Err() != nil || !iterator.scanner.Scan() {
+		return false
+	}
+	var event Event
+	err := json.Unmarshal(iterator.scanner.Bytes(), &event)
+	if err != nil {
+		iterator.err = merry.Wrap(err)
+		return false
+	}
+	iterator.event = &event
+	return true
+}
+
+func (iterator *JSONLEventIterator) Event() *Event {
+	return iterator.event
+}
+
+func (iterator *JSONLEventIterator) Err() error {
+	if iterator.err != nil {
+		return iterator.err
+	}
+	return iterator.scanner.Err()
+}
@@ -0,0 +1,310 @@
+package eventlog_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ansel1/merry"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+)
+
+type IndexedEventLogFactory struct {
+	pattern string
+}
+
+func (factory *IndexedEventLogFactory) Create(ctx context.Context) eventlog.EventLog {
+	folderPath, err := os.MkdirTemp("testdata", factory.pattern)
+	if err != nil {
+		panic(err)
+	}
+	return eventlog.NewIndexedEventLogOrFatal(&eventlog.NewIndexedEventLogInput{
+		FolderPath: folderPath,
+	})
+}
+
+func TestIndexedEventLogEventLog(t *testing.T) {
+	testEventLog(t, &IndexedEventLogFactory{
+		pattern: "TestIndexedEventLog-*",
+	})
+}
+
+func TestIndexedEventLogFollow(t *testing.T) {
+	testFollow(t, &IndexedEventLogFactory{
+		pattern: "TestIndexedEventLogFollow-*",
+	})
+}
+
+func TestIndexedEventLog(t *testing.T) {
+	ctx := context.Background()
+	Convey("TestIndexedEventLog", t, func() {
+		folderPath, err := os.MkdirTemp("testdata", "TestIndexedEventLog-*")
+		So(err, ShouldBeNil)
+		open := func() *eventlog.IndexedEventLog {
+			return eventlog.NewIndexedEventLogOrFatal(&eventlog.NewIndexedEventLogInput{
+				FolderPath: folderPath,
+			})
+		}
+		readAll := func(eventLog eventlog.EventLog, input eventlog.GetEventIteratorInput) (logicalClocks []int64) {
+			iterator := eventLog.GetEventIterator(ctx, input)
+			for iterator.Next(ctx) {
+				logicalClocks = append(logicalClocks, iterator.Event().LogicalClock)
+			}
+			So(iterator.Err(), ShouldBeNil)
+			return
+		}
+		first := open()
+		second := open()
+		for i := 0; i < 6; i++ {
+			eventLog := first
+			if i%2 == 1 {
+				eventLog = second
+			}
+			_, err := eventLog.Append(ctx, eventlog.AppendInput{
+				Type:      fmt.Sprintf("type-%d", i%3),
+				AccountID: fmt.Sprintf("account-%d", i%2),
+				Data:      fatal.UnlessMarshalJSON(i),
+			})
+			So(err, ShouldBeNil)
+		}
+		Convey("shared between instances", func() {
+			So(readAll(first, eventlog.GetEventIteratorInput{}), ShouldResemble, []int64{1, 2, 3, 4, 5, 6})
+			So(readAll(second, eventlog.GetEventIteratorInput{FromCursor: 4}), ShouldResemble, []int64{5, 6})
+			So(readAll(second, eventlog.GetEventIteratorInput{
+				Types: []string{"type-0", "type-2"},
+			}), ShouldResemble, []int64{1, 3, 4, 6})
+			So(readAll(first, eventlog.GetEventIteratorInput{
+				AccountID:     "account-1",
+				UnscopedTypes: []string{"type-0"},
+			}), ShouldResemble, []int64{1, 2, 4, 6})
+		})
+		Convey("follow appends by another instance", func() {
+			follower := eventlog.NewIndexedEventLogOrFatal(&eventlog.NewIndexedEventLogInput{
+				FolderPath:   folderPath,
+				PollInterval: 10 * time.Millisecond,
+			})
+			ctx, cancel := context.WithTimeout(ctx, time.Second)
+			defer cancel()
+			iterator := eventlog.Follow(ctx, eventlog.FollowInput{
+				EventLog:   follower,
+				FromCursor: 6,
+				Types:      []string{"type-1"},
+			})
+			nextC := make(chan bool)
+			go func() {
+				nextC <- iterator.Next(ctx)
+			}()
+			for _, eventType := range []string{"type-0", "type-1"} {
+				_, err := first.Append(ctx, eventlog.AppendInput{
+					Type: eventType,
+					Data: fatal.UnlessMarshalJSON(nil),
+				})
+				So(err, ShouldBeNil)
+			}
+			So(<-nextC, ShouldBeTrue)
+			So(iterator.Event().LogicalClock, ShouldEqual, 8)
+		})
+		Convey("stream conflict between instances", func() {
+			_, err := first.Append(ctx, eventlog.AppendInput{
+				Type:                 "test",
+				StreamKey:            "stream",
+				Data:                 fatal.UnlessMarshalJSON(nil),
+				ExpectedLogicalClock: eventlog.ExpectLogicalClock(0),
+			})
+			So(err, ShouldBeNil)
+			_, err = second.Append(ctx, eventlog.AppendInput{
+				Type:                 "test",
+				StreamKey:            "stream",
+				Data:                 fatal.UnlessMarshalJSON(nil),
+				ExpectedLogicalClock: eventlog.ExpectLogicalClock(0),
+			})
+			var conflict *eventlog.ConflictError
+			So(errors.As(err, &conflict), ShouldBeTrue)
+			So(conflict.ActualLogicalClock, ShouldEqual, 7)
+			for i, eventLog := range []*eventlog.IndexedEventLog{second, first, second} {
+				_, err = eventLog.Append(ctx, eventlog.AppendInput{
+					Type:                 "test",
+					StreamKey:            "stream",
+					Data:                 fatal.UnlessMarshalJSON(nil),
+					ExpectedLogicalClock: eventlog.ExpectLogicalClock(int64(7 + i)),
+				})
+				So(err, ShouldBeNil)
+			}
+			So(readAll(first, eventlog.GetEventIteratorInput{StreamKey: "stream"}), ShouldResemble, []int64{7, 8, 9, 10})
+		})
+		Convey("an index file per type and account, not per stream", func() {
+			for i := 0; i < 20; i++ {
+				_, err := first.Append(ctx, eventlog.AppendInput{
+					Type:      "type-0",
+					AccountID: "account-0",
+					StreamKey: fmt.Sprintf("stream-%d", i),
+					Data:      fatal.UnlessMarshalJSON(i),
+				})
+				So(err, ShouldBeNil)
+			}
+			count := 0
+			err := filepath.WalkDir(filepath.Join(folderPath, eventlog.IndexesFolderName), func(path string, entry os.DirEntry, err error) error {
+				if err == nil && !entry.IsDir() {
+					count++
+				}
+				return err
+			})
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 5)
+			So(readAll(second, eventlog.GetEventIteratorInput{StreamKey: "stream-3"}), ShouldResemble, []int64{10})
+		})
+		Convey("uncommitted append", func() {
+			dataFilePath := filepath.Join(folderPath, eventlog.IndexedDataFileName)
+			data, err := os.ReadFile(dataFilePath)
+			So(err, ShouldBeNil)
+			record := []byte(`{"id":"torn","type":"type-0","logical_clock":7,"unix_timestamp":0,"data":null}` + "\n")
+			So(os.WriteFile(dataFilePath, append(data, record...), 0644), ShouldBeNil)
+			clockIndexFilePath := filepath.Join(folderPath, eventlog.IndexedClockIndexFileName)
+			clockIndex, err := os.ReadFile(clockIndexFilePath)
+			So(err, ShouldBeNil)
+			entry := binary.BigEndian.AppendUint64(nil, 7)
+			entry = binary.BigEndian.AppendUint64(entry, uint64(len(data)))
+			So(os.WriteFile(clockIndexFilePath, append(append(clockIndex, entry...), entry[:4]...), 0644), ShouldBeNil)
+			So(readAll(first, eventlog.GetEventIteratorInput{}), ShouldResemble, []int64{1, 2, 3, 4, 5, 6})
+			event, err := open().Append(ctx, eventlog.AppendInput{
+				Type: "type-0",
+				Data: fatal.UnlessMarshalJSON("committed"),
+			})
+			So(err, ShouldBeNil)
+			So(event.LogicalClock, ShouldEqual, 7)
+			data, err = os.ReadFile(dataFilePath)
+			So(err, ShouldBeNil)
+			So(bytes.Contains(data, []byte("torn")), ShouldBeFalse)
+			So(readAll(second, eventlog.GetEventIteratorInput{FromCursor: 5}), ShouldResemble, []int64{6, 7})
+			So(readAll(second, eventlog.GetEventIteratorInput{
+				Types: []string{"type-0"},
+			}), ShouldResemble, []int64{1, 4, 7})
+		})
+	})
+}
+
+func TestIndexedEventLogErrors(t *testing.T) {
+	ctx := context.Background()
+	Convey("TestIndexedEventLogErrors", t, func() {
+		folderPath, err := os.MkdirTemp("testdata", "TestIndexedEventLogErrors-*")
+		So(err, ShouldBeNil)
+		eventLog := eventlog.NewIndexedEventLogOrFatal(&eventlog.NewIndexedEventLogInput{
+			FolderPath: folderPath,
+		})
+		for i := 0; i < 3; i++ {
+			_, err = eventLog.Append(ctx, eventlog.AppendInput{
+				Type: fmt.Sprintf("type-%d", i%2),
+				Data: fatal.UnlessMarshalJSON(i),
+			})
+			So(err, ShouldBeNil)
+		}
+		corruptLine(filepath.Join(folderPath, eventlog.IndexedDataFileName), 1)
+		readAll := func(input eventlog.GetEventIteratorInput) (logicalClocks []int64, corrupt int) {
+			iterator := eventLog.GetEventIterator(ctx, input)
+			for {
+				for iterator.Next(ctx) {
+					logicalClocks = append(logicalClocks, iterator.Event().LogicalClock)
+				}
+				if !merry.Is(iterator.Err(), eventlog.ErrCorruptEvent) {
+					So(iterator.Err(), ShouldBeNil)
+					return
+				}
+				corrupt++
+			}
+		}
+		Convey("corrupt event", func() {
+			logicalClocks, corrupt := readAll(eventlog.GetEventIteratorInput{})
+			So(logicalClocks, ShouldResemble, []int64{1, 3})
+			So(corrupt, ShouldEqual, 1)
+		})
+		Convey("corrupt event with types", func() {
+			logicalClocks, corrupt := readAll(eventlog.GetEventIteratorInput{
+				Types: []string{"type-1"},
+			})
+			So(logicalClocks, ShouldBeEmpty)
+			So(corrupt, ShouldEqual, 1)
+		})
+		Convey("closed", func() {
+			So(eventLog.Close(), ShouldBeNil)
+			_, err := eventLog.Append(ctx, eventlog.AppendInput{
+				Type: "type-0",
+				Data: fatal.UnlessMarshalJSON(nil),
+			})
+			So(merry.Is(err, eventlog.ErrClosed), ShouldBeTrue)
+		})
+		Convey("unreadable commit file", func() {
+			err := os.WriteFile(filepath.Join(folderPath, eventlog.IndexedCommitFileName), []byte("{"), 0644)
+			So(err, ShouldBeNil)
+			_, err = eventlog.NewIndexedEventLog(&eventlog.NewIndexedEventLogInput{
+				FolderPath: folderPath,
+			})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestImportIndexedEventLog(t *testing.T) {
+	ctx := context.Background()
+	Convey("TestImportIndexedEventLog", t, func() {
+		var buffer bytes.Buffer
+		encoder := json.NewEncoder(&buffer)
+		for _, logicalClock := range []int64{1, 2, 4, 3, 5} {
+			So(encoder.Encode(&eventlog.Event{
+				ID:            fmt.Sprintf("event-%d", logicalClock),
+				Type:          "test",
+				AccountID:     "account",
+				LogicalClock:  logicalClock,
+				UnixTimestamp: 1700000000 + logicalClock,
+				Data:          fatal.UnlessMarshalJSON(logicalClock),
+			}), ShouldBeNil)
+		}
+		folderPath, err := os.MkdirTemp("testdata", "TestImportIndexedEventLog-*")
+		So(err, ShouldBeNil)
+		report, err := eventlog.ImportIndexedEventLog(ctx, eventlog.ImportIndexedEventLogInput{
+			Events:     eventlog.NewJSONLEventIterator(bytes.NewReader(buffer.Bytes())),
+			FolderPath: folderPath,
+		})
+		So(err, ShouldBeNil)
+		So(report, ShouldResemble, eventlog.ImportReport{
+			Cursor:   5,
+			Imported: 4,
+			Skipped:  1,
+		})
+		eventLog := eventlog.NewIndexedEventLogOrFatal(&eventlog.NewIndexedEventLogInput{
+			FolderPath: folderPath,
+		})
+		iterator := eventLog.GetEventIterator(ctx, eventlog.GetEventIteratorInput{
+			FromCursor: 2,
+			AccountID:  "account",
+		})
+		So(iterator.Next(ctx), ShouldBeTrue)
+		So(iterator.Event().ID, ShouldEqual, "event-4")
+		So(iterator.Event().UnixTimestamp, ShouldEqual, 1700000004)
+		So(iterator.Next(ctx), ShouldBeTrue)
+		So(iterator.Event().LogicalClock, ShouldEqual, 5)
+		So(iterator.Next(ctx), ShouldBeFalse)
+		event, err := eventLog.Append(ctx, eventlog.AppendInput{
+			Type: "test",
+			Data: fatal.UnlessMarshalJSON(nil),
+		})
+		So(err, ShouldBeNil)
+		So(event.LogicalClock, ShouldEqual, 6)
+		Convey("into an existing log", func() {
+			_, err := eventlog.ImportIndexedEventLog(ctx, eventlog.ImportIndexedEventLogInput{
+				Events:     eventlog.NewJSONLEventIterator(bytes.NewReader(buffer.Bytes())),
+				FolderPath: folderPath,
+			})
+			So(err, ShouldWrap, eventlog.ErrImportFolderNotEmpty)
+		})
+	})
+}
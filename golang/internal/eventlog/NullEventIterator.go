@@ -15,3 +15,20 @@ func (iterator *NullEventIterator) Event() *Event {
 func (iterator *NullEventIterator) Err() error {
 	return nil
 }
+
+// errorEventIterator reports err without returning any events.
+type errorEventIterator struct {
+	err error
+}
+
+func (iterator *errorEventIterator) Next(ctx context.Context) bool {
+	return false
+}
+
+func (iterator *errorEventIterator) Event() *Event {
+	return nil
+}
+
+func (iterator *errorEventIterator) Err() error {
+	return iterator.err
+}
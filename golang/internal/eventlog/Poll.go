@@ -0,0 +1,27 @@
+package eventlog
+
+import (
+	"context"
+	"time"
+)
+
+// pollCursor calls cursor every interval and calls appended once it reports a
+// logical clock after fromCursor. It stops early when ctx is done or done is
+// closed, which can be nil.
+func pollCursor(ctx context.Context, interval time.Duration, fromCursor int64, cursor func() int64, done <-chan struct{}, appended func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		if cursor() > fromCursor {
+			appended()
+			return
+		}
+	}
+}
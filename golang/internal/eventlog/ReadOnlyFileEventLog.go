@@ -0,0 +1,258 @@
+package eventlog
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ansel1/merry"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/logx"
+)
+
+var ErrReadOnly = merry.New("event log is read only").WithHTTPCode(http.StatusMethodNotAllowed)
+var ErrUnmigratedEventLog = merry.New("event log has not been migrated to segments")
+
+const DefaultPollInterval = time.Second
+
+// ReadOnlyFileEventLog reads the folder of a FileEventLog that another process
+// may be appending to. It never writes to the folder: indexes are read or
+// built in memory, nothing is repaired, and only events up to the end of the
+// last complete batch are visible. Wait polls the folder for new events.
+type ReadOnlyFileEventLog struct {
+	folderPath    string
+	indexInterval int64
+	pollInterval  time.Duration
+	mutex         sync.Mutex
+	segments      []*segment
+	// size is the end of the last complete batch in the active segment.
+	size   int64
+	cursor int64
+}
+
+type NewReadOnlyFileEventLogInput struct {
+	FolderPath    string
+	IndexInterval int64
+	PollInterval  time.Duration
+}
+
+func NewReadOnlyFileEventLog(input *NewReadOnlyFileEventLogInput) (log *ReadOnlyFileEventLog, err error) {
+	_, err = os.Stat(input.FolderPath)
+	if err != nil {
+		return
+	}
+	if _, err := os.Stat(filepath.Join(input.FolderPath, EventsFileName)); err == nil {
+		return nil, merry.Prepend(ErrUnmigratedEventLog, input.FolderPath)
+	}
+	indexInterval := input.IndexInterval
+	if indexInterval == 0 {
+		indexInterval = DefaultIndexInterval
+	}
+	pollInterval := input.PollInterval
+	if pollInterval == 0 {
+		pollInterval = DefaultPollInterval
+	}
+	log = &ReadOnlyFileEventLog{
+		folderPath:    input.FolderPath,
+		indexInterval: indexInterval,
+		pollInterval:  pollInterval,
+	}
+	err = log.open()
+	if err != nil {
+		log = nil
+		return
+	}
+	return
+}
+
+// open scans only the active segment for the cursor, falling back to earlier
+// segments if it does not hold a complete batch yet, since every sealed
+// segment is complete.
+func (log *ReadOnlyFileEventLog) open() (err error) {
+	firstLogicalClocks, err := listSegmentFirstLogicalClocks(log.folderPath)
+	if err != nil {
+		return
+	}
+	segments := make([]*segment, 0, len(firstLogicalClocks))
+	for _, firstLogicalClock := range firstLogicalClocks {
+		segments = append(segments, &segment{
+			firstLogicalClock: firstLogicalClock,
+		})
+	}
+	log.segments = segments
+	for i := len(log.segments) - 1; i >= 0; i-- {
+		size, lastLogicalClock, _, err := scanSegmentTail(log.segmentFilePath(i), 0)
+		if err != nil {
+			log.segments = nil
+			return err
+		}
+		if i == len(log.segments)-1 {
+			log.size = size
+		}
+		if lastLogicalClock != 0 {
+			log.cursor = lastLogicalClock
+			break
+		}
+	}
+	for i := range log.segments {
+		err = log.loadIndex(i)
+		if err != nil {
+			log.segments = nil
+			return
+		}
+	}
+	return
+}
+
+// refresh picks up the batches and segments appended since the last refresh.
+func (log *ReadOnlyFileEventLog) refresh() (err error) {
+	if len(log.segments) == 0 {
+		return log.open()
+	}
+	active := len(log.segments) - 1
+	firstLogicalClocks, err := listSegmentFirstLogicalClocks(log.folderPath)
+	if err != nil {
+		return
+	}
+	for _, firstLogicalClock := range firstLogicalClocks {
+		if firstLogicalClock <= log.segments[active].firstLogicalClock {
+			continue
+		}
+		log.segments = append(log.segments, &segment{
+			firstLogicalClock: firstLogicalClock,
+		})
+	}
+	offset := log.size
+	for i := active; i < len(log.segments); i++ {
+		size, lastLogicalClock, _, err := scanSegmentTail(log.segmentFilePath(i), offset)
+		if err != nil {
+			return err
+		}
+		if lastLogicalClock != 0 {
+			log.cursor = lastLogicalClock
+		}
+		log.size = size
+		err = log.loadIndex(i)
+		if err != nil {
+			return err
+		}
+		offset = 0
+	}
+	return
+}
+
+func (log *ReadOnlyFileEventLog) segmentFilePath(i int) string {
+	return filepath.Join(log.folderPath, segmentFileName(log.segments[i].firstLogicalClock))
+}
+
+// loadIndex keeps the entries of the index file that point into the readable
+// part of the segment. The writer appends index entries before the records
+// they point to, so the file can be ahead of the segment but never wrong.
+func (log *ReadOnlyFileEventLog) loadIndex(i int) (err error) {
+	segment := log.segments[i]
+	size := log.size
+	if i != len(log.segments)-1 {
+		info, err := os.Stat(log.segmentFilePath(i))
+		if err != nil {
+			return err
+		}
+		size = info.Size()
+	}
+	index, _, err := readIndex(filepath.Join(log.folderPath, indexFileName(segment.firstLogicalClock)))
+	if err != nil {
+		return
+	}
+	index = index[:sort.Search(len(index), func(j int) bool {
+		return index[j].Offset >= size
+	})]
+	if len(index) == 0 && size != 0 {
+		index, err = buildIndex(log.segmentFilePath(i), log.indexInterval, size)
+		if err != nil {
+			return
+		}
+	}
+	segment.index = index
+	return
+}
+
+// Cursor is the logical clock of the last complete event seen so far.
+func (log *ReadOnlyFileEventLog) Cursor() int64 {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+	return log.cursor
+}
+
+func (log *ReadOnlyFileEventLog) HashChainStart() (int64, error) {
+	return readHashChainStart(log.folderPath)
+}
+
+func (log *ReadOnlyFileEventLog) HashChainRebases() ([]HashChainRebase, error) {
+	return readHashChainRebases(log.folderPath)
+}
+
+func (log *ReadOnlyFileEventLog) StartHashChain(logicalClock int64) error {
+	return ErrReadOnly.Here()
+}
+
+func (log *ReadOnlyFileEventLog) Append(ctx context.Context, input AppendInput) (event *Event, err error) {
+	err = ErrReadOnly.Here()
+	return
+}
+
+func (log *ReadOnlyFileEventLog) AppendBatch(ctx context.Context, inputs []AppendInput) (events []*Event, err error) {
+	err = ErrReadOnly.Here()
+	return
+}
+
+func (log *ReadOnlyFileEventLog) GetEventIterator(ctx context.Context, input GetEventIteratorInput) EventIterator {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+	err := log.refresh()
+	if err != nil {
+		return &errorEventIterator{err: err}
+	}
+	if input.FromCursor < 0 || input.FromCursor >= log.cursor || len(log.segments) == 0 {
+		return new(NullEventIterator)
+	}
+	target := input.FromCursor + 1
+	i := sort.Search(len(log.segments), func(i int) bool {
+		return log.segments[i].firstLogicalClock > target
+	}) - 1
+	if i < 0 {
+		i = 0
+	}
+	firstLogicalClocks := make([]int64, 0, len(log.segments)-i)
+	for _, segment := range log.segments[i:] {
+		firstLogicalClocks = append(firstLogicalClocks, segment.firstLogicalClock)
+	}
+	iterator := &FileEventIterator{
+		folderPath:         log.folderPath,
+		firstLogicalClocks: firstLogicalClocks,
+		fromCursor:         input.FromCursor,
+		lastSegmentSize:    log.size,
+	}
+	iterator.err = iterator.open(log.segments[i].seek(target))
+	return filterEventIterator(iterator, input)
+}
+
+// Wait returns a channel that is closed once a poll finds events after the
+// cursor at the time of the call.
+func (log *ReadOnlyFileEventLog) Wait(ctx context.Context) <-chan struct{} {
+	waitC := make(chan struct{})
+	go pollCursor(ctx, log.pollInterval, log.Cursor(), func() int64 {
+		log.mutex.Lock()
+		defer log.mutex.Unlock()
+		err := log.refresh()
+		if err != nil {
+			logx.Warnln(err)
+		}
+		return log.cursor
+	}, nil, func() {
+		close(waitC)
+	})
+	return waitC
+}
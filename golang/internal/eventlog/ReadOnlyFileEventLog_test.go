@@ -0,0 +1,127 @@
+package eventlog_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+)
+
+type folderState map[string]os.FileInfo
+
+func readFolderState(folderPath string) folderState {
+	entries, err := os.ReadDir(folderPath)
+	fatal.OnError(err)
+	state := make(folderState)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		fatal.OnError(err)
+		state[entry.Name()] = info
+	}
+	return state
+}
+
+func (state folderState) equals(other folderState) bool {
+	if len(state) != len(other) {
+		return false
+	}
+	for name, info := range state {
+		otherInfo, ok := other[name]
+		if !ok || info.Size() != otherInfo.Size() || !info.ModTime().Equal(otherInfo.ModTime()) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestReadOnlyFileEventLog(t *testing.T) {
+	ctx := context.Background()
+	Convey("TestReadOnlyFileEventLog", t, func() {
+		folderPath, err := os.MkdirTemp("testdata", "TestReadOnlyFileEventLog-*")
+		So(err, ShouldBeNil)
+		writer := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
+			FolderPath:     folderPath,
+			MaxSegmentSize: 1024,
+			IndexInterval:  256,
+		})
+		events := make([]*eventlog.Event, 0)
+		appendEvents := func(n int) {
+			for i := 0; i < n; i++ {
+				event, err := writer.Append(ctx, eventlog.AppendInput{
+					Type: "test",
+					Data: fatal.UnlessMarshalJSON(i),
+				})
+				So(err, ShouldBeNil)
+				events = append(events, event)
+			}
+		}
+		appendEvents(40)
+		before := readFolderState(folderPath)
+		reader, err := eventlog.NewReadOnlyFileEventLog(&eventlog.NewReadOnlyFileEventLogInput{
+			FolderPath:   folderPath,
+			PollInterval: 10 * time.Millisecond,
+		})
+		So(err, ShouldBeNil)
+		So(reader.Cursor(), ShouldEqual, 40)
+		assertEvents := func(fromCursor int64, expected []*eventlog.Event) {
+			iterator := reader.GetEventIterator(ctx, eventlog.GetEventIteratorInput{
+				FromCursor: fromCursor,
+			})
+			for _, event := range expected {
+				So(iterator.Next(ctx), ShouldBeTrue)
+				So(iterator.Event(), ShouldResemble, event)
+			}
+			So(iterator.Next(ctx), ShouldBeFalse)
+		}
+		Convey("iterate", func() {
+			for fromCursor := int64(0); fromCursor <= 40; fromCursor += 7 {
+				assertEvents(fromCursor, events[fromCursor:])
+			}
+			So(readFolderState(folderPath).equals(before), ShouldBeTrue)
+		})
+		Convey("append", func() {
+			_, err := reader.Append(ctx, eventlog.AppendInput{
+				Type: "test",
+			})
+			So(err, ShouldWrap, eventlog.ErrReadOnly)
+		})
+		Convey("see appends by the writer", func() {
+			appendEvents(40)
+			assertEvents(35, events[35:])
+			So(reader.Cursor(), ShouldEqual, 80)
+		})
+		Convey("skip an unfinished append", func() {
+			segments := writer.Segments()
+			active := segments[len(segments)-1]
+			file, err := os.OpenFile(active.FilePath, os.O_APPEND|os.O_WRONLY, 0644)
+			So(err, ShouldBeNil)
+			_, err = file.WriteString(`{"id":"partial","type":"test","logical_clock":41,"batch_remaining":1}` + "\n" + `{"id":"torn`)
+			So(err, ShouldBeNil)
+			So(file.Close(), ShouldBeNil)
+			assertEvents(0, events)
+			So(reader.Cursor(), ShouldEqual, 40)
+		})
+		Convey("follow", func() {
+			ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			iterator := eventlog.Follow(ctx, eventlog.FollowInput{
+				EventLog:   reader,
+				FromCursor: 40,
+			})
+			go func() {
+				time.Sleep(50 * time.Millisecond)
+				writer.Append(ctx, eventlog.AppendInput{
+					Type: "test",
+					Data: fatal.UnlessMarshalJSON("followed"),
+				})
+			}()
+			So(iterator.Next(ctx), ShouldBeTrue)
+			So(iterator.Event().LogicalClock, ShouldEqual, 41)
+		})
+	})
+}
@@ -0,0 +1,153 @@
+package eventlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// RecoveryReport describes what NewFileEventLog had to repair after an
+// unclean shutdown. The zero value means the log was consistent.
+type RecoveryReport struct {
+	// TruncatedSegments lists the segments whose trailing partial or
+	// unreadable records were removed.
+	TruncatedSegments []TruncatedSegment
+	// RemovedSegmentFilePaths lists trailing segments that were left empty,
+	// either by a crash straight after rolling or by truncation.
+	RemovedSegmentFilePaths []string
+	// RebuiltIndexFilePaths lists the indexes that were rebuilt because they
+	// were missing, torn or pointed past the end of their segment.
+	RebuiltIndexFilePaths []string
+	// MetadataCursor is the cursor that was read from the metadata file and
+	// RecoveredCursor is the logical clock of the last valid event.
+	MetadataCursor  int64
+	RecoveredCursor int64
+}
+
+type TruncatedSegment struct {
+	FilePath       string
+	Size           int64
+	TruncatedBytes int64
+}
+
+func (report RecoveryReport) Repaired() bool {
+	return len(report.TruncatedSegments) != 0 ||
+		len(report.RemovedSegmentFilePaths) != 0 ||
+		len(report.RebuiltIndexFilePaths) != 0 ||
+		report.MetadataCursor != report.RecoveredCursor
+}
+
+func logRecoveryReport(report *RecoveryReport) {
+	for _, truncated := range report.TruncatedSegments {
+		log.Println("Notice: truncated", truncated.TruncatedBytes, "bytes from the end of", truncated.FilePath)
+	}
+	for _, filePath := range report.RemovedSegmentFilePaths {
+		log.Println("Notice: removed empty segment", filePath)
+	}
+	for _, filePath := range report.RebuiltIndexFilePaths {
+		log.Println("Notice: rebuilt index", filePath)
+	}
+	if report.MetadataCursor != report.RecoveredCursor {
+		log.Println("Notice: metadata cursor", report.MetadataCursor, "recovered as", report.RecoveredCursor)
+	}
+}
+
+// scanSegmentTail finds the end of the last complete, valid record in a
+// segment that does not belong to an unfinished batch, starting from offset,
+// which must be the end of a record. A torn append can only damage the end of
+// the active segment, so unreadable records followed by valid ones are left
+// for iterators to report as corrupt. lastLogicalClock is zero if no record
+// was found.
+func scanSegmentTail(filePath string, offset int64) (validSize int64, lastLogicalClock int64, size int64, err error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	_, err = file.Seek(offset, io.SeekStart)
+	if err != nil {
+		return
+	}
+	reader := bufio.NewReaderSize(file, 256*1024)
+	validSize = offset
+	invalidOffset := int64(-1)
+	for {
+		var line []byte
+		line, err = reader.ReadBytes('\n')
+		offset += int64(len(line))
+		if err == io.EOF {
+			err = nil
+			break
+		}
+		if err != nil {
+			return
+		}
+		record := fileEventRecord{
+			Event: new(Event),
+		}
+		if json.Unmarshal(line, &record) != nil {
+			if invalidOffset < 0 {
+				invalidOffset = offset - int64(len(line))
+			}
+			continue
+		}
+		if invalidOffset >= 0 {
+			log.Println("Notice: corrupt record at offset", invalidOffset, "of", filePath)
+			invalidOffset = -1
+		}
+		if record.BatchRemaining != 0 {
+			continue
+		}
+		validSize = offset
+		lastLogicalClock = record.LogicalClock
+	}
+	size = offset
+	return
+}
+
+// recoverActiveSegment truncates the active segment back to its last valid
+// record, discarding trailing segments that end up empty, and returns the
+// logical clock of the last valid event in the log.
+func recoverActiveSegment(folderPath string, report *RecoveryReport) (lastLogicalClock int64, err error) {
+	firstLogicalClocks, err := listSegmentFirstLogicalClocks(folderPath)
+	if err != nil {
+		return
+	}
+	for i := len(firstLogicalClocks) - 1; i >= 0; i-- {
+		firstLogicalClock := firstLogicalClocks[i]
+		segmentFilePath := filepath.Join(folderPath, segmentFileName(firstLogicalClock))
+		var validSize, size int64
+		validSize, lastLogicalClock, size, err = scanSegmentTail(segmentFilePath, 0)
+		if err != nil {
+			return
+		}
+		if validSize != size {
+			err = os.Truncate(segmentFilePath, validSize)
+			if err != nil {
+				return
+			}
+			report.TruncatedSegments = append(report.TruncatedSegments, TruncatedSegment{
+				FilePath:       segmentFilePath,
+				Size:           validSize,
+				TruncatedBytes: size - validSize,
+			})
+		}
+		if validSize != 0 {
+			return
+		}
+		err = os.Remove(segmentFilePath)
+		if err != nil {
+			return
+		}
+		err = os.Remove(filepath.Join(folderPath, indexFileName(firstLogicalClock)))
+		if err != nil && !os.IsNotExist(err) {
+			return
+		}
+		err = nil
+		report.RemovedSegmentFilePaths = append(report.RemovedSegmentFilePaths, segmentFilePath)
+	}
+	return 0, nil
+}
@@ -0,0 +1,30 @@
+package eventlog
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ansel1/merry"
+)
+
+var ErrReplicaDiverged = merry.New("replicated event is not after the last event of the replica").WithHTTPCode(http.StatusConflict)
+
+// Replica is implemented by the logs that can store events appended to
+// another log, keeping their IDs, logical clocks and timestamps.
+type Replica interface {
+	EventLog
+	// Replicate appends events as they are, atomically, and wakes Wait
+	// subscribers once. It returns ErrReplicaDiverged unless their logical
+	// clocks increase from after Cursor.
+	Replicate(ctx context.Context, events []*Event) (err error)
+}
+
+func checkReplicated(cursor int64, events []*Event) (err error) {
+	for _, event := range events {
+		if event.LogicalClock <= cursor {
+			return merry.Prependf(ErrReplicaDiverged, "event %d after %d", event.LogicalClock, cursor)
+		}
+		cursor = event.LogicalClock
+	}
+	return
+}
@@ -0,0 +1,77 @@
+package eventlog_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+)
+
+func TestReplica(t *testing.T) {
+	testReplica(t, &FileEventLogFactory{
+		pattern: "TestReplicaFileEventLog-*",
+	})
+	testReplica(t, &IndexedEventLogFactory{
+		pattern: "TestReplicaIndexedEventLog-*",
+	})
+}
+
+func testReplica(t *testing.T, factory EventLogFactory) {
+	ctx := context.Background()
+	Convey("TestReplica", t, func() {
+		replica := factory.Create(ctx).(eventlog.Replica)
+		events := []*eventlog.Event{
+			{
+				ID:            "event-1",
+				Type:          "test",
+				AccountID:     "account",
+				LogicalClock:  1,
+				UnixTimestamp: 1700000001,
+				Data:          fatal.UnlessMarshalJSON(1),
+			},
+			{
+				ID:            "event-3",
+				Type:          "test",
+				StreamKey:     "stream",
+				LogicalClock:  3,
+				UnixTimestamp: 1700000003,
+				Data:          fatal.UnlessMarshalJSON(3),
+			},
+		}
+		waitC := replica.Wait(ctx)
+		So(replica.Replicate(ctx, events), ShouldBeNil)
+		select {
+		case <-waitC:
+		default:
+			t.Error("Wait was not woken by Replicate")
+		}
+		So(replica.Cursor(), ShouldEqual, 3)
+		iterator := replica.GetEventIterator(ctx, eventlog.GetEventIteratorInput{})
+		for _, event := range events {
+			So(iterator.Next(ctx), ShouldBeTrue)
+			So(iterator.Event(), ShouldResemble, event)
+		}
+		So(iterator.Next(ctx), ShouldBeFalse)
+		Convey("diverged", func() {
+			err := replica.Replicate(ctx, []*eventlog.Event{
+				{ID: "event-4", Type: "test", LogicalClock: 4, Data: fatal.UnlessMarshalJSON(4)},
+				{ID: "other-3", Type: "test", LogicalClock: 3, Data: fatal.UnlessMarshalJSON(3)},
+			})
+			So(err, ShouldWrap, eventlog.ErrReplicaDiverged)
+			So(replica.Cursor(), ShouldEqual, 3)
+		})
+		Convey("append after", func() {
+			event, err := replica.Append(ctx, eventlog.AppendInput{
+				Type:                 "test",
+				StreamKey:            "stream",
+				Data:                 fatal.UnlessMarshalJSON(4),
+				ExpectedLogicalClock: eventlog.ExpectLogicalClock(3),
+			})
+			So(err, ShouldBeNil)
+			So(event.LogicalClock, ShouldEqual, 4)
+		})
+	})
+}
@@ -0,0 +1,220 @@
+package eventlog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const SegmentFileExtension = ".jsonl"
+const IndexFileExtension = ".index"
+
+const DefaultMaxSegmentSize = 64 * 1024 * 1024
+const DefaultIndexInterval = 4 * 1024
+
+// A segment holds a contiguous range of events starting at firstLogicalClock.
+// Its index is sparse: an entry is written for the first event in the segment
+// and then whenever at least indexInterval bytes have been written since the
+// previous entry.
+type segment struct {
+	firstLogicalClock int64
+	index             []indexEntry
+}
+
+type indexEntry struct {
+	LogicalClock int64
+	Offset       int64
+}
+
+const indexEntrySize = 16
+
+func (segment *segment) seek(logicalClock int64) (offset int64) {
+	i := sort.Search(len(segment.index), func(i int) bool {
+		return segment.index[i].LogicalClock > logicalClock
+	})
+	if i == 0 {
+		return 0
+	}
+	return segment.index[i-1].Offset
+}
+
+type SegmentInfo struct {
+	FirstLogicalClock int64
+	FilePath          string
+	IndexFilePath     string
+	Sealed            bool
+}
+
+func segmentFileName(firstLogicalClock int64) string {
+	return fmt.Sprintf("%020d%s", firstLogicalClock, SegmentFileExtension)
+}
+
+func indexFileName(firstLogicalClock int64) string {
+	return fmt.Sprintf("%020d%s", firstLogicalClock, IndexFileExtension)
+}
+
+func listSegmentFirstLogicalClocks(folderPath string) (firstLogicalClocks []int64, err error) {
+	entries, err := os.ReadDir(folderPath)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, SegmentFileExtension) {
+			continue
+		}
+		firstLogicalClock, err := strconv.ParseInt(strings.TrimSuffix(name, SegmentFileExtension), 10, 64)
+		if err != nil {
+			continue
+		}
+		firstLogicalClocks = append(firstLogicalClocks, firstLogicalClock)
+	}
+	sort.Slice(firstLogicalClocks, func(i, j int) bool {
+		return firstLogicalClocks[i] < firstLogicalClocks[j]
+	})
+	return
+}
+
+func removeSegments(folderPath string) (err error) {
+	firstLogicalClocks, err := listSegmentFirstLogicalClocks(folderPath)
+	if err != nil {
+		return
+	}
+	for _, firstLogicalClock := range firstLogicalClocks {
+		err = os.Remove(filepath.Join(folderPath, segmentFileName(firstLogicalClock)))
+		if err != nil {
+			return
+		}
+		err = os.Remove(filepath.Join(folderPath, indexFileName(firstLogicalClock)))
+		if os.IsNotExist(err) {
+			err = nil
+			continue
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// loadSegment reads the index of a segment, rebuilding it from the segment
+// file if it is missing, torn or points past the end of the segment.
+func loadSegment(folderPath string, firstLogicalClock int64, indexInterval int64) (loaded *segment, size int64, rebuilt bool, err error) {
+	segmentFilePath := filepath.Join(folderPath, segmentFileName(firstLogicalClock))
+	indexFilePath := filepath.Join(folderPath, indexFileName(firstLogicalClock))
+	info, err := os.Stat(segmentFilePath)
+	if err != nil {
+		return
+	}
+	size = info.Size()
+	index, complete, err := readIndex(indexFilePath)
+	if err != nil {
+		return
+	}
+	if !complete || !indexIsConsistent(index, size) {
+		index, err = buildIndex(segmentFilePath, indexInterval, size)
+		if err != nil {
+			return
+		}
+		err = writeIndex(indexFilePath, index)
+		if err != nil {
+			return
+		}
+		rebuilt = true
+	}
+	loaded = &segment{
+		firstLogicalClock: firstLogicalClock,
+		index:             index,
+	}
+	return
+}
+
+func indexIsConsistent(index []indexEntry, size int64) bool {
+	if size == 0 {
+		return len(index) == 0
+	}
+	if len(index) == 0 {
+		return false
+	}
+	return index[len(index)-1].Offset < size
+}
+
+// readIndex reports whether the index file exists and holds only whole
+// entries. A torn entry means the index must be rebuilt.
+func readIndex(filePath string) (index []indexEntry, complete bool, err error) {
+	data, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		err = nil
+		return
+	}
+	if err != nil {
+		return
+	}
+	complete = len(data)%indexEntrySize == 0
+	n := len(data) / indexEntrySize
+	index = make([]indexEntry, n)
+	for i := 0; i < n; i++ {
+		index[i] = decodeIndexEntry(data[i*indexEntrySize:])
+	}
+	return
+}
+
+func writeIndex(filePath string, index []indexEntry) error {
+	data := make([]byte, 0, len(index)*indexEntrySize)
+	for _, entry := range index {
+		data = append(data, encodeIndexEntry(entry)...)
+	}
+	return writeFileAtomically(filePath, data)
+}
+
+// buildIndex indexes the first size bytes of a segment, which must end on a
+// record boundary. A corrupt record is left out of the index.
+func buildIndex(segmentFilePath string, indexInterval int64, size int64) (index []indexEntry, err error) {
+	file, err := os.Open(segmentFilePath)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(bufio.NewReaderSize(io.LimitReader(file, size), 256*1024))
+	offset := int64(0)
+	lastIndexedOffset := int64(0)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var event Event
+		if json.Unmarshal(line, &event) != nil {
+			offset += int64(len(line)) + 1
+			continue
+		}
+		if len(index) == 0 || offset-lastIndexedOffset >= indexInterval {
+			index = append(index, indexEntry{
+				LogicalClock: event.LogicalClock,
+				Offset:       offset,
+			})
+			lastIndexedOffset = offset
+		}
+		offset += int64(len(line)) + 1
+	}
+	err = scanner.Err()
+	return
+}
+
+func encodeIndexEntry(entry indexEntry) []byte {
+	data := make([]byte, indexEntrySize)
+	binary.BigEndian.PutUint64(data[0:8], uint64(entry.LogicalClock))
+	binary.BigEndian.PutUint64(data[8:16], uint64(entry.Offset))
+	return data
+}
+
+func decodeIndexEntry(data []byte) indexEntry {
+	return indexEntry{
+		LogicalClock: int64(binary.BigEndian.Uint64(data[0:8])),
+		Offset:       int64(binary.BigEndian.Uint64(data[8:16])),
+	}
+}
@@ -0,0 +1,159 @@
+package eventlog
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/ansel1/merry"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/logx"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/store"
+)
+
+// Snapshot is the serialised state of a projection together with the cursor
+// of the last event it reflects.
+type Snapshot struct {
+	SchemaVersion int             `json:"schema_version"`
+	Cursor        int64           `json:"cursor"`
+	UnixTimestamp int64           `json:"unix_timestamp"`
+	State         json.RawMessage `json:"state"`
+}
+
+// Snapshotter is implemented by projections that can serialise their state.
+// MarshalSnapshot must return a cursor that is consistent with the state, and
+// UnmarshalSnapshot must leave the projection untouched if it returns an error.
+type Snapshotter interface {
+	MarshalSnapshot(ctx context.Context) (cursor int64, state []byte, err error)
+	UnmarshalSnapshot(ctx context.Context, cursor int64, state []byte) (err error)
+}
+
+type SnapshotStore struct {
+	store         store.Store
+	key           string
+	schemaVersion int
+}
+
+type NewSnapshotStoreInput struct {
+	Store         store.Store
+	Key           string
+	SchemaVersion int
+}
+
+func NewSnapshotStore(input NewSnapshotStoreInput) *SnapshotStore {
+	return &SnapshotStore{
+		store:         input.Store,
+		key:           input.Key,
+		schemaVersion: input.SchemaVersion,
+	}
+}
+
+// Load returns nil if there is no snapshot or if it was written with a
+// different schema version, in which case the projection should be rebuilt
+// from the start of the log.
+func (snapshots *SnapshotStore) Load(ctx context.Context) (snapshot *Snapshot, err error) {
+	data, err := snapshots.store.Get(ctx, snapshots.key)
+	if merry.HTTPCode(err) == http.StatusNotFound {
+		err = nil
+		return
+	}
+	if err != nil {
+		return
+	}
+	snapshot = new(Snapshot)
+	err = json.Unmarshal(data, snapshot)
+	if err != nil {
+		snapshot = nil
+		return
+	}
+	if snapshot.SchemaVersion != snapshots.schemaVersion {
+		logx.Infof("ignoring %s snapshot with schema version %d, expected %d\n", snapshots.key, snapshot.SchemaVersion, snapshots.schemaVersion)
+		snapshot = nil
+		return
+	}
+	return
+}
+
+func (snapshots *SnapshotStore) Save(ctx context.Context, cursor int64, state []byte) (err error) {
+	data, err := json.Marshal(Snapshot{
+		SchemaVersion: snapshots.schemaVersion,
+		Cursor:        cursor,
+		UnixTimestamp: time.Now().Unix(),
+		State:         state,
+	})
+	if err != nil {
+		return
+	}
+	return snapshots.store.Put(ctx, snapshots.key, data)
+}
+
+type RestoreSnapshotInput struct {
+	Snapshots   *SnapshotStore
+	Snapshotter Snapshotter
+}
+
+// RestoreSnapshot loads the latest compatible snapshot into the projection and
+// returns the cursor to resume from, or 0 if there was nothing to restore.
+func RestoreSnapshot(ctx context.Context, input RestoreSnapshotInput) (cursor int64, err error) {
+	snapshot, err := input.Snapshots.Load(ctx)
+	if err != nil || snapshot == nil {
+		return
+	}
+	err = input.Snapshotter.UnmarshalSnapshot(ctx, snapshot.Cursor, snapshot.State)
+	if err != nil {
+		return
+	}
+	cursor = snapshot.Cursor
+	return
+}
+
+type SaveSnapshotInput struct {
+	Snapshots   *SnapshotStore
+	Snapshotter Snapshotter
+}
+
+func SaveSnapshot(ctx context.Context, input SaveSnapshotInput) (cursor int64, err error) {
+	cursor, state, err := input.Snapshotter.MarshalSnapshot(ctx)
+	if err != nil {
+		return
+	}
+	err = input.Snapshots.Save(ctx, cursor, state)
+	return
+}
+
+type RunSnapshotsInput struct {
+	Snapshots   *SnapshotStore
+	Snapshotter Snapshotter
+	Interval    time.Duration
+}
+
+// RunSnapshots periodically saves a snapshot of a projection that keeps its
+// own cursor and is safe to marshal from another goroutine. Projections driven
+// by Project should set ProjectInput.Snapshots instead.
+func RunSnapshots(ctx context.Context, input RunSnapshotsInput) {
+	ticker := time.NewTicker(input.Interval)
+	defer ticker.Stop()
+	lastCursor := int64(-1)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		cursor, state, err := input.Snapshotter.MarshalSnapshot(ctx)
+		if err != nil {
+			logx.Errorln(err)
+			continue
+		}
+		if cursor == lastCursor {
+			continue
+		}
+		err = input.Snapshots.Save(ctx, cursor, state)
+		if err != nil {
+			logx.Errorln(err)
+			continue
+		}
+		lastCursor = cursor
+	}
+}
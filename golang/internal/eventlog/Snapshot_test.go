@@ -0,0 +1,143 @@
+package eventlog_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/store"
+)
+
+type countingProjection struct {
+	mutex   sync.Mutex
+	cursor  int64
+	count   int
+	applied []int64
+}
+
+func (projection *countingProjection) Apply(ctx context.Context, event *eventlog.Event) error {
+	projection.mutex.Lock()
+	defer projection.mutex.Unlock()
+	projection.count++
+	projection.cursor = event.LogicalClock
+	projection.applied = append(projection.applied, event.LogicalClock)
+	return nil
+}
+
+func (projection *countingProjection) Applied() []int64 {
+	projection.mutex.Lock()
+	defer projection.mutex.Unlock()
+	return append([]int64(nil), projection.applied...)
+}
+
+func (projection *countingProjection) MarshalSnapshot(ctx context.Context) (cursor int64, state []byte, err error) {
+	projection.mutex.Lock()
+	defer projection.mutex.Unlock()
+	state, err = json.Marshal(projection.count)
+	cursor = projection.cursor
+	return
+}
+
+func (projection *countingProjection) UnmarshalSnapshot(ctx context.Context, cursor int64, state []byte) (err error) {
+	projection.mutex.Lock()
+	defer projection.mutex.Unlock()
+	err = json.Unmarshal(state, &projection.count)
+	projection.cursor = cursor
+	return
+}
+
+func TestSnapshot(t *testing.T) {
+	Convey("TestSnapshot", t, func() {
+		ctx := context.Background()
+		memoryStore := store.NewMemoryStore()
+		snapshots := eventlog.NewSnapshotStore(eventlog.NewSnapshotStoreInput{
+			Store:         memoryStore,
+			Key:           "counting",
+			SchemaVersion: 1,
+		})
+		Convey("load missing", func() {
+			snapshot, err := snapshots.Load(ctx)
+			So(err, ShouldBeNil)
+			So(snapshot, ShouldBeNil)
+		})
+		Convey("save and load", func() {
+			err := snapshots.Save(ctx, 42, fatal.UnlessMarshalJSON(7))
+			So(err, ShouldBeNil)
+			snapshot, err := snapshots.Load(ctx)
+			So(err, ShouldBeNil)
+			So(snapshot, ShouldNotBeNil)
+			So(snapshot.SchemaVersion, ShouldEqual, 1)
+			So(snapshot.Cursor, ShouldEqual, 42)
+			So(snapshot.State, ShouldResemble, json.RawMessage("7"))
+			Convey("incompatible schema version", func() {
+				snapshots := eventlog.NewSnapshotStore(eventlog.NewSnapshotStoreInput{
+					Store:         memoryStore,
+					Key:           "counting",
+					SchemaVersion: 2,
+				})
+				snapshot, err := snapshots.Load(ctx)
+				So(err, ShouldBeNil)
+				So(snapshot, ShouldBeNil)
+			})
+		})
+		Convey("Project", func() {
+			folderPath, err := os.MkdirTemp("testdata", "TestSnapshot-*")
+			So(err, ShouldBeNil)
+			eventLog := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
+				FolderPath: folderPath,
+			})
+			for i := 0; i < 10; i++ {
+				_, err = eventLog.Append(ctx, eventlog.AppendInput{
+					Type: "test",
+					Data: fatal.UnlessMarshalJSON(i),
+				})
+				So(err, ShouldBeNil)
+			}
+			project := func(projection *countingProjection) context.CancelFunc {
+				ctx, cancel := context.WithCancel(ctx)
+				done := make(chan struct{})
+				go func() {
+					defer close(done)
+					eventlog.Project(ctx, eventlog.ProjectInput{
+						EventLog:    eventLog,
+						Apply:       projection.Apply,
+						Snapshots:   snapshots,
+						Snapshotter: projection,
+					})
+				}()
+				return func() {
+					cancel()
+					<-done
+				}
+			}
+			first := new(countingProjection)
+			stop := project(first)
+			time.Sleep(50 * time.Millisecond)
+			stop()
+			So(first.Applied(), ShouldHaveLength, 10)
+			snapshot, err := snapshots.Load(ctx)
+			So(err, ShouldBeNil)
+			So(snapshot.Cursor, ShouldEqual, 10)
+			Convey("resume from snapshot", func() {
+				_, err = eventLog.Append(ctx, eventlog.AppendInput{
+					Type: "test",
+					Data: fatal.UnlessMarshalJSON(10),
+				})
+				So(err, ShouldBeNil)
+				second := new(countingProjection)
+				stop := project(second)
+				time.Sleep(50 * time.Millisecond)
+				stop()
+				So(second.Applied(), ShouldResemble, []int64{11})
+				So(second.count, ShouldEqual, 11)
+			})
+		})
+	})
+}
@@ -0,0 +1,87 @@
+package eventlog
+
+import (
+	"context"
+	"slices"
+)
+
+// DefaultMaxStreamHeads bounds how many stream heads a log keeps in memory
+// for appends that expect one.
+const DefaultMaxStreamHeads = 16 * 1024
+
+// streamHeads keeps the last logical clock of the streams appended to most
+// recently. Every stream with an event after from is held, so a stream that
+// is not held has no event after from, and is looked up in the log unless
+// from is zero.
+type streamHeads struct {
+	max   int
+	from  int64
+	heads map[string]int64
+}
+
+func newStreamHeads(max int, from int64) *streamHeads {
+	if max == 0 {
+		max = DefaultMaxStreamHeads
+	}
+	return &streamHeads{
+		max:   max,
+		from:  from,
+		heads: make(map[string]int64),
+	}
+}
+
+// get returns the head of the stream of input, looking it up among the
+// events of its account, which the events of a stream all belong to, if it
+// is not held.
+func (heads *streamHeads) get(ctx context.Context, eventLog EventLog, input AppendInput) (head int64, err error) {
+	head, ok := heads.heads[input.StreamKey]
+	if ok || heads.from == 0 {
+		return
+	}
+	iterator := eventLog.GetEventIterator(ctx, GetEventIteratorInput{
+		FromCursor: 0,
+		AccountID:  input.AccountID,
+		StreamKey:  input.StreamKey,
+	})
+	for iterator.Next(ctx) {
+		head = iterator.Event().LogicalClock
+	}
+	err = iterator.Err()
+	if err != nil {
+		return
+	}
+	heads.set(input.StreamKey, head)
+	return
+}
+
+func (heads *streamHeads) add(event *Event) {
+	if event.StreamKey != "" {
+		heads.set(event.StreamKey, event.LogicalClock)
+	}
+}
+
+func (heads *streamHeads) set(streamKey string, head int64) {
+	heads.heads[streamKey] = head
+	if len(heads.heads) > heads.max {
+		heads.evict()
+	}
+}
+
+// evict drops the older half of the heads and moves from up to the newest
+// head dropped.
+func (heads *streamHeads) evict() {
+	values := make([]int64, 0, len(heads.heads))
+	for _, head := range heads.heads {
+		values = append(values, head)
+	}
+	slices.Sort(values)
+	from := values[len(values)/2]
+	for streamKey, head := range heads.heads {
+		if head <= from {
+			delete(heads.heads, streamKey)
+		}
+	}
+	if from > heads.from {
+		heads.from = from
+	}
+}
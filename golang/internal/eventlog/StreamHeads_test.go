@@ -0,0 +1,73 @@
+package eventlog_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+)
+
+func TestStreamHeads(t *testing.T) {
+	ctx := context.Background()
+	const maxStreamHeads = 4
+	opens := map[string]func(folderPath string) eventlog.EventLog{
+		"FileEventLog": func(folderPath string) eventlog.EventLog {
+			return eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
+				FolderPath:     folderPath,
+				MaxStreamHeads: maxStreamHeads,
+			})
+		},
+		"IndexedEventLog": func(folderPath string) eventlog.EventLog {
+			return eventlog.NewIndexedEventLogOrFatal(&eventlog.NewIndexedEventLogInput{
+				FolderPath:     folderPath,
+				MaxStreamHeads: maxStreamHeads,
+			})
+		},
+	}
+	for name, open := range opens {
+		Convey(name, t, func() {
+			folderPath, err := os.MkdirTemp("testdata", "TestStreamHeads-*")
+			So(err, ShouldBeNil)
+			eventLog := open(folderPath)
+			heads := make(map[int]int64)
+			appendToStream := func(eventLog eventlog.EventLog, stream int, expected int64) error {
+				event, err := eventLog.Append(ctx, eventlog.AppendInput{
+					Type:                 "test",
+					AccountID:            fmt.Sprintf("account-%d", stream%3),
+					StreamKey:            fmt.Sprintf("stream-%d", stream),
+					Data:                 fatal.UnlessMarshalJSON(stream),
+					ExpectedLogicalClock: eventlog.ExpectLogicalClock(expected),
+				})
+				if err == nil {
+					heads[stream] = event.LogicalClock
+				}
+				return err
+			}
+			assertHeads := func(eventLog eventlog.EventLog) {
+				for stream := 0; stream < 20; stream++ {
+					var conflict *eventlog.ConflictError
+					err := appendToStream(eventLog, stream, heads[stream]+1)
+					So(errors.As(err, &conflict), ShouldBeTrue)
+					So(conflict.ActualLogicalClock, ShouldEqual, heads[stream])
+					So(appendToStream(eventLog, stream, heads[stream]), ShouldBeNil)
+				}
+				So(appendToStream(eventLog, 20, 0), ShouldBeNil)
+			}
+			for stream := 0; stream < 20; stream++ {
+				So(appendToStream(eventLog, stream, 0), ShouldBeNil)
+			}
+			Convey("more streams than are kept", func() {
+				assertHeads(eventLog)
+			})
+			Convey("reopened", func() {
+				assertHeads(open(folderPath))
+			})
+		})
+	}
+}
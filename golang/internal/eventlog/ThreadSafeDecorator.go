@@ -26,6 +26,12 @@ func (decorator *ThreadSafeDecorator) Append(ctx context.Context, input AppendIn
 	return decorator.decorated.Append(ctx, input)
 }
 
+func (decorator *ThreadSafeDecorator) AppendBatch(ctx context.Context, inputs []AppendInput) (events []*Event, err error) {
+	decorator.mutex.Lock()
+	defer decorator.mutex.Unlock()
+	return decorator.decorated.AppendBatch(ctx, inputs)
+}
+
 func (decorator *ThreadSafeDecorator) GetEventIterator(ctx context.Context, input GetEventIteratorInput) EventIterator {
 	decorator.mutex.Lock()
 	defer decorator.mutex.Unlock()
@@ -37,3 +43,9 @@ func (decorator *ThreadSafeDecorator) Wait(ctx context.Context) <-chan struct{}
 	defer decorator.mutex.Unlock()
 	return decorator.decorated.Wait(ctx)
 }
+
+func (decorator *ThreadSafeDecorator) Cursor() int64 {
+	decorator.mutex.Lock()
+	defer decorator.mutex.Unlock()
+	return decorator.decorated.Cursor()
+}
@@ -16,7 +16,7 @@ func (factory *ThreadSafeDecoratorFactory) Create(ctx context.Context) eventlog.
 		panic(err)
 	}
 	eventLog := eventlog.NewThreadSafeDecorator(&eventlog.NewThreadSafeDecoratorInput{
-		Decorated: eventlog.NewFileEventLog(&eventlog.NewFileEventLogInput{
+		Decorated: eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
 			FolderPath: folderPath,
 		}),
 	})
@@ -0,0 +1,206 @@
+package eventschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/ansel1/merry"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+)
+
+var ErrUnknownEventType = merry.New("unknown event type").WithHTTPCode(http.StatusBadRequest)
+var ErrPayloadType = merry.New("event type is registered with a different payload type")
+var ErrMissingUpcaster = merry.New("no upcaster registered")
+
+// Upcaster migrates a payload from one version of its schema to the next.
+type Upcaster func(data json.RawMessage) (json.RawMessage, error)
+
+// VersionDetector recognises the version of a stored payload from its shape.
+// Payloads do not record their version, so a type with more than one version
+// registers one to tell them apart.
+type VersionDetector func(data json.RawMessage) int
+
+type schema struct {
+	payloadType       reflect.Type
+	version           int
+	detectVersion     VersionDetector
+	upcasterByVersion map[int]Upcaster
+}
+
+// Registry maps event types to the Go type and current version of their
+// payload. Packages register the events they own from init, so decoding an
+// event only requires importing the package that defines its payload.
+type Registry struct {
+	mutex        sync.RWMutex
+	schemaByType map[string]*schema
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		schemaByType: make(map[string]*schema),
+	}
+}
+
+// Default is the registry that packages register their events with.
+var Default = NewRegistry()
+
+// Register records that events of eventType carry a T at version. It panics
+// if eventType is already registered, as http.Handle does for patterns.
+func Register[T any](registry *Registry, eventType string, version int) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	if _, ok := registry.schemaByType[eventType]; ok {
+		panic("eventschema: " + eventType + " is already registered")
+	}
+	registry.schemaByType[eventType] = &schema{
+		payloadType:       reflect.TypeFor[T](),
+		version:           version,
+		upcasterByVersion: make(map[int]Upcaster),
+	}
+}
+
+// RegisterUpcaster registers the migration of eventType payloads from
+// fromVersion to fromVersion+1.
+func (registry *Registry) RegisterUpcaster(eventType string, fromVersion int, upcaster Upcaster) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.mustGetSchema(eventType).upcasterByVersion[fromVersion] = upcaster
+}
+
+func (registry *Registry) RegisterVersionDetector(eventType string, detectVersion VersionDetector) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.mustGetSchema(eventType).detectVersion = detectVersion
+}
+
+func (registry *Registry) mustGetSchema(eventType string) *schema {
+	schema, ok := registry.schemaByType[eventType]
+	if !ok {
+		panic("eventschema: " + eventType + " is not registered")
+	}
+	return schema
+}
+
+func (registry *Registry) getSchema(eventType string) (schema *schema, err error) {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+	schema, ok := registry.schemaByType[eventType]
+	if !ok {
+		err = merry.Prepend(ErrUnknownEventType, eventType)
+		return
+	}
+	return
+}
+
+// Types returns the registered event types in order.
+func (registry *Registry) Types() (eventTypes []string) {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+	for eventType := range registry.schemaByType {
+		eventTypes = append(eventTypes, eventType)
+	}
+	sort.Strings(eventTypes)
+	return
+}
+
+// Version returns the current version of eventType payloads.
+func (registry *Registry) Version(eventType string) (version int, err error) {
+	schema, err := registry.getSchema(eventType)
+	if err != nil {
+		return
+	}
+	version = schema.version
+	return
+}
+
+// Upcast returns the payload of event migrated to the current version of its
+// schema. The event itself is not modified.
+func (registry *Registry) Upcast(event *eventlog.Event) (data json.RawMessage, err error) {
+	schema, err := registry.getSchema(event.Type)
+	if err != nil {
+		return
+	}
+	return schema.upcast(event.Type, event.Data)
+}
+
+func (schema *schema) upcast(eventType string, data json.RawMessage) (json.RawMessage, error) {
+	if schema.detectVersion == nil {
+		return data, nil
+	}
+	for version := schema.detectVersion(data); version < schema.version; version++ {
+		upcaster, ok := schema.upcasterByVersion[version]
+		if !ok {
+			return nil, merry.Prepend(ErrMissingUpcaster, fmt.Sprintf("%s from version %d", eventType, version))
+		}
+		upcasted, err := upcaster(data)
+		if err != nil {
+			return nil, merry.Prepend(err, fmt.Sprintf("failed to upcast %s from version %d", eventType, version))
+		}
+		data = upcasted
+	}
+	return data, nil
+}
+
+// Decode upcasts the payload of event and decodes it into a new value of its
+// registered type, returning a pointer to it.
+func (registry *Registry) Decode(event *eventlog.Event) (payload any, err error) {
+	schema, err := registry.getSchema(event.Type)
+	if err != nil {
+		return
+	}
+	data, err := schema.upcast(event.Type, event.Data)
+	if err != nil {
+		return
+	}
+	value := reflect.New(schema.payloadType)
+	err = json.Unmarshal(data, value.Interface())
+	if err != nil {
+		return
+	}
+	payload = value.Interface()
+	return
+}
+
+// Decode upcasts the payload of event and decodes it into a T, which must be
+// the type its event type is registered with.
+func Decode[T any](registry *Registry, event *eventlog.Event) (payload T, err error) {
+	schema, err := registry.getSchema(event.Type)
+	if err != nil {
+		return
+	}
+	if schema.payloadType != reflect.TypeFor[T]() {
+		err = merry.Prepend(ErrPayloadType, fmt.Sprintf("%s is %s not %s", event.Type, schema.payloadType, reflect.TypeFor[T]()))
+		return
+	}
+	data, err := schema.upcast(event.Type, event.Data)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(data, &payload)
+	return
+}
+
+// DecodeEvent decodes event with the Default registry. A payload that cannot
+// be decoded is reported as eventlog.ErrCorruptEvent, so that a projection
+// returning it is handled by the OnError policy of eventlog.Project.
+func DecodeEvent[T any](event *eventlog.Event) (payload T, err error) {
+	payload, err = Decode[T](Default, event)
+	if err != nil {
+		err = merry.Prependf(eventlog.ErrCorruptEvent, "decode event %d: %v", event.LogicalClock, err).WithCause(err)
+	}
+	return
+}
+
+// DecodeOrFatal decodes event with the Default registry, for tools that
+// cannot carry on without it.
+func DecodeOrFatal[T any](event *eventlog.Event) T {
+	payload, err := Decode[T](Default, event)
+	fatal.OnError(err)
+	return payload
+}
@@ -0,0 +1,138 @@
+package eventschema_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/connections"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventschema"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+)
+
+type greeting struct {
+	Text string `json:"text"`
+}
+
+func TestRegistry(t *testing.T) {
+	Convey("TestRegistry", t, func() {
+		registry := eventschema.NewRegistry()
+		eventschema.Register[greeting](registry, "greeting", 3)
+		registry.RegisterVersionDetector("greeting", func(data json.RawMessage) int {
+			var probe struct {
+				Version int `json:"version"`
+			}
+			fatal.UnlessUnmarshalJSON(data, &probe)
+			if probe.Version == 0 {
+				return 3
+			}
+			return probe.Version
+		})
+		registry.RegisterUpcaster("greeting", 1, func(data json.RawMessage) (json.RawMessage, error) {
+			return json.RawMessage(`{"version":2,"text":"hello"}`), nil
+		})
+		registry.RegisterUpcaster("greeting", 2, func(data json.RawMessage) (json.RawMessage, error) {
+			return json.RawMessage(`{"text":"hello, world"}`), nil
+		})
+		Convey("current version", func() {
+			payload, err := eventschema.Decode[greeting](registry, &eventlog.Event{
+				Type: "greeting",
+				Data: json.RawMessage(`{"text":"hi"}`),
+			})
+			So(err, ShouldBeNil)
+			So(payload, ShouldResemble, greeting{Text: "hi"})
+		})
+		Convey("upcast through every version", func() {
+			event := &eventlog.Event{
+				Type: "greeting",
+				Data: json.RawMessage(`{"version":1}`),
+			}
+			payload, err := eventschema.Decode[greeting](registry, event)
+			So(err, ShouldBeNil)
+			So(payload, ShouldResemble, greeting{Text: "hello, world"})
+			So(string(event.Data), ShouldEqual, `{"version":1}`)
+			untyped, err := registry.Decode(event)
+			So(err, ShouldBeNil)
+			So(untyped, ShouldResemble, &greeting{Text: "hello, world"})
+		})
+		Convey("missing upcaster", func() {
+			_, err := eventschema.Decode[greeting](registry, &eventlog.Event{
+				Type: "greeting",
+				Data: json.RawMessage(`{"version":-1}`),
+			})
+			So(err, ShouldWrap, eventschema.ErrMissingUpcaster)
+		})
+		Convey("unknown type", func() {
+			_, err := eventschema.Decode[greeting](registry, &eventlog.Event{
+				Type: "farewell",
+				Data: json.RawMessage(`{}`),
+			})
+			So(err, ShouldWrap, eventschema.ErrUnknownEventType)
+		})
+		Convey("wrong payload type", func() {
+			_, err := eventschema.Decode[connections.EventConnected](registry, &eventlog.Event{
+				Type: "greeting",
+				Data: json.RawMessage(`{}`),
+			})
+			So(err, ShouldWrap, eventschema.ErrPayloadType)
+		})
+		Convey("register twice", func() {
+			So(func() {
+				eventschema.Register[greeting](registry, "greeting", 1)
+			}, ShouldPanic)
+		})
+	})
+}
+
+func TestDefaultRegistry(t *testing.T) {
+	Convey("TestDefaultRegistry", t, func() {
+		So(eventschema.Default.Types(), ShouldContain, connections.EventTypeDisconnected)
+		version, err := eventschema.Default.Version(connections.EventTypeDisconnected)
+		So(err, ShouldBeNil)
+		So(version, ShouldEqual, 2)
+		decodeDisconnected := func(data any) connections.EventDisconnected {
+			return eventschema.DecodeOrFatal[connections.EventDisconnected](&eventlog.Event{
+				Type: connections.EventTypeDisconnected,
+				Data: fatal.UnlessMarshalJSON(data),
+			})
+		}
+		Convey("web socket close codes", func() {
+			clean := decodeDisconnected(connections.EventDisconnectedV1{
+				ClientID:           "client",
+				ConnectionID:       "connection",
+				RequestID:          "request",
+				WebSocketCloseCode: 1000,
+			})
+			So(clean, ShouldResemble, connections.EventDisconnected{
+				ClientID:     "client",
+				ConnectionID: "connection",
+				RequestID:    "request",
+				Reason:       connections.DisconnectReasonClean,
+			})
+			unexpected := decodeDisconnected(connections.EventDisconnectedV1{
+				WebSocketCloseCode: 1006,
+			})
+			So(unexpected.Reason, ShouldEqual, connections.DisconnectReasonUnexpected)
+		})
+		Convey("reasons", func() {
+			disconnected := connections.EventDisconnected{
+				ClientID:     "client",
+				ConnectionID: "connection",
+				RequestID:    "request",
+				Reason:       connections.DisconnectReasonClean,
+			}
+			So(decodeDisconnected(disconnected), ShouldResemble, disconnected)
+			disconnected.Reason = ""
+			So(decodeDisconnected(disconnected), ShouldResemble, disconnected)
+		})
+		Convey("corrupt payload", func() {
+			_, err := eventschema.DecodeEvent[connections.EventDisconnected](&eventlog.Event{
+				Type: connections.EventTypeDisconnected,
+				Data: json.RawMessage(`"not an object"`),
+			})
+			So(err, ShouldWrap, eventlog.ErrCorruptEvent)
+		})
+	})
+}
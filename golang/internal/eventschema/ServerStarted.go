@@ -0,0 +1,10 @@
+package eventschema
+
+// server.started has no owning package, so it is registered here.
+const EventTypeServerStarted = "server.started"
+
+type ServerStarted struct{}
+
+func init() {
+	Register[ServerStarted](Default, EventTypeServerStarted, 1)
+}
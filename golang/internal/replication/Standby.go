@@ -0,0 +1,371 @@
+package replication
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ansel1/merry"
+	"github.com/dgrijalva/jwt-go"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/logx"
+)
+
+// Service is the subject service of the access tokens a standby presents to
+// the primary.
+const Service = "replication"
+
+const EventsPath = "/replication/events"
+
+// HeadEventName is the server-sent event name of the primary's head, sent
+// when a standby connects and on every keep-alive. Its data is a Head.
+const HeadEventName = "head"
+
+const DefaultRetryInterval = 5 * time.Second
+
+const accessTokenDuration = 10 * time.Minute
+
+// maxBatchSize bounds how many received events are replicated together
+// while the standby is catching up.
+const maxBatchSize = 1024
+
+// maxMessageSize bounds a single server-sent event, which carries one event.
+const maxMessageSize = 16 * 1024 * 1024
+
+type Head struct {
+	LogicalClock int64 `json:"logical_clock"`
+}
+
+// Status is what a standby reports about how far behind the primary it is.
+type Status struct {
+	Cursor        int64 `json:"cursor"`
+	PrimaryCursor int64 `json:"primary_cursor"`
+	LagEvents     int64 `json:"lag_events"`
+	// LagSeconds is how long the standby has been behind the primary's last
+	// known head, zero when it is caught up.
+	LagSeconds float64 `json:"lag_seconds"`
+	Connected  bool    `json:"connected"`
+	LastError  string  `json:"last_error,omitempty"`
+}
+
+// Standby follows the event log of a primary backend and replicates it into
+// a local log with the same IDs and logical clocks. Events of a batch
+// appended on the primary may be replicated in separate batches, so a crash
+// of the standby can leave it part way through one; it resumes from its
+// cursor on the next connection.
+type Standby struct {
+	replica       eventlog.Replica
+	primaryURL    string
+	key           []byte
+	signingMethod jwt.SigningMethod
+	id            string
+	client        *http.Client
+	retryInterval time.Duration
+
+	// cursor is the replica's cursor as of the last replicated batch, kept
+	// so that Status does not read the replica while Run appends to it.
+	mutex         sync.Mutex
+	cursor        int64
+	primaryCursor int64
+	behindSince   time.Time
+	connected     bool
+	lastError     error
+}
+
+type NewStandbyInput struct {
+	Replica eventlog.Replica
+	// PrimaryURL is the base URL of the primary backend.
+	PrimaryURL string
+	// Key signs the access tokens presented to the primary, which has to
+	// share it.
+	Key           []byte
+	SigningMethod jwt.SigningMethod
+	// ID identifies the standby in its access tokens.
+	ID            string
+	Client        *http.Client
+	RetryInterval time.Duration
+}
+
+func NewStandby(input NewStandbyInput) *Standby {
+	if input.SigningMethod == nil {
+		input.SigningMethod = jwt.SigningMethodHS256
+	}
+	if input.ID == "" {
+		input.ID = "standby"
+	}
+	if input.Client == nil {
+		input.Client = http.DefaultClient
+	}
+	if input.RetryInterval == 0 {
+		input.RetryInterval = DefaultRetryInterval
+	}
+	return &Standby{
+		replica:       input.Replica,
+		cursor:        input.Replica.Cursor(),
+		primaryURL:    strings.TrimSuffix(input.PrimaryURL, "/"),
+		key:           input.Key,
+		signingMethod: input.SigningMethod,
+		id:            input.ID,
+		client:        input.Client,
+		retryInterval: input.RetryInterval,
+	}
+}
+
+// Run replicates until ctx is done, reconnecting to the primary after
+// RetryInterval whenever the stream ends.
+func (standby *Standby) Run(ctx context.Context) {
+	for {
+		err := standby.replicate(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		standby.setLastError(err)
+		logx.Warnln("replication stream ended:", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(standby.retryInterval):
+		}
+	}
+}
+
+type message struct {
+	event *eventlog.Event
+	head  *Head
+}
+
+func (standby *Standby) replicate(ctx context.Context) (err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	response, err := standby.connect(ctx)
+	if err != nil {
+		return
+	}
+	defer response.Body.Close()
+	standby.setConnected(true)
+	defer standby.setConnected(false)
+	messageC := make(chan message, maxBatchSize)
+	var readErr error
+	go func() {
+		defer close(messageC)
+		readErr = readMessages(ctx, response.Body, messageC)
+	}()
+	defer func() {
+		cancel()
+		for range messageC {
+		}
+		if err == nil {
+			err = readErr
+		}
+	}()
+	for message := range messageC {
+		if message.head != nil {
+			standby.observeHead(message.head.LogicalClock)
+			continue
+		}
+		batch := []*eventlog.Event{message.event}
+		var head *Head
+	drain:
+		for len(batch) < maxBatchSize {
+			select {
+			case next, ok := <-messageC:
+				if !ok {
+					break drain
+				}
+				if next.head != nil {
+					head = next.head
+					break drain
+				}
+				batch = append(batch, next.event)
+			default:
+				break drain
+			}
+		}
+		err = standby.replica.Replicate(ctx, batch)
+		if err != nil {
+			return
+		}
+		standby.observeReplicated(batch[len(batch)-1].LogicalClock)
+		if head != nil {
+			standby.observeHead(head.LogicalClock)
+		}
+	}
+	return
+}
+
+func (standby *Standby) connect(ctx context.Context) (response *http.Response, err error) {
+	query := url.Values{
+		"from_cursor": {strconv.FormatInt(standby.replica.Cursor(), 10)},
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, standby.primaryURL+EventsPath+"?"+query.Encode(), nil)
+	if err != nil {
+		return
+	}
+	request.Header.Set("Authorization", "Bearer "+standby.createAccessToken())
+	request.Header.Set("Accept", "text/event-stream")
+	response, err = standby.client.Do(request)
+	if err != nil {
+		return
+	}
+	if response.StatusCode != http.StatusOK {
+		defer response.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		err = merry.Errorf("primary responded with %d: %s", response.StatusCode, body)
+		response = nil
+		return
+	}
+	return
+}
+
+func (standby *Standby) createAccessToken() (accessToken string) {
+	claims := internal.Claims{
+		Issuer:   "beddybytes",
+		Audience: "beddybytes",
+		Subject: internal.URN{
+			Service:      Service,
+			ResourceType: "standby",
+			ResourceID:   standby.id,
+		},
+		Expiry: time.Now().Add(accessTokenDuration).Unix(),
+	}
+	accessToken, err := jwt.NewWithClaims(standby.signingMethod, &claims).SignedString(standby.key)
+	fatal.OnError(err)
+	return
+}
+
+// readMessages parses the server-sent events of the primary into messageC
+// until the stream ends.
+func readMessages(ctx context.Context, reader io.Reader, messageC chan<- message) (err error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(nil, maxMessageSize)
+	var name string
+	var data []byte
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if data == nil {
+				break
+			}
+			var next message
+			next, err = parseMessage(name, data)
+			if err != nil {
+				return
+			}
+			select {
+			case messageC <- next:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			name, data = "", nil
+		case strings.HasPrefix(line, ":"):
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = append(data, strings.TrimPrefix(line, "data: ")...)
+		}
+	}
+	err = scanner.Err()
+	if err != nil {
+		return
+	}
+	return merry.New("primary closed the replication stream")
+}
+
+func parseMessage(name string, data []byte) (parsed message, err error) {
+	switch name {
+	case HeadEventName:
+		parsed.head = new(Head)
+		err = json.Unmarshal(data, parsed.head)
+	case "":
+		parsed.event = new(eventlog.Event)
+		err = json.Unmarshal(data, parsed.event)
+	default:
+		err = merry.Errorf("unknown replication message: %s", name)
+	}
+	return
+}
+
+func (standby *Standby) setConnected(connected bool) {
+	standby.mutex.Lock()
+	defer standby.mutex.Unlock()
+	standby.connected = connected
+	if connected {
+		standby.lastError = nil
+	}
+}
+
+func (standby *Standby) setLastError(err error) {
+	standby.mutex.Lock()
+	defer standby.mutex.Unlock()
+	standby.lastError = err
+}
+
+func (standby *Standby) observeHead(primaryCursor int64) {
+	standby.mutex.Lock()
+	defer standby.mutex.Unlock()
+	if primaryCursor > standby.primaryCursor {
+		standby.primaryCursor = primaryCursor
+	}
+	standby.updateBehindSince(standby.cursor)
+}
+
+func (standby *Standby) observeReplicated(cursor int64) {
+	standby.mutex.Lock()
+	defer standby.mutex.Unlock()
+	standby.cursor = cursor
+	if cursor > standby.primaryCursor {
+		standby.primaryCursor = cursor
+	}
+	standby.updateBehindSince(cursor)
+}
+
+func (standby *Standby) updateBehindSince(cursor int64) {
+	if cursor >= standby.primaryCursor {
+		standby.behindSince = time.Time{}
+		return
+	}
+	if standby.behindSince.IsZero() {
+		standby.behindSince = time.Now()
+	}
+}
+
+func (standby *Standby) Status() (status Status) {
+	standby.mutex.Lock()
+	defer standby.mutex.Unlock()
+	cursor := standby.cursor
+	status = Status{
+		Cursor:        cursor,
+		PrimaryCursor: standby.primaryCursor,
+		Connected:     standby.connected,
+	}
+	if standby.primaryCursor > cursor {
+		status.LagEvents = standby.primaryCursor - cursor
+	}
+	if !standby.behindSince.IsZero() && status.LagEvents > 0 {
+		status.LagSeconds = time.Since(standby.behindSince).Seconds()
+	}
+	if standby.lastError != nil {
+		status.LastError = standby.lastError.Error()
+	}
+	return
+}
+
+func (standby *Standby) GetStatus(responseWriter http.ResponseWriter, request *http.Request) {
+	responseWriter.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(responseWriter).Encode(standby.Status())
+	if err != nil {
+		logx.Warnln(err)
+		return
+	}
+}
@@ -0,0 +1,155 @@
+package replication_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/replication"
+)
+
+// primary stands in for the replication endpoint of a primary backend. Each
+// stream writes the events serve returns for its cursor and then ends.
+type primary struct {
+	server      *httptest.Server
+	mutex       sync.Mutex
+	fromCursors []int64
+}
+
+func newPrimary(key []byte, serve func(fromCursor int64) []*eventlog.Event) *primary {
+	primary := new(primary)
+	handler := http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		fromCursor, err := strconv.ParseInt(request.FormValue("from_cursor"), 10, 64)
+		if err != nil {
+			http.Error(responseWriter, err.Error(), http.StatusBadRequest)
+			return
+		}
+		primary.mutex.Lock()
+		primary.fromCursors = append(primary.fromCursors, fromCursor)
+		primary.mutex.Unlock()
+		responseWriter.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range serve(fromCursor) {
+			fmt.Fprintf(responseWriter, "data: %s\n\n", fatal.UnlessMarshalJSON(event))
+		}
+	})
+	primary.server = httptest.NewServer(internal.NewServiceAuthorizationMiddleware(key, replication.Service).Middleware(handler))
+	return primary
+}
+
+func (primary *primary) getFromCursors() []int64 {
+	primary.mutex.Lock()
+	defer primary.mutex.Unlock()
+	return append([]int64(nil), primary.fromCursors...)
+}
+
+func TestStandby(t *testing.T) {
+	Convey("TestStandby", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		key := []byte("0123456789abcdef0123456789abcdef")
+		events := make([]*eventlog.Event, 5)
+		for i := range events {
+			events[i] = &eventlog.Event{
+				ID:            uuid.NewV4().String(),
+				Type:          "test",
+				LogicalClock:  int64(i) + 1,
+				UnixTimestamp: time.Now().Unix(),
+				Data:          fatal.UnlessMarshalJSON(i),
+			}
+		}
+		folderPath, err := os.MkdirTemp("testdata", "TestStandby-*")
+		So(err, ShouldBeNil)
+		replica := eventlog.NewIndexedEventLogOrFatal(&eventlog.NewIndexedEventLogInput{
+			FolderPath: folderPath,
+		})
+		defer replica.Close()
+		run := func(primary *primary, key []byte) *replication.Standby {
+			standby := replication.NewStandby(replication.NewStandbyInput{
+				Replica:       replica,
+				PrimaryURL:    primary.server.URL,
+				Key:           key,
+				RetryInterval: 10 * time.Millisecond,
+			})
+			go standby.Run(ctx)
+			return standby
+		}
+		waitFor := func(standby *replication.Standby, condition func(status replication.Status) bool) replication.Status {
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				status := standby.Status()
+				if condition(status) || time.Now().After(deadline) {
+					return status
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+		readAll := func() (events []*eventlog.Event) {
+			ctx := context.Background()
+			iterator := replica.GetEventIterator(ctx, eventlog.GetEventIteratorInput{})
+			for iterator.Next(ctx) {
+				events = append(events, iterator.Event())
+			}
+			So(iterator.Err(), ShouldBeNil)
+			return
+		}
+		Convey("reconnects from its cursor", func() {
+			primary := newPrimary(key, func(fromCursor int64) []*eventlog.Event {
+				return events[fromCursor:min(fromCursor+2, int64(len(events)))]
+			})
+			defer primary.server.Close()
+			standby := run(primary, key)
+			status := waitFor(standby, func(status replication.Status) bool {
+				return status.Cursor == 5
+			})
+			cancel()
+			So(status.Cursor, ShouldEqual, 5)
+			So(primary.getFromCursors()[:3], ShouldResemble, []int64{0, 2, 4})
+			So(readAll(), ShouldResemble, events)
+		})
+		Convey("rejected access token", func() {
+			primary := newPrimary(key, func(fromCursor int64) []*eventlog.Event {
+				return events[fromCursor:]
+			})
+			defer primary.server.Close()
+			standby := run(primary, []byte("fedcba9876543210fedcba9876543210"))
+			status := waitFor(standby, func(status replication.Status) bool {
+				return status.LastError != ""
+			})
+			cancel()
+			So(status.LastError, ShouldContainSubstring, "401")
+			So(status.Connected, ShouldBeFalse)
+			So(status.Cursor, ShouldEqual, 0)
+			So(primary.getFromCursors(), ShouldBeEmpty)
+		})
+		Convey("divergent primary", func() {
+			So(replica.Replicate(ctx, events[:3]), ShouldBeNil)
+			// A primary restored from before the standby's cursor sends
+			// events the standby already has.
+			primary := newPrimary(key, func(fromCursor int64) []*eventlog.Event {
+				return events[1:2]
+			})
+			defer primary.server.Close()
+			standby := run(primary, key)
+			status := waitFor(standby, func(status replication.Status) bool {
+				return status.LastError != ""
+			})
+			cancel()
+			So(status.LastError, ShouldContainSubstring, eventlog.ErrReplicaDiverged.Error())
+			So(primary.getFromCursors()[0], ShouldEqual, 3)
+			So(replica.Cursor(), ShouldEqual, 3)
+			So(readAll(), ShouldResemble, events[:3])
+		})
+	})
+}
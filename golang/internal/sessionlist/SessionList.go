@@ -2,15 +2,19 @@ package sessionlist
 
 import (
 	"context"
-	"encoding/json"
+	"maps"
+	"slices"
 	"sort"
+	"strings"
 	"sync"
-	"time"
 
+	"github.com/Ryan-A-B/beddybytes/golang/internal/accounts"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/connections"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/contextx"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
-	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventschema"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/logx"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/sessions"
 )
 
 type SessionList struct {
@@ -69,21 +73,31 @@ func (sessionList *SessionList) catchUp(ctx context.Context) {
 	defer sessionList.mutex.Unlock()
 	iterator := sessionList.log.GetEventIterator(ctx, eventlog.GetEventIteratorInput{
 		FromCursor: sessionList.cursor,
+		Types:      eventTypes,
 	})
+	var err error
 	for iterator.Next(ctx) {
 		event := iterator.Event()
-		sessionList.apply(ctx, event)
+		err = sessionList.apply(ctx, event)
+		if err != nil {
+			break
+		}
 		sessionList.cursor = event.LogicalClock
 	}
-	fatal.OnError(iterator.Err())
+	if err == nil {
+		err = iterator.Err()
+	}
+	if err != nil {
+		logx.Warnln("session list is behind the log:", err)
+	}
 }
 
-func (sessionList *SessionList) apply(ctx context.Context, event *eventlog.Event) {
+func (sessionList *SessionList) apply(ctx context.Context, event *eventlog.Event) error {
 	apply, ok := applyByType[event.Type]
 	if !ok {
-		return
+		return nil
 	}
-	apply(ctx, sessionList, event)
+	return apply(ctx, sessionList, event)
 }
 
 func (sessionList *SessionList) put(session *Session) {
@@ -142,32 +156,26 @@ func (sessionList *SessionList) getSessionByConnectionID(accountID string, conne
 	return
 }
 
-type applyFunc func(ctx context.Context, sessionList *SessionList, event *eventlog.Event)
+type applyFunc func(ctx context.Context, sessionList *SessionList, event *eventlog.Event) error
 
-const EventTypeServerStarted = "server.started"
-const EventTypeSessionStarted = "session.started"
-const EventTypeSessionEnded = "session.ended"
 const maxDisconnectedSessionsPerAccount = 4
 
 var applyByType = map[string]applyFunc{
-	EventTypeServerStarted:            applyServerStartedEvent,
-	EventTypeSessionStarted:           applySessionStartedEvent,
-	EventTypeSessionEnded:             applySessionEndedEvent,
-	connections.EventTypeConnected:    applyClientConnectedEvent,
-	connections.EventTypeDisconnected: applyClientDisconnectedEvent,
+	eventschema.EventTypeServerStarted: applyServerStartedEvent,
+	sessions.EventTypeStarted:          applySessionStartedEvent,
+	sessions.EventTypeEnded:            applySessionEndedEvent,
+	connections.EventTypeConnected:     applyClientConnectedEvent,
+	connections.EventTypeDisconnected:  applyClientDisconnectedEvent,
+	accounts.EventTypeAccountDeleted:   applyAccountDeletedEvent,
 }
 
-type SessionStartedEventData struct {
-	ID               string    `json:"id"`
-	Name             string    `json:"name"`
-	HostConnectionID string    `json:"host_connection_id"`
-	StartedAt        time.Time `json:"started_at"`
-}
+var eventTypes = slices.Collect(maps.Keys(applyByType))
 
-func applySessionStartedEvent(ctx context.Context, sessionList *SessionList, event *eventlog.Event) {
-	var sessionStartedEventData SessionStartedEventData
-	err := json.Unmarshal(event.Data, &sessionStartedEventData)
-	fatal.OnError(err)
+func applySessionStartedEvent(ctx context.Context, sessionList *SessionList, event *eventlog.Event) error {
+	sessionStartedEventData, err := eventschema.DecodeEvent[sessions.EventStarted](event)
+	if err != nil {
+		return err
+	}
 	existingSession, ok := sessionList.getSessionByConnectionID(event.AccountID, sessionStartedEventData.HostConnectionID)
 	if ok {
 		sessionList.delete(event.AccountID, existingSession.ID)
@@ -198,35 +206,35 @@ func applySessionStartedEvent(ctx context.Context, sessionList *SessionList, eve
 		StartedAt:           sessionStartedEventData.StartedAt,
 		HostConnectionState: hostConnectionState,
 	})
+	return nil
 }
 
-type SessionEndedEventData struct {
-	ID string `json:"id"`
-}
-
-func applySessionEndedEvent(ctx context.Context, sessionList *SessionList, event *eventlog.Event) {
-	var sessionEndedEventData SessionEndedEventData
-	err := json.Unmarshal(event.Data, &sessionEndedEventData)
-	fatal.OnError(err)
+func applySessionEndedEvent(ctx context.Context, sessionList *SessionList, event *eventlog.Event) error {
+	sessionEndedEventData, err := eventschema.DecodeEvent[sessions.EventEnded](event)
+	if err != nil {
+		return err
+	}
 	sessionList.delete(event.AccountID, sessionEndedEventData.ID)
 	sessionList.removeDisconnectedSessionByID(event.AccountID, sessionEndedEventData.ID)
+	return nil
 }
 
-func applyClientDisconnectedEvent(ctx context.Context, sessionList *SessionList, event *eventlog.Event) {
-	var data connections.EventDisconnected
-	err := json.Unmarshal(event.Data, &data)
-	fatal.OnError(err)
+func applyClientDisconnectedEvent(ctx context.Context, sessionList *SessionList, event *eventlog.Event) error {
+	data, err := eventschema.DecodeEvent[connections.EventDisconnected](event)
+	if err != nil {
+		return err
+	}
 	sessionList.deleteActiveConnection(event.AccountID, data.ConnectionID)
 	session, ok := sessionList.getSessionByConnectionID(event.AccountID, data.ConnectionID)
 	if !ok {
-		return
+		return nil
 	}
 	if session.HostConnectionState.GetState() != ConnectionStateConnected {
-		return
+		return nil
 	}
 	hostConnectionState := session.HostConnectionState.(HostConnectionStateConnected)
 	if hostConnectionState.RequestID != data.RequestID {
-		return
+		return nil
 	}
 	session.HostConnectionState = HostConnectionStateDisconnected{
 		HostConnectionStateBase: HostConnectionStateBase{
@@ -235,12 +243,14 @@ func applyClientDisconnectedEvent(ctx context.Context, sessionList *SessionList,
 		},
 	}
 	sessionList.trackDisconnectedSession(session)
+	return nil
 }
 
-func applyClientConnectedEvent(ctx context.Context, sessionList *SessionList, event *eventlog.Event) {
-	var data connections.EventConnected
-	err := json.Unmarshal(event.Data, &data)
-	fatal.OnError(err)
+func applyClientConnectedEvent(ctx context.Context, sessionList *SessionList, event *eventlog.Event) error {
+	data, err := eventschema.DecodeEvent[connections.EventConnected](event)
+	if err != nil {
+		return err
+	}
 	sessionList.putActiveConnection(event.AccountID, data.ConnectionID, activeConnectionInfo{
 		RequestID: data.RequestID,
 		Since:     event.UnixTimestamp,
@@ -249,7 +259,7 @@ func applyClientConnectedEvent(ctx context.Context, sessionList *SessionList, ev
 	if !ok {
 		session, ok = sessionList.removeDisconnectedSessionByConnectionID(event.AccountID, data.ConnectionID)
 		if !ok {
-			return
+			return nil
 		}
 		sessionList.put(session)
 	}
@@ -260,9 +270,10 @@ func applyClientConnectedEvent(ctx context.Context, sessionList *SessionList, ev
 		},
 		RequestID: data.RequestID,
 	}
+	return nil
 }
 
-func applyServerStartedEvent(ctx context.Context, sessionList *SessionList, event *eventlog.Event) {
+func applyServerStartedEvent(ctx context.Context, sessionList *SessionList, event *eventlog.Event) error {
 	activeSessions := append([]*Session(nil), sessionList.sessions...)
 	sessionList.activeConnectionByKey = make(map[string]activeConnectionInfo)
 	for _, session := range activeSessions {
@@ -277,6 +288,29 @@ func applyServerStartedEvent(ctx context.Context, sessionList *SessionList, even
 		}
 		sessionList.trackDisconnectedSession(session)
 	}
+	return nil
+}
+
+func applyAccountDeletedEvent(ctx context.Context, sessionList *SessionList, event *eventlog.Event) error {
+	start := sort.Search(len(sessionList.sessions), func(i int) bool {
+		return sessionList.sessions[i].AccountID >= event.AccountID
+	})
+	end := start
+	for end < len(sessionList.sessions) && sessionList.sessions[end].AccountID == event.AccountID {
+		end++
+	}
+	sessionList.sessions = append(sessionList.sessions[:start], sessionList.sessions[end:]...)
+	for _, session := range sessionList.disconnectedSessionsByAccountID[event.AccountID] {
+		delete(sessionList.disconnectedSessionByKey, sessionKey(session.AccountID, session.HostConnectionID))
+	}
+	delete(sessionList.disconnectedSessionsByAccountID, event.AccountID)
+	keyPrefix := sessionKey(event.AccountID, "")
+	for key := range sessionList.activeConnectionByKey {
+		if strings.HasPrefix(key, keyPrefix) {
+			delete(sessionList.activeConnectionByKey, key)
+		}
+	}
+	return nil
 }
 
 func (sessionList *SessionList) trackDisconnectedSession(session *Session) {
@@ -14,7 +14,7 @@ import (
 func BenchmarkSessionListRealData(b *testing.B) {
 	ctx := context.Background()
 	eventLogPath := findRealEventLogPath(b)
-	log := eventlog.NewFileEventLog(&eventlog.NewFileEventLogInput{
+	log := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
 		FolderPath: eventLogPath,
 	})
 	ctx = contextx.WithAccountID(ctx, "")
@@ -65,8 +65,8 @@ func findRealEventLogPath(tb testing.TB) string {
 		goModPath := filepath.Join(dir, "go.mod")
 		if _, err := os.Stat(goModPath); err == nil {
 			eventLogPath := filepath.Join(dir, "eventlog")
-			eventsPath := filepath.Join(eventLogPath, eventlog.EventsFileName)
-			if _, err := os.Stat(eventsPath); err == nil {
+			metadataPath := filepath.Join(eventLogPath, eventlog.MetadataFileName)
+			if _, err := os.Stat(metadataPath); err == nil {
 				return eventLogPath
 			}
 			tb.Skipf("real eventlog not found at %s", eventLogPath)
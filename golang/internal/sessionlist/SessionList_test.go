@@ -10,10 +10,12 @@ import (
 	uuid "github.com/satori/go.uuid"
 	. "github.com/smartystreets/goconvey/convey"
 
+	"github.com/Ryan-A-B/beddybytes/golang/internal/accounts"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/connections"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/contextx"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
 	"github.com/Ryan-A-B/beddybytes/golang/internal/sessionlist"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/sessions"
 )
 
 func TestSessionList(t *testing.T) {
@@ -23,7 +25,7 @@ func TestSessionList(t *testing.T) {
 		ctx = contextx.WithAccountID(ctx, accountID)
 		folderPath, err := os.MkdirTemp("testdata", "TestSessionList-*")
 		So(err, ShouldBeNil)
-		log := eventlog.NewFileEventLog(&eventlog.NewFileEventLogInput{
+		log := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
 			FolderPath: folderPath,
 		})
 		sessionList := sessionlist.New(ctx, sessionlist.NewInput{
@@ -37,7 +39,7 @@ func TestSessionList(t *testing.T) {
 			sessionID := uuid.NewV4().String()
 			sessionName := uuid.NewV4().String()
 			hostConnectionID := uuid.NewV4().String()
-			sessionStartedEventData := sessionlist.SessionStartedEventData{
+			sessionStartedEventData := sessions.EventStarted{
 				ID:               sessionID,
 				Name:             sessionName,
 				HostConnectionID: hostConnectionID,
@@ -46,7 +48,7 @@ func TestSessionList(t *testing.T) {
 			data, err := json.Marshal(sessionStartedEventData)
 			So(err, ShouldBeNil)
 			_, err = log.Append(ctx, eventlog.AppendInput{
-				Type:      sessionlist.EventTypeSessionStarted,
+				Type:      sessions.EventTypeStarted,
 				AccountID: accountID,
 				Data:      data,
 			})
@@ -59,7 +61,7 @@ func TestSessionList(t *testing.T) {
 						otherSessionID := "00000000"
 						sessionName := uuid.NewV4().String()
 						hostConnectionID := uuid.NewV4().String()
-						sessionStartedEventData := sessionlist.SessionStartedEventData{
+						sessionStartedEventData := sessions.EventStarted{
 							ID:               otherSessionID,
 							Name:             sessionName,
 							HostConnectionID: hostConnectionID,
@@ -68,7 +70,7 @@ func TestSessionList(t *testing.T) {
 						data, err := json.Marshal(sessionStartedEventData)
 						So(err, ShouldBeNil)
 						_, err = log.Append(ctx, eventlog.AppendInput{
-							Type:      sessionlist.EventTypeSessionStarted,
+							Type:      sessions.EventTypeStarted,
 							AccountID: accountID,
 							Data:      data,
 						})
@@ -77,13 +79,13 @@ func TestSessionList(t *testing.T) {
 						So(output.Sessions, ShouldHaveLength, 2)
 						Convey("End the", func() {
 							Convey("Initial session", func() {
-								sessionEndedEventData := sessionlist.SessionEndedEventData{
+								sessionEndedEventData := sessions.EventEnded{
 									ID: sessionID,
 								}
 								data, err := json.Marshal(sessionEndedEventData)
 								So(err, ShouldBeNil)
 								_, err = log.Append(ctx, eventlog.AppendInput{
-									Type:      sessionlist.EventTypeSessionEnded,
+									Type:      sessions.EventTypeEnded,
 									AccountID: accountID,
 									Data:      data,
 								})
@@ -94,13 +96,13 @@ func TestSessionList(t *testing.T) {
 								So(session.ID, ShouldEqual, otherSessionID)
 							})
 							Convey("Other session", func() {
-								sessionEndedEventData := sessionlist.SessionEndedEventData{
+								sessionEndedEventData := sessions.EventEnded{
 									ID: otherSessionID,
 								}
 								data, err := json.Marshal(sessionEndedEventData)
 								So(err, ShouldBeNil)
 								_, err = log.Append(ctx, eventlog.AppendInput{
-									Type:      sessionlist.EventTypeSessionEnded,
+									Type:      sessions.EventTypeEnded,
 									AccountID: accountID,
 									Data:      data,
 								})
@@ -116,7 +118,7 @@ func TestSessionList(t *testing.T) {
 						otherSessionID := "ffffffff"
 						sessionName := uuid.NewV4().String()
 						hostConnectionID := uuid.NewV4().String()
-						sessionStartedEventData := sessionlist.SessionStartedEventData{
+						sessionStartedEventData := sessions.EventStarted{
 							ID:               otherSessionID,
 							Name:             sessionName,
 							HostConnectionID: hostConnectionID,
@@ -125,7 +127,7 @@ func TestSessionList(t *testing.T) {
 						data, err := json.Marshal(sessionStartedEventData)
 						So(err, ShouldBeNil)
 						_, err = log.Append(ctx, eventlog.AppendInput{
-							Type:      sessionlist.EventTypeSessionStarted,
+							Type:      sessions.EventTypeStarted,
 							AccountID: accountID,
 							Data:      data,
 						})
@@ -134,13 +136,13 @@ func TestSessionList(t *testing.T) {
 						So(output.Sessions, ShouldHaveLength, 2)
 						Convey("End the", func() {
 							Convey("Initial session", func() {
-								sessionEndedEventData := sessionlist.SessionEndedEventData{
+								sessionEndedEventData := sessions.EventEnded{
 									ID: sessionID,
 								}
 								data, err := json.Marshal(sessionEndedEventData)
 								So(err, ShouldBeNil)
 								_, err = log.Append(ctx, eventlog.AppendInput{
-									Type:      sessionlist.EventTypeSessionEnded,
+									Type:      sessions.EventTypeEnded,
 									AccountID: accountID,
 									Data:      data,
 								})
@@ -151,13 +153,13 @@ func TestSessionList(t *testing.T) {
 								So(session.ID, ShouldEqual, otherSessionID)
 							})
 							Convey("Other session", func() {
-								sessionEndedEventData := sessionlist.SessionEndedEventData{
+								sessionEndedEventData := sessions.EventEnded{
 									ID: otherSessionID,
 								}
 								data, err := json.Marshal(sessionEndedEventData)
 								So(err, ShouldBeNil)
 								_, err = log.Append(ctx, eventlog.AppendInput{
-									Type:      sessionlist.EventTypeSessionEnded,
+									Type:      sessions.EventTypeEnded,
 									AccountID: accountID,
 									Data:      data,
 								})
@@ -177,7 +179,7 @@ func TestSessionList(t *testing.T) {
 						sessionID := uuid.NewV4().String()
 						sessionName := uuid.NewV4().String()
 						hostConnectionID := uuid.NewV4().String()
-						sessionStartedEventData := sessionlist.SessionStartedEventData{
+						sessionStartedEventData := sessions.EventStarted{
 							ID:               sessionID,
 							Name:             sessionName,
 							HostConnectionID: hostConnectionID,
@@ -186,7 +188,7 @@ func TestSessionList(t *testing.T) {
 						data, err := json.Marshal(sessionStartedEventData)
 						So(err, ShouldBeNil)
 						_, err = log.Append(ctx, eventlog.AppendInput{
-							Type:      sessionlist.EventTypeSessionStarted,
+							Type:      sessions.EventTypeStarted,
 							AccountID: otherAccountID,
 							Data:      data,
 						})
@@ -205,7 +207,7 @@ func TestSessionList(t *testing.T) {
 						sessionID := uuid.NewV4().String()
 						sessionName := uuid.NewV4().String()
 						hostConnectionID := uuid.NewV4().String()
-						sessionStartedEventData := sessionlist.SessionStartedEventData{
+						sessionStartedEventData := sessions.EventStarted{
 							ID:               sessionID,
 							Name:             sessionName,
 							HostConnectionID: hostConnectionID,
@@ -214,7 +216,7 @@ func TestSessionList(t *testing.T) {
 						data, err := json.Marshal(sessionStartedEventData)
 						So(err, ShouldBeNil)
 						_, err = log.Append(ctx, eventlog.AppendInput{
-							Type:      sessionlist.EventTypeSessionStarted,
+							Type:      sessions.EventTypeStarted,
 							AccountID: otherAccountID,
 							Data:      data,
 						})
@@ -230,13 +232,13 @@ func TestSessionList(t *testing.T) {
 				})
 			})
 			Convey("End the session", func() {
-				sessionEndedEventData := sessionlist.SessionEndedEventData{
+				sessionEndedEventData := sessions.EventEnded{
 					ID: sessionID,
 				}
 				data, err := json.Marshal(sessionEndedEventData)
 				So(err, ShouldBeNil)
 				_, err = log.Append(ctx, eventlog.AppendInput{
-					Type:      sessionlist.EventTypeSessionEnded,
+					Type:      sessions.EventTypeEnded,
 					AccountID: accountID,
 					Data:      data,
 				})
@@ -244,6 +246,16 @@ func TestSessionList(t *testing.T) {
 				output := sessionList.List(ctx)
 				So(output.Sessions, ShouldBeEmpty)
 			})
+			Convey("Delete the account", func() {
+				_, err = log.Append(ctx, eventlog.AppendInput{
+					Type:      accounts.EventTypeAccountDeleted,
+					AccountID: accountID,
+					Data:      []byte("{}"),
+				})
+				So(err, ShouldBeNil)
+				output := sessionList.List(ctx)
+				So(output.Sessions, ShouldBeEmpty)
+			})
 		})
 	})
 }
@@ -255,14 +267,14 @@ func TestSessionListReconnectCache(t *testing.T) {
 		ctx = contextx.WithAccountID(ctx, accountID)
 		folderPath, err := os.MkdirTemp("testdata", "TestSessionListReconnectCache-*")
 		So(err, ShouldBeNil)
-		log := eventlog.NewFileEventLog(&eventlog.NewFileEventLogInput{
+		log := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
 			FolderPath: folderPath,
 		})
 		sessionList := sessionlist.New(ctx, sessionlist.NewInput{
 			Log: log,
 		})
 		connectionID := uuid.NewV4().String()
-		sessionStartedEventData := sessionlist.SessionStartedEventData{
+		sessionStartedEventData := sessions.EventStarted{
 			ID:               uuid.NewV4().String(),
 			Name:             "test",
 			HostConnectionID: connectionID,
@@ -271,7 +283,7 @@ func TestSessionListReconnectCache(t *testing.T) {
 		data, err := json.Marshal(sessionStartedEventData)
 		So(err, ShouldBeNil)
 		_, err = log.Append(ctx, eventlog.AppendInput{
-			Type:      sessionlist.EventTypeSessionStarted,
+			Type:      sessions.EventTypeStarted,
 			AccountID: accountID,
 			Data:      data,
 		})
@@ -0,0 +1,141 @@
+package sessionlist
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+const SnapshotSchemaVersion = 1
+
+type snapshotState struct {
+	Sessions                        []*snapshotSession            `json:"sessions"`
+	ActiveConnections               []*snapshotActiveConnection   `json:"active_connections"`
+	DisconnectedSessionsByAccountID map[string][]*snapshotSession `json:"disconnected_sessions_by_account_id"`
+}
+
+type snapshotSession struct {
+	AccountID        string          `json:"account_id"`
+	ID               string          `json:"id"`
+	Name             string          `json:"name"`
+	HostConnectionID string          `json:"host_connection_id"`
+	StartedAt        time.Time       `json:"started_at"`
+	State            ConnectionState `json:"state"`
+	Since            int64           `json:"since"`
+	RequestID        string          `json:"request_id,omitempty"`
+}
+
+type snapshotActiveConnection struct {
+	Key       string `json:"key"`
+	RequestID string `json:"request_id"`
+	Since     int64  `json:"since"`
+}
+
+func newSnapshotSession(session *Session) *snapshotSession {
+	output := &snapshotSession{
+		AccountID:        session.AccountID,
+		ID:               session.ID,
+		Name:             session.Name,
+		HostConnectionID: session.HostConnectionID,
+		StartedAt:        session.StartedAt,
+		State:            session.HostConnectionState.GetState(),
+		Since:            session.HostConnectionState.GetSince(),
+	}
+	if connected, ok := session.HostConnectionState.(HostConnectionStateConnected); ok {
+		output.RequestID = connected.RequestID
+	}
+	return output
+}
+
+func (input *snapshotSession) session() *Session {
+	base := HostConnectionStateBase{
+		State: input.State,
+		Since: input.Since,
+	}
+	var hostConnectionState HostConnectionState = HostConnectionStateDisconnected{
+		HostConnectionStateBase: base,
+	}
+	if input.State == ConnectionStateConnected {
+		hostConnectionState = HostConnectionStateConnected{
+			HostConnectionStateBase: base,
+			RequestID:               input.RequestID,
+		}
+	}
+	return &Session{
+		AccountID:           input.AccountID,
+		ID:                  input.ID,
+		Name:                input.Name,
+		HostConnectionID:    input.HostConnectionID,
+		StartedAt:           input.StartedAt,
+		HostConnectionState: hostConnectionState,
+	}
+}
+
+func (sessionList *SessionList) MarshalSnapshot(ctx context.Context) (cursor int64, data []byte, err error) {
+	sessionList.catchUp(ctx)
+	sessionList.mutex.Lock()
+	defer sessionList.mutex.Unlock()
+	state := snapshotState{
+		Sessions:                        make([]*snapshotSession, len(sessionList.sessions)),
+		ActiveConnections:               make([]*snapshotActiveConnection, 0, len(sessionList.activeConnectionByKey)),
+		DisconnectedSessionsByAccountID: make(map[string][]*snapshotSession, len(sessionList.disconnectedSessionsByAccountID)),
+	}
+	for i, session := range sessionList.sessions {
+		state.Sessions[i] = newSnapshotSession(session)
+	}
+	for key, info := range sessionList.activeConnectionByKey {
+		state.ActiveConnections = append(state.ActiveConnections, &snapshotActiveConnection{
+			Key:       key,
+			RequestID: info.RequestID,
+			Since:     info.Since,
+		})
+	}
+	for accountID, sessions := range sessionList.disconnectedSessionsByAccountID {
+		disconnectedSessions := make([]*snapshotSession, len(sessions))
+		for i, session := range sessions {
+			disconnectedSessions[i] = newSnapshotSession(session)
+		}
+		state.DisconnectedSessionsByAccountID[accountID] = disconnectedSessions
+	}
+	data, err = json.Marshal(state)
+	cursor = sessionList.cursor
+	return
+}
+
+func (sessionList *SessionList) UnmarshalSnapshot(ctx context.Context, cursor int64, data []byte) (err error) {
+	var state snapshotState
+	err = json.Unmarshal(data, &state)
+	if err != nil {
+		return
+	}
+	sessions := make([]*Session, len(state.Sessions))
+	for i, session := range state.Sessions {
+		sessions[i] = session.session()
+	}
+	activeConnectionByKey := make(map[string]activeConnectionInfo, len(state.ActiveConnections))
+	for _, connection := range state.ActiveConnections {
+		activeConnectionByKey[connection.Key] = activeConnectionInfo{
+			RequestID: connection.RequestID,
+			Since:     connection.Since,
+		}
+	}
+	disconnectedSessionByKey := make(map[string]*Session)
+	disconnectedSessionsByAccountID := make(map[string][]*Session, len(state.DisconnectedSessionsByAccountID))
+	for accountID, snapshotSessions := range state.DisconnectedSessionsByAccountID {
+		disconnectedSessions := make([]*Session, len(snapshotSessions))
+		for i, snapshotSession := range snapshotSessions {
+			session := snapshotSession.session()
+			disconnectedSessions[i] = session
+			disconnectedSessionByKey[sessionKey(accountID, session.HostConnectionID)] = session
+		}
+		disconnectedSessionsByAccountID[accountID] = disconnectedSessions
+	}
+	sessionList.mutex.Lock()
+	defer sessionList.mutex.Unlock()
+	sessionList.cursor = cursor
+	sessionList.sessions = sessions
+	sessionList.activeConnectionByKey = activeConnectionByKey
+	sessionList.disconnectedSessionByKey = disconnectedSessionByKey
+	sessionList.disconnectedSessionsByAccountID = disconnectedSessionsByAccountID
+	return
+}
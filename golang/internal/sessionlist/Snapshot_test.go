@@ -0,0 +1,89 @@
+package sessionlist_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/connections"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/contextx"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventlog"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/sessionlist"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/sessions"
+)
+
+func TestSessionListSnapshot(t *testing.T) {
+	Convey("TestSessionListSnapshot", t, func() {
+		ctx := context.Background()
+		accountID := uuid.NewV4().String()
+		ctx = contextx.WithAccountID(ctx, accountID)
+		folderPath, err := os.MkdirTemp("testdata", "TestSessionListSnapshot-*")
+		So(err, ShouldBeNil)
+		log := eventlog.NewFileEventLogOrFatal(&eventlog.NewFileEventLogInput{
+			FolderPath: folderPath,
+		})
+		appendEvent := func(eventType string, data interface{}) {
+			_, err := log.Append(ctx, eventlog.AppendInput{
+				Type:      eventType,
+				AccountID: accountID,
+				Data:      fatal.UnlessMarshalJSON(data),
+			})
+			So(err, ShouldBeNil)
+		}
+		connectedConnectionID := uuid.NewV4().String()
+		disconnectedConnectionID := uuid.NewV4().String()
+		appendEvent(connections.EventTypeConnected, connections.EventConnected{
+			ClientID:     uuid.NewV4().String(),
+			ConnectionID: connectedConnectionID,
+			RequestID:    uuid.NewV4().String(),
+		})
+		appendEvent(sessions.EventTypeStarted, sessions.EventStarted{
+			ID:               uuid.NewV4().String(),
+			Name:             uuid.NewV4().String(),
+			HostConnectionID: connectedConnectionID,
+			StartedAt:        time.Now().UTC(),
+		})
+		disconnectedRequestID := uuid.NewV4().String()
+		appendEvent(connections.EventTypeConnected, connections.EventConnected{
+			ClientID:     uuid.NewV4().String(),
+			ConnectionID: disconnectedConnectionID,
+			RequestID:    disconnectedRequestID,
+		})
+		appendEvent(sessions.EventTypeStarted, sessions.EventStarted{
+			ID:               uuid.NewV4().String(),
+			Name:             uuid.NewV4().String(),
+			HostConnectionID: disconnectedConnectionID,
+			StartedAt:        time.Now().UTC(),
+		})
+		appendEvent(connections.EventTypeDisconnected, connections.EventDisconnected{
+			ConnectionID: disconnectedConnectionID,
+			RequestID:    disconnectedRequestID,
+		})
+		original := sessionlist.New(ctx, sessionlist.NewInput{
+			Log: log,
+		})
+		cursor, data, err := original.MarshalSnapshot(ctx)
+		So(err, ShouldBeNil)
+		So(cursor, ShouldEqual, 5)
+		restored := sessionlist.New(ctx, sessionlist.NewInput{
+			Log: log,
+		})
+		err = restored.UnmarshalSnapshot(ctx, cursor, data)
+		So(err, ShouldBeNil)
+		So(restored.List(ctx), ShouldResemble, original.List(ctx))
+		Convey("disconnected session reconnects after restore", func() {
+			reconnectRequestID := uuid.NewV4().String()
+			appendEvent(connections.EventTypeConnected, connections.EventConnected{
+				ConnectionID: disconnectedConnectionID,
+				RequestID:    reconnectRequestID,
+			})
+			So(restored.List(ctx), ShouldResemble, original.List(ctx))
+			So(restored.List(ctx).Sessions, ShouldHaveLength, 2)
+		})
+	})
+}
@@ -0,0 +1,26 @@
+package sessions
+
+import (
+	"time"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/eventschema"
+)
+
+const EventTypeStarted = "session.started"
+const EventTypeEnded = "session.ended"
+
+type EventStarted struct {
+	ID               string    `json:"id"`
+	Name             string    `json:"name"`
+	HostConnectionID string    `json:"host_connection_id"`
+	StartedAt        time.Time `json:"started_at"`
+}
+
+type EventEnded struct {
+	ID string `json:"id"`
+}
+
+func init() {
+	eventschema.Register[EventStarted](eventschema.Default, EventTypeStarted, 1)
+	eventschema.Register[EventEnded](eventschema.Default, EventTypeEnded, 1)
+}
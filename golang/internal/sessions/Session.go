@@ -9,3 +9,8 @@ type Session struct {
 	HostConnectionID string    `json:"host_connection_id"`
 	StartedAt        time.Time `json:"started_at"`
 }
+
+// StreamKey is the eventlog stream key of the events of one session.
+func StreamKey(accountID string, sessionID string) string {
+	return "accounts/" + accountID + "/sessions/" + sessionID
+}
@@ -43,6 +43,10 @@ func (store *InMemory) List(accountID string) (sessions []*sessions.Session) {
 	return
 }
 
+func (store *InMemory) ListAll() (sessions []*sessions.Session) {
+	return append(sessions, store.sessions...)
+}
+
 func (store *InMemory) Remove(accountID string, sessionID string) {
 	index := store.search(accountID, sessionID)
 	if index == len(store.sessions) {
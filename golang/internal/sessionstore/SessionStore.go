@@ -5,5 +5,6 @@ import "github.com/Ryan-A-B/beddybytes/golang/internal/sessions"
 type SessionStore interface {
 	Put(session *sessions.Session)
 	List(accountID string) []*sessions.Session
+	ListAll() []*sessions.Session
 	Remove(accountID string, sessionID string)
 }
@@ -33,6 +33,12 @@ func (decorator *ThreadSafeDecorator) List(accountID string) []*sessions.Session
 	return decorator.decorated.List(accountID)
 }
 
+func (decorator *ThreadSafeDecorator) ListAll() []*sessions.Session {
+	decorator.mutex.Lock()
+	defer decorator.mutex.Unlock()
+	return decorator.decorated.ListAll()
+}
+
 func (decorator *ThreadSafeDecorator) Remove(accountID string, sessionID string) {
 	decorator.mutex.Lock()
 	defer decorator.mutex.Unlock()
@@ -1,51 +1,121 @@
 package store
 
-import "context"
+import (
+	"context"
+	"time"
 
+	"github.com/ansel1/merry"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/cache"
+)
+
+// CachingDecorator caches values, and misses if NotFoundTTL is set, in a
+// bounded LRU cache. A Get that races a write can cache the value it read,
+// until it is written again or expires.
 type CachingDecorator struct {
 	store Store
-	// TODO: use LRU cache
-	cache map[string][]byte
+	cache *cache.LRU[string, []byte]
 }
 
 type NewCachingDecoratorInput struct {
 	Store Store
+	// MaxEntries and MaxBytes bound the cache. Zero means unbounded.
+	MaxEntries int
+	MaxBytes   int64
+	// TTL is how long a value is cached. Zero means until it is evicted.
+	TTL time.Duration
+	// NotFoundTTL is how long ErrNotFound is cached. Zero means it isn't.
+	NotFoundTTL time.Duration
 }
 
 func NewCachingDecorator(input *NewCachingDecoratorInput) *CachingDecorator {
 	return &CachingDecorator{
 		store: input.Store,
-		cache: make(map[string][]byte),
+		cache: cache.NewLRU[string](cache.NewLRUInput[[]byte]{
+			MaxEntries: input.MaxEntries,
+			MaxBytes:   input.MaxBytes,
+			Size: func(data []byte) int64 {
+				return int64(len(data))
+			},
+			TTL:         input.TTL,
+			NotFoundTTL: input.NotFoundTTL,
+		}),
 	}
 }
 
+func (decorator *CachingDecorator) Stats() cache.Stats {
+	return decorator.cache.Stats()
+}
+
 func (decorator *CachingDecorator) Put(ctx context.Context, key string, data []byte) (err error) {
 	err = decorator.store.Put(ctx, key, data)
 	if err != nil {
+		decorator.cache.Delete(key)
 		return
 	}
-	decorator.cache[key] = data
+	decorator.cache.Put(key, data)
 	return
 }
 
 func (decorator *CachingDecorator) Get(ctx context.Context, key string) (data []byte, err error) {
-	data, ok := decorator.cache[key]
-	if ok {
+	data, found, cached := decorator.cache.Get(key)
+	if cached && !found {
+		err = ErrNotFound.Here()
+		return
+	}
+	if cached {
 		return
 	}
 	data, err = decorator.store.Get(ctx, key)
+	if merry.Is(err, ErrNotFound) {
+		decorator.cache.PutNotFound(key)
+		return
+	}
 	if err != nil {
 		return
 	}
-	decorator.cache[key] = data
+	decorator.cache.Put(key, data)
 	return
 }
 
 func (decorator *CachingDecorator) Delete(ctx context.Context, key string) (err error) {
 	err = decorator.store.Delete(ctx, key)
+	decorator.cache.Delete(key)
+	return
+}
+
+func (decorator *CachingDecorator) List(ctx context.Context, prefix string) (keys []string, err error) {
+	return decorator.store.List(ctx, prefix)
+}
+
+func (decorator *CachingDecorator) GetVersion(ctx context.Context, key string) (data []byte, version string, err error) {
+	data, version, err = decorator.store.GetVersion(ctx, key)
 	if err != nil {
 		return
 	}
-	delete(decorator.cache, key)
+	decorator.cache.Put(key, data)
+	return
+}
+
+func (decorator *CachingDecorator) PutIfVersion(ctx context.Context, key string, data []byte, version string) (newVersion string, err error) {
+	newVersion, err = decorator.store.PutIfVersion(ctx, key, data, version)
+	if err != nil {
+		// The cached value is stale if another writer won.
+		decorator.cache.Delete(key)
+		return
+	}
+	decorator.cache.Put(key, data)
+	return
+}
+
+func (decorator *CachingDecorator) Batch(ctx context.Context, ops []Op) (err error) {
+	err = decorator.store.Batch(ctx, ops)
+	for _, op := range ops {
+		if err != nil || op.Delete {
+			decorator.cache.Delete(op.Key)
+			continue
+		}
+		decorator.cache.Put(op.Key, op.Data)
+	}
 	return
 }
@@ -0,0 +1,50 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ansel1/merry"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/store"
+)
+
+func TestCachingDecorator(t *testing.T) {
+	Convey("TestCachingDecorator", t, func() {
+		ctx := context.Background()
+		memoryStore := store.NewMemoryStore()
+		cachingStore := store.NewCachingDecorator(&store.NewCachingDecoratorInput{
+			Store:       memoryStore,
+			MaxEntries:  100,
+			TTL:         time.Minute,
+			NotFoundTTL: time.Minute,
+		})
+		testStore(t, cachingStore)
+		Convey("caches values", func() {
+			So(cachingStore.Put(ctx, "foo", []byte("foo")), ShouldBeNil)
+			So(memoryStore.Delete(ctx, "foo"), ShouldBeNil)
+			data, err := cachingStore.Get(ctx, "foo")
+			So(err, ShouldBeNil)
+			So(data, ShouldResemble, []byte("foo"))
+			So(cachingStore.Stats().Hits, ShouldEqual, 1)
+		})
+		Convey("caches not found", func() {
+			_, err := cachingStore.Get(ctx, "missing")
+			So(merry.Is(err, store.ErrNotFound), ShouldBeTrue)
+			So(memoryStore.Put(ctx, "missing", []byte("missing")), ShouldBeNil)
+			_, err = cachingStore.Get(ctx, "missing")
+			So(merry.Is(err, store.ErrNotFound), ShouldBeTrue)
+			stats := cachingStore.Stats()
+			So(stats.Misses, ShouldEqual, 1)
+			So(stats.Hits, ShouldEqual, 1)
+			Convey("until written", func() {
+				So(cachingStore.Put(ctx, "missing", []byte("found")), ShouldBeNil)
+				data, err := cachingStore.Get(ctx, "missing")
+				So(err, ShouldBeNil)
+				So(data, ShouldResemble, []byte("found"))
+			})
+		})
+	})
+}
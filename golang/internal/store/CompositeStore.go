@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"net/http"
+	"slices"
 
 	"github.com/ansel1/merry"
 
@@ -43,3 +44,50 @@ func (compositeStore *CompositeStore) Delete(ctx context.Context, key string) (e
 	}
 	return
 }
+
+// List returns the keys of every store.
+func (compositeStore *CompositeStore) List(ctx context.Context, prefix string) (keys []string, err error) {
+	for _, store := range compositeStore.Stores {
+		var storeKeys []string
+		storeKeys, err = store.List(ctx, prefix)
+		if err != nil {
+			return
+		}
+		keys = append(keys, storeKeys...)
+	}
+	slices.Sort(keys)
+	keys = slices.Compact(keys)
+	return
+}
+
+// GetVersion returns the version of the first store.
+func (compositeStore *CompositeStore) GetVersion(ctx context.Context, key string) (data []byte, version string, err error) {
+	return compositeStore.Stores[0].GetVersion(ctx, key)
+}
+
+// PutIfVersion checks the version in the first store and copies the value
+// to the others once it accepts it.
+func (compositeStore *CompositeStore) PutIfVersion(ctx context.Context, key string, data []byte, version string) (newVersion string, err error) {
+	newVersion, err = compositeStore.Stores[0].PutIfVersion(ctx, key, data, version)
+	if err != nil {
+		return
+	}
+	for _, store := range compositeStore.Stores[1:] {
+		err = store.Put(ctx, key, data)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Batch is atomic within each store, not across them.
+func (compositeStore *CompositeStore) Batch(ctx context.Context, ops []Op) (err error) {
+	for _, store := range compositeStore.Stores {
+		err = store.Batch(ctx, ops)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
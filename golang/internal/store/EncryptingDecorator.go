@@ -6,39 +6,83 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
-	"io"
+	"net/http"
+	"time"
+
+	"github.com/ansel1/merry"
 
 	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/logx"
 )
 
+// ErrUndecryptable is returned for a value that can't be decrypted with any
+// known key, or that has been tampered with.
+var ErrUndecryptable = merry.New("value can't be decrypted").WithHTTPCode(http.StatusInternalServerError)
+
+// DefaultKeyID is the ID of NewEncryptingDecoratorInput.Key.
+const DefaultKeyID = "default"
+
+// envelopeMagic starts every value encrypted with AES-GCM. It is followed by
+// the length of the key ID, the key ID, the nonce and the sealed data.
+// Values written before it was introduced are AES-CBC with the IV
+// prepended.
+var envelopeMagic = []byte("BBE1")
+
+// EncryptingDecorator encrypts values with AES-GCM under the current key,
+// recording the key's ID so that values can be decrypted with any known key
+// while keys are rotated. The store key is authenticated with the value, so
+// a value copied to another key doesn't decrypt.
 type EncryptingDecorator struct {
-	store Store
-	key   []byte
+	store        Store
+	keys         map[string]cipher.AEAD
+	currentKeyID string
+	legacyKey    []byte
 }
 
 type NewEncryptingDecoratorInput struct {
 	Store Store
-	Key   []byte
+	// Key is shorthand for Keys with only DefaultKeyID.
+	Key []byte
+	// Keys are the AES keys by ID. Values are encrypted with CurrentKeyID
+	// and decrypted with the key they were encrypted with.
+	Keys         map[string][]byte
+	CurrentKeyID string
+	// LegacyKeyID is the key of values written in the AES-CBC format, which
+	// doesn't record one. Those values aren't authenticated, so they are only
+	// read when it is set, until Reencrypt has rewritten them.
+	LegacyKeyID string
 }
 
 func NewEncryptingDecorator(input *NewEncryptingDecoratorInput) *EncryptingDecorator {
-	return &EncryptingDecorator{
-		store: input.Store,
-		key:   input.Key,
+	keys := input.Keys
+	currentKeyID := input.CurrentKeyID
+	if len(keys) == 0 {
+		keys = map[string][]byte{DefaultKeyID: input.Key}
+		currentKeyID = DefaultKeyID
+	}
+	_, ok := keys[currentKeyID]
+	fatal.Unless(ok, "unknown current key ID "+currentKeyID)
+	fatal.Unless(len(currentKeyID) <= 255, "key ID is too long")
+	decorator := &EncryptingDecorator{
+		store:        input.Store,
+		keys:         make(map[string]cipher.AEAD, len(keys)),
+		currentKeyID: currentKeyID,
 	}
+	if input.LegacyKeyID != "" {
+		decorator.legacyKey, ok = keys[input.LegacyKeyID]
+		fatal.Unless(ok, "unknown legacy key ID "+input.LegacyKeyID)
+	}
+	for keyID, key := range keys {
+		block, err := aes.NewCipher(key)
+		fatal.OnError(err)
+		decorator.keys[keyID], err = cipher.NewGCM(block)
+		fatal.OnError(err)
+	}
+	return decorator
 }
 
 func (decorator *EncryptingDecorator) Put(ctx context.Context, key string, data []byte) (err error) {
-	paddedData := decorator.pkcs7Pad(data, aes.BlockSize)
-	block, err := aes.NewCipher(decorator.key)
-	fatal.OnError(err)
-	encryptedData := make([]byte, aes.BlockSize+len(paddedData))
-	iv := encryptedData[:aes.BlockSize]
-	_, err = io.ReadFull(rand.Reader, iv)
-	fatal.OnError(err)
-	encrypter := cipher.NewCBCEncrypter(block, encryptedData[:aes.BlockSize])
-	encrypter.CryptBlocks(encryptedData[aes.BlockSize:], paddedData)
-	return decorator.store.Put(ctx, key, encryptedData)
+	return decorator.store.Put(ctx, key, decorator.encrypt(key, data))
 }
 
 func (decorator *EncryptingDecorator) Get(ctx context.Context, key string) (data []byte, err error) {
@@ -46,13 +90,7 @@ func (decorator *EncryptingDecorator) Get(ctx context.Context, key string) (data
 	if err != nil {
 		return
 	}
-	block, err := aes.NewCipher(decorator.key)
-	fatal.OnError(err)
-	iv := encryptedData[:aes.BlockSize]
-	paddedData := make([]byte, len(encryptedData[aes.BlockSize:]))
-	encrypter := cipher.NewCBCDecrypter(block, iv)
-	encrypter.CryptBlocks(paddedData, encryptedData[aes.BlockSize:])
-	data = decorator.pkcs7Unpad(paddedData, aes.BlockSize)
+	data, _, err = decorator.decrypt(key, encryptedData)
 	return
 }
 
@@ -60,16 +98,194 @@ func (decorator *EncryptingDecorator) Delete(ctx context.Context, key string) (e
 	return decorator.store.Delete(ctx, key)
 }
 
-func (decorator *EncryptingDecorator) pkcs7Pad(data []byte, blockSize int) []byte {
-	padding := blockSize - (len(data) % blockSize)
-	padText := bytes.Repeat([]byte{byte(padding)}, padding)
-	return append(data, padText...)
+func (decorator *EncryptingDecorator) List(ctx context.Context, prefix string) (keys []string, err error) {
+	return decorator.store.List(ctx, prefix)
+}
+
+func (decorator *EncryptingDecorator) GetVersion(ctx context.Context, key string) (data []byte, version string, err error) {
+	encryptedData, version, err := decorator.store.GetVersion(ctx, key)
+	if err != nil {
+		return
+	}
+	data, _, err = decorator.decrypt(key, encryptedData)
+	return
+}
+
+func (decorator *EncryptingDecorator) PutIfVersion(ctx context.Context, key string, data []byte, version string) (newVersion string, err error) {
+	return decorator.store.PutIfVersion(ctx, key, decorator.encrypt(key, data), version)
+}
+
+func (decorator *EncryptingDecorator) Batch(ctx context.Context, ops []Op) (err error) {
+	encryptedOps := make([]Op, len(ops))
+	for i, op := range ops {
+		encryptedOps[i] = op
+		if !op.Delete {
+			encryptedOps[i].Data = decorator.encrypt(op.Key, op.Data)
+		}
+	}
+	return decorator.store.Batch(ctx, encryptedOps)
+}
+
+func (decorator *EncryptingDecorator) encrypt(key string, data []byte) []byte {
+	aead := decorator.keys[decorator.currentKeyID]
+	header := make([]byte, 0, len(envelopeMagic)+1+len(decorator.currentKeyID)+aead.NonceSize())
+	header = append(header, envelopeMagic...)
+	header = append(header, byte(len(decorator.currentKeyID)))
+	header = append(header, decorator.currentKeyID...)
+	nonce := make([]byte, aead.NonceSize())
+	_, err := rand.Read(nonce)
+	fatal.OnError(err)
+	header = append(header, nonce...)
+	return aead.Seal(header, nonce, data, []byte(key))
 }
 
-func (decorator *EncryptingDecorator) pkcs7Unpad(data []byte, blockSize int) []byte {
+// decrypt returns the ID of the key the value was encrypted with, empty for
+// a value in the AES-CBC format.
+func (decorator *EncryptingDecorator) decrypt(key string, encryptedData []byte) (data []byte, keyID string, err error) {
+	data, keyID, isEnvelope, err := decorator.open(key, encryptedData)
+	if isEnvelope {
+		return
+	}
+	// The IV of an AES-CBC value can start with the magic by chance.
+	data, legacyErr := decorator.decryptLegacy(encryptedData)
+	if legacyErr != nil {
+		return nil, "", err
+	}
+	return data, "", nil
+}
+
+// open decrypts an envelope. isEnvelope is false if the value isn't one with
+// a known key, so may be an AES-CBC value, rather than failing to
+// authenticate.
+func (decorator *EncryptingDecorator) open(key string, encryptedData []byte) (data []byte, keyID string, isEnvelope bool, err error) {
+	rest, ok := bytes.CutPrefix(encryptedData, envelopeMagic)
+	if !ok || len(rest) < 1 || len(rest) < 1+int(rest[0]) {
+		err = ErrUndecryptable.Here()
+		return
+	}
+	keyID, rest = string(rest[1:1+int(rest[0])]), rest[1+int(rest[0]):]
+	aead, ok := decorator.keys[keyID]
+	if !ok {
+		err = merry.Prependf(ErrUndecryptable, "unknown key ID %s", keyID)
+		return
+	}
+	if len(rest) < aead.NonceSize() {
+		err = ErrUndecryptable.Here()
+		return
+	}
+	isEnvelope = true
+	data, err = aead.Open([]byte{}, rest[:aead.NonceSize()], rest[aead.NonceSize():], []byte(key))
+	if err != nil {
+		err = ErrUndecryptable.Here().WithCause(err)
+	}
+	return
+}
+
+func (decorator *EncryptingDecorator) decryptLegacy(encryptedData []byte) (data []byte, err error) {
+	if decorator.legacyKey == nil {
+		return nil, ErrUndecryptable.Here()
+	}
+	if len(encryptedData) < 2*aes.BlockSize || len(encryptedData)%aes.BlockSize != 0 {
+		return nil, ErrUndecryptable.Here()
+	}
+	block, err := aes.NewCipher(decorator.legacyKey)
+	fatal.OnError(err)
+	iv := encryptedData[:aes.BlockSize]
+	paddedData := make([]byte, len(encryptedData[aes.BlockSize:]))
+	decrypter := cipher.NewCBCDecrypter(block, iv)
+	decrypter.CryptBlocks(paddedData, encryptedData[aes.BlockSize:])
+	return pkcs7Unpad(paddedData, aes.BlockSize)
+}
+
+func pkcs7Unpad(data []byte, blockSize int) ([]byte, error) {
 	length := len(data)
 	unpadding := int(data[length-1])
-	fatal.Unless(unpadding <= blockSize, "invalid padding")
-	fatal.Unless(unpadding > 0, "invalid padding")
-	return data[:(length - unpadding)]
+	if unpadding == 0 || unpadding > blockSize {
+		return nil, merry.Prepend(ErrUndecryptable, "invalid padding")
+	}
+	for _, b := range data[length-unpadding:] {
+		if int(b) != unpadding {
+			return nil, merry.Prepend(ErrUndecryptable, "invalid padding")
+		}
+	}
+	return data[:(length - unpadding)], nil
+}
+
+type ReencryptReport struct {
+	Reencrypted int
+	Current     int
+	// Failed counts values that couldn't be decrypted or rewritten.
+	Failed int
+}
+
+// Reencrypt rewrites the values under prefix that aren't encrypted with the
+// current key, so that older keys can be retired once it reports none
+// failed. A value written while it runs is left as it was written.
+func (decorator *EncryptingDecorator) Reencrypt(ctx context.Context, prefix string) (report ReencryptReport, err error) {
+	keys, err := decorator.store.List(ctx, prefix)
+	if err != nil {
+		return
+	}
+	for _, key := range keys {
+		err = ctx.Err()
+		if err != nil {
+			return
+		}
+		var reencrypted bool
+		reencrypted, err = decorator.reencrypt(ctx, key)
+		switch {
+		case merry.Is(err, ErrNotFound):
+			// Deleted since it was listed.
+		case err != nil:
+			logx.Warnln("failed to reencrypt", key, err)
+			report.Failed++
+		case reencrypted:
+			report.Reencrypted++
+		default:
+			report.Current++
+		}
+	}
+	err = nil
+	return
+}
+
+func (decorator *EncryptingDecorator) reencrypt(ctx context.Context, key string) (reencrypted bool, err error) {
+	encryptedData, version, err := decorator.store.GetVersion(ctx, key)
+	if err != nil {
+		return
+	}
+	data, keyID, err := decorator.decrypt(key, encryptedData)
+	if err != nil {
+		return
+	}
+	if keyID == decorator.currentKeyID {
+		return
+	}
+	_, err = decorator.store.PutIfVersion(ctx, key, decorator.encrypt(key, data), version)
+	if merry.Is(err, ErrConflict) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// RunReencryption runs Reencrypt in the background until a pass has nothing
+// left to re-encrypt and no failures, waiting retryInterval between passes.
+func (decorator *EncryptingDecorator) RunReencryption(ctx context.Context, prefix string, retryInterval time.Duration) {
+	for {
+		report, err := decorator.Reencrypt(ctx, prefix)
+		if err != nil && ctx.Err() == nil {
+			logx.Errorln("failed to reencrypt store:", err)
+		}
+		if err == nil {
+			logx.Infof("reencrypted %d values, %d already current, %d failed\n", report.Reencrypted, report.Current, report.Failed)
+			if report.Reencrypted == 0 && report.Failed == 0 {
+				return
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryInterval):
+		}
+	}
 }
@@ -1,7 +1,10 @@
 package store_test
 
 import (
+	"bytes"
 	"context"
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/rand"
 	"io"
 	mathrand "math/rand"
@@ -9,6 +12,7 @@ import (
 	"time"
 
 	"github.com/Ryan-A-B/beddybytes/golang/internal/store"
+	"github.com/ansel1/merry"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
@@ -24,6 +28,7 @@ func TestEncryptingDecorator(t *testing.T) {
 			Store: memoryStore,
 			Key:   key,
 		})
+		testStore(t, encryptedStore)
 		Convey("Put", func() {
 			dataSize := mathrand.Intn(40)
 			expectedData := make([]byte, dataSize)
@@ -46,5 +51,98 @@ func TestEncryptingDecorator(t *testing.T) {
 				So(err, ShouldNotBeNil)
 			})
 		})
+		Convey("empty", func() {
+			err := encryptedStore.Put(ctx, "foo", []byte{})
+			So(err, ShouldBeNil)
+			data, err := encryptedStore.Get(ctx, "foo")
+			So(err, ShouldBeNil)
+			So(data, ShouldNotBeNil)
+			So(data, ShouldBeEmpty)
+		})
+	})
+}
+
+func TestEncryptingDecoratorKeyRotation(t *testing.T) {
+	Convey("TestEncryptingDecoratorKeyRotation", t, func() {
+		ctx := context.Background()
+		newKey := func() []byte {
+			key := make([]byte, 32)
+			_, err := io.ReadFull(rand.Reader, key)
+			So(err, ShouldBeNil)
+			return key
+		}
+		oldKey, currentKey := newKey(), newKey()
+		memoryStore := store.NewMemoryStore()
+		oldStore := store.NewEncryptingDecorator(&store.NewEncryptingDecoratorInput{
+			Store:        memoryStore,
+			Keys:         map[string][]byte{"old": oldKey},
+			CurrentKeyID: "old",
+		})
+		So(oldStore.Put(ctx, "foo", []byte("foo")), ShouldBeNil)
+		// A value written before values were authenticated.
+		legacyData := []byte("legacy")
+		padding := aes.BlockSize - len(legacyData)%aes.BlockSize
+		paddedData := append(legacyData, bytes.Repeat([]byte{byte(padding)}, padding)...)
+		encryptedData := make([]byte, aes.BlockSize+len(paddedData))
+		_, err := io.ReadFull(rand.Reader, encryptedData[:aes.BlockSize])
+		So(err, ShouldBeNil)
+		block, err := aes.NewCipher(oldKey)
+		So(err, ShouldBeNil)
+		cipher.NewCBCEncrypter(block, encryptedData[:aes.BlockSize]).CryptBlocks(encryptedData[aes.BlockSize:], paddedData)
+		So(memoryStore.Put(ctx, "legacy", encryptedData), ShouldBeNil)
+		rotatedStore := store.NewEncryptingDecorator(&store.NewEncryptingDecoratorInput{
+			Store:        memoryStore,
+			Keys:         map[string][]byte{"old": oldKey, "current": currentKey},
+			CurrentKeyID: "current",
+			LegacyKeyID:  "old",
+		})
+		currentStore := store.NewEncryptingDecorator(&store.NewEncryptingDecoratorInput{
+			Store:        memoryStore,
+			Keys:         map[string][]byte{"current": currentKey},
+			CurrentKeyID: "current",
+		})
+		Convey("decrypt with any known key", func() {
+			data, err := rotatedStore.Get(ctx, "foo")
+			So(err, ShouldBeNil)
+			So(data, ShouldResemble, []byte("foo"))
+			data, err = rotatedStore.Get(ctx, "legacy")
+			So(err, ShouldBeNil)
+			So(data, ShouldResemble, []byte("legacy"))
+			_, err = currentStore.Get(ctx, "foo")
+			So(merry.Is(err, store.ErrUndecryptable), ShouldBeTrue)
+		})
+		Convey("legacy values are only read with LegacyKeyID", func() {
+			_, err := oldStore.Get(ctx, "legacy")
+			So(merry.Is(err, store.ErrUndecryptable), ShouldBeTrue)
+		})
+		Convey("Reencrypt", func() {
+			So(rotatedStore.Put(ctx, "bar", []byte("bar")), ShouldBeNil)
+			report, err := rotatedStore.Reencrypt(ctx, "")
+			So(err, ShouldBeNil)
+			So(report, ShouldResemble, store.ReencryptReport{Reencrypted: 2, Current: 1})
+			for _, key := range []string{"foo", "legacy", "bar"} {
+				data, err := currentStore.Get(ctx, key)
+				So(err, ShouldBeNil)
+				So(string(data), ShouldEqual, key)
+			}
+			report, err = rotatedStore.Reencrypt(ctx, "")
+			So(err, ShouldBeNil)
+			So(report, ShouldResemble, store.ReencryptReport{Current: 3})
+		})
+		Convey("tampered", func() {
+			encryptedData, err := memoryStore.Get(ctx, "foo")
+			So(err, ShouldBeNil)
+			encryptedData[len(encryptedData)-1] ^= 1
+			So(memoryStore.Put(ctx, "foo", encryptedData), ShouldBeNil)
+			_, err = rotatedStore.Get(ctx, "foo")
+			So(merry.Is(err, store.ErrUndecryptable), ShouldBeTrue)
+		})
+		Convey("moved to another key", func() {
+			encryptedData, err := memoryStore.Get(ctx, "foo")
+			So(err, ShouldBeNil)
+			So(memoryStore.Put(ctx, "bar", encryptedData), ShouldBeNil)
+			_, err = rotatedStore.Get(ctx, "bar")
+			So(merry.Is(err, store.ErrUndecryptable), ShouldBeTrue)
+		})
 	})
 }
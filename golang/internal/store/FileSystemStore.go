@@ -3,17 +3,47 @@ package store
 import (
 	"context"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"io"
-	"io/ioutil"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/ansel1/merry"
 
 	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
 )
 
+const keysFolderName = "keys"
+
+// batchFileName is the journal of the batch being applied. A batch left
+// behind by a crash is applied again when the store is opened.
+const batchFileName = "batch.json"
+
+// A key is encoded into a file name, split into folders every
+// maxFileNameLength characters to stay within file name limits.
+const maxFileNameLength = 128
+
+const valueFileExtension = ".value"
+
+const folderExtension = ".more"
+
+// FileSystemStore stores each value in a file named after its key, so that
+// keys can be listed. Values written before keys were listable are in files
+// named after a hash of their key; they are moved when read or written and
+// aren't listed until then. Keys can be read from the file names, so a
+// KeyHashingDecorator should be used for sensitive keys.
+//
+// PutIfVersion and Batch are atomic between the users of one
+// FileSystemStore, not between processes.
 type FileSystemStore struct {
-	root string
+	root  string
+	mutex sync.Mutex
 }
 
 type NewFileSystemStoreInput struct {
@@ -24,10 +54,22 @@ func NewFileSystemStore(input *NewFileSystemStoreInput) (store *FileSystemStore)
 	store = &FileSystemStore{
 		root: input.Root,
 	}
+	fatal.OnError(store.recoverBatch())
 	return
 }
 
 func (store *FileSystemStore) getFilePath(key string) string {
+	name := base64.RawURLEncoding.EncodeToString([]byte(key))
+	elems := []string{store.root, keysFolderName}
+	for len(name) > maxFileNameLength {
+		elems = append(elems, name[:maxFileNameLength]+folderExtension)
+		name = name[maxFileNameLength:]
+	}
+	elems = append(elems, name+valueFileExtension)
+	return filepath.Join(elems...)
+}
+
+func (store *FileSystemStore) getLegacyFilePath(key string) string {
 	hash := sha256.New()
 	io.WriteString(hash, key)
 	sum := hash.Sum(nil)
@@ -35,34 +77,223 @@ func (store *FileSystemStore) getFilePath(key string) string {
 }
 
 func (store *FileSystemStore) Put(ctx context.Context, key string, data []byte) (err error) {
-	path := store.getFilePath(key)
-	file, err := os.Create(path)
-	fatal.OnError(err)
-	defer file.Close()
-	_, err = file.Write(data)
-	fatal.OnError(err)
-	return
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return store.put(key, data)
+}
+
+func (store *FileSystemStore) put(key string, data []byte) (err error) {
+	err = writeFileAtomically(store.getFilePath(key), data)
+	if err != nil {
+		return
+	}
+	return removeIfExists(store.getLegacyFilePath(key))
 }
 
 func (store *FileSystemStore) Get(ctx context.Context, key string) (data []byte, err error) {
-	path := store.getFilePath(key)
-	file, err := os.Open(path)
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return store.get(key)
+}
+
+func (store *FileSystemStore) get(key string) (data []byte, err error) {
+	data, err = os.ReadFile(store.getFilePath(key))
+	if !os.IsNotExist(err) {
+		return
+	}
+	data, err = os.ReadFile(store.getLegacyFilePath(key))
 	if os.IsNotExist(err) {
 		err = ErrNotFound.WithCause(err)
 		return
 	}
-	fatal.OnError(err)
-	defer file.Close()
-	data, err = ioutil.ReadAll(file)
-	fatal.OnError(err)
+	if err != nil {
+		return
+	}
+	err = store.put(key, data)
 	return
 }
 
 func (store *FileSystemStore) Delete(ctx context.Context, key string) (err error) {
-	err = os.Remove(store.getFilePath(key))
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return store.delete(key)
+}
+
+func (store *FileSystemStore) delete(key string) (err error) {
+	err = removeIfExists(store.getFilePath(key))
+	if err != nil {
+		return
+	}
+	return removeIfExists(store.getLegacyFilePath(key))
+}
+
+func (store *FileSystemStore) List(ctx context.Context, prefix string) (keys []string, err error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	keysFolderPath := filepath.Join(store.root, keysFolderName)
+	err = filepath.WalkDir(keysFolderPath, func(path string, entry fs.DirEntry, err error) error {
+		if os.IsNotExist(err) && path == keysFolderPath {
+			return filepath.SkipAll
+		}
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !strings.HasSuffix(path, valueFileExtension) {
+			return nil
+		}
+		relativePath, err := filepath.Rel(keysFolderPath, path)
+		if err != nil {
+			return err
+		}
+		var name strings.Builder
+		for _, elem := range strings.Split(relativePath, string(filepath.Separator)) {
+			name.WriteString(strings.TrimSuffix(strings.TrimSuffix(elem, folderExtension), valueFileExtension))
+		}
+		key, err := base64.RawURLEncoding.DecodeString(name.String())
+		if err != nil {
+			return merry.Prependf(err, "invalid key file name %s", path)
+		}
+		if strings.HasPrefix(string(key), prefix) {
+			keys = append(keys, string(key))
+		}
+		return nil
+	})
+	slices.Sort(keys)
+	return
+}
+
+func (store *FileSystemStore) GetVersion(ctx context.Context, key string) (data []byte, version string, err error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	data, err = store.get(key)
+	if err != nil {
+		return
+	}
+	version = contentVersion(data)
+	return
+}
+
+func (store *FileSystemStore) PutIfVersion(ctx context.Context, key string, data []byte, version string) (newVersion string, err error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	current, err := store.get(key)
+	switch {
+	case merry.Is(err, ErrNotFound):
+		if version != "" {
+			err = ErrConflict.Here()
+			return
+		}
+	case err != nil:
+		return
+	case contentVersion(current) != version:
+		err = ErrConflict.Here()
+		return
+	}
+	err = store.put(key, data)
+	if err != nil {
+		return
+	}
+	newVersion = contentVersion(data)
+	return
+}
+
+// Batch journals ops before applying them. A batch that fails part way is
+// finished by the next Batch, or when the store is next opened.
+func (store *FileSystemStore) Batch(ctx context.Context, ops []Op) (err error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	err = store.recoverBatch()
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(ops)
+	if err != nil {
+		return
+	}
+	batchFilePath := filepath.Join(store.root, batchFileName)
+	err = writeFileAtomically(batchFilePath, data)
+	if err != nil {
+		return
+	}
+	err = store.apply(ops)
+	if err != nil {
+		return
+	}
+	return os.Remove(batchFilePath)
+}
+
+func (store *FileSystemStore) recoverBatch() (err error) {
+	batchFilePath := filepath.Join(store.root, batchFileName)
+	data, err := os.ReadFile(batchFilePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return
+	}
+	var ops []Op
+	err = json.Unmarshal(data, &ops)
+	if err != nil {
+		return
+	}
+	err = store.apply(ops)
+	if err != nil {
+		return
+	}
+	return os.Remove(batchFilePath)
+}
+
+func (store *FileSystemStore) apply(ops []Op) (err error) {
+	for _, op := range ops {
+		if op.Delete {
+			err = store.delete(op.Key)
+		} else {
+			err = store.put(op.Key, op.Data)
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// writeFileAtomically replaces the file so that readers, and a crash, see
+// either the old data or the new.
+func writeFileAtomically(filePath string, data []byte) (err error) {
+	folderPath := filepath.Dir(filePath)
+	err = os.MkdirAll(folderPath, 0755)
+	if err != nil {
+		return
+	}
+	file, err := os.CreateTemp(folderPath, ".tmp-*")
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}()
+	_, err = file.Write(data)
+	if err != nil {
+		return
+	}
+	err = file.Sync()
+	if err != nil {
+		return
+	}
+	err = file.Close()
+	if err != nil {
+		return
+	}
+	return os.Rename(file.Name(), filePath)
+}
+
+func removeIfExists(filePath string) (err error) {
+	err = os.Remove(filePath)
 	if os.IsNotExist(err) {
 		return nil
 	}
-	fatal.OnError(err)
 	return
 }
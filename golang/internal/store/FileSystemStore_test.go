@@ -1,8 +1,15 @@
 package store_test
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
 	"testing"
 
+	uuid "github.com/satori/go.uuid"
 	. "github.com/smartystreets/goconvey/convey"
 
 	"github.com/Ryan-A-B/beddybytes/golang/internal/store"
@@ -16,3 +23,56 @@ func TestFileSystemStore(t *testing.T) {
 		testStore(t, fsStore)
 	})
 }
+
+func TestFileSystemStoreMigration(t *testing.T) {
+	Convey("TestFileSystemStoreMigration", t, func() {
+		ctx := context.Background()
+		root, err := os.MkdirTemp("testdata", "TestFileSystemStoreMigration-*")
+		So(err, ShouldBeNil)
+		fsStore := store.NewFileSystemStore(&store.NewFileSystemStoreInput{
+			Root: root,
+		})
+		key := uuid.NewV4().String()
+		sum := sha256.Sum256([]byte(key))
+		legacyFilePath := filepath.Join(root, hex.EncodeToString(sum[:]))
+		So(os.WriteFile(legacyFilePath, []byte("legacy"), 0644), ShouldBeNil)
+		keys, err := fsStore.List(ctx, "")
+		So(err, ShouldBeNil)
+		So(keys, ShouldBeEmpty)
+		data, err := fsStore.Get(ctx, key)
+		So(err, ShouldBeNil)
+		So(data, ShouldResemble, []byte("legacy"))
+		_, err = os.Stat(legacyFilePath)
+		So(os.IsNotExist(err), ShouldBeTrue)
+		keys, err = fsStore.List(ctx, "")
+		So(err, ShouldBeNil)
+		So(keys, ShouldResemble, []string{key})
+	})
+}
+
+func TestFileSystemStoreBatchRecovery(t *testing.T) {
+	Convey("TestFileSystemStoreBatchRecovery", t, func() {
+		ctx := context.Background()
+		root, err := os.MkdirTemp("testdata", "TestFileSystemStoreBatchRecovery-*")
+		So(err, ShouldBeNil)
+		fsStore := store.NewFileSystemStore(&store.NewFileSystemStoreInput{
+			Root: root,
+		})
+		So(fsStore.Put(ctx, "deleted", []byte("deleted")), ShouldBeNil)
+		// A crash after journaling the batch, before applying it.
+		ops, err := json.Marshal([]store.Op{
+			store.PutOp("put", []byte("put")),
+			store.DeleteOp("deleted"),
+		})
+		So(err, ShouldBeNil)
+		So(os.WriteFile(filepath.Join(root, "batch.json"), ops, 0644), ShouldBeNil)
+		reopened := store.NewFileSystemStore(&store.NewFileSystemStoreInput{
+			Root: root,
+		})
+		keys, err := reopened.List(ctx, "")
+		So(err, ShouldBeNil)
+		So(keys, ShouldResemble, []string{"put"})
+		_, err = os.Stat(filepath.Join(root, "batch.json"))
+		So(os.IsNotExist(err), ShouldBeTrue)
+	})
+}
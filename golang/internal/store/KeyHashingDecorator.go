@@ -0,0 +1,152 @@
+package store
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/ansel1/merry"
+)
+
+// KeyHashingDecorator stores each value under an HMAC of its key, so that
+// keys such as emails can't be read from file names or object keys. The key
+// is kept with the value, for List, so Decorated should encrypt values, as
+// an EncryptingDecorator does.
+//
+// List reads every value, so it is only suited to occasional use, such as
+// re-encryption.
+type KeyHashingDecorator struct {
+	store   Store
+	hashKey []byte
+}
+
+type NewKeyHashingDecoratorInput struct {
+	Store Store
+	// HashKey is the HMAC key. Changing it loses every value.
+	HashKey []byte
+}
+
+func NewKeyHashingDecorator(input *NewKeyHashingDecoratorInput) *KeyHashingDecorator {
+	return &KeyHashingDecorator{
+		store:   input.Store,
+		hashKey: input.HashKey,
+	}
+}
+
+type keyedValue struct {
+	Key  string `json:"key"`
+	Data []byte `json:"data"`
+}
+
+func (decorator *KeyHashingDecorator) Put(ctx context.Context, key string, data []byte) (err error) {
+	value, err := marshalKeyedValue(key, data)
+	if err != nil {
+		return
+	}
+	return decorator.store.Put(ctx, decorator.hashKeyOf(key), value)
+}
+
+func (decorator *KeyHashingDecorator) Get(ctx context.Context, key string) (data []byte, err error) {
+	value, err := decorator.store.Get(ctx, decorator.hashKeyOf(key))
+	if err != nil {
+		return
+	}
+	return unmarshalKeyedValue(key, value)
+}
+
+func (decorator *KeyHashingDecorator) Delete(ctx context.Context, key string) (err error) {
+	return decorator.store.Delete(ctx, decorator.hashKeyOf(key))
+}
+
+func (decorator *KeyHashingDecorator) List(ctx context.Context, prefix string) (keys []string, err error) {
+	hashedKeys, err := decorator.store.List(ctx, "")
+	if err != nil {
+		return
+	}
+	for _, hashedKey := range hashedKeys {
+		var value []byte
+		value, err = decorator.store.Get(ctx, hashedKey)
+		if merry.Is(err, ErrNotFound) {
+			// Deleted since it was listed.
+			continue
+		}
+		if err != nil {
+			return
+		}
+		var keyed keyedValue
+		err = json.Unmarshal(value, &keyed)
+		if err != nil {
+			return nil, merry.Prependf(err, "invalid value under %s", hashedKey)
+		}
+		if strings.HasPrefix(keyed.Key, prefix) {
+			keys = append(keys, keyed.Key)
+		}
+	}
+	err = nil
+	slices.Sort(keys)
+	return
+}
+
+func (decorator *KeyHashingDecorator) GetVersion(ctx context.Context, key string) (data []byte, version string, err error) {
+	value, version, err := decorator.store.GetVersion(ctx, decorator.hashKeyOf(key))
+	if err != nil {
+		return
+	}
+	data, err = unmarshalKeyedValue(key, value)
+	return
+}
+
+func (decorator *KeyHashingDecorator) PutIfVersion(ctx context.Context, key string, data []byte, version string) (newVersion string, err error) {
+	value, err := marshalKeyedValue(key, data)
+	if err != nil {
+		return
+	}
+	return decorator.store.PutIfVersion(ctx, decorator.hashKeyOf(key), value, version)
+}
+
+func (decorator *KeyHashingDecorator) Batch(ctx context.Context, ops []Op) (err error) {
+	hashedOps := make([]Op, len(ops))
+	for i, op := range ops {
+		hashedOps[i] = Op{
+			Key:    decorator.hashKeyOf(op.Key),
+			Delete: op.Delete,
+		}
+		if !op.Delete {
+			hashedOps[i].Data, err = marshalKeyedValue(op.Key, op.Data)
+			if err != nil {
+				return
+			}
+		}
+	}
+	return decorator.store.Batch(ctx, hashedOps)
+}
+
+func (decorator *KeyHashingDecorator) hashKeyOf(key string) string {
+	mac := hmac.New(sha256.New, decorator.hashKey)
+	mac.Write([]byte(key))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func marshalKeyedValue(key string, data []byte) ([]byte, error) {
+	return json.Marshal(keyedValue{Key: key, Data: data})
+}
+
+// unmarshalKeyedValue checks the value was stored under key, rather than
+// another key with the same hash.
+func unmarshalKeyedValue(key string, value []byte) (data []byte, err error) {
+	var keyed keyedValue
+	err = json.Unmarshal(value, &keyed)
+	if err != nil {
+		return
+	}
+	if keyed.Key != key {
+		err = merry.Prepend(ErrNotFound, "value is for another key")
+		return
+	}
+	data = keyed.Data
+	return
+}
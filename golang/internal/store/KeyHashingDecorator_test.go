@@ -0,0 +1,53 @@
+package store_test
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/store"
+)
+
+func TestKeyHashingDecorator(t *testing.T) {
+	Convey("TestKeyHashingDecorator", t, func() {
+		ctx := context.Background()
+		root, err := os.MkdirTemp("testdata", "TestKeyHashingDecorator-*")
+		So(err, ShouldBeNil)
+		hashingStore := store.NewKeyHashingDecorator(&store.NewKeyHashingDecoratorInput{
+			Store: store.NewEncryptingDecorator(&store.NewEncryptingDecoratorInput{
+				Store: store.NewFileSystemStore(&store.NewFileSystemStoreInput{
+					Root: root,
+				}),
+				Key: bytes.Repeat([]byte{1}, 32),
+			}),
+			HashKey: []byte("hash key"),
+		})
+		testStore(t, hashingStore)
+		Convey("keys aren't readable on disk", func() {
+			key := "email/foo@example.com"
+			So(hashingStore.Put(ctx, key, []byte("1")), ShouldBeNil)
+			So(hashingStore.Batch(ctx, []store.Op{store.PutOp(key+"2", []byte("2"))}), ShouldBeNil)
+			err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+				So(err, ShouldBeNil)
+				So(strings.Contains(path, "foo"), ShouldBeFalse)
+				if entry.IsDir() {
+					return nil
+				}
+				data, err := os.ReadFile(path)
+				So(err, ShouldBeNil)
+				So(bytes.Contains(data, []byte("foo")), ShouldBeFalse)
+				return nil
+			})
+			So(err, ShouldBeNil)
+			keys, err := hashingStore.List(ctx, "email/")
+			So(err, ShouldBeNil)
+			So(keys, ShouldResemble, []string{key, key + "2"})
+		})
+	})
+}
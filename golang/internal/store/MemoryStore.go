@@ -1,8 +1,15 @@
 package store
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"sync"
+)
 
 type MemoryStore struct {
+	mutex sync.Mutex
 	store map[string][]byte
 }
 
@@ -13,6 +20,8 @@ func NewMemoryStore() *MemoryStore {
 }
 
 func (store *MemoryStore) Get(ctx context.Context, key string) (value []byte, err error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
 	value, ok := store.store[key]
 	if !ok {
 		err = ErrNotFound.Here()
@@ -21,11 +30,83 @@ func (store *MemoryStore) Get(ctx context.Context, key string) (value []byte, er
 }
 
 func (store *MemoryStore) Put(ctx context.Context, key string, value []byte) (err error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
 	store.store[key] = value
 	return
 }
 
 func (store *MemoryStore) Delete(ctx context.Context, key string) (err error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
 	delete(store.store, key)
 	return
 }
+
+func (store *MemoryStore) List(ctx context.Context, prefix string) (keys []string, err error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	for key := range store.store {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return
+}
+
+func (store *MemoryStore) GetVersion(ctx context.Context, key string) (value []byte, version string, err error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	value, ok := store.store[key]
+	if !ok {
+		err = ErrNotFound.Here()
+		return
+	}
+	version = contentVersion(value)
+	return
+}
+
+func (store *MemoryStore) PutIfVersion(ctx context.Context, key string, value []byte, version string) (newVersion string, err error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	current, ok := store.store[key]
+	if (ok && contentVersion(current) != version) || (!ok && version != "") {
+		err = ErrConflict.Here()
+		return
+	}
+	store.store[key] = value
+	newVersion = contentVersion(value)
+	return
+}
+
+func (store *MemoryStore) Batch(ctx context.Context, ops []Op) (err error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	for _, op := range ops {
+		if op.Delete {
+			delete(store.store, op.Key)
+			continue
+		}
+		store.store[op.Key] = op.Data
+	}
+	return
+}
+
+func (store *MemoryStore) MarshalJSON() (data []byte, err error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return json.Marshal(store.store)
+}
+
+func (store *MemoryStore) UnmarshalJSON(data []byte) (err error) {
+	values := make(map[string][]byte)
+	err = json.Unmarshal(data, &values)
+	if err != nil {
+		return
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.store = values
+	return
+}
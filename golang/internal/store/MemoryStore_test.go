@@ -0,0 +1,15 @@
+package store_test
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/store"
+)
+
+func TestMemoryStore(t *testing.T) {
+	Convey("TestMemoryStore", t, func() {
+		testStore(t, store.NewMemoryStore())
+	})
+}
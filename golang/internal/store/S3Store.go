@@ -7,13 +7,25 @@ import (
 	"encoding/hex"
 	"errors"
 	"io"
+	"strings"
 
+	"github.com/ansel1/merry"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
-
-	"github.com/Ryan-A-B/beddybytes/golang/internal/fatal"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 )
 
+// keysPrefix is where objects are stored under their key, so that keys can
+// be listed with a prefix scan. Objects written before keys were listable
+// are named after a hash of their key; they are moved when read or written
+// and aren't listed until then.
+const keysPrefix = "keys/"
+
+// S3Store versions values by ETag and uses conditional writes for
+// PutIfVersion. It can't write several objects atomically, so Batch returns
+// ErrNotSupported.
 type S3Store struct {
 	client *s3.Client
 	bucket string
@@ -35,54 +47,154 @@ func NewS3Store(input *NewS3StoreInput) *S3Store {
 }
 
 func (store *S3Store) getObjectKey(key string) string {
+	return store.prefix + keysPrefix + key
+}
+
+func (store *S3Store) getLegacyObjectKey(key string) string {
 	hash := sha256.New()
 	io.WriteString(hash, key)
 	sum := hash.Sum(nil)
 	return store.prefix + hex.EncodeToString(sum)
 }
 
-func (store *S3Store) notFoundOrFatal(err error) error {
+func isNoSuchKey(err error) bool {
 	var noSuchKey *types.NoSuchKey
-	fatal.Unless(errors.As(err, &noSuchKey), "unexpected error: "+err.Error())
-	return ErrNotFound.WithCause(err)
+	return errors.As(err, &noSuchKey)
+}
+
+func isPreconditionFailed(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.ErrorCode() {
+	case "PreconditionFailed", "ConditionalRequestConflict":
+		return true
+	}
+	return false
 }
 
 func (store *S3Store) Put(ctx context.Context, key string, data []byte) (err error) {
+	_, err = store.put(ctx, key, data)
+	return
+}
+
+// put writes the object and removes its legacy copy. Conditions are passed
+// as API options.
+func (store *S3Store) put(ctx context.Context, key string, data []byte, optFns ...func(*s3.Options)) (version string, err error) {
 	objectKey := store.getObjectKey(key)
-	_, err = store.client.PutObject(ctx, &s3.PutObjectInput{
+	output, err := store.client.PutObject(ctx, &s3.PutObjectInput{
 		Bucket: &store.bucket,
 		Key:    &objectKey,
 		Body:   bytes.NewReader(data),
-	})
-	fatal.OnError(err)
+	}, optFns...)
+	// An If-Match for a missing object fails with NoSuchKey.
+	if isPreconditionFailed(err) || isNoSuchKey(err) {
+		err = ErrConflict.WithCause(err)
+		return
+	}
+	if err != nil {
+		return
+	}
+	version = aws.ToString(output.ETag)
+	err = store.deleteObject(ctx, store.getLegacyObjectKey(key))
 	return
 }
 
 func (store *S3Store) Get(ctx context.Context, key string) (data []byte, err error) {
-	objectKey := store.getObjectKey(key)
+	data, _, err = store.get(ctx, key)
+	return
+}
+
+func (store *S3Store) get(ctx context.Context, key string) (data []byte, version string, err error) {
+	data, version, err = store.getObject(ctx, store.getObjectKey(key))
+	if !isNoSuchKey(err) {
+		return
+	}
+	data, _, err = store.getObject(ctx, store.getLegacyObjectKey(key))
+	if isNoSuchKey(err) {
+		err = ErrNotFound.WithCause(err)
+		return
+	}
+	if err != nil {
+		return
+	}
+	version, err = store.put(ctx, key, data)
+	return
+}
+
+func (store *S3Store) getObject(ctx context.Context, objectKey string) (data []byte, version string, err error) {
 	output, err := store.client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: &store.bucket,
 		Key:    &objectKey,
 	})
 	if err != nil {
-		err = store.notFoundOrFatal(err)
 		return
 	}
 	defer output.Body.Close()
 	data, err = io.ReadAll(output.Body)
-	fatal.OnError(err)
+	if err != nil {
+		return
+	}
+	version = aws.ToString(output.ETag)
 	return
 }
 
 func (store *S3Store) Delete(ctx context.Context, key string) (err error) {
-	objectKey := store.getObjectKey(key)
+	err = store.deleteObject(ctx, store.getObjectKey(key))
+	if err != nil {
+		return
+	}
+	return store.deleteObject(ctx, store.getLegacyObjectKey(key))
+}
+
+func (store *S3Store) deleteObject(ctx context.Context, objectKey string) (err error) {
 	_, err = store.client.DeleteObject(ctx, &s3.DeleteObjectInput{
 		Bucket: &store.bucket,
 		Key:    &objectKey,
 	})
-	if err != nil {
-		store.notFoundOrFatal(err)
+	if isNoSuchKey(err) {
 		return nil
 	}
 	return
 }
+
+func (store *S3Store) List(ctx context.Context, prefix string) (keys []string, err error) {
+	objectKeyPrefix := store.getObjectKey(prefix)
+	paginator := s3.NewListObjectsV2Paginator(store.client, &s3.ListObjectsV2Input{
+		Bucket: &store.bucket,
+		Prefix: &objectKeyPrefix,
+	})
+	for paginator.HasMorePages() {
+		var page *s3.ListObjectsV2Output
+		page, err = paginator.NextPage(ctx)
+		if err != nil {
+			return
+		}
+		for _, object := range page.Contents {
+			keys = append(keys, strings.TrimPrefix(aws.ToString(object.Key), store.prefix+keysPrefix))
+		}
+	}
+	return
+}
+
+func (store *S3Store) GetVersion(ctx context.Context, key string) (data []byte, version string, err error) {
+	return store.get(ctx, key)
+}
+
+func (store *S3Store) PutIfVersion(ctx context.Context, key string, data []byte, version string) (newVersion string, err error) {
+	// Moves a legacy copy, so that the condition applies to it.
+	_, _, err = store.get(ctx, key)
+	if err != nil && !merry.Is(err, ErrNotFound) {
+		return
+	}
+	condition := smithyhttp.AddHeaderValue("If-Match", version)
+	if version == "" {
+		condition = smithyhttp.AddHeaderValue("If-None-Match", "*")
+	}
+	return store.put(ctx, key, data, s3.WithAPIOptions(condition))
+}
+
+func (store *S3Store) Batch(ctx context.Context, ops []Op) (err error) {
+	return ErrNotSupported.Here()
+}
@@ -2,6 +2,8 @@ package store
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"net/http"
 
 	"github.com/ansel1/merry"
@@ -9,8 +11,56 @@ import (
 
 var ErrNotFound = merry.New("key not found").WithHTTPCode(http.StatusNotFound)
 
+// ErrConflict is returned by PutIfVersion when the stored value no longer
+// has the expected version.
+var ErrConflict = merry.New("version conflict").WithHTTPCode(http.StatusPreconditionFailed)
+
+// ErrNotSupported is returned by a store whose backend cannot provide an
+// operation, such as an atomic Batch on S3.
+var ErrNotSupported = merry.New("operation not supported by store").WithHTTPCode(http.StatusNotImplemented)
+
 type Store interface {
 	Put(ctx context.Context, key string, data []byte) (err error)
 	Get(ctx context.Context, key string) (data []byte, err error)
 	Delete(ctx context.Context, key string) (err error)
+	// List returns the keys that start with prefix, in order.
+	List(ctx context.Context, prefix string) (keys []string, err error)
+	// GetVersion returns the value with its version, to be passed to
+	// PutIfVersion.
+	GetVersion(ctx context.Context, key string) (data []byte, version string, err error)
+	// PutIfVersion puts data only if the value stored under key still has
+	// version, or if there is no value when version is empty, and returns
+	// ErrConflict otherwise.
+	PutIfVersion(ctx context.Context, key string, data []byte, version string) (newVersion string, err error)
+	// Batch applies ops in order, such that readers and a restarted store
+	// see either all of them or none.
+	Batch(ctx context.Context, ops []Op) (err error)
+}
+
+type Op struct {
+	Key string
+	// Data is put under Key unless Delete is set.
+	Data   []byte
+	Delete bool
+}
+
+func PutOp(key string, data []byte) Op {
+	return Op{
+		Key:  key,
+		Data: data,
+	}
+}
+
+func DeleteOp(key string) Op {
+	return Op{
+		Key:    key,
+		Delete: true,
+	}
+}
+
+// contentVersion is the version of a value for stores that don't keep one
+// of their own.
+func contentVersion(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
 }
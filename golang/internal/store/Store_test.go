@@ -5,10 +5,12 @@ import (
 	"crypto/rand"
 	"io"
 	mathrand "math/rand"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/Ryan-A-B/beddybytes/golang/internal/store"
+	"github.com/ansel1/merry"
 	uuid "github.com/satori/go.uuid"
 	. "github.com/smartystreets/goconvey/convey"
 )
@@ -39,5 +41,68 @@ func testStore(t *testing.T, s store.Store) {
 				})
 			})
 		})
+		Convey("List", func() {
+			prefix := uuid.NewV4().String() + "/"
+			keys := []string{prefix + "a", prefix + "b/c", prefix + "b/d"}
+			for _, key := range keys {
+				So(s.Put(ctx, key, []byte(key)), ShouldBeNil)
+			}
+			So(s.Put(ctx, uuid.NewV4().String(), nil), ShouldBeNil)
+			actualKeys, err := s.List(ctx, prefix)
+			So(err, ShouldBeNil)
+			So(actualKeys, ShouldResemble, keys)
+			actualKeys, err = s.List(ctx, prefix+"b/")
+			So(err, ShouldBeNil)
+			So(actualKeys, ShouldResemble, keys[1:])
+			Convey("long key", func() {
+				key := prefix + strings.Repeat("x", 300)
+				So(s.Put(ctx, key, []byte("long")), ShouldBeNil)
+				data, err := s.Get(ctx, key)
+				So(err, ShouldBeNil)
+				So(data, ShouldResemble, []byte("long"))
+				actualKeys, err := s.List(ctx, prefix)
+				So(err, ShouldBeNil)
+				So(actualKeys, ShouldResemble, append(keys, key))
+			})
+		})
+		Convey("PutIfVersion", func() {
+			key := uuid.NewV4().String()
+			_, err := s.PutIfVersion(ctx, key, []byte("first"), "stale")
+			So(merry.Is(err, store.ErrConflict), ShouldBeTrue)
+			version, err := s.PutIfVersion(ctx, key, []byte("first"), "")
+			So(err, ShouldBeNil)
+			data, actualVersion, err := s.GetVersion(ctx, key)
+			So(err, ShouldBeNil)
+			So(data, ShouldResemble, []byte("first"))
+			So(actualVersion, ShouldEqual, version)
+			_, err = s.PutIfVersion(ctx, key, []byte("second"), "")
+			So(merry.Is(err, store.ErrConflict), ShouldBeTrue)
+			newVersion, err := s.PutIfVersion(ctx, key, []byte("second"), version)
+			So(err, ShouldBeNil)
+			So(newVersion, ShouldNotEqual, version)
+			_, err = s.PutIfVersion(ctx, key, []byte("third"), version)
+			So(merry.Is(err, store.ErrConflict), ShouldBeTrue)
+			data, err = s.Get(ctx, key)
+			So(err, ShouldBeNil)
+			So(data, ShouldResemble, []byte("second"))
+		})
+		Convey("Batch", func() {
+			deleted := uuid.NewV4().String()
+			So(s.Put(ctx, deleted, []byte("deleted")), ShouldBeNil)
+			put := uuid.NewV4().String()
+			err := s.Batch(ctx, []store.Op{
+				store.PutOp(put, []byte("put")),
+				store.DeleteOp(deleted),
+			})
+			if merry.Is(err, store.ErrNotSupported) {
+				return
+			}
+			So(err, ShouldBeNil)
+			data, err := s.Get(ctx, put)
+			So(err, ShouldBeNil)
+			So(data, ShouldResemble, []byte("put"))
+			_, err = s.Get(ctx, deleted)
+			So(merry.Is(err, store.ErrNotFound), ShouldBeTrue)
+		})
 	})
 }
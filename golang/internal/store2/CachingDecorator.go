@@ -0,0 +1,52 @@
+package store2
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/cache"
+)
+
+// CachingDecorator caches values, and ErrNotFound if the cache has a
+// NotFoundTTL, in front of Decorated.
+type CachingDecorator[K comparable, V any] struct {
+	Decorated Store[K, V]
+	Cache     *cache.LRU[K, V]
+}
+
+func (decorator CachingDecorator[K, V]) Put(ctx context.Context, key K, value V) (err error) {
+	err = decorator.Decorated.Put(ctx, key, value)
+	if err != nil {
+		decorator.Cache.Delete(key)
+		return
+	}
+	decorator.Cache.Put(key, value)
+	return
+}
+
+func (decorator CachingDecorator[K, V]) Get(ctx context.Context, key K) (value V, err error) {
+	value, found, cached := decorator.Cache.Get(key)
+	if cached && !found {
+		err = ErrNotFound{}
+		return
+	}
+	if cached {
+		return
+	}
+	value, err = decorator.Decorated.Get(ctx, key)
+	if errors.As(err, new(ErrNotFound)) {
+		decorator.Cache.PutNotFound(key)
+		return
+	}
+	if err != nil {
+		return
+	}
+	decorator.Cache.Put(key, value)
+	return
+}
+
+func (decorator CachingDecorator[K, V]) Delete(ctx context.Context, key K) (err error) {
+	err = decorator.Decorated.Delete(ctx, key)
+	decorator.Cache.Delete(key)
+	return
+}
@@ -0,0 +1,42 @@
+package store2_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/cache"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/store2"
+)
+
+func TestCachingDecorator(t *testing.T) {
+	ctx := context.Background()
+	Convey("TestCachingDecorator", t, func() {
+		decorated := make(store2.StoreInMemory[string, int])
+		var store store2.Store[string, int] = store2.CachingDecorator[string, int]{
+			Decorated: decorated,
+			Cache: cache.NewLRU[string](cache.NewLRUInput[int]{
+				MaxEntries:  10,
+				NotFoundTTL: time.Minute,
+			}),
+		}
+		err := store.Put(ctx, "foo", 1)
+		So(err, ShouldBeNil)
+		delete(decorated, "foo")
+		value, err := store.Get(ctx, "foo")
+		So(err, ShouldBeNil)
+		So(value, ShouldEqual, 1)
+		_, err = store.Get(ctx, "bar")
+		So(errors.As(err, new(store2.ErrNotFound)), ShouldBeTrue)
+		decorated["bar"] = 2
+		_, err = store.Get(ctx, "bar")
+		So(errors.As(err, new(store2.ErrNotFound)), ShouldBeTrue)
+		err = store.Delete(ctx, "foo")
+		So(err, ShouldBeNil)
+		_, err = store.Get(ctx, "foo")
+		So(errors.As(err, new(store2.ErrNotFound)), ShouldBeTrue)
+	})
+}
@@ -0,0 +1,194 @@
+package store2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrUniqueConstraint is returned by Put when another key already has the
+// value in a unique index.
+type ErrUniqueConstraint struct {
+	Index string
+	Value string
+}
+
+func (err ErrUniqueConstraint) Error() string {
+	return fmt.Sprintf("%s %s is already in use", err.Index, err.Value)
+}
+
+// IndexKey is the key of an index entry. It formats as index/value, so the
+// entries can share a store.Store through a StoreAdapter.
+type IndexKey struct {
+	Index string
+	Value string
+}
+
+func (key IndexKey) String() string {
+	return key.Index + "/" + key.Value
+}
+
+type IndexedStore[K comparable, V any] interface {
+	Store[K, V]
+	GetBy(ctx context.Context, index string, value string) (V, error)
+}
+
+// IndexingDecorator keeps unique secondary indexes of the values in
+// Decorated. Each index extracts a value, such as an email, and an empty
+// value isn't indexed. Entries maps each indexed value to its key.
+//
+// Each Put and Delete writes the entries and the value together through
+// Atomically, such as one from BatchWrites, if it is set. Otherwise entries
+// are written before the value and removed after it, and an entry only
+// counts if its key's value still extracts to it, so an interrupted write
+// leaves at most stale entries behind. Writes must not be concurrent.
+type IndexingDecorator[K comparable, V any] struct {
+	Decorated  Store[K, V]
+	Indexes    map[string]func(value V) string
+	Entries    Store[IndexKey, K]
+	Atomically func(ctx context.Context, write func(ctx context.Context) error) error
+}
+
+func (decorator IndexingDecorator[K, V]) atomically(ctx context.Context, write func(ctx context.Context) error) error {
+	if decorator.Atomically == nil {
+		return write(ctx)
+	}
+	return decorator.Atomically(ctx, write)
+}
+
+func (decorator IndexingDecorator[K, V]) Put(ctx context.Context, key K, value V) (err error) {
+	return decorator.atomically(ctx, func(ctx context.Context) error {
+		return decorator.put(ctx, key, value)
+	})
+}
+
+func (decorator IndexingDecorator[K, V]) put(ctx context.Context, key K, value V) (err error) {
+	previous, hasPrevious, err := decorator.get(ctx, key)
+	if err != nil {
+		return
+	}
+	indexes := decorator.indexNames()
+	for _, index := range indexes {
+		err = decorator.checkUnique(ctx, key, IndexKey{Index: index, Value: decorator.Indexes[index](value)})
+		if err != nil {
+			return
+		}
+	}
+	for _, index := range indexes {
+		indexValue := decorator.Indexes[index](value)
+		if indexValue == "" {
+			continue
+		}
+		err = decorator.Entries.Put(ctx, IndexKey{Index: index, Value: indexValue}, key)
+		if err != nil {
+			return
+		}
+	}
+	err = decorator.Decorated.Put(ctx, key, value)
+	if err != nil || !hasPrevious {
+		return
+	}
+	for _, index := range indexes {
+		previousValue := decorator.Indexes[index](previous)
+		if previousValue == "" || previousValue == decorator.Indexes[index](value) {
+			continue
+		}
+		err = decorator.Entries.Delete(ctx, IndexKey{Index: index, Value: previousValue})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (decorator IndexingDecorator[K, V]) Get(ctx context.Context, key K) (value V, err error) {
+	return decorator.Decorated.Get(ctx, key)
+}
+
+func (decorator IndexingDecorator[K, V]) Delete(ctx context.Context, key K) (err error) {
+	return decorator.atomically(ctx, func(ctx context.Context) error {
+		return decorator.delete(ctx, key)
+	})
+}
+
+func (decorator IndexingDecorator[K, V]) delete(ctx context.Context, key K) (err error) {
+	value, found, err := decorator.get(ctx, key)
+	if err != nil {
+		return
+	}
+	err = decorator.Decorated.Delete(ctx, key)
+	if err != nil || !found {
+		return
+	}
+	for _, index := range decorator.indexNames() {
+		indexValue := decorator.Indexes[index](value)
+		if indexValue == "" {
+			continue
+		}
+		err = decorator.Entries.Delete(ctx, IndexKey{Index: index, Value: indexValue})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// GetBy returns the value whose index extracts to indexValue.
+func (decorator IndexingDecorator[K, V]) GetBy(ctx context.Context, index string, indexValue string) (value V, err error) {
+	extract, ok := decorator.Indexes[index]
+	if !ok {
+		err = fmt.Errorf("unknown index %s", index)
+		return
+	}
+	key, err := decorator.Entries.Get(ctx, IndexKey{Index: index, Value: indexValue})
+	if err != nil {
+		return
+	}
+	value, err = decorator.Decorated.Get(ctx, key)
+	if err != nil {
+		return
+	}
+	if extract(value) != indexValue {
+		err = ErrNotFound{}
+	}
+	return
+}
+
+func (decorator IndexingDecorator[K, V]) get(ctx context.Context, key K) (value V, found bool, err error) {
+	value, err = decorator.Decorated.Get(ctx, key)
+	if errors.As(err, new(ErrNotFound)) {
+		return value, false, nil
+	}
+	return value, err == nil, err
+}
+
+func (decorator IndexingDecorator[K, V]) checkUnique(ctx context.Context, key K, indexKey IndexKey) (err error) {
+	if indexKey.Value == "" {
+		return
+	}
+	owner, err := decorator.Entries.Get(ctx, indexKey)
+	if errors.As(err, new(ErrNotFound)) {
+		return nil
+	}
+	if err != nil || owner == key {
+		return
+	}
+	ownerValue, found, err := decorator.get(ctx, owner)
+	if err != nil {
+		return
+	}
+	if found && decorator.Indexes[indexKey.Index](ownerValue) == indexKey.Value {
+		err = ErrUniqueConstraint{Index: indexKey.Index, Value: indexKey.Value}
+	}
+	return
+}
+
+func (decorator IndexingDecorator[K, V]) indexNames() []string {
+	indexes := make([]string, 0, len(decorator.Indexes))
+	for index := range decorator.Indexes {
+		indexes = append(indexes, index)
+	}
+	sort.Strings(indexes)
+	return indexes
+}
@@ -0,0 +1,148 @@
+package store2_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/store"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/store2"
+)
+
+type user struct {
+	Name  string
+	Email string
+}
+
+func TestIndexingDecorator(t *testing.T) {
+	ctx := context.Background()
+	Convey("TestIndexingDecorator", t, func() {
+		users := make(store2.StoreInMemory[string, user])
+		entries := make(store2.StoreInMemory[store2.IndexKey, string])
+		var store store2.IndexedStore[string, user] = store2.IndexingDecorator[string, user]{
+			Decorated: users,
+			Indexes: map[string]func(value user) string{
+				"email": func(value user) string { return value.Email },
+			},
+			Entries: entries,
+		}
+		err := store.Put(ctx, "1", user{Name: "foo", Email: "foo@example.com"})
+		So(err, ShouldBeNil)
+		Convey("GetBy", func() {
+			value, err := store.GetBy(ctx, "email", "foo@example.com")
+			So(err, ShouldBeNil)
+			So(value.Name, ShouldEqual, "foo")
+			_, err = store.GetBy(ctx, "email", "bar@example.com")
+			So(errors.As(err, new(store2.ErrNotFound)), ShouldBeTrue)
+			_, err = store.GetBy(ctx, "name", "foo")
+			So(err, ShouldNotBeNil)
+		})
+		Convey("unique", func() {
+			err := store.Put(ctx, "2", user{Name: "bar", Email: "foo@example.com"})
+			var uniqueErr store2.ErrUniqueConstraint
+			So(errors.As(err, &uniqueErr), ShouldBeTrue)
+			So(uniqueErr.Index, ShouldEqual, "email")
+			So(uniqueErr.Value, ShouldEqual, "foo@example.com")
+			_, err = store.Get(ctx, "2")
+			So(errors.As(err, new(store2.ErrNotFound)), ShouldBeTrue)
+			err = store.Put(ctx, "1", user{Name: "baz", Email: "foo@example.com"})
+			So(err, ShouldBeNil)
+		})
+		Convey("update", func() {
+			err := store.Put(ctx, "1", user{Name: "foo", Email: "bar@example.com"})
+			So(err, ShouldBeNil)
+			value, err := store.GetBy(ctx, "email", "bar@example.com")
+			So(err, ShouldBeNil)
+			So(value.Name, ShouldEqual, "foo")
+			_, err = store.GetBy(ctx, "email", "foo@example.com")
+			So(errors.As(err, new(store2.ErrNotFound)), ShouldBeTrue)
+			So(entries, ShouldHaveLength, 1)
+			err = store.Put(ctx, "2", user{Name: "bar", Email: "foo@example.com"})
+			So(err, ShouldBeNil)
+		})
+		Convey("Delete", func() {
+			err := store.Delete(ctx, "1")
+			So(err, ShouldBeNil)
+			_, err = store.GetBy(ctx, "email", "foo@example.com")
+			So(errors.As(err, new(store2.ErrNotFound)), ShouldBeTrue)
+			So(entries, ShouldBeEmpty)
+			err = store.Delete(ctx, "1")
+			So(err, ShouldBeNil)
+		})
+		Convey("stale entry", func() {
+			// As left by a Put that was interrupted after writing the entry.
+			err := entries.Put(ctx, store2.IndexKey{Index: "email", Value: "bar@example.com"}, "1")
+			So(err, ShouldBeNil)
+			_, err = store.GetBy(ctx, "email", "bar@example.com")
+			So(errors.As(err, new(store2.ErrNotFound)), ShouldBeTrue)
+			err = store.Put(ctx, "2", user{Name: "bar", Email: "bar@example.com"})
+			So(err, ShouldBeNil)
+			value, err := store.GetBy(ctx, "email", "bar@example.com")
+			So(err, ShouldBeNil)
+			So(value.Name, ShouldEqual, "bar")
+		})
+	})
+}
+
+// batchOnlyStore fails writes made outside Batch.
+type batchOnlyStore struct {
+	*store.MemoryStore
+	batches int
+}
+
+func (batchOnly *batchOnlyStore) Put(ctx context.Context, key string, data []byte) error {
+	return errors.New("unbatched Put")
+}
+
+func (batchOnly *batchOnlyStore) Delete(ctx context.Context, key string) error {
+	return errors.New("unbatched Delete")
+}
+
+func (batchOnly *batchOnlyStore) Batch(ctx context.Context, ops []store.Op) error {
+	batchOnly.batches++
+	return batchOnly.MemoryStore.Batch(ctx, ops)
+}
+
+func TestIndexingDecoratorAtomically(t *testing.T) {
+	ctx := context.Background()
+	Convey("TestIndexingDecoratorAtomically", t, func() {
+		backend := &batchOnlyStore{MemoryStore: store.NewMemoryStore()}
+		var store store2.IndexedStore[string, user] = store2.IndexingDecorator[string, user]{
+			Decorated: store2.JSONEncodingDecorator[string, user]{
+				Decorated: store2.StoreAdapter[string]{
+					Decorated: backend,
+					EncodeKey: store2.PrefixKey[string]("user/"),
+				},
+			},
+			Indexes: map[string]func(value user) string{
+				"email": func(value user) string { return value.Email },
+			},
+			Entries: store2.JSONEncodingDecorator[store2.IndexKey, string]{
+				Decorated: store2.StoreAdapter[store2.IndexKey]{
+					Decorated: backend,
+					EncodeKey: store2.PrefixKey[store2.IndexKey]("index/"),
+				},
+			},
+			Atomically: store2.BatchWrites(backend),
+		}
+		err := store.Put(ctx, "1", user{Name: "foo", Email: "foo@example.com"})
+		So(err, ShouldBeNil)
+		err = store.Put(ctx, "1", user{Name: "foo", Email: "bar@example.com"})
+		So(err, ShouldBeNil)
+		So(backend.batches, ShouldEqual, 2)
+		keys, err := backend.List(ctx, "")
+		So(err, ShouldBeNil)
+		So(keys, ShouldResemble, []string{"index/email/bar@example.com", "user/1"})
+		value, err := store.GetBy(ctx, "email", "bar@example.com")
+		So(err, ShouldBeNil)
+		So(value.Name, ShouldEqual, "foo")
+		err = store.Delete(ctx, "1")
+		So(err, ShouldBeNil)
+		So(backend.batches, ShouldEqual, 3)
+		keys, err = backend.List(ctx, "")
+		So(err, ShouldBeNil)
+		So(keys, ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,92 @@
+package store2
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ansel1/merry"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/store"
+)
+
+// StoreAdapter exposes a store.Store, such as a store.FileSystemStore,
+// store.S3Store or store.EncryptingDecorator, as a Store[K, []byte].
+type StoreAdapter[K comparable] struct {
+	Decorated store.Store
+	// EncodeKey turns a key into the key of Decorated. It defaults to
+	// fmt.Sprint.
+	EncodeKey func(key K) string
+}
+
+func (adapter StoreAdapter[K]) encodeKey(key K) string {
+	if adapter.EncodeKey == nil {
+		return fmt.Sprint(key)
+	}
+	return adapter.EncodeKey(key)
+}
+
+func (adapter StoreAdapter[K]) Put(ctx context.Context, key K, value []byte) (err error) {
+	if batch := batchFromContext(ctx, adapter.Decorated); batch != nil {
+		batch.ops = append(batch.ops, store.PutOp(adapter.encodeKey(key), value))
+		return
+	}
+	return adapter.Decorated.Put(ctx, adapter.encodeKey(key), value)
+}
+
+func (adapter StoreAdapter[K]) Get(ctx context.Context, key K) (value []byte, err error) {
+	value, err = adapter.Decorated.Get(ctx, adapter.encodeKey(key))
+	if merry.Is(err, store.ErrNotFound) {
+		err = ErrNotFound{}
+	}
+	return
+}
+
+func (adapter StoreAdapter[K]) Delete(ctx context.Context, key K) (err error) {
+	if batch := batchFromContext(ctx, adapter.Decorated); batch != nil {
+		batch.ops = append(batch.ops, store.DeleteOp(adapter.encodeKey(key)))
+		return
+	}
+	return adapter.Decorated.Delete(ctx, adapter.encodeKey(key))
+}
+
+// PrefixKey returns an EncodeKey that prefixes keys, to share a store.Store
+// between several stores.
+func PrefixKey[K any](prefix string) func(key K) string {
+	return func(key K) string {
+		return prefix + fmt.Sprint(key)
+	}
+}
+
+type batchContextKey struct{}
+
+type batch struct {
+	backend store.Store
+	ops     []store.Op
+}
+
+func batchFromContext(ctx context.Context, backend store.Store) *batch {
+	batch, ok := ctx.Value(batchContextKey{}).(*batch)
+	if !ok || batch.backend != backend {
+		return nil
+	}
+	return batch
+}
+
+// BatchWrites returns a func that collects the writes made by write through
+// StoreAdapters of backend, and applies them in one store.Batch, so that they
+// all happen or none do. Reads aren't batched, so write sees the store as it
+// was before. If backend doesn't support Batch, write is run again unbatched.
+func BatchWrites(backend store.Store) func(ctx context.Context, write func(ctx context.Context) error) error {
+	return func(ctx context.Context, write func(ctx context.Context) error) (err error) {
+		batch := &batch{backend: backend}
+		err = write(context.WithValue(ctx, batchContextKey{}, batch))
+		if err != nil || len(batch.ops) == 0 {
+			return
+		}
+		err = backend.Batch(ctx, batch.ops)
+		if merry.Is(err, store.ErrNotSupported) {
+			return write(ctx)
+		}
+		return
+	}
+}
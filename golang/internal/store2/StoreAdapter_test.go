@@ -0,0 +1,111 @@
+package store2_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/Ryan-A-B/beddybytes/golang/internal/store"
+	"github.com/Ryan-A-B/beddybytes/golang/internal/store2"
+)
+
+func TestStoreAdapter(t *testing.T) {
+	ctx := context.Background()
+	Convey("TestStoreAdapter", t, func() {
+		memoryStore := store.NewMemoryStore()
+		var adapter store2.Store[string, []byte] = store2.StoreAdapter[string]{
+			Decorated: memoryStore,
+			EncodeKey: store2.PrefixKey[string]("prefix/"),
+		}
+		err := adapter.Put(ctx, "foo", []byte("foo"))
+		So(err, ShouldBeNil)
+		data, err := memoryStore.Get(ctx, "prefix/foo")
+		So(err, ShouldBeNil)
+		So(data, ShouldResemble, []byte("foo"))
+		data, err = adapter.Get(ctx, "foo")
+		So(err, ShouldBeNil)
+		So(data, ShouldResemble, []byte("foo"))
+		err = adapter.Delete(ctx, "foo")
+		So(err, ShouldBeNil)
+		_, err = adapter.Get(ctx, "foo")
+		So(errors.As(err, new(store2.ErrNotFound)), ShouldBeTrue)
+		Convey("typed", func() {
+			var typed store2.Store[int, int] = store2.JSONEncodingDecorator[int, int]{
+				Decorated: store2.StoreAdapter[int]{
+					Decorated: memoryStore,
+				},
+			}
+			err := typed.Put(ctx, 1, 2)
+			So(err, ShouldBeNil)
+			data, err := memoryStore.Get(ctx, "1")
+			So(err, ShouldBeNil)
+			So(data, ShouldResemble, []byte("2"))
+			value, err := typed.Get(ctx, 1)
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, 2)
+		})
+	})
+}
+
+// unbatchedStore is a store.Store without Batch, like store.S3Store.
+type unbatchedStore struct {
+	*store.MemoryStore
+}
+
+func (unbatched unbatchedStore) Batch(ctx context.Context, ops []store.Op) error {
+	return store.ErrNotSupported.Here()
+}
+
+func TestBatchWrites(t *testing.T) {
+	ctx := context.Background()
+	Convey("TestBatchWrites", t, func() {
+		write := func(adapter store2.StoreAdapter[string]) func(ctx context.Context) error {
+			return func(ctx context.Context) error {
+				err := adapter.Put(ctx, "foo", []byte("foo"))
+				if err != nil {
+					return err
+				}
+				_, err = adapter.Get(ctx, "foo")
+				So(errors.As(err, new(store2.ErrNotFound)), ShouldBeTrue)
+				return adapter.Put(ctx, "bar", []byte("bar"))
+			}
+		}
+		Convey("Batch", func() {
+			memoryStore := store.NewMemoryStore()
+			adapter := store2.StoreAdapter[string]{Decorated: memoryStore}
+			err := store2.BatchWrites(memoryStore)(ctx, write(adapter))
+			So(err, ShouldBeNil)
+			keys, err := memoryStore.List(ctx, "")
+			So(err, ShouldBeNil)
+			So(keys, ShouldResemble, []string{"bar", "foo"})
+		})
+		Convey("failed write", func() {
+			memoryStore := store.NewMemoryStore()
+			adapter := store2.StoreAdapter[string]{Decorated: memoryStore}
+			err := store2.BatchWrites(memoryStore)(ctx, func(ctx context.Context) error {
+				So(adapter.Put(ctx, "foo", []byte("foo")), ShouldBeNil)
+				return errors.New("failed")
+			})
+			So(err, ShouldNotBeNil)
+			keys, err := memoryStore.List(ctx, "")
+			So(err, ShouldBeNil)
+			So(keys, ShouldBeEmpty)
+		})
+		Convey("not supported", func() {
+			backend := unbatchedStore{MemoryStore: store.NewMemoryStore()}
+			adapter := store2.StoreAdapter[string]{Decorated: backend}
+			calls := 0
+			err := store2.BatchWrites(backend)(ctx, func(ctx context.Context) error {
+				calls++
+				return adapter.Put(ctx, "foo", []byte("foo"))
+			})
+			So(err, ShouldBeNil)
+			So(calls, ShouldEqual, 2)
+			data, err := backend.Get(ctx, "foo")
+			So(err, ShouldBeNil)
+			So(data, ShouldResemble, []byte("foo"))
+		})
+	})
+}